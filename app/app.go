@@ -3,6 +3,7 @@
 package app
 
 import (
+	"errors"
 	"image"
 	"os"
 	"path/filepath"
@@ -24,7 +25,7 @@ var extraArgs string
 
 // ID is the app id exposed to the platform.
 //
-// On X11 ID is the X11 XClassHint.
+// On Wayland ID is the xdg_toplevel app_id, on X11 it is the X11 XClassHint.
 //
 // ID is set by the [github.com/mleku/gio/cmd/gogio] tool or manually with the -X linker flag. For example,
 //
@@ -39,7 +40,15 @@ var ID = ""
 type FrameEvent struct {
 	// Now is the current animation. Use Now instead of time.Now to
 	// synchronize animation and to avoid the time.Now call overhead.
+	// Now is PresentTime if known, so that animations match what is
+	// on screen.
 	Now time.Time
+	// PresentTime is the predicted time the frame appears on screen,
+	// or the zero time if the platform does not report it.
+	PresentTime time.Time
+	// RefreshInterval is the duration between refreshes of the display,
+	// or zero if unknown.
+	RefreshInterval time.Duration
 	// Metric converts device independent dp and sp to device pixels.
 	Metric unit.Metric
 	// Size is the dimensions of the window.
@@ -116,6 +125,31 @@ func DataDir() (string, error) {
 	return dataDir()
 }
 
+// appDir returns the name of the directory of the program files under
+// DataDir: ID, or the program name if ID is empty. Files must not be
+// shared with other programs, so an unusable name is an error.
+func appDir() (string, error) {
+	for _, name := range []string{ID, filepath.Base(os.Args[0])} {
+		if name != "" && filepath.Clean(name) != "." && filepath.IsLocal(name) {
+			return name, nil
+		}
+	}
+	return "", errors.New("app: no ID to store program files under")
+}
+
+// appFile returns the path of the program file name under DataDir.
+func appFile(name string) (string, error) {
+	app, err := appDir()
+	if err != nil {
+		return "", err
+	}
+	dir, err := DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, app, name), nil
+}
+
 // Main must be called last from the program main function.
 // On most platforms Main blocks forever, for JS/WASM
 // it returns immediately to give control of the main
@@ -0,0 +1,323 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package app
+
+import (
+	"fmt"
+	"image"
+	"slices"
+	"strconv"
+	"syscall/js"
+
+	"github.com/mleku/gio/f32"
+	"github.com/mleku/gio/io/input"
+	"github.com/mleku/gio/io/key"
+	"github.com/mleku/gio/io/semantic"
+)
+
+// ariaTree mirrors the semantic tree of a window as invisible DOM
+// elements with ARIA roles and states, for screen readers and
+// find-in-page. The elements are placed over the content they describe,
+// and focus and clicks on them are routed back to the window.
+type ariaTree struct {
+	w    *window
+	elem js.Value
+	// root is the semantic root, and scale the pixel ratio the
+	// elements were placed with.
+	root  input.SemanticID
+	scale float32
+	nodes map[input.SemanticID]*ariaNode
+	diffs []input.SemanticID
+}
+
+type ariaNode struct {
+	elem js.Value
+	// text holds the label of the node.
+	text     js.Value
+	desc     input.SemanticDesc
+	children []input.SemanticID
+}
+
+// ariaIDAttr is the attribute that links an element to its node.
+const ariaIDAttr = "data-gio-id"
+
+func newARIATree(w *window) *ariaTree {
+	elem := w.document.Call("createElement", "div")
+	style := elem.Get("style")
+	style.Set("position", "fixed")
+	style.Set("left", "0")
+	style.Set("top", "0")
+	style.Set("width", "100%")
+	style.Set("height", "100%")
+	style.Set("overflow", "hidden")
+	style.Set("opacity", "0")
+	// Leave the pointer to the canvas below.
+	style.Set("pointer-events", "none")
+	a := &ariaTree{w: w, elem: elem}
+	w.addEventListener(elem, "focusin", func(this js.Value, args []js.Value) interface{} {
+		id, ok := ariaTarget(args[0])
+		if !ok {
+			return nil
+		}
+		// Focus moved from the text input; the window keeps it.
+		if !w.config.Focused {
+			w.config.Focused = true
+			w.processEvent(ConfigEvent{Config: w.config})
+		}
+		w.w.FocusSemantic(id)
+		return nil
+	})
+	w.addEventListener(elem, "click", func(this js.Value, args []js.Value) interface{} {
+		id, ok := ariaTarget(args[0])
+		if !ok {
+			return nil
+		}
+		args[0].Call("preventDefault")
+		a.click(id)
+		return nil
+	})
+	// Keys typed while a mirrored element is focused belong to the
+	// window.
+	w.addEventListener(elem, "keydown", func(this js.Value, args []js.Value) interface{} {
+		w.keyEvent(args[0], key.Press)
+		return nil
+	})
+	w.addEventListener(elem, "keyup", func(this js.Value, args []js.Value) interface{} {
+		w.keyEvent(args[0], key.Release)
+		return nil
+	})
+	return a
+}
+
+// ariaTarget returns the semantic node of the element targeted by the
+// DOM event e.
+func ariaTarget(e js.Value) (input.SemanticID, bool) {
+	elem := e.Get("target").Call("closest", "["+ariaIDAttr+"]")
+	if !elem.Truthy() {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(elem.Call("getAttribute", ariaIDAttr).String(), 10, 64)
+	return input.SemanticID(id), err == nil
+}
+
+// click performs the system action under the node id, or clicks it.
+func (a *ariaTree) click(id input.SemanticID) {
+	n, ok := a.nodes[id]
+	if !ok {
+		return
+	}
+	b := n.desc.Bounds
+	center := f32.Pt(float32(b.Min.X+b.Max.X)/2, float32(b.Min.Y+b.Max.Y)/2)
+	if act, ok := a.w.w.ActionAt(center); ok {
+		a.w.Perform(act)
+		return
+	}
+	a.w.w.ClickSemantic(id)
+}
+
+// update brings the elements up to date with the semantic tree of the
+// last frame.
+func (a *ariaTree) update() {
+	root := a.w.w.SemanticRoot()
+	if a.nodes == nil || root != a.root || a.scale != a.w.scale {
+		a.rebuild(root)
+		return
+	}
+	a.diffs = a.w.w.AppendSemanticDiffs(a.diffs[:0])
+	// Diffs are ordered children first, so parents place their
+	// children after the children are updated.
+	for _, id := range a.diffs {
+		if n, ok := a.w.w.LookupSemantic(id); ok {
+			a.sync(n)
+		}
+	}
+}
+
+func (a *ariaTree) rebuild(root input.SemanticID) {
+	a.elem.Set("textContent", "")
+	a.nodes = make(map[input.SemanticID]*ariaNode)
+	a.root = root
+	a.scale = a.w.scale
+	n, ok := a.w.w.LookupSemantic(root)
+	if !ok {
+		return
+	}
+	a.elem.Call("appendChild", a.build(n).elem)
+}
+
+// build creates the elements of n and its descendants.
+func (a *ariaTree) build(n input.SemanticNode) *ariaNode {
+	doc := a.w.document
+	an := &ariaNode{
+		elem: doc.Call("createElement", "div"),
+		text: doc.Call("createTextNode", ""),
+	}
+	a.nodes[n.ID] = an
+	an.elem.Call("setAttribute", ariaIDAttr, strconv.FormatUint(uint64(n.ID), 10))
+	an.elem.Call("appendChild", an.text)
+	style := an.elem.Get("style")
+	style.Set("position", "absolute")
+	if n.ID == a.root {
+		// The root covers the window.
+		style.Set("left", "0")
+		style.Set("top", "0")
+		style.Set("width", "100%")
+		style.Set("height", "100%")
+	}
+	a.describe(n.ID, an, n.Desc)
+	for _, ch := range n.Children {
+		an.elem.Call("appendChild", a.build(ch).elem)
+		an.children = append(an.children, ch.ID)
+	}
+	return an
+}
+
+// sync updates the element of n and replaces its children.
+func (a *ariaTree) sync(n input.SemanticNode) {
+	an, ok := a.nodes[n.ID]
+	if !ok {
+		return
+	}
+	moved := an.desc.Bounds.Min != n.Desc.Bounds.Min
+	a.describe(n.ID, an, n.Desc)
+	children := make([]input.SemanticID, 0, len(n.Children))
+	for _, ch := range n.Children {
+		children = append(children, ch.ID)
+	}
+	for _, id := range an.children {
+		if !slices.Contains(children, id) {
+			a.remove(id)
+		}
+	}
+	if !slices.Equal(an.children, children) {
+		elems := an.elem.Get("children")
+		for i, ch := range n.Children {
+			cn, ok := a.nodes[ch.ID]
+			if !ok {
+				cn = a.build(ch)
+			}
+			// Moving an element loses its focus, so leave elements
+			// in place when possible.
+			next := elems.Index(i)
+			if next.IsUndefined() {
+				next = js.Null()
+			}
+			if !next.Equal(cn.elem) {
+				an.elem.Call("insertBefore", cn.elem, next)
+			}
+		}
+		an.children = children
+	}
+	if moved {
+		// Children are placed relative to their parent.
+		for _, id := range an.children {
+			cn := a.nodes[id]
+			a.place(id, cn, cn.desc)
+		}
+	}
+}
+
+// remove deletes the elements of the node id and its descendants.
+func (a *ariaTree) remove(id input.SemanticID) {
+	n, ok := a.nodes[id]
+	if !ok {
+		return
+	}
+	delete(a.nodes, id)
+	n.elem.Call("remove")
+	for _, ch := range n.children {
+		a.remove(ch)
+	}
+}
+
+// describe sets the ARIA attributes and the position of the element of
+// the node id from its description.
+func (a *ariaTree) describe(id input.SemanticID, n *ariaNode, d input.SemanticDesc) {
+	n.desc = d
+	n.text.Set("nodeValue", d.Label)
+	role := ariaRole(d)
+	ariaAttr(n.elem, "role", role)
+	label, desc := "", d.Description
+	if d.Label == "" {
+		label, desc = desc, ""
+	}
+	ariaAttr(n.elem, "aria-label", label)
+	ariaAttr(n.elem, "aria-description", desc)
+	var disabled, checked string
+	if d.Disabled {
+		disabled = "true"
+	}
+	if ariaCheckable(d.Class) {
+		checked = strconv.FormatBool(d.Selected)
+	}
+	ariaAttr(n.elem, "aria-disabled", disabled)
+	ariaAttr(n.elem, "aria-checked", checked)
+	// Interactive nodes are focusable, but only Gio moves the focus
+	// between them.
+	tabindex := ""
+	if role != "" {
+		tabindex = "-1"
+	}
+	ariaAttr(n.elem, "tabindex", tabindex)
+	if id != a.root {
+		a.place(id, n, d)
+	}
+}
+
+// place positions the element of a node relative to the element of its
+// parent, in CSS pixels.
+func (a *ariaTree) place(id input.SemanticID, n *ariaNode, d input.SemanticDesc) {
+	var origin image.Point
+	if p, ok := a.w.w.LookupSemantic(id); ok && p.ParentID != a.root {
+		if parent, ok := a.nodes[p.ParentID]; ok {
+			origin = parent.desc.Bounds.Min
+		}
+	}
+	r := d.Bounds.Sub(origin)
+	css := func(v int) string {
+		return fmt.Sprintf("%gpx", float32(v)/a.scale)
+	}
+	style := n.elem.Get("style")
+	style.Set("left", css(r.Min.X))
+	style.Set("top", css(r.Min.Y))
+	style.Set("width", css(r.Dx()))
+	style.Set("height", css(r.Dy()))
+}
+
+// ariaAttr sets an attribute of elem, or removes it if value is empty.
+func ariaAttr(elem js.Value, name, value string) {
+	if value == "" {
+		elem.Call("removeAttribute", name)
+	} else {
+		elem.Call("setAttribute", name, value)
+	}
+}
+
+// ariaRole returns the ARIA role of a node, or the empty string for
+// nodes without one.
+func ariaRole(d input.SemanticDesc) string {
+	switch d.Class {
+	case semantic.Button:
+		return "button"
+	case semantic.CheckBox:
+		return "checkbox"
+	case semantic.Editor:
+		return "textbox"
+	case semantic.RadioButton:
+		return "radio"
+	case semantic.Switch:
+		return "switch"
+	}
+	if d.Gestures&input.ClickGesture != 0 {
+		return "button"
+	}
+	return ""
+}
+
+func ariaCheckable(c semantic.ClassOp) bool {
+	switch c {
+	case semantic.CheckBox, semantic.RadioButton, semantic.Switch:
+		return true
+	}
+	return false
+}
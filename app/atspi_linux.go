@@ -0,0 +1,901 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package app
+
+import (
+	"image"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mleku/gio/internal/dbus"
+	"github.com/mleku/gio/io/input"
+	"github.com/mleku/gio/io/semantic"
+)
+
+// The AT-SPI bridge exports the semantic trees of the windows to
+// assistive technologies, such as screen readers, over the
+// accessibility bus. The application object is the root of the
+// accessible tree, with the windows as children and the semantic nodes
+// of each window below it.
+
+const (
+	atspiRootPath = dbus.ObjectPath("/org/a11y/atspi/accessible/root")
+	atspiNullPath = dbus.ObjectPath("/org/a11y/atspi/null")
+	// atspiWindowPath is the prefix of the object paths of windows.
+	atspiWindowPath = "/org/gio/a11y/"
+
+	atspiAccessible  = "org.a11y.atspi.Accessible"
+	atspiApplication = "org.a11y.atspi.Application"
+	atspiComponent   = "org.a11y.atspi.Component"
+	atspiAction      = "org.a11y.atspi.Action"
+	atspiEventObject = "org.a11y.atspi.Event.Object"
+	atspiEventFocus  = "org.a11y.atspi.Event.Focus"
+	dbusProperties   = "org.freedesktop.DBus.Properties"
+)
+
+// AT-SPI roles.
+const (
+	atspiRoleCheckBox     = 7
+	atspiRoleFrame        = 23
+	atspiRoleLabel        = 29
+	atspiRolePanel        = 39
+	atspiRolePushButton   = 43
+	atspiRoleRadioButton  = 44
+	atspiRoleToggleButton = 62
+	atspiRoleApplication  = 75
+	atspiRoleEntry        = 79
+)
+
+// AT-SPI states.
+const (
+	atspiStateActive    = 1
+	atspiStateChecked   = 4
+	atspiStateEditable  = 7
+	atspiStateEnabled   = 8
+	atspiStateFocusable = 11
+	atspiStateFocused   = 12
+	atspiStateSelected  = 23
+	atspiStateSensitive = 24
+	atspiStateShowing   = 25
+	atspiStateVisible   = 30
+	atspiStateCheckable = 41
+)
+
+// AT-SPI coordinate types.
+const (
+	atspiCoordScreen = 0
+	atspiCoordWindow = 1
+	atspiCoordParent = 2
+)
+
+// atspiLayerWidget is the layer of the components of a window.
+const atspiLayerWidget = 3
+
+// atspiRef is a reference to an accessible object, the "(so)" type.
+type atspiRef struct {
+	Name string
+	Path dbus.ObjectPath
+}
+
+// atspiRect is a rectangle in the "(iiii)" form of x, y, width and
+// height.
+type atspiRect struct {
+	X, Y, W, H int32
+}
+
+// atspiActionInfo describes an action, the "(sss)" type of name,
+// description and key binding.
+type atspiActionInfo struct {
+	Name, Description, KeyBinding string
+}
+
+// atspiWindow mirrors the semantic tree of a window for the bridge.
+// The tree is updated on the window goroutine and read by the bridge.
+type atspiWindow struct {
+	w *callbacks
+	// wakeup wakes the window goroutine to run the queued actions.
+	wakeup func()
+	path   dbus.ObjectPath
+
+	mu      sync.Mutex
+	title   string
+	origin  image.Point
+	size    image.Point
+	active  bool
+	root    input.SemanticID
+	focus   input.SemanticID
+	nodes   map[input.SemanticID]*atspiNode
+	actions []func()
+}
+
+// atspiNode is a snapshot of a semantic node.
+type atspiNode struct {
+	parent   input.SemanticID
+	children []input.SemanticID
+	desc     input.SemanticDesc
+}
+
+// atspiBridge is the connection to the accessibility bus.
+var atspiBridge struct {
+	mu sync.Mutex
+	// connecting is set while connecting to the bus.
+	connecting bool
+	conn       *dbus.Conn
+	parent     atspiRef
+	id         int32
+	windows    []*atspiWindow
+	nextID     int
+}
+
+// newATSPIWindow registers a window with the bridge, connecting to the
+// accessibility bus if needed. The tree of the window is published by
+// update; wakeup must arrange for runActions to be called on the window
+// goroutine.
+func newATSPIWindow(w *callbacks, wakeup func()) *atspiWindow {
+	b := &atspiBridge
+	b.mu.Lock()
+	b.nextID++
+	a := &atspiWindow{
+		w:      w,
+		wakeup: wakeup,
+		path:   dbus.ObjectPath(atspiWindowPath + strconv.Itoa(b.nextID)),
+	}
+	b.windows = append(b.windows, a)
+	idx := len(b.windows) - 1
+	conn := b.conn
+	if conn == nil && !b.connecting {
+		b.connecting = true
+		go atspiConnect()
+	}
+	b.mu.Unlock()
+	if conn != nil {
+		conn.Emit(atspiRootPath, atspiEventObject, "ChildrenChanged", "add", int32(idx), int32(0), dbus.Variant{Value: a.ref(conn)}, atspiNoProps())
+	}
+	return a
+}
+
+// atspiBusAddress returns the address of the accessibility bus.
+func atspiBusAddress() (string, error) {
+	if addr := os.Getenv("AT_SPI_BUS_ADDRESS"); addr != "" {
+		return addr, nil
+	}
+	session, err := dbus.Dial(dbus.SessionBusAddress(), nil)
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+	reply, err := session.Call("org.a11y.Bus", "/org/a11y/bus", "org.a11y.Bus", "GetAddress")
+	if err != nil {
+		return "", err
+	}
+	addr, _ := reply[0].(string)
+	return addr, nil
+}
+
+// atspiConnect connects to the accessibility bus and embeds the
+// application in the desktop of the registry. The bridge stays
+// disconnected if there is no bus, until the next window is created.
+func atspiConnect() {
+	b := &atspiBridge
+	var conn *dbus.Conn
+	addr, err := atspiBusAddress()
+	if err == nil {
+		conn, err = dbus.Dial(addr, atspiHandle)
+	}
+	b.mu.Lock()
+	b.connecting = false
+	if err != nil {
+		b.mu.Unlock()
+		return
+	}
+	b.conn = conn
+	windows := slices.Clone(b.windows)
+	b.mu.Unlock()
+	go func() {
+		<-conn.Done()
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.conn = nil
+		b.parent = atspiRef{}
+		for _, w := range b.windows {
+			w.reset()
+		}
+	}()
+	// The registry may be missing, such as on a private bus.
+	reply, err := conn.Call("org.a11y.atspi.Registry", atspiRootPath, "org.a11y.atspi.Socket", "Embed", atspiRef{conn.UniqueName(), atspiRootPath})
+	if err == nil && len(reply) > 0 {
+		if ref, ok := reply[0].([]any); ok && len(ref) == 2 {
+			b.mu.Lock()
+			b.parent.Name, _ = ref[0].(string)
+			b.parent.Path, _ = ref[1].(dbus.ObjectPath)
+			b.mu.Unlock()
+		}
+	}
+	// Publish the trees of existing windows.
+	for _, w := range windows {
+		w.wakeup()
+	}
+}
+
+// update publishes the semantic tree of the window and signals the
+// changes since the previous update. The window is at origin on the
+// screen, has the size and is active if it has the keyboard focus.
+func (a *atspiWindow) update(title string, origin, size image.Point, active bool) {
+	if a == nil {
+		return
+	}
+	b := &atspiBridge
+	b.mu.Lock()
+	conn := b.conn
+	b.mu.Unlock()
+	if conn == nil {
+		return
+	}
+	nodes := make(map[input.SemanticID]*atspiNode)
+	root := a.w.SemanticRoot()
+	if n, ok := a.w.LookupSemantic(root); ok {
+		atspiCollect(nodes, n)
+	}
+	focus, _ := a.w.SemanticFocus()
+
+	a.mu.Lock()
+	old, oldRoot, oldFocus, oldTitle, wasActive := a.nodes, a.root, a.focus, a.title, a.active
+	a.nodes, a.root, a.focus, a.title, a.origin, a.size, a.active = nodes, root, focus, title, origin, size, active
+	a.mu.Unlock()
+
+	if old == nil {
+		return
+	}
+	emit := func(path dbus.ObjectPath, member, detail string, d1 int32, v any) {
+		conn.Emit(path, atspiEventObject, member, detail, d1, int32(0), dbus.Variant{Value: v}, atspiNoProps())
+	}
+	boolInt := func(b bool) int32 {
+		if b {
+			return 1
+		}
+		return 0
+	}
+	if title != oldTitle {
+		emit(a.path, "PropertyChange", "accessible-name", 0, title)
+	}
+	if active != wasActive {
+		emit(a.path, "StateChanged", "active", boolInt(active), int32(0))
+	}
+	for id, n := range nodes {
+		path := a.nodePath(id, root)
+		o, ok := old[id]
+		if !ok {
+			continue
+		}
+		if id == root && oldRoot != root {
+			continue
+		}
+		name, desc := atspiText(n.desc)
+		oname, odesc := atspiText(o.desc)
+		// The window is named by its title instead.
+		if id != root && name != oname {
+			emit(path, "PropertyChange", "accessible-name", 0, name)
+		}
+		if id != root && desc != odesc {
+			emit(path, "PropertyChange", "accessible-description", 0, desc)
+		}
+		if n.desc.Selected != o.desc.Selected {
+			state := "selected"
+			if atspiCheckable(n.desc.Class) {
+				state = "checked"
+			}
+			emit(path, "StateChanged", state, boolInt(n.desc.Selected), int32(0))
+		}
+		if n.desc.Disabled != o.desc.Disabled {
+			emit(path, "StateChanged", "enabled", boolInt(!n.desc.Disabled), int32(0))
+			emit(path, "StateChanged", "sensitive", boolInt(!n.desc.Disabled), int32(0))
+		}
+		for i, ch := range o.children {
+			if !slices.Contains(n.children, ch) {
+				emit(path, "ChildrenChanged", "remove", int32(i), atspiRef{conn.UniqueName(), a.nodePath(ch, oldRoot)})
+			}
+		}
+		for i, ch := range n.children {
+			if !slices.Contains(o.children, ch) {
+				emit(path, "ChildrenChanged", "add", int32(i), atspiRef{conn.UniqueName(), a.nodePath(ch, root)})
+			}
+		}
+	}
+	if focus != oldFocus {
+		if _, ok := nodes[oldFocus]; ok && oldFocus != 0 {
+			emit(a.nodePath(oldFocus, root), "StateChanged", "focused", 0, int32(0))
+		}
+		if focus != 0 {
+			path := a.nodePath(focus, root)
+			emit(path, "StateChanged", "focused", 1, int32(0))
+			conn.Emit(path, atspiEventFocus, "Focus", "", int32(0), int32(0), dbus.Variant{Value: int32(0)}, atspiNoProps())
+		}
+	}
+}
+
+func atspiCollect(nodes map[input.SemanticID]*atspiNode, n input.SemanticNode) {
+	an := &atspiNode{parent: n.ParentID, desc: n.Desc}
+	for _, ch := range n.Children {
+		an.children = append(an.children, ch.ID)
+		atspiCollect(nodes, ch)
+	}
+	nodes[n.ID] = an
+}
+
+// reset forgets the published tree after the bridge disconnected.
+func (a *atspiWindow) reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.nodes = nil
+}
+
+// runActions runs the actions requested by assistive technologies. It
+// must be called on the window goroutine.
+func (a *atspiWindow) runActions() {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	actions := a.actions
+	a.actions = nil
+	a.mu.Unlock()
+	for _, f := range actions {
+		f()
+	}
+}
+
+// destroy unregisters the window from the bridge.
+func (a *atspiWindow) destroy() {
+	if a == nil {
+		return
+	}
+	b := &atspiBridge
+	b.mu.Lock()
+	idx := slices.Index(b.windows, a)
+	if idx != -1 {
+		b.windows = slices.Delete(b.windows, idx, idx+1)
+	}
+	conn := b.conn
+	b.mu.Unlock()
+	if conn != nil && idx != -1 {
+		conn.Emit(atspiRootPath, atspiEventObject, "ChildrenChanged", "remove", int32(idx), int32(0), dbus.Variant{Value: a.ref(conn)}, atspiNoProps())
+	}
+}
+
+func (a *atspiWindow) ref(conn *dbus.Conn) atspiRef {
+	return atspiRef{conn.UniqueName(), a.path}
+}
+
+// nodePath returns the object path of a node. The semantic root is the
+// window itself.
+func (a *atspiWindow) nodePath(id, root input.SemanticID) dbus.ObjectPath {
+	if id == root {
+		return a.path
+	}
+	return a.path + "/" + dbus.ObjectPath(strconv.FormatUint(uint64(id), 10))
+}
+
+func atspiNoProps() map[string]dbus.Variant {
+	return map[string]dbus.Variant{}
+}
+
+// atspiText returns the accessible name and description of a node.
+// Nodes without a label are named by their description.
+func atspiText(d input.SemanticDesc) (string, string) {
+	if d.Label == "" {
+		return d.Description, ""
+	}
+	return d.Label, d.Description
+}
+
+func atspiCheckable(c semantic.ClassOp) bool {
+	switch c {
+	case semantic.CheckBox, semantic.RadioButton, semantic.Switch:
+		return true
+	}
+	return false
+}
+
+// atspiRole returns the role and role name of a node.
+func atspiRole(n *atspiNode) (uint32, string) {
+	switch n.desc.Class {
+	case semantic.Button:
+		return atspiRolePushButton, "push button"
+	case semantic.CheckBox:
+		return atspiRoleCheckBox, "check box"
+	case semantic.Editor:
+		return atspiRoleEntry, "entry"
+	case semantic.RadioButton:
+		return atspiRoleRadioButton, "radio button"
+	case semantic.Switch:
+		return atspiRoleToggleButton, "toggle button"
+	}
+	if len(n.children) == 0 && (n.desc.Label != "" || n.desc.Description != "") && n.desc.Gestures&input.ClickGesture == 0 {
+		return atspiRoleLabel, "label"
+	}
+	return atspiRolePanel, "panel"
+}
+
+// atspiStates returns the state set of a node, as a bit set.
+func atspiStates(n *atspiNode, focused bool) []uint32 {
+	var bits uint64
+	set := func(states ...uint) {
+		for _, s := range states {
+			bits |= 1 << s
+		}
+	}
+	set(atspiStateVisible, atspiStateShowing)
+	if !n.desc.Disabled {
+		set(atspiStateEnabled, atspiStateSensitive)
+	}
+	switch n.desc.Class {
+	case semantic.Unknown:
+	case semantic.Editor:
+		set(atspiStateFocusable, atspiStateEditable)
+	default:
+		set(atspiStateFocusable)
+	}
+	if atspiCheckable(n.desc.Class) {
+		set(atspiStateCheckable)
+		if n.desc.Selected {
+			set(atspiStateChecked)
+		}
+	} else if n.desc.Selected {
+		set(atspiStateSelected)
+	}
+	if focused {
+		set(atspiStateFocused)
+	}
+	return []uint32{uint32(bits), uint32(bits >> 32)}
+}
+
+// atspiObject is the target of a method call.
+type atspiObject struct {
+	conn *dbus.Conn
+	// win is nil for the application.
+	win  *atspiWindow
+	id   input.SemanticID
+	node *atspiNode
+}
+
+// atspiHandle handles the method calls of the accessibility bus.
+func atspiHandle(conn *dbus.Conn, m *dbus.Message) {
+	if m.Type != dbus.TypeMethodCall {
+		return
+	}
+	b := &atspiBridge
+	obj := atspiObject{conn: conn}
+	if m.Path != atspiRootPath {
+		rest, ok := strings.CutPrefix(string(m.Path), atspiWindowPath)
+		if !ok {
+			conn.ReplyError(m, dbus.ErrUnknownObject, string(m.Path))
+			return
+		}
+		win, node, _ := strings.Cut(rest, "/")
+		b.mu.Lock()
+		for _, w := range b.windows {
+			if string(w.path) == atspiWindowPath+win {
+				obj.win = w
+				break
+			}
+		}
+		b.mu.Unlock()
+		if obj.win == nil {
+			conn.ReplyError(m, dbus.ErrUnknownObject, string(m.Path))
+			return
+		}
+		w := obj.win
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		obj.id = w.root
+		if node != "" {
+			id, err := strconv.ParseUint(node, 10, 64)
+			if err != nil {
+				conn.ReplyError(m, dbus.ErrUnknownObject, string(m.Path))
+				return
+			}
+			obj.id = input.SemanticID(id)
+		}
+		if obj.node = w.nodes[obj.id]; obj.node == nil {
+			conn.ReplyError(m, dbus.ErrUnknownObject, string(m.Path))
+			return
+		}
+	}
+	var reply []any
+	var err *dbus.Error
+	switch m.Interface {
+	case dbusProperties:
+		reply, err = obj.properties(m)
+	case atspiAccessible:
+		reply, err = obj.accessible(m)
+	case atspiApplication:
+		reply, err = obj.application(m)
+	case atspiComponent:
+		reply, err = obj.component(m)
+	case atspiAction:
+		reply, err = obj.action(m)
+	default:
+		err = &dbus.Error{Name: dbus.ErrUnknownInterface, Message: m.Interface}
+	}
+	if err != nil {
+		conn.ReplyError(m, err.Name, err.Message)
+		return
+	}
+	conn.Reply(m, reply...)
+}
+
+// interfaces returns the interfaces implemented by the object.
+func (o *atspiObject) interfaces() []string {
+	if o.win == nil {
+		return []string{atspiAccessible, atspiApplication}
+	}
+	ifaces := []string{atspiAccessible, atspiComponent}
+	if o.node.desc.Gestures&input.ClickGesture != 0 {
+		ifaces = append(ifaces, atspiAction)
+	}
+	return ifaces
+}
+
+func (o *atspiObject) ref(id input.SemanticID) atspiRef {
+	return atspiRef{o.conn.UniqueName(), o.win.nodePath(id, o.win.root)}
+}
+
+func (o *atspiObject) parent() atspiRef {
+	b := &atspiBridge
+	switch {
+	case o.win == nil:
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if b.parent.Path == "" {
+			return atspiRef{"", atspiNullPath}
+		}
+		return b.parent
+	case o.id == o.win.root:
+		return atspiRef{o.conn.UniqueName(), atspiRootPath}
+	}
+	return o.ref(o.node.parent)
+}
+
+func (o *atspiObject) children() []atspiRef {
+	var refs []atspiRef
+	if o.win == nil {
+		b := &atspiBridge
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		for _, w := range b.windows {
+			refs = append(refs, w.ref(o.conn))
+		}
+		return refs
+	}
+	for _, ch := range o.node.children {
+		refs = append(refs, o.ref(ch))
+	}
+	return refs
+}
+
+func (o *atspiObject) indexInParent() int32 {
+	b := &atspiBridge
+	switch {
+	case o.win == nil:
+		return -1
+	case o.id == o.win.root:
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return int32(slices.Index(b.windows, o.win))
+	}
+	if p, ok := o.win.nodes[o.node.parent]; ok {
+		return int32(slices.Index(p.children, o.id))
+	}
+	return -1
+}
+
+func (o *atspiObject) name() string {
+	switch {
+	case o.win == nil:
+		return ID
+	case o.id == o.win.root:
+		return o.win.title
+	}
+	name, _ := atspiText(o.node.desc)
+	return name
+}
+
+func (o *atspiObject) description() string {
+	if o.win == nil {
+		return ""
+	}
+	_, desc := atspiText(o.node.desc)
+	return desc
+}
+
+func (o *atspiObject) role() (uint32, string) {
+	switch {
+	case o.win == nil:
+		return atspiRoleApplication, "application"
+	case o.id == o.win.root:
+		return atspiRoleFrame, "frame"
+	}
+	return atspiRole(o.node)
+}
+
+func (o *atspiObject) property(iface, name string) (any, bool) {
+	switch iface + "." + name {
+	case atspiAccessible + ".Name":
+		return o.name(), true
+	case atspiAccessible + ".Description":
+		return o.description(), true
+	case atspiAccessible + ".Parent":
+		return o.parent(), true
+	case atspiAccessible + ".ChildCount":
+		return int32(len(o.children())), true
+	case atspiAccessible + ".Locale":
+		return atspiLocale(), true
+	case atspiAccessible + ".AccessibleId":
+		if o.win == nil {
+			return "", true
+		}
+		return strconv.FormatUint(uint64(o.id), 10), true
+	case atspiAccessible + ".HelpText":
+		return "", true
+	}
+	if o.win == nil {
+		switch iface + "." + name {
+		case atspiApplication + ".ToolkitName":
+			return "Gio", true
+		case atspiApplication + ".Version":
+			return "", true
+		case atspiApplication + ".AtspiVersion":
+			return "2.1", true
+		case atspiApplication + ".Id":
+			b := &atspiBridge
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			return b.id, true
+		}
+	} else if o.node.desc.Gestures&input.ClickGesture != 0 && iface+"."+name == atspiAction+".NActions" {
+		return int32(1), true
+	}
+	return nil, false
+}
+
+func (o *atspiObject) properties(m *dbus.Message) ([]any, *dbus.Error) {
+	iface, _ := atspiArg[string](m, 0)
+	if !slices.Contains(o.interfaces(), iface) {
+		return nil, &dbus.Error{Name: dbus.ErrUnknownInterface, Message: iface}
+	}
+	switch m.Member {
+	case "Get":
+		name, _ := atspiArg[string](m, 1)
+		v, ok := o.property(iface, name)
+		if !ok {
+			return nil, &dbus.Error{Name: dbus.ErrUnknownProperty, Message: name}
+		}
+		return []any{dbus.Variant{Value: v}}, nil
+	case "GetAll":
+		props := make(map[string]dbus.Variant)
+		for _, name := range []string{"Name", "Description", "Parent", "ChildCount", "Locale", "AccessibleId", "HelpText", "ToolkitName", "Version", "AtspiVersion", "Id", "NActions"} {
+			if v, ok := o.property(iface, name); ok {
+				props[name] = dbus.Variant{Value: v}
+			}
+		}
+		return []any{props}, nil
+	case "Set":
+		name, _ := atspiArg[string](m, 1)
+		v, _ := atspiArg[dbus.Variant](m, 2)
+		// The registry assigns the application an id.
+		if id, ok := v.Value.(int32); ok && o.win == nil && iface == atspiApplication && name == "Id" {
+			b := &atspiBridge
+			b.mu.Lock()
+			b.id = id
+			b.mu.Unlock()
+			return nil, nil
+		}
+		return nil, &dbus.Error{Name: dbus.ErrInvalidArgs, Message: "read-only property " + name}
+	}
+	return nil, atspiUnknownMethod(m)
+}
+
+func (o *atspiObject) accessible(m *dbus.Message) ([]any, *dbus.Error) {
+	switch m.Member {
+	case "GetChildAtIndex":
+		idx, _ := atspiArg[int32](m, 0)
+		children := o.children()
+		if idx < 0 || int(idx) >= len(children) {
+			return []any{atspiRef{"", atspiNullPath}}, nil
+		}
+		return []any{children[idx]}, nil
+	case "GetChildren":
+		return []any{o.children()}, nil
+	case "GetIndexInParent":
+		return []any{o.indexInParent()}, nil
+	case "GetRelationSet":
+		type relation struct {
+			Type    uint32
+			Targets []atspiRef
+		}
+		return []any{[]relation{}}, nil
+	case "GetRole":
+		role, _ := o.role()
+		return []any{role}, nil
+	case "GetRoleName", "GetLocalizedRoleName":
+		_, name := o.role()
+		return []any{name}, nil
+	case "GetState":
+		if o.win == nil {
+			return []any{[]uint32{0, 0}}, nil
+		}
+		if o.id == o.win.root {
+			n := *o.node
+			n.desc.Class = semantic.Unknown
+			states := atspiStates(&n, false)
+			if o.win.active {
+				states[0] |= 1 << atspiStateActive
+			}
+			return []any{states}, nil
+		}
+		return []any{atspiStates(o.node, o.id == o.win.focus)}, nil
+	case "GetAttributes":
+		return []any{map[string]string{"toolkit": "Gio"}}, nil
+	case "GetApplication":
+		return []any{atspiRef{o.conn.UniqueName(), atspiRootPath}}, nil
+	case "GetInterfaces":
+		return []any{o.interfaces()}, nil
+	}
+	return nil, atspiUnknownMethod(m)
+}
+
+func (o *atspiObject) application(m *dbus.Message) ([]any, *dbus.Error) {
+	if o.win != nil {
+		return nil, &dbus.Error{Name: dbus.ErrUnknownInterface, Message: m.Interface}
+	}
+	switch m.Member {
+	case "GetLocale":
+		return []any{atspiLocale()}, nil
+	}
+	return nil, atspiUnknownMethod(m)
+}
+
+// extents returns the bounds of the object in the coordinate type.
+func (o *atspiObject) extents(coords uint32) image.Rectangle {
+	r := o.node.desc.Bounds
+	if o.id == o.win.root {
+		// The root area is unbounded; use the window instead.
+		r = image.Rectangle{Max: o.win.size}
+	}
+	switch coords {
+	case atspiCoordScreen:
+		r = r.Add(o.win.origin)
+	case atspiCoordParent:
+		if p, ok := o.win.nodes[o.node.parent]; ok && o.node.parent != o.win.root {
+			r = r.Sub(p.desc.Bounds.Min)
+		}
+	}
+	return r
+}
+
+func (o *atspiObject) component(m *dbus.Message) ([]any, *dbus.Error) {
+	if o.win == nil {
+		return nil, &dbus.Error{Name: dbus.ErrUnknownInterface, Message: m.Interface}
+	}
+	switch m.Member {
+	case "GetExtents":
+		coords, _ := atspiArg[uint32](m, 0)
+		r := o.extents(coords)
+		return []any{atspiRect{int32(r.Min.X), int32(r.Min.Y), int32(r.Dx()), int32(r.Dy())}}, nil
+	case "GetPosition":
+		coords, _ := atspiArg[uint32](m, 0)
+		r := o.extents(coords)
+		return []any{int32(r.Min.X), int32(r.Min.Y)}, nil
+	case "GetSize":
+		r := o.extents(atspiCoordWindow)
+		return []any{int32(r.Dx()), int32(r.Dy())}, nil
+	case "Contains", "GetAccessibleAtPoint":
+		x, _ := atspiArg[int32](m, 0)
+		y, _ := atspiArg[int32](m, 1)
+		coords, _ := atspiArg[uint32](m, 2)
+		p := image.Pt(int(x), int(y))
+		if m.Member == "Contains" {
+			return []any{p.In(o.extents(coords))}, nil
+		}
+		// Convert to window coordinates.
+		switch coords {
+		case atspiCoordScreen:
+			p = p.Sub(o.win.origin)
+		case atspiCoordParent:
+			p = p.Add(o.extents(atspiCoordWindow).Min)
+		}
+		id, ok := o.win.nodeAt(o.id, p)
+		if !ok || id == o.id {
+			return []any{atspiRef{"", atspiNullPath}}, nil
+		}
+		return []any{o.ref(id)}, nil
+	case "GetLayer":
+		return []any{uint32(atspiLayerWidget)}, nil
+	case "GetMDIZOrder":
+		return []any{int16(0)}, nil
+	case "GetAlpha":
+		return []any{1.0}, nil
+	case "GrabFocus":
+		w, id := o.win, o.id
+		w.actions = append(w.actions, func() {
+			w.w.FocusSemantic(id)
+		})
+		go w.wakeup()
+		return []any{true}, nil
+	case "ScrollTo", "ScrollToPoint":
+		return []any{false}, nil
+	}
+	return nil, atspiUnknownMethod(m)
+}
+
+// nodeAt returns the innermost descendant of node id containing p, in
+// window coordinates.
+func (a *atspiWindow) nodeAt(id input.SemanticID, p image.Point) (input.SemanticID, bool) {
+	n, ok := a.nodes[id]
+	if !ok || (id != a.root && !p.In(n.desc.Bounds)) {
+		return 0, false
+	}
+	// Later children are drawn on top.
+	for i := len(n.children) - 1; i >= 0; i-- {
+		if ch, ok := a.nodeAt(n.children[i], p); ok {
+			return ch, true
+		}
+	}
+	return id, true
+}
+
+func (o *atspiObject) action(m *dbus.Message) ([]any, *dbus.Error) {
+	if o.win == nil || o.node.desc.Gestures&input.ClickGesture == 0 {
+		return nil, &dbus.Error{Name: dbus.ErrUnknownInterface, Message: m.Interface}
+	}
+	idx, _ := atspiArg[int32](m, 0)
+	switch m.Member {
+	case "GetName", "GetLocalizedName":
+		if idx != 0 {
+			return []any{""}, nil
+		}
+		return []any{"click"}, nil
+	case "GetDescription", "GetKeyBinding":
+		return []any{""}, nil
+	case "GetActions":
+		return []any{[]atspiActionInfo{{Name: "click"}}}, nil
+	case "DoAction":
+		if idx != 0 || o.node.desc.Disabled {
+			return []any{false}, nil
+		}
+		w, id := o.win, o.id
+		w.actions = append(w.actions, func() {
+			w.w.ClickSemantic(id)
+		})
+		go w.wakeup()
+		return []any{true}, nil
+	}
+	return nil, atspiUnknownMethod(m)
+}
+
+// atspiArg returns argument i of a method call.
+func atspiArg[T any](m *dbus.Message, i int) (T, bool) {
+	var zero T
+	if i >= len(m.Body) {
+		return zero, false
+	}
+	v, ok := m.Body[i].(T)
+	return v, ok
+}
+
+func atspiUnknownMethod(m *dbus.Message) *dbus.Error {
+	return &dbus.Error{Name: dbus.ErrUnknownMethod, Message: m.Interface + "." + m.Member}
+}
+
+// atspiLocale returns the locale of the messages of the program.
+func atspiLocale() string {
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if l := os.Getenv(env); l != "" {
+			return l
+		}
+	}
+	return "C"
+}
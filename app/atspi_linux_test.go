@@ -0,0 +1,170 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package app
+
+import (
+	"image"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mleku/gio/internal/dbus"
+	"github.com/mleku/gio/internal/dbus/dbustest"
+	"github.com/mleku/gio/io/semantic"
+	"github.com/mleku/gio/layout"
+	"github.com/mleku/gio/op"
+	"github.com/mleku/gio/widget"
+)
+
+func TestATSPI(t *testing.T) {
+	addr := dbustest.StartBus(t)
+	t.Setenv("AT_SPI_BUS_ADDRESS", addr)
+	signals := make(chan *dbus.Message, 100)
+	client, err := dbus.Dial(addr, func(c *dbus.Conn, m *dbus.Message) {
+		if m.Type == dbus.TypeSignal {
+			signals <- m
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if _, err := client.Call(dbus.BusName, dbus.BusPath, dbus.BusInterface, "AddMatch", "type='signal',interface='"+atspiEventObject+"'"); err != nil {
+		t.Fatal(err)
+	}
+
+	w := new(Window)
+	w.Option(Headless(), Decorated(false), Size(100, 100))
+	var (
+		a       *atspiWindow
+		bridge  string
+		ops     op.Ops
+		btns    [2]widget.Clickable
+		clicks  [2]int
+		buttons []any
+	)
+	labels := []string{"First", "Second"}
+	call := func(path dbus.ObjectPath, iface, method string, args ...any) []any {
+		t.Helper()
+		reply, err := client.Call(bridge, path, iface, method, args...)
+		if err != nil {
+			t.Fatalf("%s.%s: %v", iface, method, err)
+		}
+		return reply
+	}
+	prop := func(path dbus.ObjectPath, name string) any {
+		t.Helper()
+		return call(path, dbusProperties, "Get", atspiAccessible, name)[0].(dbus.Variant).Value
+	}
+	refPath := func(ref any) dbus.ObjectPath {
+		return ref.([]any)[1].(dbus.ObjectPath)
+	}
+	for {
+		switch e := w.Event().(type) {
+		case FrameEvent:
+			gtx := NewContext(&ops, e)
+			for i := range btns {
+				for btns[i].Clicked(gtx) {
+					clicks[i]++
+				}
+				off := op.Offset(image.Pt(0, i*50)).Push(gtx.Ops)
+				gtx := gtx
+				gtx.Constraints = layout.Exact(image.Pt(100, 50))
+				btns[i].Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+					semantic.Button.Add(gtx.Ops)
+					semantic.LabelOp(labels[i]).Add(gtx.Ops)
+					return layout.Dimensions{Size: gtx.Constraints.Max}
+				})
+				off.Pop()
+			}
+			e.Frame(gtx.Ops)
+			if a == nil {
+				a = newATSPIWindow(&callbacks{w}, w.Headless().d.Invalidate)
+				defer a.destroy()
+				deadline := time.Now().Add(5 * time.Second)
+				for {
+					atspiBridge.mu.Lock()
+					conn := atspiBridge.conn
+					atspiBridge.mu.Unlock()
+					if conn != nil {
+						bridge = conn.UniqueName()
+						break
+					}
+					if time.Now().After(deadline) {
+						t.Fatal("no connection to the accessibility bus")
+					}
+					time.Sleep(10 * time.Millisecond)
+				}
+			}
+			a.runActions()
+			a.update("Test", image.Pt(10, 20), e.Size, true)
+			if buttons == nil {
+				windows := call(atspiRootPath, atspiAccessible, "GetChildren")[0].([]any)
+				idx := slices.IndexFunc(windows, func(ref any) bool {
+					return refPath(ref) == a.path
+				})
+				if idx == -1 {
+					t.Fatalf("window %s missing from application children %v", a.path, windows)
+				}
+				if role := call(a.path, atspiAccessible, "GetRole")[0]; role != uint32(atspiRoleFrame) {
+					t.Errorf("window role %v, want frame", role)
+				}
+				if name := prop(a.path, "Name"); name != "Test" {
+					t.Errorf("window name %q, want %q", name, "Test")
+				}
+				buttons = call(a.path, atspiAccessible, "GetChildren")[0].([]any)
+				if len(buttons) != 2 {
+					t.Fatalf("got %d window children, want 2", len(buttons))
+				}
+				second := refPath(buttons[1])
+				if name := prop(second, "Name"); name != "Second" {
+					t.Errorf("button name %q, want %q", name, "Second")
+				}
+				if parent := refPath(prop(second, "Parent")); parent != a.path {
+					t.Errorf("button parent %s, want %s", parent, a.path)
+				}
+				if role := call(second, atspiAccessible, "GetRole")[0]; role != uint32(atspiRolePushButton) {
+					t.Errorf("button role %v, want push button", role)
+				}
+				states := call(second, atspiAccessible, "GetState")[0].([]any)
+				if bits := states[0].(uint32); bits&(1<<atspiStateEnabled) == 0 || bits&(1<<atspiStateFocused) != 0 {
+					t.Errorf("button states %#x, want enabled and unfocused", bits)
+				}
+				ext := call(second, atspiComponent, "GetExtents", uint32(atspiCoordScreen))[0]
+				if want := []any{int32(10), int32(70), int32(100), int32(50)}; !slices.Equal(ext.([]any), want) {
+					t.Errorf("button extents %v, want %v", ext, want)
+				}
+				at := call(a.path, atspiComponent, "GetAccessibleAtPoint", int32(50), int32(75), uint32(atspiCoordWindow))[0]
+				if refPath(at) != second {
+					t.Errorf("accessible at point %v, want %s", at, second)
+				}
+				if ok := call(second, atspiAction, "DoAction", int32(0))[0]; ok != true {
+					t.Error("DoAction failed")
+				}
+				if ok := call(refPath(buttons[0]), atspiComponent, "GrabFocus")[0]; ok != true {
+					t.Error("GrabFocus failed")
+				}
+				continue
+			}
+			if clicks[1] == 0 {
+				continue
+			}
+			if clicks[0] != 0 {
+				t.Errorf("first button clicked %d times", clicks[0])
+			}
+			first := refPath(buttons[0])
+			timeout := time.After(5 * time.Second)
+			for focused := false; !focused; {
+				select {
+				case s := <-signals:
+					focused = s.Path == first && s.Member == "StateChanged" && s.Body[0] == "focused" && s.Body[1] == int32(1)
+				case <-timeout:
+					t.Fatal("no focus signal")
+				}
+			}
+			w.Headless().Close()
+		case DestroyEvent:
+			return
+		}
+	}
+}
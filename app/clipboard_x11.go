@@ -0,0 +1,393 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+//go:build linux && !nox11
+// +build linux,!nox11
+
+package app
+
+/*
+#include <stdlib.h>
+#include <X11/Xlib.h>
+#include <X11/Xatom.h>
+*/
+import "C"
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"unsafe"
+
+	"github.com/mleku/gio/io/clipboard"
+	"github.com/mleku/gio/io/input"
+	"github.com/mleku/gio/io/transfer"
+)
+
+// x11Clipboard tracks the selection transfers of a window.
+type x11Clipboard struct {
+	// content and primary are the representations of the
+	// clipboard and primary selection owned by the window.
+	content, primary []input.ClipboardData
+	// reads are the selection reads in progress, indexed by
+	// clipboard kind.
+	reads [2]x11SelectionRead
+	// writes are the incremental transfers to other clients.
+	writes []x11SelectionWrite
+}
+
+// x11SelectionRead is a read of the types of a selection. Each
+// selection is converted into its own property, so reads of the
+// clipboard and the primary selection proceed independently.
+type x11SelectionRead struct {
+	kind clipboard.Kind
+	// selection is the selection being read, or 0.
+	selection C.Atom
+	// property is the destination of the conversions.
+	property C.Atom
+	// mimes are the types left to read.
+	mimes []string
+	// targets are the conversion targets reported by the
+	// selection owner, or nil if it didn't report any.
+	targets []C.Atom
+	// mime is the type being converted.
+	mime string
+	incr x11IncrRead
+}
+
+// x11IncrRead is an incremental transfer of selection data from
+// another client.
+type x11IncrRead struct {
+	// active is set during the transfer to buf.
+	active bool
+	buf    []byte
+}
+
+// x11SelectionWrite is an incremental transfer of selection data.
+type x11SelectionWrite struct {
+	requestor C.Window
+	property  C.Atom
+	target    C.Atom
+	// data is the data left to transfer. The transfer ends with
+	// an empty write.
+	data []byte
+}
+
+func (w *x11Window) ReadClipboard(kind clipboard.Kind, mimes []string) {
+	r := &w.clipboard.reads[kind]
+	if r.selection != 0 {
+		// Queue the new types after the read in progress. The
+		// type being converted is delivered to every handler
+		// waiting for it.
+		for _, mime := range mimes {
+			if mime != r.mime && !slices.Contains(r.mimes, mime) {
+				r.mimes = append(r.mimes, mime)
+			}
+		}
+		return
+	}
+	*r = x11SelectionRead{
+		kind:      kind,
+		selection: w.selectionAtom(kind),
+		property:  w.atoms.clipboardContent,
+		mimes:     slices.Clone(mimes),
+	}
+	if kind == clipboard.Primary {
+		r.property = w.atoms.primaryContent
+	}
+	// Negotiate the conversion targets before reading.
+	C.XDeleteProperty(w.x, w.xw, r.property)
+	C.XConvertSelection(w.x, r.selection, w.atoms.targets, r.property, w.xw, C.CurrentTime)
+}
+
+func (w *x11Window) WriteClipboard(kind clipboard.Kind, content []input.ClipboardData) {
+	switch kind {
+	case clipboard.Primary:
+		w.clipboard.primary = content
+	default:
+		w.clipboard.content = content
+	}
+	C.XSetSelectionOwner(w.x, w.selectionAtom(kind), w.xw, C.CurrentTime)
+}
+
+// selectionAtom returns the X11 selection corresponding to kind.
+func (w *x11Window) selectionAtom(kind clipboard.Kind) C.Atom {
+	if kind == clipboard.Primary {
+		return w.atoms.primary
+	}
+	return w.atoms.clipboard
+}
+
+// textTargets returns the conversion targets for plain text, in order
+// of preference.
+func (w *x11Window) textTargets() []C.Atom {
+	return []C.Atom{w.atoms.utf8string, w.atoms.plaintext, w.atoms.gtk_text_buffer_contents}
+}
+
+// selectionRead returns the read in progress of the selection, or nil.
+func (w *x11Window) selectionRead(selection C.Atom) *x11SelectionRead {
+	for i := range w.clipboard.reads {
+		if r := &w.clipboard.reads[i]; r.selection != 0 && r.selection == selection {
+			return r
+		}
+	}
+	return nil
+}
+
+// receiveSelection handles the conversion results of a selection read.
+func (w *x11Window) receiveSelection(cevt *C.XSelectionEvent) {
+	r := w.selectionRead(cevt.selection)
+	if r == nil {
+		return
+	}
+	if cevt.target == w.atoms.targets {
+		if cevt.property != C.None {
+			r.targets = w.atomList(w.xw, cevt.property)
+		}
+		w.readSelection(r)
+		return
+	}
+	if cevt.property == C.None {
+		// Conversion failed.
+		w.deliverSelection(r, nil)
+		return
+	}
+	data, ok := w.readIncr(&r.incr, cevt.property)
+	if !ok {
+		return
+	}
+	w.deliverSelection(r, data)
+}
+
+// readIncr reads the converted data in prop. It reports false if the
+// data is transferred incrementally, in which case the data is delivered
+// through property notifications.
+func (w *x11Window) readIncr(r *x11IncrRead, prop C.Atom) ([]byte, bool) {
+	typ, data := w.readProperty(prop)
+	if typ == w.atoms.incr {
+		// Deleting the property starts the transfer.
+		r.active = true
+		r.buf = nil
+		C.XDeleteProperty(w.x, w.xw, prop)
+		return nil, false
+	}
+	return data, true
+}
+
+// incrChunk reads the next chunk of an incremental transfer into prop.
+// It reports whether the transfer is complete, along with its data.
+func (w *x11Window) incrChunk(r *x11IncrRead, prop C.Atom) ([]byte, bool) {
+	_, data := w.readProperty(prop)
+	if len(data) > 0 {
+		r.buf = append(r.buf, data...)
+		return nil, false
+	}
+	r.active = false
+	data, r.buf = r.buf, nil
+	return data, true
+}
+
+// readSelection converts the next type of the selection read, if any.
+func (w *x11Window) readSelection(r *x11SelectionRead) {
+	for len(r.mimes) > 0 {
+		mime := r.mimes[0]
+		r.mimes = r.mimes[1:]
+		r.mime = mime
+		target := w.selectionTarget(mime, r.targets)
+		if target == C.None {
+			w.ProcessEvent(selectionEvent(r.kind, mime, nil))
+			continue
+		}
+		C.XDeleteProperty(w.x, w.xw, r.property)
+		C.XConvertSelection(w.x, r.selection, target, r.property, w.xw, C.CurrentTime)
+		return
+	}
+	r.selection = 0
+	r.mime = ""
+}
+
+// selectionTarget returns the conversion target for reading mime
+// from a selection with the available targets.
+func (w *x11Window) selectionTarget(mime string, targets []C.Atom) C.Atom {
+	if isTextMIME(mime) {
+		if targets == nil {
+			return w.atoms.utf8string
+		}
+		for _, t := range w.textTargets() {
+			if slices.Contains(targets, t) {
+				return t
+			}
+		}
+		return C.None
+	}
+	if targets == nil {
+		return w.atom(mime, false)
+	}
+	if t := w.atom(mime, true); slices.Contains(targets, t) {
+		return t
+	}
+	return C.None
+}
+
+// deliverSelection delivers the data of the type being read and moves on
+// to the next type.
+func (w *x11Window) deliverSelection(r *x11SelectionRead, data []byte) {
+	w.ProcessEvent(selectionEvent(r.kind, r.mime, data))
+	w.readSelection(r)
+}
+
+func selectionEvent(kind clipboard.Kind, mime string, data []byte) input.ClipboardEvent {
+	return input.ClipboardEvent{
+		Kind: kind,
+		DataEvent: transfer.DataEvent{
+			Type: mime,
+			Open: func() io.ReadCloser {
+				return io.NopCloser(bytes.NewReader(data))
+			},
+		},
+	}
+}
+
+// requestSelection handles a conversion request for the clipboard or the
+// primary selection.
+func (w *x11Window) requestSelection(cevt *C.XSelectionRequestEvent) {
+	content := w.clipboard.content
+	if cevt.selection == w.atoms.primary {
+		content = w.clipboard.primary
+	}
+	if cevt.target == w.atoms.targets {
+		// The requestor wants the supported clipboard
+		// formats. First write the targets...
+		formats := []C.Atom{w.atoms.targets}
+		for _, c := range content {
+			if isTextMIME(c.Type) {
+				// GTK clients need GTK_TEXT_BUFFER_CONTENTS.
+				formats = append(formats, w.textTargets()...)
+			} else {
+				formats = append(formats, w.atom(c.Type, false))
+			}
+		}
+		C.XChangeProperty(w.x, cevt.requestor, cevt.property, w.atoms.atom,
+			32 /* bitwidth of formats */, C.PropModeReplace,
+			(*C.uchar)(unsafe.Pointer(&formats[0])), C.int(len(formats)),
+		)
+		// ...then notify the requestor.
+		w.notifySelection(cevt, cevt.property)
+		return
+	}
+	mime := "text/plain"
+	if !slices.Contains(w.textTargets(), cevt.target) {
+		mime = w.atomName(cevt.target)
+	}
+	data, ok := clipboardContent(content, mime)
+	if !ok {
+		w.notifySelection(cevt, C.None)
+		return
+	}
+	// Transfer large data incrementally.
+	if len(data) > w.maxSelectionChunk() {
+		if cevt.requestor != w.xw {
+			C.XSelectInput(w.x, cevt.requestor, C.PropertyChangeMask)
+		}
+		size := C.long(len(data))
+		C.XChangeProperty(w.x, cevt.requestor, cevt.property, w.atoms.incr,
+			32, C.PropModeReplace, (*C.uchar)(unsafe.Pointer(&size)), 1)
+		w.clipboard.writes = append(w.clipboard.writes, x11SelectionWrite{
+			requestor: cevt.requestor,
+			property:  cevt.property,
+			target:    cevt.target,
+			data:      data,
+		})
+		w.notifySelection(cevt, cevt.property)
+		return
+	}
+	var ptr *C.uchar
+	if len(data) > 0 {
+		ptr = (*C.uchar)(unsafe.Pointer(&data[0]))
+	}
+	C.XChangeProperty(w.x, cevt.requestor, cevt.property, cevt.target,
+		8 /* bitwidth */, C.PropModeReplace,
+		ptr, C.int(len(data)),
+	)
+	w.notifySelection(cevt, cevt.property)
+}
+
+// maxSelectionChunk returns the largest selection data in bytes to
+// transfer in one request.
+func (w *x11Window) maxSelectionChunk() int {
+	n := int(C.XExtendedMaxRequestSize(w.x))
+	if n == 0 {
+		n = int(C.XMaxRequestSize(w.x))
+	}
+	// Convert from 4-byte units and leave room for the request header.
+	return min(n*4-100, 256*1024)
+}
+
+// selectionProperty advances incremental transfers.
+func (w *x11Window) selectionProperty(pevt *C.XPropertyEvent) {
+	switch {
+	case pevt.state == C.PropertyNewValue && pevt.window == w.xw:
+		for i := range w.clipboard.reads {
+			r := &w.clipboard.reads[i]
+			if r.selection == 0 || !r.incr.active || pevt.atom != r.property {
+				continue
+			}
+			if data, done := w.incrChunk(&r.incr, pevt.atom); done {
+				w.deliverSelection(r, data)
+			}
+			return
+		}
+		if in := &w.dnd.in; in.src != 0 && in.incr.active && pevt.atom == w.atoms.xdndContent {
+			if data, done := w.incrChunk(&in.incr, pevt.atom); done {
+				w.deliverDrop(data)
+			}
+		}
+	case pevt.state == C.PropertyDelete:
+		writes := w.clipboard.writes
+		for i := range writes {
+			wr := &writes[i]
+			if wr.requestor != pevt.window || wr.property != pevt.atom {
+				continue
+			}
+			n := min(len(wr.data), w.maxSelectionChunk())
+			var ptr *C.uchar
+			if n > 0 {
+				ptr = (*C.uchar)(unsafe.Pointer(&wr.data[0]))
+			}
+			C.XChangeProperty(w.x, wr.requestor, wr.property, wr.target, 8, C.PropModeReplace, ptr, C.int(n))
+			wr.data = wr.data[n:]
+			if n > 0 {
+				return
+			}
+			// The empty write completed the transfer.
+			if wr.requestor != w.xw {
+				C.XSelectInput(w.x, wr.requestor, C.NoEventMask)
+			}
+			w.clipboard.writes = slices.Delete(writes, i, i+1)
+			return
+		}
+	}
+}
+
+// readProperty reads and deletes the byte data of the property prop
+// on the window.
+func (w *x11Window) readProperty(prop C.Atom) (C.Atom, []byte) {
+	var (
+		typ    C.Atom
+		format C.int
+		nitems C.ulong
+		after  C.ulong
+		data   *C.uchar
+	)
+	if C.XGetWindowProperty(w.x, w.xw, prop, 0, 0x1fffffff, C.True, C.AnyPropertyType,
+		&typ, &format, &nitems, &after, &data) != C.Success {
+		return C.None, nil
+	}
+	if data == nil {
+		return typ, nil
+	}
+	defer C.XFree(unsafe.Pointer(data))
+	if format != 8 {
+		return typ, nil
+	}
+	return typ, C.GoBytes(unsafe.Pointer(data), C.int(nitems))
+}
@@ -0,0 +1,192 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package app
+
+import (
+	"errors"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"syscall/js"
+)
+
+// jsDnD tracks an HTML5 drag and drop transfer into the window.
+type jsDnD struct {
+	// active is set while a drag is over the canvas.
+	active bool
+	// mime is the type accepted by the target under the pointer.
+	mime string
+}
+
+// addDragListeners translates the HTML5 drag and drop events of the
+// canvas into transfers.
+func (w *window) addDragListeners() {
+	dnd := &w.dnd
+	w.addEventListener(w.cnv, "dragenter", func(this js.Value, args []js.Value) interface{} {
+		e := args[0]
+		e.Call("preventDefault")
+		if !dnd.active {
+			dnd.active = true
+			dnd.mime = ""
+			w.w.DragEnter(dragMIMEs(e.Get("dataTransfer")))
+		}
+		return nil
+	})
+	w.addEventListener(w.cnv, "dragover", func(this js.Value, args []js.Value) interface{} {
+		e := args[0]
+		e.Call("preventDefault")
+		if !dnd.active {
+			return nil
+		}
+		effect := "none"
+		if w.dragOver(e) {
+			effect = "copy"
+		}
+		e.Get("dataTransfer").Set("dropEffect", effect)
+		return nil
+	})
+	w.addEventListener(w.cnv, "dragleave", func(this js.Value, args []js.Value) interface{} {
+		if dnd.active {
+			dnd.active = false
+			w.w.DragLeave()
+		}
+		return nil
+	})
+	w.addEventListener(w.cnv, "drop", func(this js.Value, args []js.Value) interface{} {
+		e := args[0]
+		e.Call("preventDefault")
+		if !dnd.active {
+			return nil
+		}
+		dnd.active = false
+		if !w.dragOver(e) {
+			w.w.DragLeave()
+			return nil
+		}
+		data, ok := w.dropData(e.Get("dataTransfer"), dnd.mime)
+		if !ok {
+			w.w.DragLeave()
+			return nil
+		}
+		w.w.Drop(dnd.mime, data)
+		return nil
+	})
+}
+
+// dragOver moves the transfer to the position of the drag event e and
+// reports whether a target accepts it.
+func (w *window) dragOver(e js.Value) bool {
+	mime, ok := w.w.DragOver(w.eventPos(e))
+	w.dnd.mime = mime
+	return ok
+}
+
+// dropData returns the contents of type mime from the DataTransfer dt.
+// Files are preferred over strings, and only the first file of a type is
+// transferred.
+func (w *window) dropData(dt js.Value, mime string) (io.ReadCloser, bool) {
+	if items := dt.Get("items"); items.Truthy() {
+		for i := 0; i < items.Length(); i++ {
+			it := items.Index(i)
+			if it.Get("kind").String() != "file" || dragItemType(it) != mime {
+				continue
+			}
+			if f := it.Call("getAsFile"); f.Truthy() {
+				return w.newBlobReader(f), true
+			}
+		}
+	}
+	t := mime
+	if isTextMIME(mime) {
+		t = "text/plain"
+	}
+	if !dt.Get("types").Call("includes", t).Bool() {
+		return nil, false
+	}
+	return io.NopCloser(strings.NewReader(dt.Call("getData", t).String())), true
+}
+
+// dragMIMEs returns the MIME types offered by the DataTransfer dt.
+func dragMIMEs(dt js.Value) []string {
+	var mimes []string
+	add := func(mime string) {
+		if !slices.Contains(mimes, mime) {
+			mimes = append(mimes, mime)
+		}
+	}
+	if items := dt.Get("items"); items.Truthy() {
+		for i := 0; i < items.Length(); i++ {
+			add(dragItemType(items.Index(i)))
+		}
+	} else {
+		types := dt.Get("types")
+		for i := 0; i < types.Length(); i++ {
+			if t := types.Index(i).String(); t != "Files" {
+				add(t)
+			}
+		}
+	}
+	// Offer text under the MIME types used by Gio programs.
+	if slices.Contains(mimes, "text/plain") {
+		add("application/text")
+	}
+	return mimes
+}
+
+// dragItemType returns the MIME type of a DataTransferItem. Files of
+// unknown type are binary data.
+func dragItemType(it js.Value) string {
+	t := it.Get("type").String()
+	if t == "" && it.Get("kind").String() == "file" {
+		t = "application/octet-stream"
+	}
+	return t
+}
+
+// blobReader streams the contents of a Blob, such as a dropped file.
+// Read waits for the browser, and must not be called from a JavaScript
+// callback.
+type blobReader struct {
+	w      *window
+	reader js.Value
+	buf    []byte
+	err    error
+}
+
+func (w *window) newBlobReader(blob js.Value) *blobReader {
+	return &blobReader{w: w, reader: blob.Call("stream").Call("getReader")}
+}
+
+func (r *blobReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 && r.err == nil {
+		res := make(chan js.Value, 1)
+		r.w.then(r.reader.Call("read"), func(v js.Value) {
+			res <- v
+		})
+		switch v := <-res; {
+		case v.IsNull():
+			r.err = errors.New("app: reading dropped data failed")
+		case v.Get("done").Bool():
+			r.err = io.EOF
+		default:
+			chunk := v.Get("value")
+			r.buf = make([]byte, chunk.Length())
+			js.CopyBytesToGo(r.buf, chunk)
+		}
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	if len(r.buf) > 0 {
+		return n, nil
+	}
+	return n, r.err
+}
+
+func (r *blobReader) Close() error {
+	if r.err == nil {
+		r.err = os.ErrClosed
+		r.reader.Call("cancel")
+	}
+	return nil
+}
@@ -0,0 +1,488 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+//go:build linux && !nox11
+// +build linux,!nox11
+
+package app
+
+/*
+#include <stdlib.h>
+#include <X11/Xlib.h>
+#include <X11/Xatom.h>
+*/
+import "C"
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"unsafe"
+
+	"github.com/mleku/gio/f32"
+)
+
+// x11XdndVersion is the supported version of the XDND protocol.
+// See https://freedesktop.org/wiki/Specifications/XDND/.
+const x11XdndVersion = 5
+
+// x11TextTypes are the X11 selection targets equivalent to plain
+// UTF-8 text.
+var x11TextTypes = []string{"text/plain;charset=utf-8", "UTF8_STRING"}
+
+// x11DnD tracks XDND transfers to and from the window.
+type x11DnD struct {
+	// in is the transfer from another client.
+	in struct {
+		// src is the source window, or 0 if no transfer is in progress.
+		src C.Window
+		// types maps offered MIME types to their selection targets.
+		types map[string]C.Atom
+		// mime is the type accepted by the target under the pointer.
+		mime string
+		// incr is the incremental transfer of the dropped data.
+		incr x11IncrRead
+	}
+	// out is the drag exported to other clients.
+	out x11DnDExport
+}
+
+// x11DnDExport tracks a drag exported to other clients.
+type x11DnDExport struct {
+	active bool
+	// types maps selection targets to the MIME types of the source.
+	types map[C.Atom]string
+	atoms []C.Atom
+	// target is the aware window under the pointer.
+	target  C.Window
+	version int
+	// accepted reports whether target accepts the drop.
+	accepted bool
+	// waiting is set while a position message is awaiting
+	// its status reply, and pending if the pointer moved in
+	// the meantime.
+	waiting, pending bool
+	pos              C.long
+	time             C.Time
+	// dropped is set when the drop has been handed to target.
+	dropped bool
+	// req is the selection request waiting for data.
+	req    C.XSelectionRequestEvent
+	hasReq bool
+}
+
+// handleXdnd handles XDND client messages for both incoming and exported
+// drags.
+func (w *x11Window) handleXdnd(cevt *C.XClientMessageEvent) {
+	l := (*[5]C.long)(unsafe.Pointer(&cevt.data))
+	in := &w.dnd.in
+	out := &w.dnd.out
+	switch cevt.message_type {
+	case w.atoms.xdndEnter:
+		src := C.Window(l[0])
+		var atoms []C.Atom
+		if l[1]&1 != 0 {
+			atoms = w.atomList(src, w.atoms.xdndTypeList)
+		} else {
+			for _, a := range l[2:] {
+				if a != C.None {
+					atoms = append(atoms, C.Atom(a))
+				}
+			}
+		}
+		in.src = src
+		in.mime = ""
+		in.types = make(map[string]C.Atom)
+		var mimes []string
+		for _, a := range atoms {
+			name := w.atomName(a)
+			if name == "" {
+				continue
+			}
+			in.types[name] = a
+			mimes = append(mimes, name)
+		}
+		// Offer UTF-8 text under the MIME types used by Gio programs.
+		for _, t := range x11TextTypes {
+			a, ok := in.types[t]
+			if !ok {
+				continue
+			}
+			for _, alias := range []string{"text/plain", "application/text"} {
+				if _, exists := in.types[alias]; !exists {
+					in.types[alias] = a
+					mimes = append(mimes, alias)
+				}
+			}
+			break
+		}
+		w.w.DragEnter(mimes)
+	case w.atoms.xdndPosition:
+		if C.Window(l[0]) != in.src || in.src == 0 {
+			break
+		}
+		var x, y C.int
+		var child C.Window
+		root := C.XDefaultRootWindow(w.x)
+		C.XTranslateCoordinates(w.x, root, w.xw, C.int(l[2]>>16), C.int(l[2]&0xffff), &x, &y, &child)
+		mime, ok := w.w.DragOver(f32.Pt(float32(x), float32(y)))
+		in.mime = mime
+		// Always ask for position updates, because targets may move
+		// within the window.
+		status := [5]C.long{C.long(w.xw), 2}
+		if ok {
+			status[1] |= 1
+			status[4] = C.long(w.atoms.xdndActionCopy)
+		}
+		w.sendClientMessage(in.src, w.atoms.xdndStatus, status)
+	case w.atoms.xdndLeave:
+		if C.Window(l[0]) != in.src || in.src == 0 {
+			break
+		}
+		in.src = 0
+		w.w.DragLeave()
+	case w.atoms.xdndDrop:
+		if C.Window(l[0]) != in.src || in.src == 0 {
+			break
+		}
+		a, ok := in.types[in.mime]
+		if in.mime == "" || !ok {
+			w.finishDrop(false)
+			break
+		}
+		C.XDeleteProperty(w.x, w.xw, w.atoms.xdndContent)
+		C.XConvertSelection(w.x, w.atoms.xdndSelection, a, w.atoms.xdndContent, w.xw, C.Time(l[2]))
+	case w.atoms.xdndStatus:
+		if !out.waiting || C.Window(l[0]) != out.target {
+			break
+		}
+		out.waiting = false
+		out.accepted = l[1]&1 != 0
+		if out.pending {
+			out.pending = false
+			w.sendPosition()
+		}
+	case w.atoms.xdndFinished:
+		if !out.dropped || C.Window(l[0]) != out.target {
+			break
+		}
+		w.resetExport()
+		w.w.CancelExport()
+	}
+}
+
+// receiveDrop handles the conversion of the XdndSelection requested by an
+// XdndDrop message.
+func (w *x11Window) receiveDrop(cevt *C.XSelectionEvent) {
+	in := &w.dnd.in
+	if in.src == 0 {
+		return
+	}
+	if cevt.property == C.None {
+		// Conversion failed.
+		w.finishDrop(false)
+		return
+	}
+	content, ok := w.readIncr(&in.incr, cevt.property)
+	if !ok {
+		return
+	}
+	w.deliverDrop(content)
+}
+
+// deliverDrop delivers the dropped content and completes the transfer.
+func (w *x11Window) deliverDrop(content []byte) {
+	in := &w.dnd.in
+	if content == nil {
+		// Only byte data is supported.
+		w.finishDrop(false)
+		return
+	}
+	mime := in.mime
+	w.finishDrop(true)
+	w.w.Drop(mime, io.NopCloser(bytes.NewReader(content)))
+}
+
+// finishDrop completes the incoming transfer and notifies its source.
+func (w *x11Window) finishDrop(success bool) {
+	in := &w.dnd.in
+	msg := [5]C.long{C.long(w.xw)}
+	if success {
+		msg[1] = 1
+		msg[2] = C.long(w.atoms.xdndActionCopy)
+	}
+	w.sendClientMessage(in.src, w.atoms.xdndFinished, msg)
+	in.src = 0
+	in.incr = x11IncrRead{}
+	if !success {
+		w.w.DragLeave()
+	}
+}
+
+// dragExport updates the exported drag after a pointer motion to (x, y),
+// or (rootX, rootY) in root window coordinates.
+func (w *x11Window) dragExport(x, y, rootX, rootY C.int, t C.Time) {
+	out := &w.dnd.out
+	if out.dropped {
+		return
+	}
+	if !out.active {
+		sz := w.config.Size
+		if x >= 0 && y >= 0 && int(x) < sz.X && int(y) < sz.Y {
+			return
+		}
+		mimes, ok := w.w.DragSource()
+		if !ok {
+			return
+		}
+		out.active = true
+		out.types = make(map[C.Atom]string)
+		out.atoms = out.atoms[:0]
+		offer := func(target, mime string) {
+			a := w.atom(target, false)
+			if _, exists := out.types[a]; exists {
+				return
+			}
+			out.types[a] = mime
+			out.atoms = append(out.atoms, a)
+		}
+		for _, m := range mimes {
+			offer(m, m)
+		}
+		for _, m := range mimes {
+			if m == "text/plain" || m == "application/text" {
+				for _, t := range x11TextTypes {
+					offer(t, m)
+				}
+			}
+		}
+		C.XSetSelectionOwner(w.x, w.atoms.xdndSelection, w.xw, t)
+	}
+	target, version := w.xdndTarget(rootX, rootY)
+	if target != out.target {
+		if out.target != 0 {
+			w.sendClientMessage(out.target, w.atoms.xdndLeave, [5]C.long{C.long(w.xw)})
+		}
+		out.target = target
+		out.version = version
+		out.accepted = false
+		out.waiting = false
+		out.pending = false
+		if target != 0 {
+			w.sendEnter()
+		}
+	}
+	out.pos = C.long(rootX)<<16 | C.long(rootY)&0xffff
+	out.time = t
+	if target == 0 {
+		return
+	}
+	if out.waiting {
+		out.pending = true
+		return
+	}
+	w.sendPosition()
+}
+
+// dropExport ends the exported drag when the primary button is released.
+func (w *x11Window) dropExport(t C.Time) {
+	out := &w.dnd.out
+	if !out.active || out.dropped {
+		return
+	}
+	if out.target == 0 || !out.accepted {
+		if out.target != 0 {
+			w.sendClientMessage(out.target, w.atoms.xdndLeave, [5]C.long{C.long(w.xw)})
+		}
+		w.resetExport()
+		return
+	}
+	out.dropped = true
+	w.sendClientMessage(out.target, w.atoms.xdndDrop, [5]C.long{C.long(w.xw), 0, C.long(t)})
+	w.w.ExportDrag()
+}
+
+// clearExport ends the exported drag when another client takes the
+// XdndSelection.
+func (w *x11Window) clearExport() {
+	if !w.dnd.out.active {
+		return
+	}
+	w.resetExport()
+	w.w.CancelExport()
+}
+
+// resetExport ends the exported drag, and releases the XdndSelection if
+// the window still owns it.
+func (w *x11Window) resetExport() {
+	out := &w.dnd.out
+	if out.hasReq {
+		w.notifySelection(&out.req, C.None)
+	}
+	// Any client may clear the selection, so check that another client
+	// didn't take it before releasing it.
+	if out.active && C.XGetSelectionOwner(w.x, w.atoms.xdndSelection) == w.xw {
+		C.XSetSelectionOwner(w.x, w.atoms.xdndSelection, C.None, out.time)
+	}
+	*out = x11DnDExport{atoms: out.atoms[:0]}
+}
+
+func (w *x11Window) sendEnter() {
+	out := &w.dnd.out
+	msg := [5]C.long{C.long(w.xw), C.long(out.version) << 24}
+	if len(out.atoms) > 3 {
+		msg[1] |= 1
+		C.XChangeProperty(w.x, w.xw, w.atoms.xdndTypeList, C.XA_ATOM, 32, C.PropModeReplace,
+			(*C.uchar)(unsafe.Pointer(&out.atoms[0])), C.int(len(out.atoms)))
+	}
+	for i, a := range out.atoms {
+		if i == 3 {
+			break
+		}
+		msg[2+i] = C.long(a)
+	}
+	w.sendClientMessage(out.target, w.atoms.xdndEnter, msg)
+}
+
+func (w *x11Window) sendPosition() {
+	out := &w.dnd.out
+	out.waiting = true
+	w.sendClientMessage(out.target, w.atoms.xdndPosition, [5]C.long{
+		C.long(w.xw), 0, out.pos, C.long(out.time), C.long(w.atoms.xdndActionCopy),
+	})
+}
+
+// requestExport handles a conversion request for the XdndSelection.
+func (w *x11Window) requestExport(cevt *C.XSelectionRequestEvent) {
+	out := &w.dnd.out
+	if cevt.property == C.None {
+		// Obsolete requestor.
+		return
+	}
+	if cevt.target == w.atoms.targets {
+		targets := append([]C.Atom{w.atoms.targets}, out.atoms...)
+		C.XChangeProperty(w.x, cevt.requestor, cevt.property, w.atoms.atom, 32, C.PropModeReplace,
+			(*C.uchar)(unsafe.Pointer(&targets[0])), C.int(len(targets)))
+		w.notifySelection(cevt, cevt.property)
+		return
+	}
+	mime, ok := out.types[cevt.target]
+	if !ok || !out.dropped {
+		w.notifySelection(cevt, C.None)
+		return
+	}
+	if out.hasReq {
+		w.notifySelection(&out.req, C.None)
+	}
+	out.req = *cevt
+	out.hasReq = true
+	w.w.RequestExport(mime)
+}
+
+func (w *x11Window) ExportData(mime string, data io.ReadCloser) {
+	defer data.Close()
+	out := &w.dnd.out
+	if !out.hasReq {
+		return
+	}
+	req := out.req
+	out.hasReq = false
+	content, err := io.ReadAll(data)
+	if err != nil {
+		w.notifySelection(&req, C.None)
+		return
+	}
+	var ptr *C.uchar
+	if len(content) > 0 {
+		ptr = (*C.uchar)(unsafe.Pointer(&content[0]))
+	}
+	C.XChangeProperty(w.x, req.requestor, req.property, req.target, 8, C.PropModeReplace,
+		ptr, C.int(len(content)))
+	w.notifySelection(&req, req.property)
+}
+
+// notifySelection replies to a selection request with the converted data
+// in prop, or C.None if the conversion failed.
+func (w *x11Window) notifySelection(req *C.XSelectionRequestEvent, prop C.Atom) {
+	var xev C.XEvent
+	ev := (*C.XSelectionEvent)(unsafe.Pointer(&xev))
+	*ev = C.XSelectionEvent{
+		_type:     C.SelectionNotify,
+		display:   w.x,
+		requestor: req.requestor,
+		selection: req.selection,
+		target:    req.target,
+		property:  prop,
+		time:      req.time,
+	}
+	C.XSendEvent(w.x, req.requestor, 0, 0, &xev)
+}
+
+// xdndTarget returns the innermost XDND aware window at the root coordinates
+// (x, y) along with the protocol version to use, or 0 if there is none.
+func (w *x11Window) xdndTarget(x, y C.int) (C.Window, int) {
+	root := C.XDefaultRootWindow(w.x)
+	win := root
+	for {
+		var dx, dy C.int
+		var child C.Window
+		if C.XTranslateCoordinates(w.x, root, win, x, y, &dx, &dy, &child) == 0 || child == 0 {
+			return 0, 0
+		}
+		if child == w.xw {
+			// Drags within the window are handled by the router.
+			return 0, 0
+		}
+		if v := w.atomList(child, w.atoms.xdndAware); len(v) == 1 {
+			return child, min(int(v[0]), x11XdndVersion)
+		}
+		win = child
+	}
+}
+
+// atomList returns the atoms stored in the property prop of win.
+func (w *x11Window) atomList(win C.Window, prop C.Atom) []C.Atom {
+	var (
+		typ    C.Atom
+		format C.int
+		nitems C.ulong
+		after  C.ulong
+		data   *C.uchar
+	)
+	if C.XGetWindowProperty(w.x, win, prop, 0, 1024, C.False, C.XA_ATOM,
+		&typ, &format, &nitems, &after, &data) != C.Success {
+		return nil
+	}
+	if data == nil {
+		return nil
+	}
+	defer C.XFree(unsafe.Pointer(data))
+	if typ != C.XA_ATOM || format != 32 {
+		return nil
+	}
+	// Format 32 properties are returned as longs.
+	return slices.Clone(unsafe.Slice((*C.Atom)(unsafe.Pointer(data)), int(nitems)))
+}
+
+// atomName is a wrapper around XGetAtomName.
+func (w *x11Window) atomName(a C.Atom) string {
+	cname := C.XGetAtomName(w.x, a)
+	if cname == nil {
+		return ""
+	}
+	defer C.XFree(unsafe.Pointer(cname))
+	return C.GoString(cname)
+}
+
+func (w *x11Window) sendClientMessage(dst C.Window, typ C.Atom, data [5]C.long) {
+	var xev C.XEvent
+	ev := (*C.XClientMessageEvent)(unsafe.Pointer(&xev))
+	*ev = C.XClientMessageEvent{
+		_type:        C.ClientMessage,
+		display:      w.x,
+		window:       dst,
+		message_type: typ,
+		format:       32,
+	}
+	*(*[5]C.long)(unsafe.Pointer(&ev.data)) = data
+	C.XSendEvent(w.x, dst, C.False, C.NoEventMask, &xev)
+}
@@ -37,7 +37,7 @@ A program must keep receiving events from the event channel until
 The Main function must be called from a program's main function, to hand over
 control of the main thread to operating systems that need it.
 
-Because Main is also blocking on Linux/X11, the event loop of a Window must run in a goroutine.
+Because Main is also blocking on Linux, the event loop of a Window must run in a goroutine.
 
 For example, to display a blank but otherwise functional window:
 
@@ -55,6 +55,41 @@ For example, to display a blank but otherwise functional window:
 		app.Main()
 	}
 
+# Building on Linux
+
+The Linux drivers link system libraries found by pkg-config. The
+Wayland driver needs wayland-client, wayland-cursor, wayland-egl, egl
+and xkbcommon. The X11 driver needs x11, x11-xcb, xkbcommon,
+xkbcommon-x11, xcursor and xfixes, and the extensions
+
+	xrandr            screens, their scales and refresh rates
+	xi                XInput2 touch, pen and smooth scrolling
+	xext              the Shape extension, for input regions
+	xcb xcb-present   animation paced by the Present extension
+
+On Debian and Ubuntu, the development packages are
+
+	libwayland-dev libegl-dev libxkbcommon-dev libxkbcommon-x11-dev
+	libx11-dev libx11-xcb-dev libxcursor-dev libxfixes-dev libxrandr-dev
+	libxi-dev libxext-dev libxcb1-dev libxcb-present-dev libvulkan-dev
+
+Build tags leave out parts and their libraries:
+
+	nowayland      the Wayland driver
+	nox11          the X11 driver
+	nox11present   the Present extension; X11 animation is then paced by
+	               the swap of the GPU context
+	novulkan       the Vulkan backend
+	noopengl       the OpenGL backend
+
+# Headless Windows
+
+A window created with the [Headless] option, or while the GIO_HEADLESS
+environment variable is set, runs without a display. Its frames are
+rendered in memory, and tests drive it through [Window.Headless] by
+injecting input events, resizing it and reading back the frames. The event
+loop of a headless window does not need Main.
+
 # Permissions
 
 The packages under github.com/mleku/gio/app/permission should be imported
@@ -63,6 +98,6 @@ operating-system permissions are required.  Please see documentation for
 package github.com/mleku/gio/app/permission for more information.
 
 Note: Permission packages are primarily for mobile platforms and may not be
-applicable to Linux/X11 or JS/WASM platforms.
+applicable to Linux or JS/WASM platforms.
 */
 package app
@@ -0,0 +1,94 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+//go:build linux && !nowayland && !noopengl
+// +build linux,!nowayland,!noopengl
+
+package app
+
+import (
+	"errors"
+	"unsafe"
+
+	"github.com/mleku/gio/internal/egl"
+)
+
+/*
+#cgo linux pkg-config: egl wayland-egl
+#cgo CFLAGS: -DEGL_NO_X11
+
+#include <EGL/egl.h>
+#include <wayland-client.h>
+#include <wayland-egl.h>
+*/
+import "C"
+
+type wlContext struct {
+	win *wlWindow
+	*egl.Context
+	eglWin *C.struct_wl_egl_window
+}
+
+func init() {
+	newWaylandEGLContext = func(w *wlWindow) (context, error) {
+		disp := egl.NativeDisplayType(unsafe.Pointer(w.display()))
+		var ctx *egl.Context
+		var err error
+		if w.config.Transparent {
+			ctx, err = egl.NewTransparentContext(disp, 0)
+		} else {
+			ctx, err = egl.NewContext(disp)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &wlContext{Context: ctx, win: w}, nil
+	}
+}
+
+func (c *wlContext) Release() {
+	if c.Context != nil {
+		c.Context.Release()
+		c.Context = nil
+	}
+	if c.eglWin != nil {
+		C.wl_egl_window_destroy(c.eglWin)
+		c.eglWin = nil
+	}
+}
+
+func (c *wlContext) Refresh() error {
+	c.Context.ReleaseSurface()
+	if c.eglWin != nil {
+		C.wl_egl_window_destroy(c.eglWin)
+		c.eglWin = nil
+	}
+	surf, width, height := c.win.surface()
+	if surf == nil {
+		return errors.New("wayland: no surface")
+	}
+	eglWin := C.wl_egl_window_create(surf, C.int(width), C.int(height))
+	if eglWin == nil {
+		return errors.New("wayland: wl_egl_window_create failed")
+	}
+	c.eglWin = eglWin
+	eglSurf := egl.NativeWindowType(uintptr(unsafe.Pointer(eglWin)))
+	if err := c.Context.CreateSurface(eglSurf); err != nil {
+		return err
+	}
+	if err := c.Context.MakeCurrent(); err != nil {
+		return err
+	}
+	defer c.Context.ReleaseCurrent()
+	// We're in charge of the frame callbacks, don't let eglSwapBuffers
+	// wait for callbacks that may never arrive.
+	c.Context.EnableVSync(false)
+	return nil
+}
+
+func (c *wlContext) Lock() error {
+	return c.Context.MakeCurrent()
+}
+
+func (c *wlContext) Unlock() {
+	c.Context.ReleaseCurrent()
+}
@@ -19,7 +19,13 @@ type x11Context struct {
 func init() {
 	newX11EGLContext = func(w *x11Window) (context, error) {
 		disp := egl.NativeDisplayType(unsafe.Pointer(w.display()))
-		ctx, err := egl.NewContext(disp)
+		var ctx *egl.Context
+		var err error
+		if w.visualID != 0 {
+			ctx, err = egl.NewTransparentContext(disp, w.visualID)
+		} else {
+			ctx, err = egl.NewContext(disp)
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,146 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"image"
+	"os"
+	"path/filepath"
+
+	"github.com/mleku/gio/unit"
+)
+
+// Geometry is the size, position and mode of a window, saved under
+// [DataDir] to reopen the window as it was left. A typical use is
+//
+//	geom, _ := app.LoadGeometry("main")
+//	w.Option(geom.Options()...)
+//	for {
+//		switch e := w.Event().(type) {
+//		case app.ConfigEvent:
+//			geom.Update(e.Config)
+//		case app.DestroyEvent:
+//			geom.Save("main")
+//			...
+//		}
+//	}
+type Geometry struct {
+	// Size and Position are the size and position of the window in
+	// Windowed mode, in pixels. A zero Size means no geometry is
+	// known.
+	Size     image.Point `json:"size"`
+	Position image.Point `json:"position"`
+	// Mode is the window mode, Windowed, Maximized or Fullscreen.
+	Mode WindowMode `json:"mode"`
+}
+
+// LoadGeometry loads the geometry saved under name. It returns the zero
+// Geometry and an error satisfying errors.Is(err, fs.ErrNotExist) if
+// none is saved.
+func LoadGeometry(name string) (Geometry, error) {
+	path, err := geometryPath(name)
+	if err != nil {
+		return Geometry{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Geometry{}, err
+	}
+	var g Geometry
+	if err := json.Unmarshal(data, &g); err != nil {
+		return Geometry{}, err
+	}
+	return g, nil
+}
+
+// Save saves the geometry under name, replacing the file atomically.
+func (g Geometry) Save(name string) error {
+	path, err := geometryPath(name)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(g)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// Update records the window mode of cnf. The size and position are
+// recorded only in Windowed mode, so that a maximized window reopens
+// maximized and restores to its previous size. Minimized windows keep
+// their previous mode.
+func (g *Geometry) Update(cnf Config) {
+	switch cnf.Mode {
+	case Windowed:
+		g.Size = cnf.Size
+		g.Position = cnf.Position
+	case Minimized:
+		return
+	}
+	g.Mode = cnf.Mode
+}
+
+// Options returns the options that restore the geometry. The position
+// is restored only if the window would overlap one of the screens, and
+// the size only if it is known.
+func (g Geometry) Options() []Option {
+	var opts []Option
+	if g.Size.X > 0 && g.Size.Y > 0 {
+		size := g.Size
+		opts = append(opts, func(_ unit.Metric, cnf *Config) {
+			cnf.Mode = Windowed
+			cnf.Size = size
+		})
+		bounds := image.Rectangle{Min: g.Position, Max: g.Position.Add(size)}
+		for _, s := range Screens() {
+			if bounds.Overlaps(s.Bounds) {
+				opts = append(opts, Position(g.Position))
+				break
+			}
+		}
+	}
+	switch g.Mode {
+	case Maximized, Fullscreen:
+		opts = append(opts, g.Mode.Option())
+	}
+	return opts
+}
+
+// geometryPath returns the file of the geometry saved under name.
+func geometryPath(name string) (string, error) {
+	if name == "" || filepath.Base(name) != name {
+		return "", errors.New("app: invalid geometry name")
+	}
+	return appFile("geometry-" + name + ".json")
+}
+
+// writeFileAtomic replaces the file at path with data. Readers see
+// either the old or the new content, even if the program is
+// interrupted.
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
@@ -22,6 +22,9 @@ func newContext(w *window) (*glContext, error) {
 		// See https://developers.google.com/web/updates/2019/05/desynchronized.
 		"desynchronized":        true,
 		"preserveDrawingBuffer": true,
+		// Blend the canvas with the page below.
+		"alpha":              true,
+		"premultipliedAlpha": true,
 	}
 	ctx := w.cnv.Call("getContext", "webgl2", args)
 	if ctx.IsNull() {
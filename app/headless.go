@@ -0,0 +1,449 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package app
+
+import (
+	"image"
+	"image/draw"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mleku/gio/gpu"
+	"github.com/mleku/gio/io/clipboard"
+	"github.com/mleku/gio/io/event"
+	"github.com/mleku/gio/io/input"
+	"github.com/mleku/gio/io/key"
+	"github.com/mleku/gio/io/pointer"
+	"github.com/mleku/gio/io/system"
+	"github.com/mleku/gio/io/transfer"
+	"github.com/mleku/gio/op"
+	"github.com/mleku/gio/unit"
+)
+
+// headlessEnv is the environment variable that selects the headless
+// driver for every window when set to a non-empty value.
+const headlessEnv = "GIO_HEADLESS"
+
+// HeadlessWindow controls a window run by the headless driver, in
+// place of the user and the platform. Its methods are safe for
+// concurrent use. The effects of a method are processed by the next
+// call to the Event method of the window.
+type HeadlessWindow struct {
+	d *headlessWindow
+}
+
+// headlessWindow is a driver that runs a window in memory, without a
+// display, and renders its frames with the CPU rasterizer.
+type headlessWindow struct {
+	w      *callbacks
+	config Config
+	metric unit.Metric
+	// size is the size of the window content, excluding the
+	// decorations drawn by Gio.
+	size image.Point
+	// decorated tracks the Decorated option. The platform provides no
+	// decorations, so Gio draws them when requested.
+	decorated bool
+	animating bool
+	// redraw is set when a frame is due regardless of animation.
+	redraw bool
+	closed bool
+	// target is the render target of the frames.
+	target *image.RGBA
+
+	// wakeups wakes the event loop after a change to the fields below.
+	wakeups chan struct{}
+
+	mu sync.Mutex
+	// funcs run on the event loop, to apply the HeadlessWindow methods.
+	funcs       []func()
+	invalidated bool
+	// frame is the most recently presented frame.
+	frame *image.RGBA
+	// cursor, imageCursor and textInput mirror the most recent
+	// SetCursor, SetImageCursor and ShowTextInput.
+	cursor      pointer.Cursor
+	imageCursor *pointer.ImageCursor
+	textInput   bool
+}
+
+// headlessContext renders frames of a headless window into memory.
+type headlessContext struct {
+	d *headlessWindow
+}
+
+// headlessClipboard is the clipboard shared by headless windows.
+var headlessClipboard struct {
+	mu      sync.Mutex
+	content map[clipboard.Kind][]input.ClipboardData
+}
+
+// Headless runs the window with the headless driver. The window has no
+// display; its input is injected and its frames are read through the
+// controls returned by Window.Headless. Setting the GIO_HEADLESS
+// environment variable to a non-empty value has the same effect for
+// every window.
+//
+// Headless must be set when the window is created.
+func Headless() Option {
+	return func(_ unit.Metric, cnf *Config) {
+		cnf.headless = true
+	}
+}
+
+// useHeadless reports whether the window created with cnf is headless.
+func useHeadless(cnf Config) bool {
+	return cnf.headless || os.Getenv(headlessEnv) != ""
+}
+
+// Headless returns the controls of the window if it runs with the
+// headless driver, or nil otherwise. It returns nil until the window
+// is created by its first Event call.
+func (w *Window) Headless() *HeadlessWindow {
+	w.invMu.Lock()
+	defer w.invMu.Unlock()
+	if d, ok := w.driver.(*headlessWindow); ok {
+		return &HeadlessWindow{d: d}
+	}
+	return nil
+}
+
+// Inject delivers events as if they came from the platform, such as
+// pointer.Event, key.Event, key.EditEvent and transfer.DataEvent.
+// Pointer positions are in pixels of the window, including the
+// decorations drawn by Gio.
+func (h *HeadlessWindow) Inject(events ...event.Event) {
+	h.d.run(func() {
+		for _, e := range events {
+			h.d.ProcessEvent(e)
+		}
+	})
+}
+
+// Resize changes the size of the window content, as if resized by the
+// user.
+func (h *HeadlessWindow) Resize(size image.Point) {
+	h.d.run(func() {
+		h.d.size = size
+		h.d.updateSize()
+		h.d.ProcessEvent(ConfigEvent{Config: h.d.config})
+	})
+}
+
+// Focus gives or takes the keyboard focus of the window, as if by the
+// user.
+func (h *HeadlessWindow) Focus(focus bool) {
+	h.d.run(func() {
+		if h.d.config.Focused == focus {
+			return
+		}
+		h.d.config.Focused = focus
+		h.d.ProcessEvent(ConfigEvent{Config: h.d.config})
+	})
+}
+
+// Close closes the window, as if by the user. The window then delivers
+// a DestroyEvent.
+func (h *HeadlessWindow) Close() {
+	h.d.run(func() {
+		h.d.shutdown()
+	})
+}
+
+// Frame returns a copy of the most recently drawn frame, including the
+// decorations drawn by Gio, or nil if no frame was drawn. The image is
+// in sRGB with premultiplied alpha.
+func (h *HeadlessWindow) Frame() *image.RGBA {
+	h.d.mu.Lock()
+	defer h.d.mu.Unlock()
+	if h.d.frame == nil {
+		return nil
+	}
+	img := image.NewRGBA(h.d.frame.Bounds())
+	copy(img.Pix, h.d.frame.Pix)
+	return img
+}
+
+// Cursor returns the cursor most recently set by the window.
+func (h *HeadlessWindow) Cursor() pointer.Cursor {
+	h.d.mu.Lock()
+	defer h.d.mu.Unlock()
+	return h.d.cursor
+}
+
+// ImageCursor returns the image cursor requested by the window, or nil
+// if the cursor is a predefined shape.
+func (h *HeadlessWindow) ImageCursor() *pointer.ImageCursor {
+	h.d.mu.Lock()
+	defer h.d.mu.Unlock()
+	return h.d.imageCursor
+}
+
+// TextInput reports whether the window requested the virtual keyboard.
+func (h *HeadlessWindow) TextInput() bool {
+	h.d.mu.Lock()
+	defer h.d.mu.Unlock()
+	return h.d.textInput
+}
+
+func newHeadlessWindow(callbacks *callbacks, options []Option) {
+	d := &headlessWindow{
+		w:         callbacks,
+		metric:    unit.Metric{PxPerDp: 1, PxPerSp: 1},
+		decorated: true,
+		wakeups:   make(chan struct{}, 1),
+	}
+	// The window is shown with the keyboard focus.
+	d.config.Focused = true
+	d.w.SetDriver(d)
+	d.Configure(options)
+}
+
+// run queues f for the event loop and wakes it.
+func (d *headlessWindow) run(f func()) {
+	d.mu.Lock()
+	d.funcs = append(d.funcs, f)
+	d.mu.Unlock()
+	d.wakeup()
+}
+
+func (d *headlessWindow) wakeup() {
+	select {
+	case d.wakeups <- struct{}{}:
+	default:
+	}
+}
+
+func (d *headlessWindow) Event() event.Event {
+	for {
+		evt, ok := d.w.nextEvent()
+		if !ok {
+			d.dispatch()
+			continue
+		}
+		return evt
+	}
+}
+
+func (d *headlessWindow) dispatch() {
+	d.mu.Lock()
+	funcs, invalidated := d.funcs, d.invalidated
+	d.funcs, d.invalidated = nil, false
+	d.mu.Unlock()
+	for _, f := range funcs {
+		if d.closed {
+			break
+		}
+		f()
+	}
+	if d.closed {
+		// Only Invalidate can wake us up.
+		<-d.wakeups
+		return
+	}
+	if invalidated {
+		d.w.Invalidate()
+	}
+	// A window without area draws nothing until it is resized.
+	draw := (d.redraw || d.animating) && d.config.Size.X > 0 && d.config.Size.Y > 0
+	if !draw {
+		if len(funcs) == 0 && !invalidated {
+			<-d.wakeups
+		}
+		return
+	}
+	sync := d.redraw
+	d.redraw = false
+	d.ProcessEvent(frameEvent{
+		FrameEvent: FrameEvent{
+			Now:    time.Now(),
+			Size:   d.config.Size,
+			Metric: d.metric,
+		},
+		Sync: sync,
+	})
+}
+
+func (d *headlessWindow) shutdown() {
+	if d.closed {
+		return
+	}
+	d.closed = true
+	d.ProcessEvent(DestroyEvent{})
+}
+
+func (d *headlessWindow) Invalidate() {
+	d.mu.Lock()
+	d.invalidated = true
+	d.mu.Unlock()
+	d.wakeup()
+}
+
+func (d *headlessWindow) SetAnimating(anim bool) {
+	d.animating = anim
+}
+
+func (d *headlessWindow) ShowTextInput(show bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.textInput = show
+}
+
+func (d *headlessWindow) SetInputHint(_ key.InputHint) {}
+
+func (d *headlessWindow) NewContext() (context, error) {
+	return &headlessContext{d: d}, nil
+}
+
+func (d *headlessWindow) ReadClipboard(kind clipboard.Kind, mimes []string) {
+	headlessClipboard.mu.Lock()
+	content := headlessClipboard.content[kind]
+	headlessClipboard.mu.Unlock()
+	for _, mime := range mimes {
+		var data []byte
+		for _, c := range content {
+			if c.Type == mime || isTextMIME(c.Type) && isTextMIME(mime) {
+				data = c.Data
+				break
+			}
+		}
+		d.ProcessEvent(input.ClipboardEvent{
+			Kind: kind,
+			DataEvent: transfer.DataEvent{
+				Type: mime,
+				Open: func() io.ReadCloser {
+					return io.NopCloser(strings.NewReader(string(data)))
+				},
+			},
+		})
+	}
+}
+
+func (d *headlessWindow) WriteClipboard(kind clipboard.Kind, content []input.ClipboardData) {
+	headlessClipboard.mu.Lock()
+	defer headlessClipboard.mu.Unlock()
+	if headlessClipboard.content == nil {
+		headlessClipboard.content = make(map[clipboard.Kind][]input.ClipboardData)
+	}
+	headlessClipboard.content[kind] = content
+}
+
+func (d *headlessWindow) ExportData(mime string, data io.ReadCloser) {
+	data.Close()
+}
+
+func (d *headlessWindow) SetURL(u *url.URL, replace bool) {}
+
+func (d *headlessWindow) Configure(options []Option) {
+	prev := d.config
+	cnf := d.config
+	cnf.Size = d.size
+	cnf.Decorated = d.decorated
+	cnf.apply(d.metric, options)
+	d.decorated = cnf.Decorated
+	d.size = cnf.Size
+	// Everything is applied as requested, except for the decorations
+	// that the platform lacks.
+	cnf.Decorated = false
+	cnf.Focused = prev.Focused
+	cnf.Size = prev.Size
+	d.config = cnf
+	d.updateSize()
+	d.ProcessEvent(ConfigEvent{Config: d.config})
+}
+
+// updateSize computes the window size from the content size, the size
+// constraints and the decorations drawn by Gio.
+func (d *headlessWindow) updateSize() {
+	cnf := &d.config
+	size := d.size
+	if cnf.MinSize != (image.Point{}) {
+		size.X, size.Y = max(size.X, cnf.MinSize.X), max(size.Y, cnf.MinSize.Y)
+	}
+	if cnf.MaxSize != (image.Point{}) {
+		size.X, size.Y = min(size.X, cnf.MaxSize.X), min(size.Y, cnf.MaxSize.Y)
+	}
+	if d.decorated && cnf.Mode != Fullscreen {
+		size.Y += d.metric.Dp(cnf.decoHeight)
+	}
+	if size != cnf.Size {
+		cnf.Size = size
+		d.redraw = true
+	}
+}
+
+func (d *headlessWindow) SetCursor(cursor pointer.Cursor) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.cursor = cursor
+	d.imageCursor = nil
+}
+
+func (d *headlessWindow) SetImageCursor(cursor *pointer.ImageCursor) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.cursor = pointer.CursorDefault
+	d.imageCursor = cursor
+}
+
+func (d *headlessWindow) Perform(acts system.Action) {
+	if acts&system.ActionClose != 0 {
+		d.shutdown()
+	}
+}
+
+func (d *headlessWindow) EditorStateChanged(old, new editorState) {}
+
+func (d *headlessWindow) Run(f func()) {
+	f()
+}
+
+func (d *headlessWindow) Frame(frame *op.Ops) {
+	d.w.ProcessFrame(frame, nil)
+}
+
+func (d *headlessWindow) ProcessEvent(e event.Event) {
+	d.w.ProcessEvent(e)
+}
+
+func (c *headlessContext) API() gpu.API {
+	return gpu.CPU{}
+}
+
+func (c *headlessContext) RenderTarget() (gpu.RenderTarget, error) {
+	return gpu.CPURenderTarget{Image: c.d.target}, nil
+}
+
+func (c *headlessContext) Present() error {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	frame := c.d.frame
+	if frame == nil || frame.Bounds() != c.d.target.Bounds() {
+		frame = image.NewRGBA(c.d.target.Bounds())
+	}
+	draw.Draw(frame, frame.Bounds(), c.d.target, image.Point{}, draw.Src)
+	c.d.frame = frame
+	return nil
+}
+
+func (c *headlessContext) Refresh() error {
+	sz := c.d.config.Size
+	if t := c.d.target; t == nil || t.Bounds().Size() != sz {
+		c.d.target = image.NewRGBA(image.Rectangle{Max: sz})
+	}
+	return nil
+}
+
+func (c *headlessContext) Release() {
+	c.d.target = nil
+}
+
+func (c *headlessContext) Lock() error {
+	return nil
+}
+
+func (c *headlessContext) Unlock() {}
@@ -0,0 +1,240 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package app
+
+import (
+	"image"
+	"image/color"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mleku/gio/f32"
+	"github.com/mleku/gio/io/pointer"
+	"github.com/mleku/gio/layout"
+	"github.com/mleku/gio/op"
+	"github.com/mleku/gio/op/paint"
+	"github.com/mleku/gio/widget"
+)
+
+func TestHeadlessWindow(t *testing.T) {
+	w := new(Window)
+	w.Option(Headless(), Decorated(false), Size(40, 30))
+	var (
+		h      *HeadlessWindow
+		ops    op.Ops
+		btn    widget.Clickable
+		clicks int
+		frames int
+	)
+	red := color.NRGBA{R: 0xff, A: 0xff}
+	for {
+		switch e := w.Event().(type) {
+		case ConfigEvent:
+			if h == nil {
+				h = w.Headless()
+				if h == nil {
+					t.Fatal("no headless controls")
+				}
+			}
+		case FrameEvent:
+			gtx := NewContext(&ops, e)
+			for btn.Clicked(gtx) {
+				clicks++
+			}
+			btn.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				pointer.CursorPointer.Add(gtx.Ops)
+				paint.Fill(gtx.Ops, red)
+				return layout.Dimensions{Size: gtx.Constraints.Max}
+			})
+			e.Frame(gtx.Ops)
+			frames++
+			switch frames {
+			case 1:
+				if e.Size != image.Pt(40, 30) {
+					t.Errorf("frame size %v, want (40,30)", e.Size)
+				}
+				pos := f32.Pt(10, 10)
+				h.Inject(
+					pointer.Event{Kind: pointer.Press, Source: pointer.Mouse, Buttons: pointer.ButtonPrimary, Position: pos},
+					pointer.Event{Kind: pointer.Release, Source: pointer.Mouse, Position: pos},
+				)
+			case 2:
+				h.Resize(image.Pt(30, 20))
+			case 3:
+				if e.Size != image.Pt(30, 20) {
+					t.Errorf("resized frame size %v, want (30,20)", e.Size)
+				}
+				h.Close()
+			}
+		case DestroyEvent:
+			if e.Err != nil {
+				t.Fatal(e.Err)
+			}
+			if clicks != 1 {
+				t.Errorf("got %d clicks, want 1", clicks)
+			}
+			img := h.Frame()
+			if img == nil {
+				t.Fatal("no frame")
+			}
+			if got := img.Bounds().Size(); got != image.Pt(30, 20) {
+				t.Errorf("frame image size %v, want (30,20)", got)
+			}
+			if got := img.RGBAAt(5, 5); got != (color.RGBA{R: 0xff, A: 0xff}) {
+				t.Errorf("pixel %v, want red", got)
+			}
+			if got := h.Cursor(); got != pointer.CursorPointer {
+				t.Errorf("cursor %v, want %v", got, pointer.CursorPointer)
+			}
+			return
+		}
+	}
+}
+
+func TestStageEvent(t *testing.T) {
+	w := new(Window)
+	w.Option(Headless(), Size(10, 10))
+	var (
+		ops    op.Ops
+		stages []Stage
+		// hidden counts the frames while hidden.
+		hidden int
+	)
+	for {
+		switch e := w.Event().(type) {
+		case StageEvent:
+			stages = append(stages, e.Stage)
+			if e.Stage == StageHidden {
+				// Animation would draw frames before the window is shown
+				// again.
+				time.AfterFunc(50*time.Millisecond, func() {
+					w.Headless().Inject(StageEvent{Stage: StageRunning})
+				})
+			}
+		case FrameEvent:
+			gtx := NewContext(&ops, e)
+			gtx.Execute(op.InvalidateCmd{})
+			e.Frame(gtx.Ops)
+			switch len(stages) {
+			case 0:
+				w.Headless().Inject(StageEvent{Stage: StageHidden})
+			case 1:
+				hidden++
+			case 2:
+				w.Headless().Close()
+			}
+		case DestroyEvent:
+			if want := []Stage{StageHidden, StageRunning}; !slices.Equal(stages, want) {
+				t.Errorf("got stages %v, want %v", stages, want)
+			}
+			// A frame may be pending when the window is hidden.
+			if hidden > 1 {
+				t.Errorf("%d frames were animated while hidden", hidden)
+			}
+			return
+		}
+	}
+}
+
+func TestStageInvalidate(t *testing.T) {
+	w := new(Window)
+	w.Option(Headless(), Size(10, 10))
+	var (
+		ops    op.Ops
+		stage  = StageRunning
+		frames []Stage
+		shot   bool
+	)
+	for {
+		switch e := w.Event().(type) {
+		case StageEvent:
+			stage = e.Stage
+			if stage == StageHidden {
+				// Screenshots are drawn while hidden.
+				w.Screenshot()
+			}
+		case ScreenshotEvent:
+			shot = e.Err == nil
+			// Invalidations by the frame wait for the window to run.
+			time.AfterFunc(50*time.Millisecond, func() {
+				w.Headless().Inject(StageEvent{Stage: StageRunning})
+			})
+		case FrameEvent:
+			frames = append(frames, stage)
+			gtx := NewContext(&ops, e)
+			switch len(frames) {
+			case 1:
+				w.Headless().Inject(StageEvent{Stage: StageHidden})
+			case 2:
+				gtx.Execute(op.InvalidateCmd{})
+			case 3:
+				w.Headless().Close()
+			}
+			e.Frame(gtx.Ops)
+		case DestroyEvent:
+			if !shot {
+				t.Error("no screenshot while hidden")
+			}
+			if want := []Stage{StageRunning, StageHidden, StageRunning}; !slices.Equal(frames, want) {
+				t.Errorf("got frames in stages %v, want %v", frames, want)
+			}
+			return
+		}
+	}
+}
+
+func TestHeadlessZeroSize(t *testing.T) {
+	w := new(Window)
+	w.Option(Headless(), Size(10, 10))
+	var (
+		ops    op.Ops
+		frames int
+	)
+	for {
+		switch e := w.Event().(type) {
+		case ConfigEvent:
+			if e.Config.Size != (image.Point{}) {
+				break
+			}
+			d := w.driver.(*headlessWindow)
+			if !d.animating {
+				t.Fatal("window is not animating")
+			}
+			// Drop the wakeup of the resize.
+			select {
+			case <-d.wakeups:
+			default:
+			}
+			// An animating window without area waits for a wakeup.
+			done := make(chan struct{})
+			go func() {
+				d.dispatch()
+				close(done)
+			}()
+			select {
+			case <-done:
+				t.Error("dispatch returned without a wakeup")
+			case <-time.After(50 * time.Millisecond):
+			}
+			w.Headless().Resize(image.Pt(10, 10))
+			<-done
+		case FrameEvent:
+			gtx := NewContext(&ops, e)
+			gtx.Execute(op.InvalidateCmd{})
+			e.Frame(gtx.Ops)
+			frames++
+			switch frames {
+			case 1:
+				w.Headless().Resize(image.Point{})
+			case 2:
+				if e.Size != image.Pt(10, 10) {
+					t.Errorf("frame size %v, want (10,10)", e.Size)
+				}
+				w.Headless().Close()
+			}
+		case DestroyEvent:
+			return
+		}
+	}
+}
@@ -0,0 +1,56 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package app
+
+import (
+	"image"
+	"image/draw"
+	"slices"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// iconSizes are the sizes scaled from an icon given as a single image.
+var iconSizes = []int{16, 24, 32, 48, 64, 128}
+
+// iconImages converts icon to NRGBA images. An icon given as a single
+// image is complemented by scaled copies in the smaller of the common
+// icon sizes.
+func iconImages(icon []image.Image) []*image.NRGBA {
+	var imgs []*image.NRGBA
+	for _, img := range icon {
+		b := img.Bounds()
+		if b.Empty() {
+			continue
+		}
+		dst := image.NewNRGBA(image.Rectangle{Max: b.Size()})
+		draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+		imgs = append(imgs, dst)
+	}
+	if len(imgs) != 1 {
+		return imgs
+	}
+	src := imgs[0]
+	sz := src.Bounds().Size()
+	for _, s := range slices.Backward(iconSizes) {
+		if s >= max(sz.X, sz.Y) {
+			continue
+		}
+		// Preserve the aspect ratio.
+		dsz := image.Pt(s, s)
+		if sz.X > sz.Y {
+			dsz.Y = max(1, s*sz.Y/sz.X)
+		} else {
+			dsz.X = max(1, s*sz.X/sz.Y)
+		}
+		dst := image.NewNRGBA(image.Rectangle{Max: dsz})
+		xdraw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), xdraw.Src, nil)
+		imgs = append(imgs, dst)
+	}
+	return imgs
+}
+
+// sameIcon reports whether a and b are the same icon images.
+func sameIcon(a, b []image.Image) bool {
+	return len(a) == len(b) && (len(a) == 0 || &a[0] == &b[0])
+}
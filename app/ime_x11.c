@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+//go:build linux && !nox11
+// +build linux,!nox11
+
+#include <stdlib.h>
+#include <stdint.h>
+#include <X11/Xlib.h>
+#include "_cgo_export.h"
+
+static int gio_x11PreeditStart(XIC ic, XPointer client, XPointer call) {
+	// No limit on the pre-edit length.
+	return -1;
+}
+
+static void gio_x11PreeditDone(XIC ic, XPointer client, XPointer call) {
+	gio_x11onPreeditDone((uintptr_t)client);
+}
+
+static void gio_x11PreeditDraw(XIC ic, XPointer client, XIMPreeditDrawCallbackStruct *call) {
+	XIMText *t = call->text;
+	char *text = NULL, *buf = NULL;
+	int length = call->chg_length;
+	if (t != NULL) {
+		if (t->encoding_is_wchar && t->string.wide_char != NULL) {
+			size_t n = wcstombs(NULL, t->string.wide_char, 0);
+			if (n != (size_t)-1) {
+				buf = malloc(n + 1);
+				wcstombs(buf, t->string.wide_char, n + 1);
+				text = buf;
+			}
+		} else if (!t->encoding_is_wchar && t->string.multi_byte != NULL) {
+			text = t->string.multi_byte;
+		} else {
+			// Only the feedback of the text changed.
+			length = 0;
+		}
+	}
+	gio_x11onPreeditDraw((uintptr_t)client, call->caret, call->chg_first, length, text);
+	free(buf);
+}
+
+static void gio_x11PreeditCaret(XIC ic, XPointer client, XIMPreeditCaretCallbackStruct *call) {
+	call->position = gio_x11onPreeditCaret((uintptr_t)client, call->direction, call->position);
+}
+
+static void gio_x11IMDestroy(XIM im, XPointer client, XPointer call) {
+	gio_x11onIMDestroy((uintptr_t)client);
+}
+
+static void gio_x11IMInstantiate(Display *dpy, XPointer client, XPointer call) {
+	gio_x11onIMInstantiate((uintptr_t)client);
+}
+
+XIMStyle gio_x11IMStyle(XIM im) {
+	// Prefer drawing the pre-edit text in the editor (on-the-spot), and
+	// fall back to a separate pre-edit window (root window).
+	static const XIMStyle preferred[] = {
+		XIMPreeditCallbacks | XIMStatusNothing,
+		XIMPreeditCallbacks | XIMStatusNone,
+		XIMPreeditNothing | XIMStatusNothing,
+		XIMPreeditNothing | XIMStatusNone,
+		XIMPreeditNone | XIMStatusNone,
+	};
+	XIMStyles *styles = NULL;
+	if (XGetIMValues(im, XNQueryInputStyle, &styles, NULL) != NULL || styles == NULL) {
+		return 0;
+	}
+	XIMStyle style = 0;
+	for (int i = 0; i < sizeof(preferred)/sizeof(preferred[0]) && style == 0; i++) {
+		for (int j = 0; j < styles->count_styles; j++) {
+			if (styles->supported_styles[j] == preferred[i]) {
+				style = preferred[i];
+				break;
+			}
+		}
+	}
+	XFree(styles);
+	return style;
+}
+
+XIC gio_x11CreateIC(XIM im, Window win, XIMStyle style, uintptr_t client) {
+	XIMCallback destroy = {(XPointer)client, (XIMProc)gio_x11IMDestroy};
+	XSetIMValues(im, XNDestroyCallback, &destroy, NULL);
+	if ((style & XIMPreeditCallbacks) == 0) {
+		return XCreateIC(im, XNInputStyle, style, XNClientWindow, win, XNFocusWindow, win, NULL);
+	}
+	// Xlib copies the callbacks.
+	XIMCallback start = {(XPointer)client, (XIMProc)gio_x11PreeditStart};
+	XIMCallback done = {(XPointer)client, (XIMProc)gio_x11PreeditDone};
+	XIMCallback draw = {(XPointer)client, (XIMProc)gio_x11PreeditDraw};
+	XIMCallback caret = {(XPointer)client, (XIMProc)gio_x11PreeditCaret};
+	XVaNestedList attrs = XVaCreateNestedList(0,
+		XNPreeditStartCallback, &start,
+		XNPreeditDoneCallback, &done,
+		XNPreeditDrawCallback, &draw,
+		XNPreeditCaretCallback, &caret,
+		NULL);
+	XIC ic = XCreateIC(im, XNInputStyle, style, XNClientWindow, win, XNFocusWindow, win,
+		XNPreeditAttributes, attrs, NULL);
+	XFree(attrs);
+	return ic;
+}
+
+unsigned long gio_x11ICFilterEvents(XIC ic) {
+	unsigned long mask = 0;
+	XGetICValues(ic, XNFilterEvents, &mask, NULL);
+	return mask;
+}
+
+void gio_x11SetICSpot(XIC ic, short x, short y) {
+	XPoint spot = {x, y};
+	XVaNestedList attrs = XVaCreateNestedList(0, XNSpotLocation, &spot, NULL);
+	XSetICValues(ic, XNPreeditAttributes, attrs, NULL);
+	XFree(attrs);
+}
+
+void gio_x11WatchIM(Display *dpy, uintptr_t client, Bool watch) {
+	if (watch) {
+		XRegisterIMInstantiateCallback(dpy, NULL, NULL, NULL, gio_x11IMInstantiate, (XPointer)client);
+	} else {
+		XUnregisterIMInstantiateCallback(dpy, NULL, NULL, NULL, gio_x11IMInstantiate, (XPointer)client);
+	}
+}
@@ -0,0 +1,247 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+//go:build linux && !nox11
+// +build linux,!nox11
+
+package app
+
+/*
+#include <stdint.h>
+#include <X11/Xlib.h>
+
+XIMStyle gio_x11IMStyle(XIM im);
+XIC gio_x11CreateIC(XIM im, Window win, XIMStyle style, uintptr_t client);
+unsigned long gio_x11ICFilterEvents(XIC ic);
+void gio_x11SetICSpot(XIC ic, short x, short y);
+void gio_x11WatchIM(Display *dpy, uintptr_t client, Bool watch);
+*/
+import "C"
+
+import (
+	"image"
+	"runtime/cgo"
+	"slices"
+	"unsafe"
+
+	"github.com/mleku/gio/f32"
+	"github.com/mleku/gio/io/key"
+)
+
+// x11IME tracks the XIM input method of a window. IBus, Fcitx and
+// other input methods serve XIM clients.
+type x11IME struct {
+	// handle refers to the window from the XIM callbacks.
+	handle cgo.Handle
+	im     C.XIM
+	ic     C.XIC
+	// show mirrors the most recent ShowTextInput.
+	show bool
+	// active reports whether the input context has focus.
+	active bool
+	// preedit is the pre-edit text drawn in the editor, and caret
+	// the cursor position within it.
+	preedit []rune
+	caret   int
+	spot    image.Point
+}
+
+// initIME connects to the input method of the locale, or waits for
+// one to start.
+func (w *x11Window) initIME() {
+	w.ime.handle = cgo.NewHandle(w)
+	if !w.openIM() {
+		C.gio_x11WatchIM(w.x, C.uintptr_t(w.ime.handle), C.True)
+	}
+}
+
+// openIM opens the input method and creates an input context for the
+// window.
+func (w *x11Window) openIM() bool {
+	im := C.XOpenIM(w.x, nil, nil, nil)
+	if im == nil {
+		return false
+	}
+	style := C.gio_x11IMStyle(im)
+	if style == 0 {
+		C.XCloseIM(im)
+		return false
+	}
+	ic := C.gio_x11CreateIC(im, w.xw, style, C.uintptr_t(w.ime.handle))
+	if ic == nil {
+		C.XCloseIM(im)
+		return false
+	}
+	w.ime.im, w.ime.ic = im, ic
+	// Select the events the input method needs.
+	var attrs C.XWindowAttributes
+	C.XGetWindowAttributes(w.x, w.xw, &attrs)
+	C.XSelectInput(w.x, w.xw, attrs.your_event_mask|C.long(C.gio_x11ICFilterEvents(ic)))
+	w.ime.active = false
+	w.ime.spot = image.Point{X: -1, Y: -1}
+	w.updateIME()
+	return true
+}
+
+func (w *x11Window) destroyIME() {
+	if w.ime.handle == 0 {
+		return
+	}
+	if w.ime.ic != nil {
+		C.XDestroyIC(w.ime.ic)
+		C.XCloseIM(w.ime.im)
+	} else {
+		C.gio_x11WatchIM(w.x, C.uintptr_t(w.ime.handle), C.False)
+	}
+	w.ime.handle.Delete()
+	w.ime = x11IME{}
+}
+
+// updateIME focuses the input context while an editor has the keyboard
+// focus.
+func (w *x11Window) updateIME() {
+	ic := w.ime.ic
+	if ic == nil {
+		return
+	}
+	active := w.ime.show && w.config.Focused
+	if active == w.ime.active {
+		return
+	}
+	w.ime.active = active
+	if active {
+		C.XSetICFocus(ic)
+		w.updateICSpot(w.w.EditorState())
+		return
+	}
+	C.XUnsetICFocus(ic)
+	// Keep the pre-edit text as entered and reset the input method.
+	if w.w.EditorState().compose.Start != -1 {
+		w.w.SetComposingRegion(key.Range{Start: -1, End: -1})
+	}
+	w.ime.preedit, w.ime.caret = nil, 0
+	if s := C.Xutf8ResetIC(ic); s != nil {
+		C.XFree(unsafe.Pointer(s))
+	}
+}
+
+// updateICSpot moves the candidate window of the input method below
+// the caret.
+func (w *x11Window) updateICSpot(st editorState) {
+	caret := st.Selection.Caret
+	pos := st.Selection.Transform.Transform(caret.Pos.Add(f32.Pt(0, caret.Descent))).Round()
+	if pos == w.ime.spot {
+		return
+	}
+	w.ime.spot = pos
+	C.gio_x11SetICSpot(w.ime.ic, C.short(pos.X), C.short(pos.Y))
+}
+
+// lookupText returns the text committed by the input method through a
+// key press.
+func (h *x11EventHandler) lookupText(kevt *C.XKeyPressedEvent) string {
+	var keysym C.KeySym
+	var status C.Status
+	for {
+		n := C.Xutf8LookupString(h.w.ime.ic, kevt, (*C.char)(unsafe.Pointer(&h.text[0])), C.int(len(h.text)), &keysym, &status)
+		switch status {
+		case C.XBufferOverflow:
+			h.text = make([]byte, n)
+			continue
+		case C.XLookupChars, C.XLookupBoth:
+			return string(h.text[:n])
+		}
+		return ""
+	}
+}
+
+// commitIME replaces the pre-edit text, if any, with text.
+func (w *x11Window) commitIME(text string) {
+	w.setPreedit(nil, 0)
+	w.w.EditorInsert(text)
+}
+
+// setPreedit replaces the pre-edit text in the editor.
+func (w *x11Window) setPreedit(preedit []rune, caret int) {
+	w.ime.preedit, w.ime.caret = preedit, caret
+	st := w.w.EditorState()
+	r := st.compose
+	if r.Start == -1 {
+		if len(preedit) == 0 {
+			return
+		}
+		// A new composition replaces the selection.
+		r = st.Selection.Range
+	}
+	start := min(r.Start, r.End)
+	w.w.EditorReplace(r, string(preedit))
+	if len(preedit) == 0 {
+		w.w.SetComposingRegion(key.Range{Start: -1, End: -1})
+		w.w.SetEditorSelection(key.Range{Start: start, End: start})
+		return
+	}
+	w.w.SetComposingRegion(key.Range{Start: start, End: start + len(preedit)})
+	w.w.SetEditorSelection(key.Range{Start: start + caret, End: start + caret})
+}
+
+func imeWindow(h C.uintptr_t) *x11Window {
+	return cgo.Handle(h).Value().(*x11Window)
+}
+
+//export gio_x11onPreeditDone
+func gio_x11onPreeditDone(h C.uintptr_t) {
+	w := imeWindow(h)
+	w.setPreedit(nil, 0)
+}
+
+//export gio_x11onPreeditDraw
+func gio_x11onPreeditDraw(h C.uintptr_t, caret, first, length C.int, text *C.char) {
+	w := imeWindow(h)
+	p := w.ime.preedit
+	start := min(max(int(first), 0), len(p))
+	end := min(start+max(int(length), 0), len(p))
+	var ins []rune
+	if text != nil {
+		ins = []rune(C.GoString(text))
+	}
+	p = slices.Concat(p[:start], ins, p[end:])
+	w.setPreedit(p, min(max(int(caret), 0), len(p)))
+}
+
+//export gio_x11onPreeditCaret
+func gio_x11onPreeditCaret(h C.uintptr_t, direction C.XIMCaretDirection, position C.int) C.int {
+	w := imeWindow(h)
+	caret := w.ime.caret
+	switch direction {
+	case C.XIMForwardChar:
+		caret++
+	case C.XIMBackwardChar:
+		caret--
+	case C.XIMLineStart:
+		caret = 0
+	case C.XIMLineEnd:
+		caret = len(w.ime.preedit)
+	case C.XIMAbsolutePosition:
+		caret = int(position)
+	}
+	caret = min(max(caret, 0), len(w.ime.preedit))
+	w.setPreedit(w.ime.preedit, caret)
+	return C.int(caret)
+}
+
+//export gio_x11onIMDestroy
+func gio_x11onIMDestroy(h C.uintptr_t) {
+	w := imeWindow(h)
+	// The input method exited, taking the input context with it.
+	w.ime.im, w.ime.ic = nil, nil
+	w.ime.active = false
+	w.setPreedit(nil, 0)
+	C.gio_x11WatchIM(w.x, h, C.True)
+}
+
+//export gio_x11onIMInstantiate
+func gio_x11onIMInstantiate(h C.uintptr_t) {
+	w := imeWindow(h)
+	if w.ime.ic == nil && w.openIM() {
+		C.gio_x11WatchIM(w.x, h, C.False)
+	}
+}
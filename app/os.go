@@ -6,8 +6,13 @@ import (
 	"errors"
 	"image"
 	"image/color"
+	"io"
+	"net/url"
+	"strings"
 
+	"github.com/mleku/gio/io/clipboard"
 	"github.com/mleku/gio/io/event"
+	"github.com/mleku/gio/io/input"
 	"github.com/mleku/gio/io/key"
 	"github.com/mleku/gio/op"
 
@@ -31,6 +36,8 @@ type Config struct {
 	MinSize image.Point
 	// Title is the window title displayed in its decoration bar.
 	Title string
+	// Icon is the window icon, in one or more sizes.
+	Icon []image.Image
 	// WindowMode is the window mode.
 	Mode WindowMode
 	// StatusColor is the color of the status bar (unused on Linux/X11).
@@ -46,9 +53,39 @@ type Config struct {
 	Decorated bool
 	// Focused reports whether has the keyboard focus.
 	Focused bool
+	// Screen is the screen containing the window, on platforms that
+	// support listing screens (see Screens).
+	Screen Screen
+	// Position is the position of the window content in the coordinates
+	// of the screen bounds, on platforms that support placing windows.
+	Position image.Point
+	// Type is the kind of window, a hint to the window manager.
+	Type WindowType
+	// AlwaysOnTop reports whether the window is kept above other windows.
+	AlwaysOnTop bool
+	// SkipTaskbar reports whether the window is left out of taskbars
+	// and window switchers.
+	SkipTaskbar bool
+	// Parent is the window this window is transient for, or nil.
+	Parent *Window
+	// Modal reports whether the window blocks input to its Parent.
+	Modal bool
+	// Popup reports whether the window is a popup of Parent (see Popup).
+	Popup bool
+	// Anchor is the rectangle in the Parent frame that a popup is
+	// placed against.
+	Anchor image.Rectangle
+	// Transparent reports whether the window blends with the content
+	// below it where the frame is not opaque (see Transparent).
+	Transparent bool
+	// InputRegion is the part of the window that receives pointer
+	// input, in pixels. The whole window receives input if it is nil.
+	InputRegion []image.Rectangle
 	// decoHeight is the height of the fallback decoration for platforms that
 	// may need fallback client-side decorations.
 	decoHeight unit.Dp
+	// headless selects the headless driver (see Headless).
+	headless bool
 }
 
 // ConfigEvent is sent whenever the configuration of a Window changes.
@@ -102,6 +139,51 @@ func (m WindowMode) String() string {
 	return ""
 }
 
+// WindowType is the kind of a window (WindowType.Option sets it). The
+// window manager may use it to choose the decorations, placement and
+// stacking of the window.
+type WindowType uint8
+
+const (
+	// NormalWindow is a top-level application window.
+	NormalWindow WindowType = iota
+	// DialogWindow is a dialog, usually with a Parent.
+	DialogWindow
+	// UtilityWindow is a small persistent window, such as a palette or
+	// toolbox.
+	UtilityWindow
+	// SplashWindow is a splash screen displayed while an application
+	// starts.
+	SplashWindow
+	// NotificationWindow is a transient notification, such as a
+	// bubble.
+	NotificationWindow
+)
+
+// Option changes the type of a Window.
+func (t WindowType) Option() Option {
+	return func(_ unit.Metric, cnf *Config) {
+		cnf.Type = t
+	}
+}
+
+// String returns the type name.
+func (t WindowType) String() string {
+	switch t {
+	case NormalWindow:
+		return "normal"
+	case DialogWindow:
+		return "dialog"
+	case UtilityWindow:
+		return "utility"
+	case SplashWindow:
+		return "splash"
+	case NotificationWindow:
+		return "notification"
+	}
+	return ""
+}
+
 // Orientation is the orientation of the app (Orientation.Option sets it).
 //
 // Supported platforms are JS/WASM.
@@ -186,14 +268,25 @@ type driver interface {
 	ShowTextInput(show bool)
 	SetInputHint(mode key.InputHint)
 	NewContext() (context, error)
-	// ReadClipboard requests the clipboard content.
-	ReadClipboard()
-	// WriteClipboard requests a clipboard write.
-	WriteClipboard(mime string, s []byte)
+	// ReadClipboard requests the content of the clipboard kind in
+	// each of the MIME types mimes.
+	ReadClipboard(kind clipboard.Kind, mimes []string)
+	// WriteClipboard requests a write of the representations in
+	// content to the clipboard kind.
+	WriteClipboard(kind clipboard.Kind, content []input.ClipboardData)
+	// ExportData delivers the data requested for a drag and drop
+	// transfer to another application. The driver must close data.
+	ExportData(mime string, data io.ReadCloser)
+	// SetURL changes the address of the window, by adding a history
+	// entry or replacing the current entry.
+	SetURL(u *url.URL, replace bool)
 	// Configure the window.
 	Configure([]Option)
 	// SetCursor updates the current cursor to name.
 	SetCursor(cursor pointer.Cursor)
+	// SetImageCursor updates the current cursor to an image. Drivers
+	// without image cursors show the default cursor.
+	SetImageCursor(cursor *pointer.ImageCursor)
 	// Perform actions on the window.
 	Perform(system.Action)
 	// EditorStateChanged notifies the driver that the editor state changed.
@@ -360,5 +453,35 @@ func walkActions(actions system.Action, do func(system.Action)) {
 	}
 }
 
+// isMoveResize reports whether a is ActionMove or a resize action, both
+// of which are directed by the pointer.
+func isMoveResize(a system.Action) bool {
+	return a == system.ActionMove || a != 0 && a&system.ActionResize == a
+}
+
 func (wakeupEvent) ImplementsEvent() {}
 func (ConfigEvent) ImplementsEvent() {}
+
+// isTextMIME reports whether mime denotes plain text, including the
+// "application/text" type of text clipboard transfers.
+func isTextMIME(mime string) bool {
+	return mime == "application/text" || mime == "text/plain" || strings.HasPrefix(mime, "text/plain;")
+}
+
+// clipboardContent returns the data in content for a MIME type. Text
+// types are interchangeable.
+func clipboardContent(content []input.ClipboardData, mime string) ([]byte, bool) {
+	for _, c := range content {
+		if c.Type == mime {
+			return c.Data, true
+		}
+	}
+	if isTextMIME(mime) {
+		for _, c := range content {
+			if isTextMIME(c.Type) {
+				return c.Data, true
+			}
+		}
+	}
+	return nil, false
+}
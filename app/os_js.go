@@ -3,21 +3,28 @@
 package app
 
 import (
+	"bytes"
+	"encoding/base64"
 	"fmt"
 	"image"
 	"image/color"
+	"image/png"
 	"io"
+	"net/url"
 	"strings"
 	"syscall/js"
 	"time"
 	"unicode"
+	"unicode/utf16"
 	"unicode/utf8"
 
 	"github.com/mleku/gio/internal/f32color"
 	"github.com/mleku/gio/op"
 
 	"github.com/mleku/gio/f32"
+	"github.com/mleku/gio/io/clipboard"
 	"github.com/mleku/gio/io/event"
+	"github.com/mleku/gio/io/input"
 	"github.com/mleku/gio/io/key"
 	"github.com/mleku/gio/io/pointer"
 	"github.com/mleku/gio/io/system"
@@ -44,9 +51,9 @@ type window struct {
 	clipboard             js.Value
 	cnv                   js.Value
 	tarea                 js.Value
+	aria                  *ariaTree
 	w                     *callbacks
 	redraw                js.Func
-	clipboardCallback     js.Func
 	requestAnimationFrame js.Value
 	browserHistory        js.Value
 	visualViewport        js.Value
@@ -55,23 +62,48 @@ type window struct {
 	touches               []js.Value
 	composing             bool
 	requestFocus          bool
+	// mirror is the editor snippet in the text input.
+	mirror key.Snippet
+	dnd    jsDnD
+	// url is the page URL last reported in a URLEvent.
+	url string
+	// stage is the visibility of the page.
+	stage Stage
 
 	config    Config
 	inset     f32.Point
 	scale     float32
 	animating bool
 	// animRequested tracks whether a requestAnimationFrame callback
-	// is pending.
+	// is pending, and animFrame is its request id.
 	animRequested bool
+	animFrame     js.Value
 	wakeups       chan struct{}
 
 	contextStatus contextStatus
+	// closed is set when a popup is dismissed.
+	closed bool
 }
 
 func newWindow(win *callbacks, options []Option) {
 	doc := js.Global().Get("document")
 	cont := getContainer(doc)
 	cnv := createCanvas(doc)
+	var cnf Config
+	cnf.apply(unit.Metric{}, options)
+	popup := false
+	if cnf.Popup && cnf.Parent != nil {
+		v, ok := cnf.Parent.view().(JSViewEvent)
+		popup = ok && v.Valid()
+	}
+	if popup {
+		// A popup is an element above the parent canvas, sized in CSS
+		// pixels, which are Dp.
+		style := cnv.Get("style")
+		style.Set("width", fmt.Sprintf("%dpx", cnf.Size.X))
+		style.Set("height", fmt.Sprintf("%dpx", cnf.Size.Y))
+		style.Set("z-index", "1000")
+	}
 	cont.Call("appendChild", cnv)
 	tarea := createTextArea(doc)
 	cont.Call("appendChild", tarea)
@@ -84,7 +116,11 @@ func newWindow(win *callbacks, options []Option) {
 		clipboard: js.Global().Get("navigator").Get("clipboard"),
 		wakeups:   make(chan struct{}, 1),
 		w:         win,
+		stage:     StageRunning,
 	}
+	w.config.Popup = popup
+	// The canvas always blends with the page below it.
+	w.config.Transparent = true
 	w.w.SetDriver(w)
 	w.requestAnimationFrame = w.window.Get("requestAnimationFrame")
 	w.browserHistory = w.window.Get("history")
@@ -99,22 +135,27 @@ func newWindow(win *callbacks, options []Option) {
 		w.draw(false)
 		return nil
 	})
-	w.clipboardCallback = w.funcOf(func(this js.Value, args []js.Value) interface{} {
-		content := args[0].String()
-		w.processEvent(transfer.DataEvent{
-			Type: "application/text",
-			Open: func() io.ReadCloser {
-				return io.NopCloser(strings.NewReader(content))
-			},
-		})
-		return nil
-	})
 	w.addEventListeners()
-	w.addHistory()
+	w.aria = newARIATree(w)
+	cont.Call("appendChild", w.aria.elem)
+	if popup {
+		w.grabPopup()
+		w.cleanfuncs = append(w.cleanfuncs, func() {
+			cont.Call("removeChild", cnv)
+			cont.Call("removeChild", tarea)
+			cont.Call("removeChild", w.aria.elem)
+		})
+	} else {
+		w.addHistory()
+	}
 
 	w.Configure(options)
 	w.blur()
 	w.processEvent(JSViewEvent{Element: cont})
+	if !popup {
+		w.processURL()
+		w.processStage()
+	}
 	w.resize()
 	w.draw(true)
 }
@@ -130,13 +171,20 @@ func getContainer(doc js.Value) js.Value {
 }
 
 func createTextArea(doc js.Value) js.Value {
-	tarea := doc.Call("createElement", "input")
+	tarea := doc.Call("createElement", "textarea")
 	style := tarea.Get("style")
+	// The text area follows the caret, for the input method windows.
+	style.Set("position", "fixed")
 	style.Set("width", "1px")
 	style.Set("height", "1px")
 	style.Set("opacity", "0")
 	style.Set("border", "0")
 	style.Set("padding", "0")
+	style.Set("resize", "none")
+	style.Set("overflow", "hidden")
+	style.Set("white-space", "pre")
+	// Leave the pointer and drags to the canvas.
+	style.Set("pointer-events", "none")
 	tarea.Set("autocomplete", "off")
 	tarea.Set("autocorrect", "off")
 	tarea.Set("autocapitalize", "off")
@@ -188,12 +236,29 @@ func (w *window) addEventListeners() {
 		args[0].Call("preventDefault")
 		return nil
 	})
-	w.addEventListener(w.window, "popstate", func(this js.Value, args []js.Value) interface{} {
-		if w.processEvent(key.Event{Name: key.NameBack}) {
-			return w.browserHistory.Call("forward")
-		}
-		return w.browserHistory.Call("back")
-	})
+	if !w.config.Popup {
+		// The history belongs to the top-level window.
+		w.addEventListener(w.window, "popstate", func(this js.Value, args []js.Value) interface{} {
+			// Entries added by SetURL are navigated by the program,
+			// while the entry added by addHistory maps to the back
+			// key.
+			if w.processURL() {
+				return nil
+			}
+			if w.processEvent(key.Event{Name: key.NameBack}) {
+				return w.browserHistory.Call("forward")
+			}
+			return w.browserHistory.Call("back")
+		})
+		w.addEventListener(w.window, "hashchange", func(this js.Value, args []js.Value) interface{} {
+			w.processURL()
+			return nil
+		})
+		w.addEventListener(w.document, "visibilitychange", func(this js.Value, args []js.Value) interface{} {
+			w.processStage()
+			return nil
+		})
+	}
 	w.addEventListener(w.cnv, "mousemove", func(this js.Value, args []js.Value) interface{} {
 		w.pointerEvent(pointer.Move, 0, 0, args[0])
 		return nil
@@ -269,10 +334,17 @@ func (w *window) addEventListeners() {
 		return nil
 	})
 	w.addEventListener(w.tarea, "keydown", func(this js.Value, args []js.Value) interface{} {
+		// Keys during a composition belong to the input method.
+		if w.composing || args[0].Get("isComposing").Bool() {
+			return nil
+		}
 		w.keyEvent(args[0], key.Press)
 		return nil
 	})
 	w.addEventListener(w.tarea, "keyup", func(this js.Value, args []js.Value) interface{} {
+		if w.composing || args[0].Get("isComposing").Bool() {
+			return nil
+		}
 		w.keyEvent(args[0], key.Release)
 		return nil
 	})
@@ -283,12 +355,10 @@ func (w *window) addEventListeners() {
 	w.addEventListener(w.tarea, "compositionend", func(this js.Value, args []js.Value) interface{} {
 		w.composing = false
 		w.flushInput()
+		w.w.SetComposingRegion(key.Range{Start: -1, End: -1})
 		return nil
 	})
 	w.addEventListener(w.tarea, "input", func(this js.Value, args []js.Value) interface{} {
-		if w.composing {
-			return nil
-		}
 		w.flushInput()
 		return nil
 	})
@@ -300,16 +370,220 @@ func (w *window) addEventListeners() {
 		args[0].Call("preventDefault")
 		return nil
 	})
+	w.addDragListeners()
+}
+
+// grabPopup dismisses a popup when a press lands outside it. The press
+// is captured before it reaches the element under it.
+func (w *window) grabPopup() {
+	for _, name := range []string{"pointerdown", "touchstart"} {
+		jsf := w.funcOf(func(this js.Value, args []js.Value) interface{} {
+			e := args[0]
+			if e.Get("target").Equal(w.cnv) {
+				return nil
+			}
+			e.Call("stopPropagation")
+			e.Call("preventDefault")
+			w.close()
+			return nil
+		})
+		w.document.Call("addEventListener", name, jsf, true)
+		w.cleanfuncs = append(w.cleanfuncs, func() {
+			w.document.Call("removeEventListener", name, jsf, true)
+		})
+	}
+}
+
+// placePopup moves a popup against its anchor. The parent canvas
+// covers the viewport, so the anchor is converted to the viewport
+// coordinates in CSS pixels.
+func (w *window) placePopup() {
+	scale := float32(w.window.Get("devicePixelRatio").Float())
+	anchor := w.config.Anchor.Add(w.config.Parent.contentOffset())
+	css := func(p image.Point) image.Point {
+		return image.Pt(int(float32(p.X)/scale), int(float32(p.Y)/scale))
+	}
+	rect := w.cnv.Call("getBoundingClientRect")
+	size := image.Pt(rect.Get("width").Int(), rect.Get("height").Int())
+	viewport := image.Rect(0, 0, w.window.Get("innerWidth").Int(), w.window.Get("innerHeight").Int())
+	r := popupBounds(image.Rectangle{Min: css(anchor.Min), Max: css(anchor.Max)}, size, viewport)
+	for _, elem := range []js.Value{w.cnv, w.aria.elem} {
+		style := elem.Get("style")
+		style.Set("left", fmt.Sprintf("%dpx", r.Min.X))
+		style.Set("top", fmt.Sprintf("%dpx", r.Min.Y))
+	}
+	style := w.aria.elem.Get("style")
+	style.Set("width", fmt.Sprintf("%dpx", r.Dx()))
+	style.Set("height", fmt.Sprintf("%dpx", r.Dy()))
+}
+
+// close dismisses a popup.
+func (w *window) close() {
+	if w.closed {
+		return
+	}
+	w.closed = true
+	if w.animRequested {
+		w.window.Call("cancelAnimationFrame", w.animFrame)
+		w.animRequested = false
+	}
+	w.processEvent(JSViewEvent{})
+	w.processEvent(DestroyEvent{})
 }
 
 func (w *window) addHistory() {
 	w.browserHistory.Call("pushState", nil, nil, w.window.Get("location").Get("href"))
 }
 
+// processStage reports the visibility of the page.
+func (w *window) processStage() {
+	stage := StageRunning
+	if w.document.Get("visibilityState").String() == "hidden" {
+		stage = StageHidden
+	}
+	if stage != w.stage {
+		w.stage = stage
+		w.processEvent(StageEvent{Stage: stage})
+	}
+}
+
+// processURL delivers the page URL in a URLEvent, and reports whether
+// it changed since the last one.
+func (w *window) processURL() bool {
+	href := w.window.Get("location").Get("href").String()
+	if href == w.url {
+		return false
+	}
+	w.url = href
+	if u, err := url.Parse(href); err == nil {
+		w.processEvent(URLEvent{URL: u})
+	}
+	return true
+}
+
+func (w *window) SetURL(u *url.URL, replace bool) {
+	if w.config.Popup {
+		return
+	}
+	loc := w.window.Get("location")
+	dst := js.Global().Get("URL").New(u.String(), loc.Get("href"))
+	// The history is restricted to the origin of the page.
+	if dst.Get("origin").String() != loc.Get("origin").String() {
+		return
+	}
+	method := "pushState"
+	if replace {
+		method = "replaceState"
+	}
+	w.url = dst.Get("href").String()
+	w.browserHistory.Call(method, nil, "", w.url)
+}
+
+// flushInput replaces the editor snippet with the contents of the text
+// input, and updates the selection and the composing region.
 func (w *window) flushInput() {
 	val := w.tarea.Get("value").String()
-	w.tarea.Set("value", "")
-	w.w.EditorInsert(string(val))
+	old, text := []rune(w.mirror.Text), []rune(val)
+	// Replace the text between the unchanged prefix and suffix.
+	pre := 0
+	for pre < len(old) && pre < len(text) && old[pre] == text[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(old)-pre && suf < len(text)-pre && old[len(old)-1-suf] == text[len(text)-1-suf] {
+		suf++
+	}
+	start := w.mirror.Start
+	w.mirror = key.Snippet{
+		Range: key.Range{Start: start, End: start + len(text)},
+		Text:  val,
+	}
+	if pre+suf < len(old) || pre+suf < len(text) {
+		r := key.Range{Start: start + pre, End: start + len(old) - suf}
+		w.w.EditorReplace(r, string(text[pre:len(text)-suf]))
+		if w.composing {
+			// The composing region covers every change of the
+			// composition.
+			end := r.Start + len(text) - pre - suf
+			c := w.w.EditorState().compose
+			if c.Start == -1 {
+				c = key.Range{Start: r.Start, End: end}
+			} else {
+				c.Start, c.End = min(c.Start, c.End, r.Start), max(c.Start, c.End, end)
+			}
+			w.w.SetComposingRegion(c)
+		}
+	}
+	sel := key.Range{
+		Start: start + utf16Runes(val, w.tarea.Get("selectionStart").Int()),
+		End:   start + utf16Runes(val, w.tarea.Get("selectionEnd").Int()),
+	}
+	if w.tarea.Get("selectionDirection").String() == "backward" {
+		sel.Start, sel.End = sel.End, sel.Start
+	}
+	if sel != w.w.EditorState().Selection.Range {
+		w.w.SetEditorSelection(sel)
+	}
+}
+
+// mirrorSnippet copies the editor snippet and selection to the text
+// input.
+func (w *window) mirrorSnippet(st editorState) {
+	if st.Snippet != w.mirror {
+		w.mirror = st.Snippet
+		w.tarea.Set("value", st.Snippet.Text)
+	}
+	text := []rune(w.mirror.Text)
+	offset := func(pos int) int {
+		pos = min(max(pos-w.mirror.Start, 0), len(text))
+		return utf16Len(text[:pos])
+	}
+	sel := st.Selection.Range
+	dir := "forward"
+	if sel.Start > sel.End {
+		sel.Start, sel.End = sel.End, sel.Start
+		dir = "backward"
+	}
+	w.tarea.Call("setSelectionRange", offset(sel.Start), offset(sel.End), dir)
+}
+
+// placeTextInput moves the text input over the caret, where the
+// browser places the windows of the input method.
+func (w *window) placeTextInput(st editorState) {
+	caret := st.Selection.Caret
+	t := st.Selection.Transform
+	top := t.Transform(caret.Pos.Sub(f32.Pt(0, caret.Ascent)))
+	bottom := t.Transform(caret.Pos.Add(f32.Pt(0, caret.Descent)))
+	rect := w.cnv.Call("getBoundingClientRect")
+	h := max(bottom.Y-top.Y, 1) / w.scale
+	style := w.tarea.Get("style")
+	style.Set("left", fmt.Sprintf("%gpx", float32(rect.Get("left").Float())+top.X/w.scale))
+	style.Set("top", fmt.Sprintf("%gpx", float32(rect.Get("top").Float())+top.Y/w.scale))
+	style.Set("height", fmt.Sprintf("%gpx", h))
+	style.Set("font-size", fmt.Sprintf("%gpx", h))
+}
+
+// utf16Len returns the length of text in UTF-16 code units.
+func utf16Len(text []rune) int {
+	n := 0
+	for _, r := range text {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+// utf16Runes converts an offset in UTF-16 code units into s to an
+// offset in runes.
+func utf16Runes(s string, offset int) int {
+	n := 0
+	for _, r := range s {
+		if offset <= 0 {
+			break
+		}
+		offset -= utf16.RuneLen(r)
+		n++
+	}
+	return n
 }
 
 func (w *window) blur() {
@@ -353,7 +627,10 @@ func (w *window) keyEvent(e js.Value, ks key.State) {
 			Modifiers: modifiersFor(e),
 			State:     ks,
 		}
-		w.processEvent(cmd)
+		// Keys handled by Gio must not also edit the text input.
+		if w.processEvent(cmd) {
+			e.Call("preventDefault")
+		}
 	}
 }
 
@@ -395,6 +672,7 @@ func (w *window) Run(f func()) {
 
 func (w *window) Frame(frame *op.Ops) {
 	w.w.ProcessFrame(frame, nil)
+	w.aria.update()
 }
 
 // modifiersFor returns the modifier set for a DOM MouseEvent or
@@ -467,17 +745,22 @@ func (w *window) touchIDFor(touch js.Value) pointer.ID {
 	return pid
 }
 
-func (w *window) pointerEvent(kind pointer.Kind, dx, dy float32, e js.Value) {
-	e.Call("preventDefault")
+// eventPos returns the position of a DOM MouseEvent in the window.
+func (w *window) eventPos(e js.Value) f32.Point {
 	x, y := e.Get("clientX").Float(), e.Get("clientY").Float()
 	rect := w.cnv.Call("getBoundingClientRect")
 	x -= rect.Get("left").Float()
 	y -= rect.Get("top").Float()
-	scale := w.scale
-	pos := f32.Point{
-		X: float32(x) * scale,
-		Y: float32(y) * scale,
+	return f32.Point{
+		X: float32(x) * w.scale,
+		Y: float32(y) * w.scale,
 	}
+}
+
+func (w *window) pointerEvent(kind pointer.Kind, dx, dy float32, e js.Value) {
+	e.Call("preventDefault")
+	pos := w.eventPos(e)
+	scale := w.scale
 	scroll := f32.Point{
 		X: dx * scale,
 		Y: dy * scale,
@@ -521,34 +804,172 @@ func (w *window) funcOf(f func(this js.Value, args []js.Value) interface{}) js.F
 	return jsf
 }
 
-func (w *window) EditorStateChanged(old, new editorState) {}
+func (w *window) EditorStateChanged(old, new editorState) {
+	// Changing the text input would cancel the composition.
+	if !w.composing {
+		w.mirrorSnippet(new)
+	}
+	if old.Selection != new.Selection {
+		w.placeTextInput(new)
+	}
+}
 
 func (w *window) SetAnimating(anim bool) {
 	w.animating = anim
-	if anim && !w.animRequested {
+	if anim && !w.animRequested && !w.closed {
 		w.animRequested = true
-		w.requestAnimationFrame.Invoke(w.redraw)
+		w.animFrame = w.requestAnimationFrame.Invoke(w.redraw)
 	}
 }
 
-func (w *window) ReadClipboard() {
-	if w.clipboard.IsUndefined() {
+func (w *window) ReadClipboard(kind clipboard.Kind, mimes []string) {
+	if kind != clipboard.Clipboard || w.clipboard.IsUndefined() {
 		return
 	}
-	if w.clipboard.Get("readText").IsUndefined() {
+	if w.clipboard.Get("read").IsUndefined() {
+		if w.clipboard.Get("readText").IsUndefined() {
+			return
+		}
+		// Only text is available.
+		w.then(w.clipboard.Call("readText"), func(v js.Value) {
+			var text []byte
+			if v.Type() == js.TypeString {
+				text = []byte(v.String())
+			}
+			for _, mime := range mimes {
+				if isTextMIME(mime) {
+					w.processClipboard(mime, text)
+				} else {
+					w.processClipboard(mime, nil)
+				}
+			}
+		})
 		return
 	}
-	w.clipboard.Call("readText", w.clipboard).Call("then", w.clipboardCallback)
+	w.then(w.clipboard.Call("read"), func(items js.Value) {
+		for _, mime := range mimes {
+			t := mime
+			if isTextMIME(mime) {
+				t = "text/plain"
+			}
+			item := js.Undefined()
+			if items.Truthy() {
+				for i := 0; i < items.Length(); i++ {
+					if it := items.Index(i); it.Get("types").Call("includes", t).Bool() {
+						item = it
+						break
+					}
+				}
+			}
+			if item.IsUndefined() {
+				w.processClipboard(mime, nil)
+				continue
+			}
+			w.then(item.Call("getType", t), func(blob js.Value) {
+				if !blob.Truthy() {
+					w.processClipboard(mime, nil)
+					return
+				}
+				w.then(blob.Call("arrayBuffer"), func(buf js.Value) {
+					var data []byte
+					if buf.Truthy() {
+						arr := js.Global().Get("Uint8Array").New(buf)
+						data = make([]byte, arr.Length())
+						js.CopyBytesToGo(data, arr)
+					}
+					w.processClipboard(mime, data)
+				})
+			})
+		}
+	})
+}
+
+// processClipboard delivers clipboard data of a MIME type.
+func (w *window) processClipboard(mime string, data []byte) {
+	w.processEvent(input.ClipboardEvent{
+		Kind: clipboard.Clipboard,
+		DataEvent: transfer.DataEvent{
+			Type: mime,
+			Open: func() io.ReadCloser {
+				return io.NopCloser(bytes.NewReader(data))
+			},
+		},
+	})
 }
 
-func (w *window) WriteClipboard(mime string, s []byte) {
-	if w.clipboard.IsUndefined() {
+func (w *window) WriteClipboard(kind clipboard.Kind, content []input.ClipboardData) {
+	if kind != clipboard.Clipboard || w.clipboard.IsUndefined() {
 		return
 	}
-	if w.clipboard.Get("writeText").IsUndefined() {
+	text, hasText := clipboardContent(content, "text/plain")
+	writeText := func() {
+		if hasText && !w.clipboard.Get("writeText").IsUndefined() {
+			w.clipboard.Call("writeText", string(text))
+		}
+	}
+	itemClass := js.Global().Get("ClipboardItem")
+	if w.clipboard.Get("write").IsUndefined() || itemClass.IsUndefined() {
+		writeText()
 		return
 	}
-	w.clipboard.Call("writeText", string(s))
+	blobs := js.Global().Get("Object").New()
+	for _, c := range content {
+		t := c.Type
+		if isTextMIME(t) {
+			t = "text/plain"
+		}
+		if blobs.Get(t).Truthy() {
+			continue
+		}
+		// Browsers reject items with unsupported types.
+		if supports := itemClass.Get("supports"); !supports.IsUndefined() && !supports.Invoke(t).Bool() {
+			continue
+		}
+		arr := js.Global().Get("Uint8Array").New(len(c.Data))
+		js.CopyBytesToJS(arr, c.Data)
+		opts := js.Global().Get("Object").New()
+		opts.Set("type", t)
+		blobs.Set(t, js.Global().Get("Blob").New([]interface{}{arr}, opts))
+	}
+	item := itemClass.New(blobs)
+	w.then(w.clipboard.Call("write", []interface{}{item}), func(v js.Value) {
+		if v.Equal(js.Undefined()) {
+			return
+		}
+		// The write failed; fall back to text.
+		writeText()
+	})
+}
+
+// then calls f with the value of the promise p when it is fulfilled,
+// or with null if p is rejected.
+func (w *window) then(p js.Value, f func(v js.Value)) {
+	var resolve, reject js.Func
+	release := func() {
+		resolve.Release()
+		reject.Release()
+	}
+	resolve = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		release()
+		v := js.Undefined()
+		if len(args) > 0 {
+			v = args[0]
+		}
+		f(v)
+		return nil
+	})
+	reject = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		release()
+		f(js.Null())
+		return nil
+	})
+	p.Call("then", resolve, reject)
+}
+
+func (w *window) ExportData(mime string, data io.ReadCloser) {
+	// Drags never leave the browser window; drags into it are
+	// handled by addDragListeners.
+	data.Close()
 }
 
 func (w *window) Configure(options []Option) {
@@ -573,13 +994,26 @@ func (w *window) Configure(options []Option) {
 		w.config.Orientation = cnf.Orientation
 		w.orientation(cnf.Orientation)
 	}
+	if !sameIcon(prev.Icon, cnf.Icon) {
+		w.config.Icon = cnf.Icon
+		w.favicon(cnf.Icon)
+	}
 	if cnf.Decorated != prev.Decorated {
 		w.config.Decorated = cnf.Decorated
 	}
+	if w.config.Popup && cnf.Anchor != prev.Anchor {
+		w.config.Parent = cnf.Parent
+		w.config.Anchor = cnf.Anchor
+		w.placePopup()
+	}
 	w.processEvent(ConfigEvent{Config: w.config})
 }
 
-func (w *window) Perform(system.Action) {}
+func (w *window) Perform(acts system.Action) {
+	if w.config.Popup && acts&system.ActionClose != 0 {
+		w.close()
+	}
+}
 
 var webCursor = [...]string{
 	pointer.CursorDefault:                  "default",
@@ -615,6 +1049,20 @@ func (w *window) SetCursor(cursor pointer.Cursor) {
 	style.Set("cursor", webCursor[cursor])
 }
 
+// SetImageCursor sets a CSS cursor to the PNG encoding of the cursor
+// image. Browsers that reject the image show the default cursor.
+func (w *window) SetImageCursor(cursor *pointer.ImageCursor) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, cursor.Image); err != nil {
+		w.SetCursor(pointer.CursorDefault)
+		return
+	}
+	hot := cursor.Hotspot.Sub(cursor.Image.Bounds().Min)
+	style := w.cnv.Get("style")
+	style.Set("cursor", fmt.Sprintf("url(data:image/png;base64,%s) %d %d, auto",
+		base64.StdEncoding.EncodeToString(buf.Bytes()), hot.X, hot.Y))
+}
+
 func (w *window) ShowTextInput(show bool) {
 	// Run in a goroutine to avoid a deadlock if the
 	// focus change result in an event.
@@ -637,8 +1085,12 @@ func (w *window) resize() {
 		X: int(float32(rect.Get("width").Float()) * w.scale),
 		Y: int(float32(rect.Get("height").Float()) * w.scale),
 	}
-	if size != w.config.Size {
+	// Moving the browser window to another screen changes the pixel
+	// ratio and triggers a resize.
+	scr := osScreens()[0]
+	if size != w.config.Size || scr != w.config.Screen {
 		w.config.Size = size
+		w.config.Screen = scr
 		w.processEvent(ConfigEvent{Config: w.config})
 	}
 
@@ -656,13 +1108,13 @@ func (w *window) resize() {
 }
 
 func (w *window) draw(sync bool) {
-	if w.contextStatus == contextStatusLost {
+	if w.contextStatus == contextStatusLost || w.closed {
 		return
 	}
 	anim := w.animating
 	w.animRequested = anim
 	if anim {
-		w.requestAnimationFrame.Invoke(w.redraw)
+		w.animFrame = w.requestAnimationFrame.Invoke(w.redraw)
 	} else if !sync {
 		return
 	}
@@ -741,10 +1193,54 @@ func (w *window) navigationColor(c color.NRGBA) {
 	theme.Set("content", fmt.Sprintf("#%06X", []uint8{rgba.R, rgba.G, rgba.B}))
 }
 
+// favicon replaces the page icons with the icon images.
+func (w *window) favicon(icon []image.Image) {
+	links := w.head.Call("querySelectorAll", `link[rel~="icon"]`)
+	for i := links.Length() - 1; i >= 0; i-- {
+		links.Index(i).Call("remove")
+	}
+	for _, img := range icon {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			continue
+		}
+		sz := img.Bounds().Size()
+		link := w.document.Call("createElement", "link")
+		link.Set("rel", "icon")
+		link.Set("type", "image/png")
+		link.Call("setAttribute", "sizes", fmt.Sprintf("%dx%d", sz.X, sz.Y))
+		link.Set("href", "data:image/png;base64,"+base64.StdEncoding.EncodeToString(buf.Bytes()))
+		w.head.Call("appendChild", link)
+	}
+}
+
 func osMain() {
 	select {}
 }
 
+// osScreens describes the screen displaying the page. Browsers don't
+// expose the other screens without a permission prompt.
+func osScreens() []Screen {
+	win := js.Global().Get("window")
+	scr := win.Get("screen")
+	scale := float32(win.Get("devicePixelRatio").Float())
+	px := func(v js.Value) int {
+		return int(float32(v.Float()) * scale)
+	}
+	left, top := 0, 0
+	if l := scr.Get("availLeft"); l.Truthy() {
+		left = px(l)
+	}
+	if t := scr.Get("availTop"); t.Truthy() {
+		top = px(t)
+	}
+	return []Screen{{
+		Bounds:  image.Rect(left, top, left+px(scr.Get("width")), top+px(scr.Get("height"))),
+		Scale:   scale,
+		Primary: scr.Get("isPrimary").Truthy() || scr.Get("isPrimary").IsUndefined(),
+	}}
+}
+
 func translateKey(k string) (key.Name, bool) {
 	var n key.Name
 
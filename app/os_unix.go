@@ -7,6 +7,9 @@ package app
 
 import (
 	"errors"
+	"net/url"
+	"os"
+	"sync"
 	"unsafe"
 
 	"github.com/mleku/gio/io/pointer"
@@ -19,12 +22,25 @@ type X11ViewEvent struct {
 	Window uintptr
 }
 
+type WaylandViewEvent struct {
+	// Display is the *wl_display returned by wl_display_connect.
+	Display unsafe.Pointer
+	// Surface is the *wl_surface returned by wl_compositor_create_surface.
+	Surface unsafe.Pointer
+}
+
 func (X11ViewEvent) implementsViewEvent() {}
 func (X11ViewEvent) ImplementsEvent()     {}
 func (x X11ViewEvent) Valid() bool {
 	return x != (X11ViewEvent{})
 }
 
+func (WaylandViewEvent) implementsViewEvent() {}
+func (WaylandViewEvent) ImplementsEvent()     {}
+func (w WaylandViewEvent) Valid() bool {
+	return w != (WaylandViewEvent{})
+}
+
 func osMain() {
 	select {}
 }
@@ -33,16 +49,42 @@ type windowDriver func(*callbacks, []Option) error
 
 // Instead of creating files with build tags for each combination of wayland +/- x11
 // let each driver initialize these variables with their own version of createWindow.
-var x11Driver windowDriver
+var wlDriver, x11Driver windowDriver
+
+// x11Screens lists the screens when the X11 driver is available.
+var x11Screens func() []Screen
+
+func osScreens() []Screen {
+	if x11Screens == nil {
+		return nil
+	}
+	return x11Screens()
+}
 
 func newWindow(window *callbacks, options []Option) {
 	var errFirst error
-	if d := x11Driver; d != nil {
+	// Prefer Wayland when running in a Wayland session, and fall back
+	// to X11 (through XWayland or otherwise).
+	drivers := []windowDriver{x11Driver, wlDriver}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		drivers = []windowDriver{wlDriver, x11Driver}
+	}
+	for _, d := range drivers {
+		if d == nil {
+			continue
+		}
 		err := d(window, options)
 		if err == nil {
+			launchURLOnce.Do(func() {
+				if u, ok := launchURL(os.Args[1:]); ok {
+					window.ProcessEvent(URLEvent{URL: u})
+				}
+			})
 			return
 		}
-		errFirst = err
+		if errFirst == nil {
+			errFirst = err
+		}
 	}
 	if errFirst == nil {
 		errFirst = errors.New("app: no window driver available")
@@ -50,6 +92,27 @@ func newWindow(window *callbacks, options []Option) {
 	window.ProcessEvent(DestroyEvent{Err: errFirst})
 }
 
+// launchURLOnce delivers the URL from the command line to the first
+// window.
+var launchURLOnce sync.Once
+
+// launchURL returns the URL among args that the desktop passes to a
+// program registered as the x-scheme-handler of its scheme. File URLs
+// are left to the program.
+func launchURL(args []string) (*url.URL, bool) {
+	for _, a := range args {
+		u, err := url.Parse(a)
+		if err != nil || len(u.Scheme) < 2 || u.Scheme == "file" {
+			continue
+		}
+		if u.Opaque == "" && u.Host == "" && u.Path == "" {
+			continue
+		}
+		return u, true
+	}
+	return nil, false
+}
+
 // xCursor contains mapping from pointer.Cursor to XCursor.
 var xCursor = [...]string{
 	pointer.CursorDefault:                  "left_ptr",
@@ -0,0 +1,30 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+//go:build linux
+// +build linux
+
+package app
+
+import "testing"
+
+func TestLaunchURL(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, ""},
+		{[]string{"-v", "notes.txt"}, ""},
+		{[]string{"file:///tmp/notes.txt"}, ""},
+		{[]string{"-v", "myapp://open/item?id=1"}, "myapp://open/item?id=1"},
+		{[]string{"mailto:gio@example.com"}, "mailto:gio@example.com"},
+	}
+	for _, test := range tests {
+		got := ""
+		if u, ok := launchURL(test.args); ok {
+			got = u.String()
+		}
+		if got != test.want {
+			t.Errorf("launchURL(%q) = %q, want %q", test.args, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,2675 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+//go:build linux && !nowayland
+// +build linux,!nowayland
+
+package app
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"math"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+	"unsafe"
+
+	syscall "golang.org/x/sys/unix"
+
+	"github.com/mleku/gio/app/internal/xkb"
+	"github.com/mleku/gio/f32"
+	"github.com/mleku/gio/internal/fling"
+	"github.com/mleku/gio/io/clipboard"
+	"github.com/mleku/gio/io/event"
+	"github.com/mleku/gio/io/input"
+	"github.com/mleku/gio/io/key"
+	"github.com/mleku/gio/io/pointer"
+	"github.com/mleku/gio/io/system"
+	"github.com/mleku/gio/io/transfer"
+	"github.com/mleku/gio/op"
+	"github.com/mleku/gio/unit"
+)
+
+// Use wayland-scanner to generate glue code for the xdg-shell and xdg-decoration extensions.
+//go:generate wayland-scanner client-header /usr/share/wayland-protocols/stable/xdg-shell/xdg-shell.xml wayland_xdg_shell.h
+//go:generate wayland-scanner private-code /usr/share/wayland-protocols/stable/xdg-shell/xdg-shell.xml wayland_xdg_shell.c
+
+//go:generate wayland-scanner client-header /usr/share/wayland-protocols/unstable/text-input/text-input-unstable-v3.xml wayland_text_input.h
+//go:generate wayland-scanner private-code /usr/share/wayland-protocols/unstable/text-input/text-input-unstable-v3.xml wayland_text_input.c
+
+//go:generate wayland-scanner client-header /usr/share/wayland-protocols/unstable/xdg-decoration/xdg-decoration-unstable-v1.xml wayland_xdg_decoration.h
+//go:generate wayland-scanner private-code /usr/share/wayland-protocols/unstable/xdg-decoration/xdg-decoration-unstable-v1.xml wayland_xdg_decoration.c
+
+//go:generate wayland-scanner client-header /usr/share/wayland-protocols/staging/cursor-shape/cursor-shape-v1.xml wayland_cursor_shape.h
+//go:generate wayland-scanner private-code /usr/share/wayland-protocols/staging/cursor-shape/cursor-shape-v1.xml wayland_cursor_shape.c
+
+//go:generate wayland-scanner client-header /usr/share/wayland-protocols/unstable/primary-selection/primary-selection-unstable-v1.xml wayland_primary_selection.h
+//go:generate wayland-scanner private-code /usr/share/wayland-protocols/unstable/primary-selection/primary-selection-unstable-v1.xml wayland_primary_selection.c
+
+//go:generate sed -i "1s;^;//go:build linux \\&\\& !nowayland\\n// +build linux,!nowayland\\n\\n;" wayland_xdg_shell.c
+//go:generate sed -i "1s;^;//go:build linux \\&\\& !nowayland\\n// +build linux,!nowayland\\n\\n;" wayland_xdg_decoration.c
+//go:generate sed -i "1s;^;//go:build linux \\&\\& !nowayland\\n// +build linux,!nowayland\\n\\n;" wayland_text_input.c
+//go:generate sed -i "1s;^;//go:build linux \\&\\& !nowayland\\n// +build linux,!nowayland\\n\\n;" wayland_cursor_shape.c
+//go:generate sed -i "1s;^;//go:build linux \\&\\& !nowayland\\n// +build linux,!nowayland\\n\\n;" wayland_primary_selection.c
+
+// The tablet protocol is not used; drop the reference to its interface.
+//go:generate sed -i "/zwp_tablet_tool_v2_interface;$/d;s/&zwp_tablet_tool_v2_interface,/NULL,/" wayland_cursor_shape.c
+
+/*
+#cgo linux pkg-config: wayland-client wayland-cursor
+#cgo freebsd openbsd LDFLAGS: -lwayland-client -lwayland-cursor
+#cgo freebsd CFLAGS: -I/usr/local/include
+#cgo freebsd LDFLAGS: -L/usr/local/lib
+
+#include <stdlib.h>
+#include <wayland-client.h>
+#include <wayland-cursor.h>
+#include "wayland_text_input.h"
+#include "wayland_xdg_decoration.h"
+#include "wayland_xdg_shell.h"
+#include "wayland_cursor_shape.h"
+#include "wayland_primary_selection.h"
+
+extern const struct wl_registry_listener gio_registry_listener;
+extern const struct wl_surface_listener gio_surface_listener;
+extern const struct xdg_surface_listener gio_xdg_surface_listener;
+extern const struct xdg_toplevel_listener gio_xdg_toplevel_listener;
+extern const struct zxdg_toplevel_decoration_v1_listener gio_zxdg_toplevel_decoration_v1_listener;
+extern const struct xdg_wm_base_listener gio_xdg_wm_base_listener;
+extern const struct wl_callback_listener gio_callback_listener;
+extern const struct wl_output_listener gio_output_listener;
+extern const struct wl_seat_listener gio_seat_listener;
+extern const struct wl_pointer_listener gio_pointer_listener;
+extern const struct wl_touch_listener gio_touch_listener;
+extern const struct wl_keyboard_listener gio_keyboard_listener;
+extern const struct zwp_text_input_v3_listener gio_zwp_text_input_v3_listener;
+extern const struct wl_data_device_listener gio_data_device_listener;
+extern const struct wl_data_offer_listener gio_data_offer_listener;
+extern const struct wl_data_source_listener gio_data_source_listener;
+*/
+import "C"
+
+type wlDisplay struct {
+	disp              *C.struct_wl_display
+	reg               *C.struct_wl_registry
+	compositor        *C.struct_wl_compositor
+	wm                *C.struct_xdg_wm_base
+	imm               *C.struct_zwp_text_input_manager_v3
+	shm               *C.struct_wl_shm
+	dataDeviceManager *C.struct_wl_data_device_manager
+	primaryManager    *C.struct_zwp_primary_selection_device_manager_v1
+	decor             *C.struct_zxdg_decoration_manager_v1
+	cursorShape       *C.struct_wp_cursor_shape_manager_v1
+	seat              *wlSeat
+	xkb               *xkb.Context
+	outputMap         map[C.uint32_t]*C.struct_wl_output
+	outputConfig      map[*C.struct_wl_output]*wlOutput
+
+	// Notification pipe fds.
+	notify struct {
+		read, write int
+	}
+
+	repeat repeatState
+	poller poller
+
+	// win is the window of the display.
+	win *wlWindow
+	// parent is the display of the parent window of a popup, whose
+	// connection the popup shares. The display of a popup dispatches
+	// its own event queue, queue. Parent is nil for the display that
+	// owns the connection.
+	parent *wlDisplay
+	queue  *C.struct_wl_event_queue
+	// refs counts the displays using the connection of the owner.
+	refs int
+	// shared is the state read by the popups of win, protected by
+	// sharedMu.
+	sharedMu sync.Mutex
+	shared   wlShared
+}
+
+// wlShared is the state of a display read by the popups of its window,
+// which dispatch their event queues on other goroutines.
+type wlShared struct {
+	// closed is set when the display is destroyed.
+	closed bool
+	// wmSurf is the xdg_surface of the window and scale its scale.
+	wmSurf *C.struct_xdg_surface
+	scale  int
+	// seat is the seat of the connection, caps its capabilities and
+	// serial its most recent input serial.
+	seat   *C.struct_wl_seat
+	caps   C.uint32_t
+	serial C.uint32_t
+}
+
+type wlSeat struct {
+	disp     *wlDisplay
+	seat     *C.struct_wl_seat
+	name     C.uint32_t
+	pointer  *C.struct_wl_pointer
+	touch    *C.struct_wl_touch
+	keyboard *C.struct_wl_keyboard
+	im       *C.struct_zwp_text_input_v3
+	// shape is the cursor-shape-v1 device for pointer, if supported.
+	shape *C.struct_wp_cursor_shape_device_v1
+
+	// The most recent input serial.
+	serial C.uint32_t
+	// The serial of the most recent pointer enter event, needed for
+	// setting the cursor.
+	pointerSerial C.uint32_t
+
+	pointerFocus  *wlWindow
+	keyboardFocus *wlWindow
+	touchFoci     map[C.int32_t]*wlTouch
+
+	// ime tracks the text-input-v3 state.
+	ime struct {
+		// focus is the window with text input focus.
+		focus *wlWindow
+		// enabled reports whether im is enabled.
+		enabled bool
+		// pending accumulates input method events until the
+		// next done event.
+		pending wlTextInputState
+	}
+
+	// Clipboard support.
+	dataDev *C.struct_wl_data_device
+	// offers is a map from active wl_data_offers to
+	// the list of mime types they support.
+	offers map[*C.struct_wl_data_offer][]string
+	// clipboard is the wl_data_offer for the clipboard.
+	clipboard *C.struct_wl_data_offer
+	// mimeType is the chosen mime type of clipboard text, if any.
+	mimeType string
+	// source represents the clipboard content of the most recent
+	// clipboard write, if any.
+	source *C.struct_wl_data_source
+	// content is the data belonging to source.
+	content []input.ClipboardData
+	// primary is the primary selection.
+	primary wlPrimary
+}
+
+// wlTouch is an active touch point.
+type wlTouch struct {
+	w   *wlWindow
+	pos f32.Point
+}
+
+// wlTextInputState is the state sent by the input method
+// between done events.
+type wlTextInputState struct {
+	preedit                  string
+	preeditBegin, preeditEnd int
+	commit                   string
+	deleteBefore             int
+	deleteAfter              int
+}
+
+type repeatState struct {
+	rate  int
+	delay time.Duration
+
+	key   uint32
+	win   *wlWindow
+	stopC chan struct{}
+
+	start time.Duration
+	last  time.Duration
+	mu    sync.Mutex
+	now   time.Duration
+}
+
+type wlWindow struct {
+	w          *callbacks
+	disp       *wlDisplay
+	surf       *C.struct_wl_surface
+	wmSurf     *C.struct_xdg_surface
+	topLvl     *C.struct_xdg_toplevel
+	popup      *C.struct_xdg_popup
+	decor      *C.struct_zxdg_toplevel_decoration_v1
+	ppdp, ppsp float32
+	scroll     struct {
+		time  time.Duration
+		steps image.Point
+		dist  f32.Point
+	}
+	pointerBtns pointer.Buttons
+	lastPos     f32.Point
+	a11y        *atspiWindow
+
+	cursor struct {
+		current pointer.Cursor
+		// The cursor theme is used when the compositor doesn't
+		// support cursor-shape-v1.
+		theme *C.struct_wl_cursor_theme
+		surf  *C.struct_wl_surface
+	}
+
+	fling struct {
+		yExtrapolation fling.Extrapolation
+		xExtrapolation fling.Extrapolation
+		anim           fling.Animation
+		start          bool
+		dir            f32.Point
+	}
+
+	textInput struct {
+		// show mirrors the most recent ShowTextInput.
+		show bool
+		hint key.InputHint
+		// applying is set while input method changes are
+		// applied to the editor.
+		applying bool
+	}
+
+	configured        bool
+	lastFrameCallback *C.struct_wl_callback
+	// frameRequested is when lastFrameCallback was requested.
+	frameRequested time.Time
+	// stage is the reported visibility of the window.
+	stage Stage
+
+	animating bool
+	redraw    bool
+	// The most recent configure serial waiting to be ack'ed.
+	serial C.uint32_t
+	scale  int
+	// size is the unscaled window size (unlike config.Size which is scaled).
+	size   image.Point
+	config Config
+	// wsize is the window config size before going fullscreen or maximized.
+	wsize image.Point
+
+	clipReads chan input.ClipboardEvent
+
+	wakeups chan struct{}
+
+	closing bool
+}
+
+type poller struct {
+	pollfds [2]syscall.PollFd
+	// buf is scratch space for draining the notification pipe.
+	buf [100]byte
+}
+
+type wlOutput struct {
+	width      int
+	height     int
+	physWidth  int
+	physHeight int
+	transform  C.int32_t
+	scale      int
+	windows    []*wlWindow
+}
+
+// callbackMap maps Wayland native handles to corresponding Go
+// references. It is necessary because the Wayland client API
+// forces the use of callbacks and storing pointers to Go values
+// in C is forbidden.
+var callbackMap sync.Map
+
+// clipboardMimeTypes is a list of supported clipboard mime types, in
+// order of preference.
+var clipboardMimeTypes = []string{"text/plain;charset=utf-8", "UTF8_STRING", "text/plain", "TEXT", "STRING"}
+
+// wlCursorShape contains the mapping from pointer.Cursor to
+// cursor-shape-v1 shapes.
+var wlCursorShape = [...]C.uint32_t{
+	pointer.CursorDefault:                  C.WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_DEFAULT,
+	pointer.CursorText:                     C.WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_TEXT,
+	pointer.CursorVerticalText:             C.WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_VERTICAL_TEXT,
+	pointer.CursorPointer:                  C.WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_POINTER,
+	pointer.CursorCrosshair:                C.WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_CROSSHAIR,
+	pointer.CursorAllScroll:                C.WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_ALL_SCROLL,
+	pointer.CursorColResize:                C.WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_COL_RESIZE,
+	pointer.CursorRowResize:                C.WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_ROW_RESIZE,
+	pointer.CursorGrab:                     C.WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_GRAB,
+	pointer.CursorGrabbing:                 C.WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_GRABBING,
+	pointer.CursorNotAllowed:               C.WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_NOT_ALLOWED,
+	pointer.CursorWait:                     C.WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_WAIT,
+	pointer.CursorProgress:                 C.WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_PROGRESS,
+	pointer.CursorNorthWestResize:          C.WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_NW_RESIZE,
+	pointer.CursorNorthEastResize:          C.WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_NE_RESIZE,
+	pointer.CursorSouthWestResize:          C.WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_SW_RESIZE,
+	pointer.CursorSouthEastResize:          C.WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_SE_RESIZE,
+	pointer.CursorNorthSouthResize:         C.WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_NS_RESIZE,
+	pointer.CursorEastWestResize:           C.WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_EW_RESIZE,
+	pointer.CursorWestResize:               C.WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_W_RESIZE,
+	pointer.CursorEastResize:               C.WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_E_RESIZE,
+	pointer.CursorNorthResize:              C.WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_N_RESIZE,
+	pointer.CursorSouthResize:              C.WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_S_RESIZE,
+	pointer.CursorNorthEastSouthWestResize: C.WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_NESW_RESIZE,
+	pointer.CursorNorthWestSouthEastResize: C.WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_NWSE_RESIZE,
+}
+
+var (
+	newWaylandEGLContext    func(w *wlWindow) (context, error)
+	newWaylandVulkanContext func(w *wlWindow) (context, error)
+)
+
+func init() {
+	wlDriver = newWLWindow
+}
+
+func newWLWindow(callbacks *callbacks, options []Option) error {
+	var cnf Config
+	cnf.apply(unit.Metric{}, options)
+	var d *wlDisplay
+	var err error
+	if parent, ok := wlPopupParent(cnf); ok {
+		d, err = parent.newPopupDisplay()
+	} else if cnf.Popup {
+		// Leave popups of other windows to the X11 driver.
+		return errors.New("wayland: popup parent is not a Wayland window")
+	} else {
+		d, err = newWLDisplay()
+	}
+	if err != nil {
+		return err
+	}
+	w, err := d.createNativeWindow(cnf)
+	if err != nil {
+		d.destroy()
+		return err
+	}
+	w.w = callbacks
+	w.w.SetDriver(w)
+	w.a11y = newATSPIWindow(w.w, w.Invalidate)
+	// Transparency is fixed when the surface is created.
+	w.config.Transparent = cnf.Transparent
+
+	// Finish and commit setup from createNativeWindow.
+	w.Configure(options)
+	C.wl_surface_commit(w.surf)
+
+	w.ProcessEvent(WaylandViewEvent{
+		Display: unsafe.Pointer(w.display()),
+		Surface: unsafe.Pointer(w.surf),
+	})
+	return nil
+}
+
+func (d *wlDisplay) createNativeWindow(cnf Config) (*wlWindow, error) {
+	if d.compositor == nil {
+		return nil, errors.New("wayland: no compositor available")
+	}
+	if d.wm == nil {
+		return nil, errors.New("wayland: no xdg_wm_base available")
+	}
+	if d.shm == nil {
+		return nil, errors.New("wayland: no wl_shm available")
+	}
+	var scale int
+	if d.parent != nil {
+		// Popups don't track outputs; they appear on the output of
+		// their parent.
+		scale = d.parent.sharedState().scale
+	} else {
+		if len(d.outputMap) == 0 {
+			return nil, errors.New("wayland: no outputs available")
+		}
+		for _, conf := range d.outputConfig {
+			if s := conf.scale; s > scale {
+				scale = s
+			}
+		}
+	}
+	if scale < 1 {
+		scale = 1
+	}
+	ppdp := wlDetectUIScale()
+
+	w := &wlWindow{
+		disp:      d,
+		scale:     scale,
+		ppdp:      ppdp,
+		ppsp:      ppdp,
+		wakeups:   make(chan struct{}, 1),
+		clipReads: make(chan input.ClipboardEvent, 1),
+		stage:     StageRunning,
+	}
+	w.surf = C.wl_compositor_create_surface(d.compositor)
+	if w.surf == nil {
+		w.destroy()
+		return nil, errors.New("wayland: wl_compositor_create_surface failed")
+	}
+	C.wl_surface_set_buffer_scale(w.surf, C.int32_t(w.scale))
+	callbackStore(unsafe.Pointer(w.surf), w)
+	w.wmSurf = C.xdg_wm_base_get_xdg_surface(d.wm, w.surf)
+	if w.wmSurf == nil {
+		w.destroy()
+		return nil, errors.New("wayland: xdg_wm_base_get_xdg_surface failed")
+	}
+	C.wl_surface_add_listener(w.surf, &C.gio_surface_listener, unsafe.Pointer(w.surf))
+	C.xdg_surface_add_listener(w.wmSurf, &C.gio_xdg_surface_listener, unsafe.Pointer(w.surf))
+	d.win = w
+	if d.parent != nil {
+		if err := w.createPopup(cnf); err != nil {
+			w.destroy()
+			return nil, err
+		}
+		d.share(w)
+		return w, nil
+	}
+	w.topLvl = C.xdg_surface_get_toplevel(w.wmSurf)
+	if w.topLvl == nil {
+		w.destroy()
+		return nil, errors.New("wayland: xdg_surface_get_toplevel failed")
+	}
+
+	id := C.CString(ID)
+	defer C.free(unsafe.Pointer(id))
+	C.xdg_toplevel_set_app_id(w.topLvl, id)
+
+	C.xdg_wm_base_add_listener(d.wm, &C.gio_xdg_wm_base_listener, unsafe.Pointer(w.surf))
+	C.xdg_toplevel_add_listener(w.topLvl, &C.gio_xdg_toplevel_listener, unsafe.Pointer(w.surf))
+
+	// Assume server-side decorations until the compositor tells otherwise.
+	w.config.Decorated = d.decor != nil
+	if d.decor != nil {
+		w.decor = C.zxdg_decoration_manager_v1_get_toplevel_decoration(d.decor, w.topLvl)
+		C.zxdg_toplevel_decoration_v1_add_listener(w.decor, &C.gio_zxdg_toplevel_decoration_v1_listener, unsafe.Pointer(w.surf))
+	}
+	d.share(w)
+	return w, nil
+}
+
+// share publishes the state of the window for its popups.
+func (d *wlDisplay) share(w *wlWindow) {
+	d.updateShared(func(st *wlShared) {
+		st.wmSurf = w.wmSurf
+		st.scale = w.scale
+	})
+}
+
+// updateShared changes the state shared with popups.
+func (d *wlDisplay) updateShared(f func(st *wlShared)) {
+	d.sharedMu.Lock()
+	defer d.sharedMu.Unlock()
+	f(&d.shared)
+}
+
+// sharedState returns a copy of the state shared with popups.
+func (d *wlDisplay) sharedState() wlShared {
+	d.sharedMu.Lock()
+	defer d.sharedMu.Unlock()
+	return d.shared
+}
+
+// window returns the window of surf, or nil if surf is not the surface
+// of the window of d. Popups share the connection of their parent,
+// and the input devices of every display see the surfaces of all.
+func (d *wlDisplay) window(surf *C.struct_wl_surface) *wlWindow {
+	if w := d.win; w != nil && w.surf == surf && surf != nil {
+		return w
+	}
+	return nil
+}
+
+func callbackDelete(k unsafe.Pointer) {
+	callbackMap.Delete(k)
+}
+
+func callbackStore(k unsafe.Pointer, v interface{}) {
+	callbackMap.Store(k, v)
+}
+
+func callbackLoad(k unsafe.Pointer) interface{} {
+	v, exists := callbackMap.Load(k)
+	if !exists {
+		panic("missing callback entry")
+	}
+	return v
+}
+
+//export gio_onSeatCapabilities
+func gio_onSeatCapabilities(data unsafe.Pointer, seat *C.struct_wl_seat, caps C.uint32_t) {
+	s := callbackLoad(data).(*wlSeat)
+	s.updateCaps(caps)
+}
+
+// flushOffers remove all wl_data_offers that isn't the clipboard
+// content.
+func (s *wlSeat) flushOffers() {
+	for o := range s.offers {
+		if o == s.clipboard {
+			continue
+		}
+		// We're only interested in clipboard offers.
+		delete(s.offers, o)
+		callbackDelete(unsafe.Pointer(o))
+		C.wl_data_offer_destroy(o)
+	}
+}
+
+// setSerial records the serial of an input event.
+func (s *wlSeat) setSerial(serial C.uint32_t) {
+	s.serial = serial
+	s.disp.updateShared(func(st *wlShared) {
+		st.serial = serial
+	})
+}
+
+func (s *wlSeat) destroy() {
+	if s.source != nil {
+		C.wl_data_source_destroy(s.source)
+		s.source = nil
+	}
+	if s.im != nil {
+		C.zwp_text_input_v3_destroy(s.im)
+		s.im = nil
+	}
+	if s.shape != nil {
+		C.wp_cursor_shape_device_v1_destroy(s.shape)
+		s.shape = nil
+	}
+	if s.pointer != nil {
+		C.wl_pointer_release(s.pointer)
+	}
+	if s.touch != nil {
+		C.wl_touch_release(s.touch)
+	}
+	if s.keyboard != nil {
+		C.wl_keyboard_release(s.keyboard)
+	}
+	s.clipboard = nil
+	s.flushOffers()
+	s.primary.destroy()
+	if s.dataDev != nil {
+		C.wl_data_device_release(s.dataDev)
+	}
+	if s.seat != nil {
+		callbackDelete(unsafe.Pointer(s.seat))
+		if s.disp.parent != nil {
+			// The seat of a popup is a wrapper.
+			C.wl_proxy_wrapper_destroy(unsafe.Pointer(s.seat))
+		} else {
+			C.wl_seat_release(s.seat)
+		}
+	}
+}
+
+func (s *wlSeat) updateCaps(caps C.uint32_t) {
+	s.disp.updateShared(func(st *wlShared) {
+		st.caps = caps
+	})
+	if s.im == nil && s.disp.imm != nil {
+		s.im = C.zwp_text_input_manager_v3_get_text_input(s.disp.imm, s.seat)
+		C.zwp_text_input_v3_add_listener(s.im, &C.gio_zwp_text_input_v3_listener, unsafe.Pointer(s.seat))
+	}
+	switch {
+	case s.pointer == nil && caps&C.WL_SEAT_CAPABILITY_POINTER != 0:
+		s.pointer = C.wl_seat_get_pointer(s.seat)
+		C.wl_pointer_add_listener(s.pointer, &C.gio_pointer_listener, unsafe.Pointer(s.seat))
+		if m := s.disp.cursorShape; m != nil {
+			s.shape = C.wp_cursor_shape_manager_v1_get_pointer(m, s.pointer)
+		}
+	case s.pointer != nil && caps&C.WL_SEAT_CAPABILITY_POINTER == 0:
+		if s.shape != nil {
+			C.wp_cursor_shape_device_v1_destroy(s.shape)
+			s.shape = nil
+		}
+		C.wl_pointer_release(s.pointer)
+		s.pointer = nil
+	}
+	switch {
+	case s.touch == nil && caps&C.WL_SEAT_CAPABILITY_TOUCH != 0:
+		s.touch = C.wl_seat_get_touch(s.seat)
+		C.wl_touch_add_listener(s.touch, &C.gio_touch_listener, unsafe.Pointer(s.seat))
+	case s.touch != nil && caps&C.WL_SEAT_CAPABILITY_TOUCH == 0:
+		C.wl_touch_release(s.touch)
+		s.touch = nil
+	}
+	switch {
+	case s.keyboard == nil && caps&C.WL_SEAT_CAPABILITY_KEYBOARD != 0:
+		s.keyboard = C.wl_seat_get_keyboard(s.seat)
+		C.wl_keyboard_add_listener(s.keyboard, &C.gio_keyboard_listener, unsafe.Pointer(s.seat))
+	case s.keyboard != nil && caps&C.WL_SEAT_CAPABILITY_KEYBOARD == 0:
+		C.wl_keyboard_release(s.keyboard)
+		s.keyboard = nil
+	}
+}
+
+//export gio_onSeatName
+func gio_onSeatName(data unsafe.Pointer, seat *C.struct_wl_seat, name *C.char) {
+}
+
+//export gio_onXdgSurfaceConfigure
+func gio_onXdgSurfaceConfigure(data unsafe.Pointer, wmSurf *C.struct_xdg_surface, serial C.uint32_t) {
+	w := callbackLoad(data).(*wlWindow)
+	w.serial = serial
+	w.redraw = true
+	C.xdg_surface_ack_configure(wmSurf, serial)
+	w.configured = true
+}
+
+//export gio_onToplevelClose
+func gio_onToplevelClose(data unsafe.Pointer, topLvl *C.struct_xdg_toplevel) {
+	w := callbackLoad(data).(*wlWindow)
+	w.closing = true
+}
+
+//export gio_onToplevelConfigure
+func gio_onToplevelConfigure(data unsafe.Pointer, topLvl *C.struct_xdg_toplevel, width, height C.int32_t, states *C.struct_wl_array) {
+	w := callbackLoad(data).(*wlWindow)
+	if width != 0 && height != 0 {
+		w.size = image.Pt(int(width), int(height))
+	}
+	mode := Windowed
+	activated := false
+	n := int(states.size) / int(unsafe.Sizeof(C.uint32_t(0)))
+	for _, s := range unsafe.Slice((*C.uint32_t)(states.data), n) {
+		switch s {
+		case C.XDG_TOPLEVEL_STATE_MAXIMIZED:
+			mode = Maximized
+		case C.XDG_TOPLEVEL_STATE_FULLSCREEN:
+			mode = Fullscreen
+		case C.XDG_TOPLEVEL_STATE_ACTIVATED:
+			activated = true
+		}
+	}
+	if w.config.Mode == Minimized && !activated {
+		// There is no way to tell whether a window is minimized;
+		// assume it remains so until activated.
+		return
+	}
+	if mode != w.config.Mode {
+		w.config.Mode = mode
+		w.ProcessEvent(ConfigEvent{Config: w.config})
+	}
+}
+
+//export gio_onToplevelDecorationConfigure
+func gio_onToplevelDecorationConfigure(data unsafe.Pointer, deco *C.struct_zxdg_toplevel_decoration_v1, mode C.uint32_t) {
+	w := callbackLoad(data).(*wlWindow)
+	decorated := w.config.Decorated
+	switch mode {
+	case C.ZXDG_TOPLEVEL_DECORATION_V1_MODE_CLIENT_SIDE:
+		w.config.Decorated = false
+	case C.ZXDG_TOPLEVEL_DECORATION_V1_MODE_SERVER_SIDE:
+		w.config.Decorated = true
+	}
+	if decorated != w.config.Decorated {
+		// Make room for (or remove) the client-side decorations.
+		h := w.decoHeight()
+		if w.config.Decorated {
+			h = -w.fallbackDecoHeight()
+		}
+		if w.config.Mode == Windowed {
+			w.size.Y += h
+		}
+		w.setWindowConstraints()
+		w.ProcessEvent(ConfigEvent{Config: w.config})
+		w.redraw = true
+	}
+}
+
+//export gio_onOutputMode
+func gio_onOutputMode(data unsafe.Pointer, output *C.struct_wl_output, flags C.uint32_t, width, height, refresh C.int32_t) {
+	if flags&C.WL_OUTPUT_MODE_CURRENT == 0 {
+		return
+	}
+	d := callbackLoad(data).(*wlDisplay)
+	c := d.outputConfig[output]
+	c.width = int(width)
+	c.height = int(height)
+}
+
+//export gio_onOutputGeometry
+func gio_onOutputGeometry(data unsafe.Pointer, output *C.struct_wl_output, x, y, physWidth, physHeight, subpixel C.int32_t, make, model *C.char, transform C.int32_t) {
+	d := callbackLoad(data).(*wlDisplay)
+	c := d.outputConfig[output]
+	c.transform = transform
+	c.physWidth = int(physWidth)
+	c.physHeight = int(physHeight)
+}
+
+//export gio_onOutputScale
+func gio_onOutputScale(data unsafe.Pointer, output *C.struct_wl_output, scale C.int32_t) {
+	d := callbackLoad(data).(*wlDisplay)
+	c := d.outputConfig[output]
+	c.scale = int(scale)
+}
+
+//export gio_onOutputDone
+func gio_onOutputDone(data unsafe.Pointer, output *C.struct_wl_output) {
+	d := callbackLoad(data).(*wlDisplay)
+	conf := d.outputConfig[output]
+	for _, w := range conf.windows {
+		w.updateOutputs()
+	}
+}
+
+//export gio_onSurfaceEnter
+func gio_onSurfaceEnter(data unsafe.Pointer, surf *C.struct_wl_surface, output *C.struct_wl_output) {
+	w := callbackLoad(data).(*wlWindow)
+	conf := w.disp.outputConfig[output]
+	if conf == nil {
+		return
+	}
+	var found bool
+	for _, w2 := range conf.windows {
+		if w2 == w {
+			found = true
+			break
+		}
+	}
+	if !found {
+		conf.windows = append(conf.windows, w)
+	}
+	w.updateOutputs()
+	if w.config.Mode == Minimized {
+		// Minimized window got brought back up: it is no longer so.
+		w.config.Mode = Windowed
+		w.ProcessEvent(ConfigEvent{Config: w.config})
+	}
+}
+
+//export gio_onSurfaceLeave
+func gio_onSurfaceLeave(data unsafe.Pointer, surf *C.struct_wl_surface, output *C.struct_wl_output) {
+	w := callbackLoad(data).(*wlWindow)
+	conf := w.disp.outputConfig[output]
+	if conf == nil {
+		return
+	}
+	for i, w2 := range conf.windows {
+		if w2 == w {
+			conf.windows = append(conf.windows[:i], conf.windows[i+1:]...)
+			break
+		}
+	}
+	w.updateOutputs()
+}
+
+//export gio_onRegistryGlobal
+func gio_onRegistryGlobal(data unsafe.Pointer, reg *C.struct_wl_registry, name C.uint32_t, cintf *C.char, version C.uint32_t) {
+	d := callbackLoad(data).(*wlDisplay)
+	switch C.GoString(cintf) {
+	case "wl_compositor":
+		d.compositor = (*C.struct_wl_compositor)(C.wl_registry_bind(reg, name, &C.wl_compositor_interface, 3))
+	case "wl_output":
+		output := (*C.struct_wl_output)(C.wl_registry_bind(reg, name, &C.wl_output_interface, 2))
+		C.wl_output_add_listener(output, &C.gio_output_listener, unsafe.Pointer(d.disp))
+		d.outputMap[name] = output
+		d.outputConfig[output] = new(wlOutput)
+	case "wl_seat":
+		if d.seat != nil {
+			break
+		}
+		if version < 5 {
+			// No support for v5 protocol.
+			break
+		}
+		s := (*C.struct_wl_seat)(C.wl_registry_bind(reg, name, &C.wl_seat_interface, 5))
+		if s == nil {
+			break
+		}
+		d.seat = &wlSeat{
+			disp:      d,
+			name:      name,
+			seat:      s,
+			offers:    make(map[*C.struct_wl_data_offer][]string),
+			touchFoci: make(map[C.int32_t]*wlTouch),
+		}
+		callbackStore(unsafe.Pointer(s), d.seat)
+		C.wl_seat_add_listener(s, &C.gio_seat_listener, unsafe.Pointer(s))
+		d.updateShared(func(st *wlShared) {
+			st.seat = s
+		})
+		d.bindDataDevice()
+		d.bindPrimaryDevice()
+	case "wl_shm":
+		d.shm = (*C.struct_wl_shm)(C.wl_registry_bind(reg, name, &C.wl_shm_interface, 1))
+	case "xdg_wm_base":
+		// Version 3 repositions popups.
+		d.wm = (*C.struct_xdg_wm_base)(C.wl_registry_bind(reg, name, &C.xdg_wm_base_interface, min(version, 3)))
+	case "zxdg_decoration_manager_v1":
+		d.decor = (*C.struct_zxdg_decoration_manager_v1)(C.wl_registry_bind(reg, name, &C.zxdg_decoration_manager_v1_interface, 1))
+	case "zwp_text_input_manager_v3":
+		d.imm = (*C.struct_zwp_text_input_manager_v3)(C.wl_registry_bind(reg, name, &C.zwp_text_input_manager_v3_interface, 1))
+	case "wp_cursor_shape_manager_v1":
+		d.cursorShape = (*C.struct_wp_cursor_shape_manager_v1)(C.wl_registry_bind(reg, name, &C.wp_cursor_shape_manager_v1_interface, 1))
+	case "wl_data_device_manager":
+		d.dataDeviceManager = (*C.struct_wl_data_device_manager)(C.wl_registry_bind(reg, name, &C.wl_data_device_manager_interface, 3))
+		d.bindDataDevice()
+	case "zwp_primary_selection_device_manager_v1":
+		d.primaryManager = (*C.struct_zwp_primary_selection_device_manager_v1)(C.wl_registry_bind(reg, name, &C.zwp_primary_selection_device_manager_v1_interface, 1))
+		d.bindPrimaryDevice()
+	}
+}
+
+//export gio_onDataOfferOffer
+func gio_onDataOfferOffer(data unsafe.Pointer, offer *C.struct_wl_data_offer, mime *C.char) {
+	s := callbackLoad(data).(*wlSeat)
+	s.offers[offer] = append(s.offers[offer], C.GoString(mime))
+}
+
+//export gio_onDataOfferSourceActions
+func gio_onDataOfferSourceActions(data unsafe.Pointer, offer *C.struct_wl_data_offer, acts C.uint32_t) {
+}
+
+//export gio_onDataOfferAction
+func gio_onDataOfferAction(data unsafe.Pointer, offer *C.struct_wl_data_offer, act C.uint32_t) {
+}
+
+//export gio_onDataDeviceOffer
+func gio_onDataDeviceOffer(data unsafe.Pointer, dataDev *C.struct_wl_data_device, id *C.struct_wl_data_offer) {
+	s := callbackLoad(data).(*wlSeat)
+	callbackStore(unsafe.Pointer(id), s)
+	C.wl_data_offer_add_listener(id, &C.gio_data_offer_listener, unsafe.Pointer(id))
+	s.offers[id] = nil
+}
+
+//export gio_onDataDeviceEnter
+func gio_onDataDeviceEnter(data unsafe.Pointer, dataDev *C.struct_wl_data_device, serial C.uint32_t, surf *C.struct_wl_surface, x, y C.wl_fixed_t, id *C.struct_wl_data_offer) {
+	s := callbackLoad(data).(*wlSeat)
+	s.setSerial(serial)
+	s.flushOffers()
+}
+
+//export gio_onDataDeviceLeave
+func gio_onDataDeviceLeave(data unsafe.Pointer, dataDev *C.struct_wl_data_device) {
+}
+
+//export gio_onDataDeviceMotion
+func gio_onDataDeviceMotion(data unsafe.Pointer, dataDev *C.struct_wl_data_device, t C.uint32_t, x, y C.wl_fixed_t) {
+}
+
+//export gio_onDataDeviceDrop
+func gio_onDataDeviceDrop(data unsafe.Pointer, dataDev *C.struct_wl_data_device) {
+}
+
+//export gio_onDataDeviceSelection
+func gio_onDataDeviceSelection(data unsafe.Pointer, dataDev *C.struct_wl_data_device, id *C.struct_wl_data_offer) {
+	s := callbackLoad(data).(*wlSeat)
+	defer s.flushOffers()
+	s.clipboard = nil
+	s.mimeType = ""
+	if id == nil || len(s.offers[id]) == 0 {
+		return
+	}
+	s.clipboard = id
+	s.mimeType = textMIME(s.offers[id])
+}
+
+// textMIME returns the preferred text type of the offered mime types,
+// or the empty string if none is text.
+func textMIME(offered []string) string {
+	for _, want := range clipboardMimeTypes {
+		if slices.Contains(offered, want) {
+			return want
+		}
+	}
+	return ""
+}
+
+//export gio_onRegistryGlobalRemove
+func gio_onRegistryGlobalRemove(data unsafe.Pointer, reg *C.struct_wl_registry, name C.uint32_t) {
+	d := callbackLoad(data).(*wlDisplay)
+	if s := d.seat; s != nil && name == s.name {
+		s.destroy()
+		d.seat = nil
+		d.updateShared(func(st *wlShared) {
+			st.seat = nil
+			st.caps = 0
+		})
+	}
+	if output, exists := d.outputMap[name]; exists {
+		C.wl_output_destroy(output)
+		delete(d.outputMap, name)
+		delete(d.outputConfig, output)
+	}
+}
+
+//export gio_onTouchDown
+func gio_onTouchDown(data unsafe.Pointer, touch *C.struct_wl_touch, serial, t C.uint32_t, surf *C.struct_wl_surface, id C.int32_t, x, y C.wl_fixed_t) {
+	s := callbackLoad(data).(*wlSeat)
+	s.setSerial(serial)
+	w := s.disp.window(surf)
+	if w == nil {
+		return
+	}
+	tp := &wlTouch{
+		w: w,
+		pos: f32.Point{
+			X: fromFixed(x) * float32(w.scale),
+			Y: fromFixed(y) * float32(w.scale),
+		},
+	}
+	s.touchFoci[id] = tp
+	w.ProcessEvent(pointer.Event{
+		Kind:      pointer.Press,
+		Source:    pointer.Touch,
+		Position:  tp.pos,
+		PointerID: pointer.ID(id),
+		Time:      time.Duration(t) * time.Millisecond,
+		Modifiers: w.disp.xkb.Modifiers(),
+	})
+}
+
+//export gio_onTouchUp
+func gio_onTouchUp(data unsafe.Pointer, touch *C.struct_wl_touch, serial, t C.uint32_t, id C.int32_t) {
+	s := callbackLoad(data).(*wlSeat)
+	s.setSerial(serial)
+	tp, ok := s.touchFoci[id]
+	if !ok {
+		return
+	}
+	delete(s.touchFoci, id)
+	w := tp.w
+	w.ProcessEvent(pointer.Event{
+		Kind:      pointer.Release,
+		Source:    pointer.Touch,
+		Position:  tp.pos,
+		PointerID: pointer.ID(id),
+		Time:      time.Duration(t) * time.Millisecond,
+		Modifiers: w.disp.xkb.Modifiers(),
+	})
+}
+
+//export gio_onTouchMotion
+func gio_onTouchMotion(data unsafe.Pointer, touch *C.struct_wl_touch, t C.uint32_t, id C.int32_t, x, y C.wl_fixed_t) {
+	s := callbackLoad(data).(*wlSeat)
+	tp, ok := s.touchFoci[id]
+	if !ok {
+		return
+	}
+	w := tp.w
+	tp.pos = f32.Point{
+		X: fromFixed(x) * float32(w.scale),
+		Y: fromFixed(y) * float32(w.scale),
+	}
+	w.ProcessEvent(pointer.Event{
+		Kind:      pointer.Move,
+		Position:  tp.pos,
+		Source:    pointer.Touch,
+		PointerID: pointer.ID(id),
+		Time:      time.Duration(t) * time.Millisecond,
+		Modifiers: w.disp.xkb.Modifiers(),
+	})
+}
+
+//export gio_onTouchFrame
+func gio_onTouchFrame(data unsafe.Pointer, touch *C.struct_wl_touch) {
+}
+
+//export gio_onTouchCancel
+func gio_onTouchCancel(data unsafe.Pointer, touch *C.struct_wl_touch) {
+	s := callbackLoad(data).(*wlSeat)
+	for id, tp := range s.touchFoci {
+		delete(s.touchFoci, id)
+		tp.w.ProcessEvent(pointer.Event{
+			Kind:   pointer.Cancel,
+			Source: pointer.Touch,
+		})
+	}
+}
+
+//export gio_onPointerEnter
+func gio_onPointerEnter(data unsafe.Pointer, pointer *C.struct_wl_pointer, serial C.uint32_t, surf *C.struct_wl_surface, x, y C.wl_fixed_t) {
+	s := callbackLoad(data).(*wlSeat)
+	s.setSerial(serial)
+	s.pointerSerial = serial
+	w := s.disp.window(surf)
+	if w == nil {
+		return
+	}
+	s.pointerFocus = w
+	w.updateCursor()
+	w.lastPos = f32.Point{
+		X: fromFixed(x) * float32(w.scale),
+		Y: fromFixed(y) * float32(w.scale),
+	}
+}
+
+//export gio_onPointerLeave
+func gio_onPointerLeave(data unsafe.Pointer, p *C.struct_wl_pointer, serial C.uint32_t, surf *C.struct_wl_surface) {
+	s := callbackLoad(data).(*wlSeat)
+	s.setSerial(serial)
+	w := s.pointerFocus
+	s.pointerFocus = nil
+	if w == nil {
+		return
+	}
+	if w.pointerBtns != 0 {
+		// The pointer left during a grab, such as an
+		// interactive move.
+		w.pointerBtns = 0
+		w.ProcessEvent(pointer.Event{Kind: pointer.Cancel})
+	}
+}
+
+//export gio_onPointerMotion
+func gio_onPointerMotion(data unsafe.Pointer, p *C.struct_wl_pointer, t C.uint32_t, x, y C.wl_fixed_t) {
+	s := callbackLoad(data).(*wlSeat)
+	w := s.pointerFocus
+	if w == nil {
+		return
+	}
+	w.resetFling()
+	w.onPointerMotion(x, y, t)
+}
+
+//export gio_onPointerButton
+func gio_onPointerButton(data unsafe.Pointer, p *C.struct_wl_pointer, serial, t, wbtn, state C.uint32_t) {
+	s := callbackLoad(data).(*wlSeat)
+	s.setSerial(serial)
+	w := s.pointerFocus
+	if w == nil {
+		return
+	}
+	// From linux-event-codes.h.
+	const (
+		BTN_LEFT   = 0x110
+		BTN_RIGHT  = 0x111
+		BTN_MIDDLE = 0x112
+	)
+	var btn pointer.Buttons
+	switch wbtn {
+	case BTN_LEFT:
+		btn = pointer.ButtonPrimary
+	case BTN_RIGHT:
+		btn = pointer.ButtonSecondary
+	case BTN_MIDDLE:
+		btn = pointer.ButtonTertiary
+	default:
+		return
+	}
+	if state == C.WL_POINTER_BUTTON_STATE_PRESSED && btn == pointer.ButtonPrimary {
+		act, ok := w.w.ActionAt(w.lastPos)
+		if ok && isMoveResize(act) && w.config.Mode == Windowed && w.topLvl != nil {
+			w.moveResize(act, serial)
+			return
+		}
+	}
+	var kind pointer.Kind
+	switch state {
+	case C.WL_POINTER_BUTTON_STATE_RELEASED:
+		w.pointerBtns &^= btn
+		kind = pointer.Release
+	case C.WL_POINTER_BUTTON_STATE_PRESSED:
+		w.pointerBtns |= btn
+		kind = pointer.Press
+	}
+	w.flushScroll()
+	w.resetFling()
+	w.ProcessEvent(pointer.Event{
+		Kind:      kind,
+		Source:    pointer.Mouse,
+		Buttons:   w.pointerBtns,
+		Position:  w.lastPos,
+		Time:      time.Duration(t) * time.Millisecond,
+		Modifiers: w.disp.xkb.Modifiers(),
+	})
+}
+
+//export gio_onPointerAxis
+func gio_onPointerAxis(data unsafe.Pointer, p *C.struct_wl_pointer, t, axis C.uint32_t, value C.wl_fixed_t) {
+	s := callbackLoad(data).(*wlSeat)
+	w := s.pointerFocus
+	if w == nil {
+		return
+	}
+	v := fromFixed(value)
+	w.resetFling()
+	if w.scroll.dist == (f32.Point{}) {
+		w.scroll.time = time.Duration(t) * time.Millisecond
+	}
+	switch axis {
+	case C.WL_POINTER_AXIS_HORIZONTAL_SCROLL:
+		w.scroll.dist.X += v
+	case C.WL_POINTER_AXIS_VERTICAL_SCROLL:
+		// horizontal scroll if shift + mousewheel(up/down) pressed.
+		if w.disp.xkb.Modifiers() == key.ModShift {
+			w.scroll.dist.X += v
+		} else {
+			w.scroll.dist.Y += v
+		}
+	}
+}
+
+//export gio_onPointerFrame
+func gio_onPointerFrame(data unsafe.Pointer, p *C.struct_wl_pointer) {
+	s := callbackLoad(data).(*wlSeat)
+	w := s.pointerFocus
+	if w == nil {
+		return
+	}
+	w.flushScroll()
+	w.flushFling()
+}
+
+func (w *wlWindow) flushFling() {
+	if !w.fling.start {
+		return
+	}
+	w.fling.start = false
+	estx, esty := w.fling.xExtrapolation.Estimate(), w.fling.yExtrapolation.Estimate()
+	w.fling.xExtrapolation = fling.Extrapolation{}
+	w.fling.yExtrapolation = fling.Extrapolation{}
+	vel := float32(math.Sqrt(float64(estx.Velocity*estx.Velocity + esty.Velocity*esty.Velocity)))
+	_, c := w.getConfig()
+	if !w.fling.anim.Start(c, time.Now(), vel) {
+		return
+	}
+	invDist := 1 / vel
+	w.fling.dir.X = estx.Velocity * invDist
+	w.fling.dir.Y = esty.Velocity * invDist
+	// Wake up the window loop.
+	w.disp.wakeup()
+}
+
+//export gio_onPointerAxisSource
+func gio_onPointerAxisSource(data unsafe.Pointer, pointer *C.struct_wl_pointer, source C.uint32_t) {
+}
+
+//export gio_onPointerAxisStop
+func gio_onPointerAxisStop(data unsafe.Pointer, p *C.struct_wl_pointer, t, axis C.uint32_t) {
+	s := callbackLoad(data).(*wlSeat)
+	w := s.pointerFocus
+	if w == nil {
+		return
+	}
+	w.fling.start = true
+}
+
+//export gio_onPointerAxisDiscrete
+func gio_onPointerAxisDiscrete(data unsafe.Pointer, p *C.struct_wl_pointer, axis C.uint32_t, discrete C.int32_t) {
+	s := callbackLoad(data).(*wlSeat)
+	w := s.pointerFocus
+	if w == nil {
+		return
+	}
+	w.resetFling()
+	switch axis {
+	case C.WL_POINTER_AXIS_HORIZONTAL_SCROLL:
+		w.scroll.steps.X += int(discrete)
+	case C.WL_POINTER_AXIS_VERTICAL_SCROLL:
+		// horizontal scroll if shift + mousewheel(up/down) pressed.
+		if w.disp.xkb.Modifiers() == key.ModShift {
+			w.scroll.steps.X += int(discrete)
+		} else {
+			w.scroll.steps.Y += int(discrete)
+		}
+	}
+}
+
+func (w *wlWindow) ReadClipboard(kind clipboard.Kind, mimes []string) {
+	for _, mime := range mimes {
+		var r io.ReadCloser
+		var err error
+		switch kind {
+		case clipboard.Clipboard:
+			r, err = w.disp.readClipboard(mime)
+		case clipboard.Primary:
+			r, err = w.disp.readPrimary(mime)
+		}
+		// Send empty responses on unavailable clipboards or errors.
+		if r == nil || err != nil {
+			w.ProcessEvent(input.ClipboardEvent{
+				Kind: kind,
+				DataEvent: transfer.DataEvent{
+					Type: mime,
+					Open: func() io.ReadCloser {
+						return io.NopCloser(strings.NewReader(""))
+					},
+				},
+			})
+			continue
+		}
+		// Don't let slow clipboard transfers block event loop.
+		go func() {
+			defer r.Close()
+			data, _ := io.ReadAll(r)
+			e := input.ClipboardEvent{
+				Kind: kind,
+				DataEvent: transfer.DataEvent{
+					Type: mime,
+					Open: func() io.ReadCloser {
+						return io.NopCloser(bytes.NewReader(data))
+					},
+				},
+			}
+			w.clipReads <- e
+			w.disp.wakeup()
+		}()
+	}
+}
+
+func (w *wlWindow) WriteClipboard(kind clipboard.Kind, content []input.ClipboardData) {
+	switch kind {
+	case clipboard.Clipboard:
+		w.disp.writeClipboard(content)
+	case clipboard.Primary:
+		w.disp.writePrimary(content)
+	}
+}
+
+func (w *wlWindow) ExportData(mime string, data io.ReadCloser) {
+	// Drags to other clients are not supported.
+	data.Close()
+}
+
+// SetURL is a no-op, because windows have no address.
+func (w *wlWindow) SetURL(u *url.URL, replace bool) {}
+
+func (w *wlWindow) Configure(options []Option) {
+	_, cfg := w.getConfig()
+	prev := w.config
+	cnf := w.config
+	cnf.apply(cfg, options)
+	w.config.decoHeight = cnf.decoHeight
+
+	switch {
+	case w.popup != nil:
+		w.configurePopup(prev, cnf)
+	case cnf.Mode == Fullscreen:
+		switch prev.Mode {
+		case Minimized, Fullscreen:
+		default:
+			w.config.Mode = Fullscreen
+			w.wsize = w.size
+			C.xdg_toplevel_set_fullscreen(w.topLvl, nil)
+		}
+	case cnf.Mode == Minimized:
+		w.config.Mode = Minimized
+		C.xdg_toplevel_set_minimized(w.topLvl)
+	case cnf.Mode == Maximized:
+		switch prev.Mode {
+		case Minimized, Maximized:
+		default:
+			w.config.Mode = Maximized
+			w.wsize = w.size
+			C.xdg_toplevel_set_maximized(w.topLvl)
+			w.setTitle(prev, cnf)
+		}
+	case cnf.Mode == Windowed:
+		switch prev.Mode {
+		case Fullscreen:
+			w.config.Mode = Windowed
+			w.size = w.wsize
+			C.xdg_toplevel_unset_fullscreen(w.topLvl)
+		case Minimized:
+			w.config.Mode = Windowed
+		case Maximized:
+			w.config.Mode = Windowed
+			w.size = w.wsize
+			C.xdg_toplevel_unset_maximized(w.topLvl)
+		}
+		w.setTitle(prev, cnf)
+		if prev.Size != cnf.Size {
+			w.config.Size = cnf.Size
+			w.size = cnf.Size.Div(w.scale)
+			w.size.Y += w.decoHeight()
+		}
+		w.config.MinSize = cnf.MinSize
+		w.config.MaxSize = cnf.MaxSize
+		w.setWindowConstraints()
+	}
+	if w.decor != nil {
+		mode := C.uint32_t(C.ZXDG_TOPLEVEL_DECORATION_V1_MODE_CLIENT_SIDE)
+		if cnf.Decorated {
+			mode = C.ZXDG_TOPLEVEL_DECORATION_V1_MODE_SERVER_SIDE
+		}
+		C.zxdg_toplevel_decoration_v1_set_mode(w.decor, mode)
+	}
+	// Wayland compositors take the icon from the desktop entry.
+	w.config.Icon = cnf.Icon
+	w.config.Size, _ = w.getConfig()
+	w.redraw = true
+	w.ProcessEvent(ConfigEvent{Config: w.config})
+}
+
+func (w *wlWindow) setWindowConstraints() {
+	if w.topLvl == nil {
+		return
+	}
+	decoHeight := w.decoHeight()
+	if scaled := w.config.MinSize.Div(w.scale); scaled != (image.Point{}) {
+		C.xdg_toplevel_set_min_size(w.topLvl, C.int32_t(scaled.X), C.int32_t(scaled.Y+decoHeight))
+	}
+	if scaled := w.config.MaxSize.Div(w.scale); scaled != (image.Point{}) {
+		C.xdg_toplevel_set_max_size(w.topLvl, C.int32_t(scaled.X), C.int32_t(scaled.Y+decoHeight))
+	}
+}
+
+// decoHeight returns the adjustment for client-side decorations, if applicable.
+// The unit is in surface-local coordinates.
+func (w *wlWindow) decoHeight() int {
+	if w.config.Decorated {
+		return 0
+	}
+	return w.fallbackDecoHeight()
+}
+
+// fallbackDecoHeight returns the height of the client-side decorations in
+// surface-local coordinates.
+func (w *wlWindow) fallbackDecoHeight() int {
+	return int(math.Round(float64(w.ppdp * float32(w.config.decoHeight))))
+}
+
+func (w *wlWindow) setTitle(prev, cnf Config) {
+	if prev.Title != cnf.Title {
+		w.config.Title = cnf.Title
+		title := C.CString(cnf.Title)
+		C.xdg_toplevel_set_title(w.topLvl, title)
+		C.free(unsafe.Pointer(title))
+	}
+}
+
+func (w *wlWindow) Perform(actions system.Action) {
+	// NB. there is no way for a minimized window to be unminimized.
+	// https://wayland.app/protocols/xdg-shell#xdg_toplevel:request:set_minimized
+	walkActions(actions, func(action system.Action) {
+		if isMoveResize(action) {
+			if s := w.disp.seat; s != nil {
+				w.moveResize(action, s.serial)
+			}
+		}
+	})
+	if actions&system.ActionClose != 0 {
+		w.closing = true
+		// Wake up the window loop to notice the close request.
+		w.disp.wakeup()
+	}
+}
+
+// moveResize starts an interactive move or resize of the window, directed
+// by the pointer.
+func (w *wlWindow) moveResize(act system.Action, serial C.uint32_t) {
+	s := w.disp.seat
+	if s == nil || w.topLvl == nil {
+		return
+	}
+	if act == system.ActionMove {
+		C.xdg_toplevel_move(w.topLvl, s.seat, serial)
+	} else {
+		var edge C.uint32_t
+		switch act {
+		case system.ActionResizeNorth:
+			edge = C.XDG_TOPLEVEL_RESIZE_EDGE_TOP
+		case system.ActionResizeSouth:
+			edge = C.XDG_TOPLEVEL_RESIZE_EDGE_BOTTOM
+		case system.ActionResizeWest:
+			edge = C.XDG_TOPLEVEL_RESIZE_EDGE_LEFT
+		case system.ActionResizeEast:
+			edge = C.XDG_TOPLEVEL_RESIZE_EDGE_RIGHT
+		case system.ActionResizeNorthWest:
+			edge = C.XDG_TOPLEVEL_RESIZE_EDGE_TOP_LEFT
+		case system.ActionResizeNorthEast:
+			edge = C.XDG_TOPLEVEL_RESIZE_EDGE_TOP_RIGHT
+		case system.ActionResizeSouthWest:
+			edge = C.XDG_TOPLEVEL_RESIZE_EDGE_BOTTOM_LEFT
+		case system.ActionResizeSouthEast:
+			edge = C.XDG_TOPLEVEL_RESIZE_EDGE_BOTTOM_RIGHT
+		}
+		C.xdg_toplevel_resize(w.topLvl, s.seat, serial, edge)
+	}
+	// The compositor grabs the pointer; release the buttons.
+	w.pointerBtns = 0
+	w.ProcessEvent(pointer.Event{Kind: pointer.Cancel})
+}
+
+func (w *wlWindow) SetCursor(cursor pointer.Cursor) {
+	w.cursor.current = cursor
+	w.updateCursor()
+}
+
+// SetImageCursor shows the default cursor; image cursors are not
+// supported.
+func (w *wlWindow) SetImageCursor(cursor *pointer.ImageCursor) {
+	w.SetCursor(pointer.CursorDefault)
+}
+
+func (w *wlWindow) updateCursor() {
+	s := w.disp.seat
+	if s == nil || s.pointer == nil || s.pointerFocus != w {
+		return
+	}
+	cursor := w.cursor.current
+	if cursor == pointer.CursorNone {
+		C.wl_pointer_set_cursor(s.pointer, s.pointerSerial, nil, 0, 0)
+		return
+	}
+	if s.shape != nil {
+		shape := C.uint32_t(C.WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_DEFAULT)
+		if int(cursor) < len(wlCursorShape) && wlCursorShape[cursor] != 0 {
+			shape = wlCursorShape[cursor]
+		}
+		C.wp_cursor_shape_device_v1_set_shape(s.shape, s.pointerSerial, shape)
+		return
+	}
+	if err := w.loadCursorTheme(); err != nil {
+		return
+	}
+	cname := C.CString(xCursor[cursor])
+	defer C.free(unsafe.Pointer(cname))
+	c := C.wl_cursor_theme_get_cursor(w.cursor.theme, cname)
+	if c == nil {
+		cname := C.CString(xCursor[pointer.CursorDefault])
+		defer C.free(unsafe.Pointer(cname))
+		c = C.wl_cursor_theme_get_cursor(w.cursor.theme, cname)
+		if c == nil {
+			return
+		}
+	}
+	img := *c.images
+	buf := C.wl_cursor_image_get_buffer(img)
+	if buf == nil {
+		return
+	}
+	C.wl_pointer_set_cursor(s.pointer, s.pointerSerial, w.cursor.surf,
+		C.int32_t(img.hotspot_x/C.uint32_t(w.scale)), C.int32_t(img.hotspot_y/C.uint32_t(w.scale)))
+	C.wl_surface_attach(w.cursor.surf, buf, 0, 0)
+	C.wl_surface_damage(w.cursor.surf, 0, 0, C.int32_t(img.width), C.int32_t(img.height))
+	C.wl_surface_commit(w.cursor.surf)
+}
+
+// loadCursorTheme loads the XCURSOR_THEME cursor theme used when the
+// compositor doesn't support cursor-shape-v1.
+func (w *wlWindow) loadCursorTheme() error {
+	if w.cursor.theme != nil {
+		return nil
+	}
+	cursorTheme := C.CString(os.Getenv("XCURSOR_THEME"))
+	defer C.free(unsafe.Pointer(cursorTheme))
+	cursorSize := 32
+	if envSize, ok := os.LookupEnv("XCURSOR_SIZE"); ok && envSize != "" {
+		size, err := strconv.Atoi(envSize)
+		if err == nil {
+			cursorSize = size
+		}
+	}
+	w.cursor.theme = C.wl_cursor_theme_load(cursorTheme, C.int(cursorSize*w.scale), w.disp.shm)
+	if w.cursor.theme == nil {
+		return errors.New("wayland: wl_cursor_theme_load failed")
+	}
+	w.cursor.surf = C.wl_compositor_create_surface(w.disp.compositor)
+	if w.cursor.surf == nil {
+		C.wl_cursor_theme_destroy(w.cursor.theme)
+		w.cursor.theme = nil
+		return errors.New("wayland: wl_compositor_create_surface failed")
+	}
+	C.wl_surface_set_buffer_scale(w.cursor.surf, C.int32_t(w.scale))
+	return nil
+}
+
+func (w *wlWindow) resetFling() {
+	w.fling.start = false
+	w.fling.anim = fling.Animation{}
+}
+
+//export gio_onKeyboardKeymap
+func gio_onKeyboardKeymap(data unsafe.Pointer, keyboard *C.struct_wl_keyboard, format C.uint32_t, fd C.int32_t, size C.uint32_t) {
+	defer syscall.Close(int(fd))
+	s := callbackLoad(data).(*wlSeat)
+	s.disp.repeat.Stop(0)
+	s.disp.xkb.DestroyKeymapState()
+	if format != C.WL_KEYBOARD_KEYMAP_FORMAT_XKB_V1 {
+		return
+	}
+	if err := s.disp.xkb.LoadKeymap(int(format), int(fd), int(size)); err != nil {
+		// Carry on without key translation; the xkb state stays
+		// unset until the compositor sends a usable keymap.
+		log.Printf("wayland: keymap: %v", err)
+	}
+}
+
+//export gio_onKeyboardEnter
+func gio_onKeyboardEnter(data unsafe.Pointer, keyboard *C.struct_wl_keyboard, serial C.uint32_t, surf *C.struct_wl_surface, keys *C.struct_wl_array) {
+	s := callbackLoad(data).(*wlSeat)
+	s.setSerial(serial)
+	w := s.disp.window(surf)
+	if w == nil {
+		return
+	}
+	s.keyboardFocus = w
+	s.disp.repeat.Stop(0)
+	w.config.Focused = true
+	w.ProcessEvent(ConfigEvent{Config: w.config})
+}
+
+//export gio_onKeyboardLeave
+func gio_onKeyboardLeave(data unsafe.Pointer, keyboard *C.struct_wl_keyboard, serial C.uint32_t, surf *C.struct_wl_surface) {
+	s := callbackLoad(data).(*wlSeat)
+	s.setSerial(serial)
+	s.disp.repeat.Stop(0)
+	w := s.keyboardFocus
+	s.keyboardFocus = nil
+	if w == nil {
+		return
+	}
+	w.config.Focused = false
+	w.ProcessEvent(ConfigEvent{Config: w.config})
+}
+
+//export gio_onKeyboardKey
+func gio_onKeyboardKey(data unsafe.Pointer, keyboard *C.struct_wl_keyboard, serial, timestamp, keyCode, state C.uint32_t) {
+	s := callbackLoad(data).(*wlSeat)
+	s.setSerial(serial)
+	w := s.keyboardFocus
+	if w == nil {
+		return
+	}
+	t := time.Duration(timestamp) * time.Millisecond
+	s.disp.repeat.Stop(t)
+	w.resetFling()
+	kc := mapXKBKeycode(uint32(keyCode))
+	ks := mapXKBKeyState(uint32(state))
+	for _, e := range w.disp.xkb.DispatchKey(kc, ks) {
+		if ee, ok := e.(key.EditEvent); ok {
+			w.w.EditorInsert(ee.Text)
+		} else {
+			w.ProcessEvent(e)
+		}
+	}
+	if state != C.WL_KEYBOARD_KEY_STATE_PRESSED {
+		return
+	}
+	if w.disp.xkb.IsRepeatKey(kc) {
+		w.disp.repeat.Start(w, kc, t)
+	}
+}
+
+func mapXKBKeycode(keyCode uint32) uint32 {
+	// According to the xkb_v1 spec: "to determine the xkb keycode, clients must add 8 to the key event keycode."
+	return keyCode + 8
+}
+
+func mapXKBKeyState(state uint32) key.State {
+	switch state {
+	case C.WL_KEYBOARD_KEY_STATE_RELEASED:
+		return key.Release
+	default:
+		return key.Press
+	}
+}
+
+func (r *repeatState) Start(w *wlWindow, keyCode uint32, t time.Duration) {
+	if r.rate <= 0 {
+		return
+	}
+	stopC := make(chan struct{})
+	r.start = t
+	r.last = 0
+	r.now = 0
+	r.stopC = stopC
+	r.key = keyCode
+	r.win = w
+	rate, delay := r.rate, r.delay
+	go func() {
+		timer := time.NewTimer(delay)
+		for {
+			select {
+			case <-timer.C:
+			case <-stopC:
+				close(stopC)
+				return
+			}
+			r.Advance(delay)
+			w.disp.wakeup()
+			delay = time.Second / time.Duration(rate)
+			timer.Reset(delay)
+		}
+	}()
+}
+
+func (r *repeatState) Stop(t time.Duration) {
+	if r.stopC == nil {
+		return
+	}
+	r.stopC <- struct{}{}
+	<-r.stopC
+	r.stopC = nil
+	t -= r.start
+	if r.now > t {
+		r.now = t
+	}
+}
+
+func (r *repeatState) Advance(dt time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.now += dt
+}
+
+func (r *repeatState) Repeat(d *wlDisplay) {
+	if r.rate <= 0 || r.stopC == nil {
+		return
+	}
+	r.mu.Lock()
+	now := r.now
+	r.mu.Unlock()
+	for {
+		var delay time.Duration
+		if r.last < r.delay {
+			delay = r.delay
+		} else {
+			delay = time.Second / time.Duration(r.rate)
+		}
+		if r.last+delay > now {
+			break
+		}
+		for _, e := range d.xkb.DispatchKey(r.key, key.Press) {
+			if ee, ok := e.(key.EditEvent); ok {
+				r.win.w.EditorInsert(ee.Text)
+			} else {
+				r.win.ProcessEvent(e)
+			}
+		}
+		r.last += delay
+	}
+}
+
+//export gio_onFrameDone
+func gio_onFrameDone(data unsafe.Pointer, callback *C.struct_wl_callback, t C.uint32_t) {
+	C.wl_callback_destroy(callback)
+	w := callbackLoad(data).(*wlWindow)
+	if w.lastFrameCallback == callback {
+		w.lastFrameCallback = nil
+		w.setStage(StageRunning)
+		w.draw(false)
+	}
+}
+
+//export gio_onKeyboardModifiers
+func gio_onKeyboardModifiers(data unsafe.Pointer, keyboard *C.struct_wl_keyboard, serial, depressed, latched, locked, group C.uint32_t) {
+	s := callbackLoad(data).(*wlSeat)
+	s.setSerial(serial)
+	d := s.disp
+	d.repeat.Stop(0)
+	if d.xkb == nil {
+		return
+	}
+	d.xkb.UpdateMask(uint32(depressed), uint32(latched), uint32(locked), uint32(group), uint32(group), uint32(group))
+}
+
+//export gio_onKeyboardRepeatInfo
+func gio_onKeyboardRepeatInfo(data unsafe.Pointer, keyboard *C.struct_wl_keyboard, rate, delay C.int32_t) {
+	s := callbackLoad(data).(*wlSeat)
+	d := s.disp
+	d.repeat.Stop(0)
+	d.repeat.rate = int(rate)
+	d.repeat.delay = time.Duration(delay) * time.Millisecond
+}
+
+//export gio_onTextInputEnter
+func gio_onTextInputEnter(data unsafe.Pointer, im *C.struct_zwp_text_input_v3, surf *C.struct_wl_surface) {
+	s := callbackLoad(data).(*wlSeat)
+	w := s.disp.window(surf)
+	if w == nil {
+		return
+	}
+	s.ime.focus = w
+	s.ime.enabled = false
+	w.updateTextInput()
+}
+
+//export gio_onTextInputLeave
+func gio_onTextInputLeave(data unsafe.Pointer, im *C.struct_zwp_text_input_v3, surf *C.struct_wl_surface) {
+	s := callbackLoad(data).(*wlSeat)
+	if s.ime.enabled {
+		C.zwp_text_input_v3_disable(s.im)
+		C.zwp_text_input_v3_commit(s.im)
+		s.ime.enabled = false
+	}
+	if w := s.ime.focus; w != nil {
+		// Commit the pending composition, if any.
+		w.w.SetComposingRegion(key.Range{Start: -1, End: -1})
+	}
+	s.ime.focus = nil
+	s.ime.pending = wlTextInputState{}
+}
+
+//export gio_onTextInputPreeditString
+func gio_onTextInputPreeditString(data unsafe.Pointer, im *C.struct_zwp_text_input_v3, ctxt *C.char, begin, end C.int32_t) {
+	s := callbackLoad(data).(*wlSeat)
+	p := &s.ime.pending
+	p.preedit = ""
+	if ctxt != nil {
+		p.preedit = C.GoString(ctxt)
+	}
+	p.preeditBegin, p.preeditEnd = int(begin), int(end)
+}
+
+//export gio_onTextInputCommitString
+func gio_onTextInputCommitString(data unsafe.Pointer, im *C.struct_zwp_text_input_v3, ctxt *C.char) {
+	s := callbackLoad(data).(*wlSeat)
+	s.ime.pending.commit = ""
+	if ctxt != nil {
+		s.ime.pending.commit = C.GoString(ctxt)
+	}
+}
+
+//export gio_onTextInputDeleteSurroundingText
+func gio_onTextInputDeleteSurroundingText(data unsafe.Pointer, im *C.struct_zwp_text_input_v3, before, after C.uint32_t) {
+	s := callbackLoad(data).(*wlSeat)
+	s.ime.pending.deleteBefore = int(before)
+	s.ime.pending.deleteAfter = int(after)
+}
+
+//export gio_onTextInputDone
+func gio_onTextInputDone(data unsafe.Pointer, im *C.struct_zwp_text_input_v3, serial C.uint32_t) {
+	s := callbackLoad(data).(*wlSeat)
+	p := s.ime.pending
+	s.ime.pending = wlTextInputState{}
+	w := s.ime.focus
+	if w == nil || !s.ime.enabled {
+		return
+	}
+	w.applyTextInput(p)
+}
+
+// applyTextInput applies input method changes to the editor in the order
+// mandated by the text-input-v3 protocol.
+func (w *wlWindow) applyTextInput(p wlTextInputState) {
+	w.textInput.applying = true
+	defer func() { w.textInput.applying = false }()
+	// Replace the existing pre-edit text with the cursor.
+	if c := w.w.EditorState().compose; c.Start != -1 {
+		w.w.SetComposingRegion(key.Range{Start: -1, End: -1})
+		w.w.EditorReplace(c, "")
+	}
+	// Delete the requested surrounding text.
+	if p.deleteBefore > 0 || p.deleteAfter > 0 {
+		st := w.w.EditorState()
+		sel := st.Selection.Range
+		start, end := min(sel.Start, sel.End), max(sel.Start, sel.End)
+		if p.deleteAfter > 0 {
+			n := snippetRunesAfter(st.Snippet, end, p.deleteAfter)
+			w.w.EditorReplace(key.Range{Start: end, End: end + n}, "")
+		}
+		if p.deleteBefore > 0 {
+			n := snippetRunesBefore(st.Snippet, start, p.deleteBefore)
+			w.w.EditorReplace(key.Range{Start: start - n, End: start}, "")
+		}
+	}
+	// Insert the commit string with the cursor at its end.
+	if p.commit != "" {
+		w.w.EditorInsert(p.commit)
+	}
+	// Insert the new pre-edit text at the cursor.
+	if p.preedit != "" {
+		sel := w.w.EditorState().Selection.Range
+		start := min(sel.Start, sel.End)
+		w.w.EditorReplace(sel, p.preedit)
+		n := utf8.RuneCountInString(p.preedit)
+		w.w.SetComposingRegion(key.Range{Start: start, End: start + n})
+		// A negative cursor means the cursor should be hidden; place it
+		// at the end of the pre-edit.
+		begin, end := n, n
+		if p.preeditBegin >= 0 && p.preeditEnd >= 0 {
+			begin = utf8.RuneCountInString(p.preedit[:min(p.preeditBegin, len(p.preedit))])
+			end = utf8.RuneCountInString(p.preedit[:min(p.preeditEnd, len(p.preedit))])
+		}
+		w.w.SetEditorSelection(key.Range{Start: start + begin, End: start + end})
+	}
+}
+
+// snippetRunesBefore converts a count of UTF-8 bytes before the rune
+// position pos to a count of runes. Text outside the snippet is assumed
+// to be one byte per rune.
+func snippetRunesBefore(s key.Snippet, pos, n int) int {
+	runes := 0
+	if pos > s.Start && pos <= s.End {
+		text := []rune(s.Text)[:pos-s.Start]
+		for i := len(text) - 1; i >= 0 && n > 0; i-- {
+			n -= utf8.RuneLen(text[i])
+			runes++
+		}
+	}
+	return min(runes+max(n, 0), pos)
+}
+
+// snippetRunesAfter converts a count of UTF-8 bytes after the rune
+// position pos to a count of runes. Text outside the snippet is assumed
+// to be one byte per rune.
+func snippetRunesAfter(s key.Snippet, pos, n int) int {
+	runes := 0
+	if pos >= s.Start && pos < s.End {
+		for _, r := range []rune(s.Text)[pos-s.Start:] {
+			if n <= 0 {
+				break
+			}
+			n -= utf8.RuneLen(r)
+			runes++
+		}
+	}
+	return runes + max(n, 0)
+}
+
+// updateTextInput enables or disables the input method according to
+// the most recent ShowTextInput, and sends the editor state when enabled.
+func (w *wlWindow) updateTextInput() {
+	s := w.disp.seat
+	if s == nil || s.im == nil || s.ime.focus != w {
+		return
+	}
+	enable := w.textInput.show
+	switch {
+	case enable && !s.ime.enabled:
+		C.zwp_text_input_v3_enable(s.im)
+	case !enable && s.ime.enabled:
+		C.zwp_text_input_v3_disable(s.im)
+	case !enable:
+		return
+	}
+	s.ime.enabled = enable
+	if enable {
+		hint, purpose := wlContentType(w.textInput.hint)
+		C.zwp_text_input_v3_set_content_type(s.im, hint, purpose)
+		w.sendEditorState(w.w.EditorState())
+	}
+	C.zwp_text_input_v3_commit(s.im)
+}
+
+// sendEditorState sends the surrounding text and caret position to the
+// input method. The caller must commit the state.
+func (w *wlWindow) sendEditorState(st editorState) {
+	s := w.disp.seat
+	sn := st.Snippet
+	sel := st.Selection.Range
+	// The protocol limits the surrounding text to 4000 bytes.
+	const maxSurrounding = 4000
+	if len(sn.Text) < maxSurrounding && sn.Start <= min(sel.Start, sel.End) && max(sel.Start, sel.End) <= sn.End {
+		runes := []rune(sn.Text)
+		cursor := len(string(runes[:sel.End-sn.Start]))
+		anchor := len(string(runes[:sel.Start-sn.Start]))
+		ctext := C.CString(sn.Text)
+		C.zwp_text_input_v3_set_surrounding_text(s.im, ctext, C.int32_t(cursor), C.int32_t(anchor))
+		C.free(unsafe.Pointer(ctext))
+		cause := C.uint32_t(C.ZWP_TEXT_INPUT_V3_CHANGE_CAUSE_OTHER)
+		if w.textInput.applying {
+			cause = C.ZWP_TEXT_INPUT_V3_CHANGE_CAUSE_INPUT_METHOD
+		}
+		C.zwp_text_input_v3_set_text_change_cause(s.im, cause)
+	}
+	caret := st.Selection.Caret
+	t := st.Selection.Transform
+	top := t.Transform(caret.Pos.Sub(f32.Pt(0, caret.Ascent)))
+	bottom := t.Transform(caret.Pos.Add(f32.Pt(0, caret.Descent)))
+	scale := float32(w.scale)
+	x0, y0 := min(top.X, bottom.X)/scale, min(top.Y, bottom.Y)/scale
+	x1, y1 := max(top.X, bottom.X)/scale, max(top.Y, bottom.Y)/scale
+	C.zwp_text_input_v3_set_cursor_rectangle(s.im, C.int32_t(x0), C.int32_t(y0),
+		C.int32_t(max(x1-x0, 1)), C.int32_t(max(y1-y0, 1)))
+}
+
+// wlContentType maps an input hint to a text-input-v3 content
+// hint and purpose.
+func wlContentType(mode key.InputHint) (hint, purpose C.uint32_t) {
+	hint = C.ZWP_TEXT_INPUT_V3_CONTENT_HINT_NONE
+	purpose = C.ZWP_TEXT_INPUT_V3_CONTENT_PURPOSE_NORMAL
+	switch mode {
+	case key.HintText:
+		hint = C.ZWP_TEXT_INPUT_V3_CONTENT_HINT_COMPLETION | C.ZWP_TEXT_INPUT_V3_CONTENT_HINT_SPELLCHECK
+	case key.HintNumeric:
+		purpose = C.ZWP_TEXT_INPUT_V3_CONTENT_PURPOSE_NUMBER
+	case key.HintEmail:
+		purpose = C.ZWP_TEXT_INPUT_V3_CONTENT_PURPOSE_EMAIL
+	case key.HintURL:
+		purpose = C.ZWP_TEXT_INPUT_V3_CONTENT_PURPOSE_URL
+	case key.HintTelephone:
+		purpose = C.ZWP_TEXT_INPUT_V3_CONTENT_PURPOSE_PHONE
+	case key.HintPassword:
+		hint = C.ZWP_TEXT_INPUT_V3_CONTENT_HINT_HIDDEN_TEXT | C.ZWP_TEXT_INPUT_V3_CONTENT_HINT_SENSITIVE_DATA
+		purpose = C.ZWP_TEXT_INPUT_V3_CONTENT_PURPOSE_PASSWORD
+	}
+	return hint, purpose
+}
+
+func (w *wlWindow) flushScroll() {
+	var fling f32.Point
+	if w.fling.anim.Active() {
+		dist := float32(w.fling.anim.Tick(time.Now()))
+		fling = w.fling.dir.Mul(dist)
+	}
+	// The Wayland reported scroll distance for
+	// discrete scroll axes is only 10 pixels, where
+	// 100 seems more appropriate.
+	const discreteScale = 10
+	if w.scroll.steps.X != 0 {
+		w.scroll.dist.X *= discreteScale
+	}
+	if w.scroll.steps.Y != 0 {
+		w.scroll.dist.Y *= discreteScale
+	}
+	total := w.scroll.dist.Add(fling)
+	if total == (f32.Point{}) {
+		return
+	}
+	if w.scroll.steps == (image.Point{}) {
+		w.fling.xExtrapolation.SampleDelta(w.scroll.time, -w.scroll.dist.X)
+		w.fling.yExtrapolation.SampleDelta(w.scroll.time, -w.scroll.dist.Y)
+	}
+	// Zero scroll distance prior to calling ProcessEvent, otherwise we may recursively
+	// re-process the scroll distance.
+	w.scroll.dist = f32.Point{}
+	w.scroll.steps = image.Point{}
+	w.ProcessEvent(pointer.Event{
+		Kind:      pointer.Scroll,
+		Source:    pointer.Mouse,
+		Buttons:   w.pointerBtns,
+		Position:  w.lastPos,
+		Scroll:    total,
+		Time:      w.scroll.time,
+		Modifiers: w.disp.xkb.Modifiers(),
+	})
+}
+
+func (w *wlWindow) onPointerMotion(x, y C.wl_fixed_t, t C.uint32_t) {
+	w.flushScroll()
+	w.lastPos = f32.Point{
+		X: fromFixed(x) * float32(w.scale),
+		Y: fromFixed(y) * float32(w.scale),
+	}
+	w.ProcessEvent(pointer.Event{
+		Kind:      pointer.Move,
+		Position:  w.lastPos,
+		Buttons:   w.pointerBtns,
+		Source:    pointer.Mouse,
+		Time:      time.Duration(t) * time.Millisecond,
+		Modifiers: w.disp.xkb.Modifiers(),
+	})
+}
+
+func (w *wlWindow) updateOutputs() {
+	scale := 1
+	var found bool
+	for _, conf := range w.disp.outputConfig {
+		for _, w2 := range conf.windows {
+			if w2 == w {
+				found = true
+				if conf.scale > scale {
+					scale = conf.scale
+				}
+			}
+		}
+	}
+	if found && scale != w.scale {
+		w.scale = scale
+		C.wl_surface_set_buffer_scale(w.surf, C.int32_t(w.scale))
+		w.disp.share(w)
+		if w.cursor.theme != nil {
+			// Reload the cursor theme at the new scale.
+			C.wl_cursor_theme_destroy(w.cursor.theme)
+			C.wl_surface_destroy(w.cursor.surf)
+			w.cursor.theme = nil
+			w.cursor.surf = nil
+			w.updateCursor()
+		}
+		w.redraw = true
+	}
+}
+
+func (w *wlWindow) getConfig() (image.Point, unit.Metric) {
+	size := w.size.Mul(w.scale)
+	return size, unit.Metric{
+		PxPerDp: w.ppdp * float32(w.scale),
+		PxPerSp: w.ppsp * float32(w.scale),
+	}
+}
+
+func (w *wlWindow) draw(sync bool) {
+	if !w.configured {
+		return
+	}
+	w.flushScroll()
+	size, cfg := w.getConfig()
+	if cfg == (unit.Metric{}) {
+		return
+	}
+	if size != w.config.Size {
+		w.config.Size = size
+		w.ProcessEvent(ConfigEvent{Config: w.config})
+	}
+	if size.X == 0 || size.Y == 0 {
+		return
+	}
+	anim := w.animating || w.fling.anim.Active()
+	// Draw animation only when not waiting for frame callback.
+	redrawAnim := anim && w.lastFrameCallback == nil
+	if !redrawAnim && !sync {
+		return
+	}
+	if anim {
+		w.frameRequested = time.Now()
+		w.lastFrameCallback = C.wl_surface_frame(w.surf)
+		// Use the surface as listener data for gio_onFrameDone.
+		C.wl_callback_add_listener(w.lastFrameCallback, &C.gio_callback_listener, unsafe.Pointer(w.surf))
+	}
+	w.ProcessEvent(frameEvent{
+		FrameEvent: FrameEvent{
+			Now:    time.Now(),
+			Size:   w.config.Size,
+			Metric: cfg,
+		},
+		Sync: sync,
+	})
+}
+
+func (w *wlWindow) display() *C.struct_wl_display {
+	return w.disp.disp
+}
+
+func (w *wlWindow) surface() (*C.struct_wl_surface, int, int) {
+	sz, _ := w.getConfig()
+	return w.surf, sz.X, sz.Y
+}
+
+func (w *wlWindow) ShowTextInput(show bool) {
+	w.textInput.show = show
+	w.updateTextInput()
+}
+
+func (w *wlWindow) SetInputHint(mode key.InputHint) {
+	w.textInput.hint = mode
+	w.updateTextInput()
+}
+
+func (w *wlWindow) EditorStateChanged(old, new editorState) {
+	s := w.disp.seat
+	if s == nil || s.im == nil || s.ime.focus != w || !s.ime.enabled {
+		return
+	}
+	if old.Snippet == new.Snippet && old.Selection == new.Selection {
+		return
+	}
+	w.sendEditorState(new)
+	C.zwp_text_input_v3_commit(s.im)
+}
+
+func (w *wlWindow) NewContext() (context, error) {
+	var firstErr error
+	// Transparent windows need an EGL configuration with alpha.
+	if f := newWaylandVulkanContext; f != nil && !w.config.Transparent {
+		c, err := f(w)
+		if err == nil {
+			return c, nil
+		}
+		firstErr = err
+	}
+	if f := newWaylandEGLContext; f != nil {
+		c, err := f(w)
+		if err == nil {
+			return c, nil
+		}
+		firstErr = err
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return nil, errors.New("wayland: no available GPU backends")
+}
+
+func (w *wlWindow) SetAnimating(anim bool) {
+	w.animating = anim
+}
+
+func (w *wlWindow) ProcessEvent(e event.Event) {
+	w.w.ProcessEvent(e)
+}
+
+func (w *wlWindow) Event() event.Event {
+	for {
+		evt, ok := w.w.nextEvent()
+		if !ok {
+			w.dispatch()
+			continue
+		}
+		return evt
+	}
+}
+
+func (w *wlWindow) Run(f func()) {
+	f()
+}
+
+func (w *wlWindow) Frame(frame *op.Ops) {
+	w.w.ProcessFrame(frame, nil)
+	// Wayland hides the position of windows.
+	w.a11y.update(w.config.Title, image.Point{}, w.config.Size, w.config.Focused)
+}
+
+func (w *wlWindow) Invalidate() {
+	select {
+	case w.wakeups <- struct{}{}:
+	default:
+		return
+	}
+	if d := w.disp; d != nil {
+		d.wakeup()
+	}
+}
+
+func (w *wlWindow) dispatch() {
+	if w.disp == nil {
+		// Only Invalidate can wake us up.
+		<-w.wakeups
+		w.w.Invalidate()
+		return
+	}
+	if err := w.disp.dispatch(); err != nil || w.closing {
+		w.ProcessEvent(WaylandViewEvent{})
+		w.ProcessEvent(DestroyEvent{Err: err})
+		w.destroy()
+		w.disp.destroy()
+		w.disp = nil
+		return
+	}
+	select {
+	case e := <-w.clipReads:
+		w.ProcessEvent(e)
+	case <-w.wakeups:
+		w.w.Invalidate()
+	default:
+	}
+	if w.frameTimeout() == 0 {
+		w.setStage(StageHidden)
+	}
+	w.a11y.runActions()
+	w.draw(w.redraw)
+	w.redraw = false
+}
+
+// wlHiddenDelay is how long an animating window waits for its frame
+// callback before it is considered hidden. Compositors don't call back
+// surfaces that are not shown, such as minimized windows. The Wayland
+// protocols in use have no other notion of visibility.
+const wlHiddenDelay = time.Second
+
+// frameTimeout returns the milliseconds until the frame callback of a
+// visible window is overdue, or -1 if no callback is awaited.
+func (w *wlWindow) frameTimeout() int {
+	if w.lastFrameCallback == nil || w.stage != StageRunning {
+		return -1
+	}
+	dt := time.Until(w.frameRequested.Add(wlHiddenDelay))
+	return int(max(0, (dt+time.Millisecond-1)/time.Millisecond))
+}
+
+// setStage reports a change of visibility.
+func (w *wlWindow) setStage(s Stage) {
+	if s == w.stage {
+		return
+	}
+	w.stage = s
+	w.ProcessEvent(StageEvent{Stage: s})
+}
+
+func (w *wlWindow) destroy() {
+	w.a11y.destroy()
+	w.a11y = nil
+	if w.lastFrameCallback != nil {
+		C.wl_callback_destroy(w.lastFrameCallback)
+		w.lastFrameCallback = nil
+	}
+	if w.cursor.surf != nil {
+		C.wl_surface_destroy(w.cursor.surf)
+		w.cursor.surf = nil
+	}
+	if w.cursor.theme != nil {
+		C.wl_cursor_theme_destroy(w.cursor.theme)
+		w.cursor.theme = nil
+	}
+	if w.decor != nil {
+		C.zxdg_toplevel_decoration_v1_destroy(w.decor)
+		w.decor = nil
+	}
+	if w.topLvl != nil {
+		C.xdg_toplevel_destroy(w.topLvl)
+		w.topLvl = nil
+	}
+	if w.popup != nil {
+		C.xdg_popup_destroy(w.popup)
+		w.popup = nil
+	}
+	if w.wmSurf != nil {
+		C.xdg_surface_destroy(w.wmSurf)
+		w.wmSurf = nil
+	}
+	if w.surf != nil {
+		callbackDelete(unsafe.Pointer(w.surf))
+		C.wl_surface_destroy(w.surf)
+		w.surf = nil
+	}
+	if s := w.disp.seat; s != nil {
+		if s.pointerFocus == w {
+			s.pointerFocus = nil
+		}
+		if s.keyboardFocus == w {
+			s.keyboardFocus = nil
+		}
+		if s.ime.focus == w {
+			s.ime.focus = nil
+		}
+	}
+	for _, conf := range w.disp.outputConfig {
+		for i, w2 := range conf.windows {
+			if w2 == w {
+				conf.windows = append(conf.windows[:i], conf.windows[i+1:]...)
+				break
+			}
+		}
+	}
+	if w.disp.repeat.win == w {
+		w.disp.repeat.Stop(0)
+	}
+}
+
+func newWLDisplay() (*wlDisplay, error) {
+	d := &wlDisplay{
+		outputMap:    make(map[C.uint32_t]*C.struct_wl_output),
+		outputConfig: make(map[*C.struct_wl_output]*wlOutput),
+		refs:         1,
+	}
+	pipe := make([]int, 2)
+	if err := syscall.Pipe2(pipe, syscall.O_NONBLOCK|syscall.O_CLOEXEC); err != nil {
+		return nil, fmt.Errorf("wayland: failed to create pipe: %v", err)
+	}
+	d.notify.read = pipe[0]
+	d.notify.write = pipe[1]
+	xkb, err := xkb.New()
+	if err != nil {
+		d.destroy()
+		return nil, fmt.Errorf("wayland: %v", err)
+	}
+	d.xkb = xkb
+	d.disp, err = C.wl_display_connect(nil)
+	if d.disp == nil {
+		d.destroy()
+		return nil, fmt.Errorf("wayland: wl_display_connect failed: %v", err)
+	}
+	callbackMap.Store(unsafe.Pointer(d.disp), d)
+	d.reg = C.wl_display_get_registry(d.disp)
+	if d.reg == nil {
+		d.destroy()
+		return nil, errors.New("wayland: wl_display_get_registry failed")
+	}
+	C.wl_registry_add_listener(d.reg, &C.gio_registry_listener, unsafe.Pointer(d.disp))
+	// Wait for the server to register all its globals to the
+	// registry listener (gio_onRegistryGlobal).
+	C.wl_display_roundtrip(d.disp)
+	// Configuration listeners are added to outputs by gio_onRegistryGlobal.
+	// We need another roundtrip to get the initial output configurations
+	// through the gio_onOutput* callbacks.
+	C.wl_display_roundtrip(d.disp)
+	return d, nil
+}
+
+func (d *wlDisplay) destroy() {
+	d.updateShared(func(st *wlShared) {
+		st.closed = true
+		st.seat = nil
+	})
+	if d.notify.write != 0 {
+		syscall.Close(d.notify.write)
+		d.notify.write = 0
+	}
+	if d.notify.read != 0 {
+		syscall.Close(d.notify.read)
+		d.notify.read = 0
+	}
+	d.repeat.Stop(0)
+	if d.xkb != nil {
+		d.xkb.Destroy()
+		d.xkb = nil
+	}
+	if d.seat != nil {
+		d.seat.destroy()
+		d.seat = nil
+	}
+	if d.parent != nil {
+		d.destroyPopup()
+		return
+	}
+	d.release()
+}
+
+// root returns the display that owns the connection of d.
+func (d *wlDisplay) root() *wlDisplay {
+	for d.parent != nil {
+		d = d.parent
+	}
+	return d
+}
+
+// acquire adds a reference to the connection of d, and reports false
+// if the connection is closed.
+func (d *wlDisplay) acquire() bool {
+	r := d.root()
+	r.sharedMu.Lock()
+	defer r.sharedMu.Unlock()
+	if r.refs == 0 {
+		return false
+	}
+	r.refs++
+	return true
+}
+
+// release drops a reference to the connection of d, and disconnects
+// when it was the last.
+func (d *wlDisplay) release() {
+	r := d.root()
+	r.sharedMu.Lock()
+	r.refs--
+	last := r.refs == 0
+	r.sharedMu.Unlock()
+	if last {
+		r.disconnect()
+	}
+}
+
+// disconnect destroys the globals of the connection owned by d and
+// disconnects.
+func (d *wlDisplay) disconnect() {
+	if d.imm != nil {
+		C.zwp_text_input_manager_v3_destroy(d.imm)
+		d.imm = nil
+	}
+	if d.decor != nil {
+		C.zxdg_decoration_manager_v1_destroy(d.decor)
+		d.decor = nil
+	}
+	if d.cursorShape != nil {
+		C.wp_cursor_shape_manager_v1_destroy(d.cursorShape)
+		d.cursorShape = nil
+	}
+	if d.shm != nil {
+		C.wl_shm_destroy(d.shm)
+		d.shm = nil
+	}
+	if d.compositor != nil {
+		C.wl_compositor_destroy(d.compositor)
+		d.compositor = nil
+	}
+	if d.wm != nil {
+		C.xdg_wm_base_destroy(d.wm)
+		d.wm = nil
+	}
+	if d.dataDeviceManager != nil {
+		C.wl_data_device_manager_destroy(d.dataDeviceManager)
+		d.dataDeviceManager = nil
+	}
+	d.destroyPrimaryManager()
+	for _, output := range d.outputMap {
+		C.wl_output_destroy(output)
+	}
+	d.outputMap = nil
+	d.outputConfig = nil
+	if d.reg != nil {
+		C.wl_registry_destroy(d.reg)
+		d.reg = nil
+	}
+	if d.disp != nil {
+		C.wl_display_disconnect(d.disp)
+		callbackDelete(unsafe.Pointer(d.disp))
+		d.disp = nil
+	}
+}
+
+// bindDataDevice initializes the dataDev field if and only if both
+// the dataDeviceManager and seat fields are initialized.
+func (d *wlDisplay) bindDataDevice() {
+	if d.dataDeviceManager == nil || d.seat == nil {
+		return
+	}
+	d.seat.dataDev = C.wl_data_device_manager_get_data_device(d.dataDeviceManager, d.seat.seat)
+	if d.seat.dataDev == nil {
+		return
+	}
+	callbackStore(unsafe.Pointer(d.seat.dataDev), d.seat)
+	C.wl_data_device_add_listener(d.seat.dataDev, &C.gio_data_device_listener, unsafe.Pointer(d.seat.dataDev))
+}
+
+func (d *wlDisplay) dispatch() error {
+	// wl_display_prepare_read records the current thread for
+	// use in wl_display_read_events or wl_display_cancel_events.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	// Handle queued events before blocking.
+	for d.prepareRead() != 0 {
+		if err := d.dispatchPending(); err != nil {
+			return err
+		}
+	}
+	dispfd := C.wl_display_get_fd(d.disp)
+	// Poll for events and notifications.
+	pollfds := append(d.poller.pollfds[:0],
+		syscall.PollFd{Fd: int32(dispfd), Events: syscall.POLLIN | syscall.POLLERR},
+		syscall.PollFd{Fd: int32(d.notify.read), Events: syscall.POLLIN | syscall.POLLERR},
+	)
+	dispFd := &pollfds[0]
+	if ret, err := C.wl_display_flush(d.disp); ret < 0 {
+		if err != syscall.EAGAIN {
+			C.wl_display_cancel_read(d.disp)
+			return fmt.Errorf("wayland: wl_display_flush failed: %v", err)
+		}
+		// EAGAIN means the output buffer was full. Poll for
+		// POLLOUT to know when we can write again.
+		dispFd.Events |= syscall.POLLOUT
+	}
+	timeout := -1
+	if w := d.win; w != nil {
+		timeout = w.frameTimeout()
+	}
+	if _, err := syscall.Poll(pollfds, timeout); err != nil && err != syscall.EINTR {
+		C.wl_display_cancel_read(d.disp)
+		return fmt.Errorf("wayland: poll failed: %v", err)
+	}
+	// Clear notifications.
+	for {
+		_, err := syscall.Read(d.notify.read, d.poller.buf[:])
+		if err == syscall.EAGAIN {
+			break
+		}
+		if err != nil {
+			C.wl_display_cancel_read(d.disp)
+			return fmt.Errorf("wayland: read from notify pipe failed: %v", err)
+		}
+	}
+	// Handle events.
+	switch {
+	case dispFd.Revents&syscall.POLLIN != 0:
+		if ret, err := C.wl_display_read_events(d.disp); ret < 0 {
+			return fmt.Errorf("wayland: wl_display_read_events failed: %v", err)
+		}
+	case dispFd.Revents&(syscall.POLLERR|syscall.POLLHUP) != 0:
+		C.wl_display_cancel_read(d.disp)
+		return errors.New("wayland: display file descriptor gone")
+	default:
+		C.wl_display_cancel_read(d.disp)
+	}
+	if err := d.dispatchPending(); err != nil {
+		return err
+	}
+	d.repeat.Repeat(d)
+	return nil
+}
+
+// prepareRead prepares to read events into the event queue of d.
+func (d *wlDisplay) prepareRead() C.int {
+	if d.queue != nil {
+		return C.wl_display_prepare_read_queue(d.disp, d.queue)
+	}
+	return C.wl_display_prepare_read(d.disp)
+}
+
+// dispatchPending dispatches the events in the event queue of d.
+func (d *wlDisplay) dispatchPending() error {
+	var ret C.int
+	var err error
+	if d.queue != nil {
+		ret, err = C.wl_display_dispatch_queue_pending(d.disp, d.queue)
+	} else {
+		ret, err = C.wl_display_dispatch_pending(d.disp)
+	}
+	if ret < 0 {
+		return fmt.Errorf("wayland: wl_display_dispatch_pending failed: %v", err)
+	}
+	return nil
+}
+
+var wlOneByte = make([]byte, 1)
+
+func (d *wlDisplay) wakeup() {
+	if _, err := syscall.Write(d.notify.write, wlOneByte); err != nil && err != syscall.EAGAIN {
+		panic(fmt.Errorf("failed to write to pipe: %v", err))
+	}
+}
+
+// readClipboard reads the clipboard content of type mime, or returns
+// nil if the clipboard holds no such content.
+func (d *wlDisplay) readClipboard(mime string) (io.ReadCloser, error) {
+	if d.seat == nil {
+		return nil, nil
+	}
+	s := d.seat
+	if s.clipboard == nil {
+		return nil, nil
+	}
+	switch {
+	case isTextMIME(mime):
+		if s.mimeType == "" {
+			return nil, nil
+		}
+		mime = s.mimeType
+	case !slices.Contains(s.offers[s.clipboard], mime):
+		return nil, nil
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	// wl_data_offer_receive performs and implicit dup(2) of the write end
+	// of the pipe. Close our version.
+	defer w.Close()
+	cmimeType := C.CString(mime)
+	defer C.free(unsafe.Pointer(cmimeType))
+	C.wl_data_offer_receive(s.clipboard, cmimeType, C.int32_t(w.Fd()))
+	return r, nil
+}
+
+func (d *wlDisplay) writeClipboard(content []input.ClipboardData) error {
+	s := d.seat
+	if s == nil {
+		return nil
+	}
+	// Clear old offer.
+	if s.source != nil {
+		C.wl_data_source_destroy(s.source)
+		s.source = nil
+		s.content = nil
+	}
+	if d.dataDeviceManager == nil || s.dataDev == nil {
+		return nil
+	}
+	s.content = content
+	s.source = C.wl_data_device_manager_create_data_source(d.dataDeviceManager)
+	C.wl_data_source_add_listener(s.source, &C.gio_data_source_listener, unsafe.Pointer(s.seat))
+	for _, mime := range offerMIMETypes(content) {
+		cmime := C.CString(mime)
+		C.wl_data_source_offer(s.source, cmime)
+		C.free(unsafe.Pointer(cmime))
+	}
+	C.wl_data_device_set_selection(s.dataDev, s.source, s.serial)
+	return nil
+}
+
+// offerMIMETypes returns the mime types to offer for content.
+func offerMIMETypes(content []input.ClipboardData) []string {
+	var mimes []string
+	for _, c := range content {
+		if isTextMIME(c.Type) {
+			mimes = append(mimes, clipboardMimeTypes...)
+		} else {
+			mimes = append(mimes, c.Type)
+		}
+	}
+	return mimes
+}
+
+// sourceContent returns the data of content requested as mime.
+func sourceContent(content []input.ClipboardData, mime string) []byte {
+	if slices.Contains(clipboardMimeTypes, mime) {
+		mime = "text/plain"
+	}
+	data, _ := clipboardContent(content, mime)
+	return data
+}
+
+//export gio_onDataSourceTarget
+func gio_onDataSourceTarget(data unsafe.Pointer, source *C.struct_wl_data_source, mime *C.char) {
+}
+
+//export gio_onDataSourceSend
+func gio_onDataSourceSend(data unsafe.Pointer, source *C.struct_wl_data_source, mime *C.char, fd C.int32_t) {
+	s := callbackLoad(data).(*wlSeat)
+	content := sourceContent(s.content, C.GoString(mime))
+	go func() {
+		defer syscall.Close(int(fd))
+		syscall.Write(int(fd), content)
+	}()
+}
+
+//export gio_onDataSourceCancelled
+func gio_onDataSourceCancelled(data unsafe.Pointer, source *C.struct_wl_data_source) {
+	s := callbackLoad(data).(*wlSeat)
+	if s.source == source {
+		s.content = nil
+		s.source = nil
+	}
+	C.wl_data_source_destroy(source)
+}
+
+//export gio_onDataSourceDNDDropPerformed
+func gio_onDataSourceDNDDropPerformed(data unsafe.Pointer, source *C.struct_wl_data_source) {
+}
+
+//export gio_onDataSourceDNDFinished
+func gio_onDataSourceDNDFinished(data unsafe.Pointer, source *C.struct_wl_data_source) {
+}
+
+//export gio_onDataSourceAction
+func gio_onDataSourceAction(data unsafe.Pointer, source *C.struct_wl_data_source, act C.uint32_t) {
+}
+
+// wlDetectUIScale reports the system UI scale, or 1.0 if it fails.
+func wlDetectUIScale() float32 {
+	// TODO: What about other window environments?
+	out, err := exec.Command("gsettings", "get", "org.gnome.desktop.interface", "text-scaling-factor").Output()
+	if err != nil {
+		return 1.0
+	}
+	scale, err := strconv.ParseFloat(string(bytes.TrimSpace(out)), 32)
+	if err != nil {
+		return 1.0
+	}
+	return float32(scale)
+}
+
+func fromFixed(v C.wl_fixed_t) float32 {
+	// Convert to float64 to avoid overflow.
+	// From wayland-util.h.
+	b := ((1023 + 44) << 52) + (1 << 51) + uint64(v)
+	f := math.Float64frombits(b) - (3 << 43)
+	return float32(f)
+}
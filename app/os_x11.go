@@ -8,8 +8,8 @@ package app
 /*
 #cgo freebsd openbsd CFLAGS: -I/usr/X11R6/include -I/usr/local/include
 #cgo freebsd openbsd LDFLAGS: -L/usr/X11R6/lib -L/usr/local/lib
-#cgo freebsd openbsd LDFLAGS: -lX11 -lxkbcommon -lxkbcommon-x11 -lX11-xcb -lXcursor -lXfixes
-#cgo linux pkg-config: x11 xkbcommon xkbcommon-x11 x11-xcb xcursor xfixes
+#cgo freebsd openbsd LDFLAGS: -lX11 -lxkbcommon -lxkbcommon-x11 -lX11-xcb -lXcursor -lXfixes -lXrandr -lXi
+#cgo linux pkg-config: x11 xkbcommon xkbcommon-x11 x11-xcb xcursor xfixes xrandr xi
 
 #include <stdlib.h>
 #include <locale.h>
@@ -20,6 +20,7 @@ package app
 #include <X11/XKBlib.h>
 #include <X11/Xlib-xcb.h>
 #include <X11/extensions/Xfixes.h>
+#include <X11/extensions/Xrandr.h>
 #include <X11/Xcursor/Xcursor.h>
 #include <xkbcommon/xkbcommon-x11.h>
 
@@ -30,9 +31,9 @@ import (
 	"errors"
 	"fmt"
 	"image"
-	"io"
+	"net/url"
+	"slices"
 	"strconv"
-	"strings"
 	"sync"
 	"time"
 	"unsafe"
@@ -42,7 +43,6 @@ import (
 	"github.com/mleku/gio/io/key"
 	"github.com/mleku/gio/io/pointer"
 	"github.com/mleku/gio/io/system"
-	"github.com/mleku/gio/io/transfer"
 	"github.com/mleku/gio/op"
 	"github.com/mleku/gio/unit"
 
@@ -56,12 +56,18 @@ const (
 	_NET_WM_STATE_ADD    = 1
 )
 
+// scrollScale is the scroll distance of a mouse wheel step.
+const scrollScale = 10
+
 type x11Window struct {
 	w            *callbacks
 	x            *C.Display
 	xkb          *xkb.Context
 	xkbEventBase C.int
-	xw           C.Window
+	// randrEventBase is the RandR event base, or zero if the
+	// extension is missing.
+	randrEventBase C.int
+	xw             C.Window
 
 	atoms struct {
 		// "UTF8_STRING".
@@ -70,12 +76,17 @@ type x11Window struct {
 		plaintext C.Atom
 		// "TARGETS"
 		targets C.Atom
+		// "INCR", the type of incremental selection transfers.
+		incr C.Atom
 		// "CLIPBOARD".
 		clipboard C.Atom
 		// "PRIMARY".
 		primary C.Atom
 		// "CLIPBOARD_CONTENT", the clipboard destination property.
 		clipboardContent C.Atom
+		// "GIO_PRIMARY_CONTENT", the primary selection destination
+		// property.
+		primaryContent C.Atom
 		// "WM_DELETE_WINDOW"
 		evDelWindow C.Atom
 		// "ATOM"
@@ -84,6 +95,8 @@ type x11Window struct {
 		gtk_text_buffer_contents C.Atom
 		// "_NET_WM_NAME"
 		wmName C.Atom
+		// "_NET_WM_ICON"
+		wmIcon C.Atom
 		// "_NET_WM_STATE"
 		wmState C.Atom
 		// "_NET_WM_STATE_FULLSCREEN"
@@ -94,21 +107,62 @@ type x11Window struct {
 		wmStateMaximizedHorz C.Atom
 		// _NET_WM_STATE_MAXIMIZED_VERT
 		wmStateMaximizedVert C.Atom
+		// "_NET_WM_MOVERESIZE"
+		wmMoveResize C.Atom
+		// "_NET_WM_STATE_ABOVE"
+		wmStateAbove C.Atom
+		// "_NET_WM_STATE_SKIP_TASKBAR"
+		wmStateSkipTaskbar C.Atom
+		// "_NET_WM_STATE_MODAL"
+		wmStateModal C.Atom
+		// "_NET_WM_WINDOW_TYPE" and the types.
+		wmWindowType             C.Atom
+		wmWindowTypeNormal       C.Atom
+		wmWindowTypeDialog       C.Atom
+		wmWindowTypeUtility      C.Atom
+		wmWindowTypeSplash       C.Atom
+		wmWindowTypeNotification C.Atom
+		// XDND drag and drop protocol atoms.
+		xdndAware      C.Atom
+		xdndEnter      C.Atom
+		xdndPosition   C.Atom
+		xdndStatus     C.Atom
+		xdndLeave      C.Atom
+		xdndDrop       C.Atom
+		xdndFinished   C.Atom
+		xdndSelection  C.Atom
+		xdndTypeList   C.Atom
+		xdndActionCopy C.Atom
+		// "GIO_XDND_CONTENT", the drop destination property.
+		xdndContent C.Atom
 	}
 	metric unit.Metric
-	notify struct {
+	// screens caches the screens of the display.
+	screens []Screen
+	notify  struct {
 		read, write int
 	}
 
 	animating bool
+	// stage is the visibility of the window.
+	stage Stage
 
 	pointerBtns pointer.Buttons
 
-	clipboard struct {
-		content []byte
-	}
-	cursor pointer.Cursor
-	config Config
+	clipboard x11Clipboard
+	dnd       x11DnD
+	xi        x11XInput
+	ime       x11IME
+	present   x11Present
+	a11y      *atspiWindow
+	cursor    pointer.Cursor
+	config    Config
+	// visualID is the visual with an alpha channel of a transparent
+	// window, or zero.
+	visualID int
+	// grabPending is set while the grabs of a popup wait to be
+	// retried.
+	grabPending bool
 
 	wakeups chan struct{}
 	handler x11EventHandler
@@ -126,7 +180,9 @@ const vulkanBuggy = true
 
 func (w *x11Window) NewContext() (context, error) {
 	var firstErr error
-	if f := newX11VulkanContext; f != nil && !vulkanBuggy {
+	// Transparent windows need an EGL configuration matching their
+	// visual.
+	if f := newX11VulkanContext; f != nil && !vulkanBuggy && !w.config.Transparent {
 		c, err := f(w)
 		if err == nil {
 			return c, nil
@@ -150,25 +206,32 @@ func (w *x11Window) SetAnimating(anim bool) {
 	w.animating = anim
 }
 
-func (w *x11Window) ReadClipboard() {
-	C.XDeleteProperty(w.x, w.xw, w.atoms.clipboardContent)
-	C.XConvertSelection(w.x, w.atoms.clipboard, w.atoms.utf8string, w.atoms.clipboardContent, w.xw, C.CurrentTime)
-}
-
-func (w *x11Window) WriteClipboard(mime string, s []byte) {
-	w.clipboard.content = s
-	C.XSetSelectionOwner(w.x, w.atoms.clipboard, w.xw, C.CurrentTime)
-	C.XSetSelectionOwner(w.x, w.atoms.primary, w.xw, C.CurrentTime)
+// setStage reports a change of visibility.
+func (w *x11Window) setStage(s Stage) {
+	if s == w.stage {
+		return
+	}
+	w.stage = s
+	w.ProcessEvent(StageEvent{Stage: s})
 }
 
 func (w *x11Window) Configure(options []Option) {
-	var shints C.XSizeHints
+	shints := C.XSizeHints{win_gravity: C.StaticGravity}
 	prev := w.config
 	cnf := w.config
 	cnf.apply(w.metric, options)
 	// Decorations are never disabled.
 	cnf.Decorated = true
 
+	if cnf.Screen.Name != prev.Screen.Name {
+		// The screen is updated when the move is reported.
+		w.moveToScreen(cnf.Screen.Name)
+	}
+	if cnf.Position != prev.Position && !w.config.Popup {
+		// The position is updated when the move is reported.
+		C.XMoveWindow(w.x, w.xw, C.int(cnf.Position.X), C.int(cnf.Position.Y))
+	}
+
 	switch cnf.Mode {
 	case Fullscreen:
 		switch prev.Mode {
@@ -230,15 +293,143 @@ func (w *x11Window) Configure(options []Option) {
 			shints.flags = shints.flags | C.PMaxSize
 		}
 		if shints.flags != 0 {
+			shints.flags |= C.PWinGravity
 			C.XSetWMNormalHints(w.x, w.xw, &shints)
 		}
 	}
 	if cnf.Decorated != prev.Decorated {
 		w.config.Decorated = cnf.Decorated
 	}
+	if !sameIcon(prev.Icon, cnf.Icon) {
+		w.config.Icon = cnf.Icon
+		w.setIcon(cnf.Icon)
+	}
+	w.setHints(prev, cnf, true)
+	if !slices.Equal(prev.InputRegion, cnf.InputRegion) {
+		w.config.InputRegion = cnf.InputRegion
+		w.setInputRegion(cnf.InputRegion)
+	}
+	if w.config.Popup && cnf.Anchor != prev.Anchor {
+		w.config.Anchor = cnf.Anchor
+		w.placePopup()
+	}
 	w.ProcessEvent(ConfigEvent{Config: w.config})
 }
 
+// setHints updates the window type, transient parent and the window
+// manager states that differ between prev and cnf. The states of a
+// window that is not yet mapped are set directly in its _NET_WM_STATE
+// property.
+func (w *x11Window) setHints(prev, cnf Config, mapped bool) {
+	if cnf.Type != prev.Type {
+		w.config.Type = cnf.Type
+		typ := w.atoms.wmWindowTypeNormal
+		switch cnf.Type {
+		case DialogWindow:
+			typ = w.atoms.wmWindowTypeDialog
+		case UtilityWindow:
+			typ = w.atoms.wmWindowTypeUtility
+		case SplashWindow:
+			typ = w.atoms.wmWindowTypeSplash
+		case NotificationWindow:
+			typ = w.atoms.wmWindowTypeNotification
+		}
+		C.XChangeProperty(w.x, w.xw, w.atoms.wmWindowType, C.XA_ATOM, 32, C.PropModeReplace,
+			(*C.uchar)(unsafe.Pointer(&typ)), 1)
+	}
+	if cnf.Parent != prev.Parent {
+		w.config.Parent = cnf.Parent
+		var parent C.Window
+		if cnf.Parent != nil {
+			if v, ok := cnf.Parent.view().(X11ViewEvent); ok && C.Window(v.Window) != w.xw {
+				parent = C.Window(v.Window)
+			}
+		}
+		if parent != 0 {
+			C.XSetTransientForHint(w.x, w.xw, parent)
+		} else {
+			C.XDeleteProperty(w.x, w.xw, C.XA_WM_TRANSIENT_FOR)
+		}
+	}
+	states := []struct {
+		atom     C.Atom
+		old, new bool
+		field    *bool
+	}{
+		{w.atoms.wmStateAbove, prev.AlwaysOnTop, cnf.AlwaysOnTop, &w.config.AlwaysOnTop},
+		{w.atoms.wmStateSkipTaskbar, prev.SkipTaskbar, cnf.SkipTaskbar, &w.config.SkipTaskbar},
+		{w.atoms.wmStateModal, prev.Modal, cnf.Modal, &w.config.Modal},
+	}
+	if !mapped {
+		var atoms []C.Atom
+		for _, s := range states {
+			*s.field = s.new
+			if s.new {
+				atoms = append(atoms, s.atom)
+			}
+		}
+		if len(atoms) > 0 {
+			C.XChangeProperty(w.x, w.xw, w.atoms.wmState, C.XA_ATOM, 32, C.PropModeReplace,
+				(*C.uchar)(unsafe.Pointer(&atoms[0])), C.int(len(atoms)))
+		}
+		return
+	}
+	for _, s := range states {
+		if s.new == s.old {
+			continue
+		}
+		*s.field = s.new
+		action := C.long(_NET_WM_STATE_REMOVE)
+		if s.new {
+			action = _NET_WM_STATE_ADD
+		}
+		w.sendWMStateEvent(action, C.ulong(s.atom), 0)
+	}
+}
+
+// updateWMState updates the configuration from the states set by the
+// window manager. It reports whether the configuration changed.
+func (w *x11Window) updateWMState() bool {
+	var above, skip, modal bool
+	for _, a := range w.atomList(w.xw, w.atoms.wmState) {
+		switch a {
+		case w.atoms.wmStateAbove:
+			above = true
+		case w.atoms.wmStateSkipTaskbar:
+			skip = true
+		case w.atoms.wmStateModal:
+			modal = true
+		}
+	}
+	cnf := &w.config
+	if cnf.AlwaysOnTop == above && cnf.SkipTaskbar == skip && cnf.Modal == modal {
+		return false
+	}
+	cnf.AlwaysOnTop, cnf.SkipTaskbar, cnf.Modal = above, skip, modal
+	return true
+}
+
+// setIcon sets _NET_WM_ICON to the icon images.
+func (w *x11Window) setIcon(icon []image.Image) {
+	var data []C.long
+	for _, img := range iconImages(icon) {
+		sz := img.Bounds().Size()
+		data = append(data, C.long(sz.X), C.long(sz.Y))
+		for y := range sz.Y {
+			for x := range sz.X {
+				c := img.NRGBAAt(x, y)
+				data = append(data, C.long(uint32(c.A)<<24|uint32(c.R)<<16|uint32(c.G)<<8|uint32(c.B)))
+			}
+		}
+	}
+	if len(data) == 0 {
+		C.XDeleteProperty(w.x, w.xw, w.atoms.wmIcon)
+		return
+	}
+	C.XChangeProperty(w.x, w.xw, w.atoms.wmIcon, C.XA_CARDINAL, 32, C.PropModeReplace,
+		(*C.uchar)(unsafe.Pointer(&data[0])), C.int(len(data)))
+}
+
 func (w *x11Window) setTitle(prev, cnf Config) {
 	if prev.Title != cnf.Title {
 		title := cnf.Title
@@ -257,6 +448,9 @@ func (w *x11Window) setTitle(prev, cnf Config) {
 	}
 }
 
+// SetURL is a no-op, because windows have no address.
+func (w *x11Window) SetURL(u *url.URL, replace bool) {}
+
 func (w *x11Window) Perform(acts system.Action) {
 	walkActions(acts, func(a system.Action) {
 		switch a {
@@ -264,6 +458,10 @@ func (w *x11Window) Perform(acts system.Action) {
 			w.center()
 		case system.ActionRaise:
 			w.raise()
+		default:
+			if isMoveResize(a) {
+				w.performMoveResize(a)
+			}
 		}
 	})
 	if acts&system.ActionClose != 0 {
@@ -310,10 +508,13 @@ func (w *x11Window) raise() {
 
 func (w *x11Window) SetCursor(cursor pointer.Cursor) {
 	if cursor == pointer.CursorNone {
-		w.cursor = cursor
-		C.XFixesHideCursor(w.x, w.xw)
+		if w.cursor != pointer.CursorNone {
+			w.cursor = cursor
+			C.XFixesHideCursor(w.x, w.xw)
+		}
 		return
 	}
+	w.showCursor()
 
 	xcursor := xCursor[cursor]
 	cname := C.CString(xcursor)
@@ -328,11 +529,58 @@ func (w *x11Window) SetCursor(cursor pointer.Cursor) {
 	C.XDefineCursor(w.x, w.xw, c)
 }
 
-func (w *x11Window) ShowTextInput(show bool) {}
+// SetImageCursor defines an ARGB cursor from the cursor image.
+func (w *x11Window) SetImageCursor(cursor *pointer.ImageCursor) {
+	w.showCursor()
+	w.cursor = pointer.CursorDefault
+	b := cursor.Image.Bounds()
+	sz := b.Size()
+	var c C.Cursor
+	if !b.Empty() {
+		if img := C.XcursorImageCreate(C.int(sz.X), C.int(sz.Y)); img != nil {
+			hot := cursor.Hotspot.Sub(b.Min)
+			img.xhot = C.XcursorDim(min(max(hot.X, 0), sz.X-1))
+			img.yhot = C.XcursorDim(min(max(hot.Y, 0), sz.Y-1))
+			// Xcursor pixels are premultiplied ARGB.
+			pixels := unsafe.Slice(img.pixels, sz.X*sz.Y)
+			for y := range sz.Y {
+				for x := range sz.X {
+					cr, cg, cb, ca := cursor.Image.At(b.Min.X+x, b.Min.Y+y).RGBA()
+					pixels[y*sz.X+x] = C.XcursorPixel(ca>>8<<24 | cr>>8<<16 | cg>>8<<8 | cb>>8)
+				}
+			}
+			c = C.XcursorImageLoadCursor(w.x, img)
+			C.XcursorImageDestroy(img)
+		}
+	}
+	C.XDefineCursor(w.x, w.xw, c)
+	if c != 0 {
+		// The window keeps the cursor until it is replaced.
+		C.XFreeCursor(w.x, c)
+	}
+}
+
+// showCursor shows the cursor hidden by CursorNone.
+func (w *x11Window) showCursor() {
+	if w.cursor == pointer.CursorNone {
+		C.XFixesShowCursor(w.x, w.xw)
+	}
+}
+
+func (w *x11Window) ShowTextInput(show bool) {
+	w.ime.show = show
+	w.updateIME()
+}
 
+// SetInputHint is a no-op, because XIM has no content types.
 func (w *x11Window) SetInputHint(_ key.InputHint) {}
 
-func (w *x11Window) EditorStateChanged(old, new editorState) {}
+func (w *x11Window) EditorStateChanged(old, new editorState) {
+	if !w.ime.active || old.Selection == new.Selection {
+		return
+	}
+	w.updateICSpot(new)
+}
 
 // close the window.
 func (w *x11Window) close() {
@@ -351,6 +599,99 @@ func (w *x11Window) close() {
 	C.XSendEvent(w.x, w.xw, C.False, C.NoEventMask, &xev)
 }
 
+// performMoveResize starts an interactive move or resize at the pointer
+// position, if a button is pressed.
+func (w *x11Window) performMoveResize(act system.Action) {
+	var root, child C.Window
+	var rootX, rootY, x, y C.int
+	var mask C.uint
+	if C.XQueryPointer(w.x, w.xw, &root, &child, &rootX, &rootY, &x, &y, &mask) == C.False {
+		return
+	}
+	var button C.uint
+	switch {
+	case mask&C.Button1Mask != 0:
+		button = C.Button1
+	case mask&C.Button2Mask != 0:
+		button = C.Button2
+	case mask&C.Button3Mask != 0:
+		button = C.Button3
+	default:
+		return
+	}
+	w.moveResize(act, rootX, rootY, button, C.CurrentTime)
+}
+
+// moveResize asks the window manager to move or resize the window
+// directed by the pointer, through _NET_WM_MOVERESIZE.
+func (w *x11Window) moveResize(act system.Action, rootX, rootY C.int, button C.uint, t C.Time) {
+	// Directions defined by the Extended Window Manager Hints.
+	const (
+		sizeTopLeft     = 0
+		sizeTop         = 1
+		sizeTopRight    = 2
+		sizeRight       = 3
+		sizeBottomRight = 4
+		sizeBottom      = 5
+		sizeBottomLeft  = 6
+		sizeLeft        = 7
+		move            = 8
+	)
+	var dir C.long
+	switch act {
+	case system.ActionMove:
+		dir = move
+	case system.ActionResizeNorth:
+		dir = sizeTop
+	case system.ActionResizeSouth:
+		dir = sizeBottom
+	case system.ActionResizeWest:
+		dir = sizeLeft
+	case system.ActionResizeEast:
+		dir = sizeRight
+	case system.ActionResizeNorthWest:
+		dir = sizeTopLeft
+	case system.ActionResizeNorthEast:
+		dir = sizeTopRight
+	case system.ActionResizeSouthWest:
+		dir = sizeBottomLeft
+	case system.ActionResizeSouthEast:
+		dir = sizeBottomRight
+	default:
+		return
+	}
+	// The window manager grabs the pointer, which fails while the
+	// implicit grab of the press is held.
+	C.XUngrabPointer(w.x, t)
+	w.ungrabXInput(t)
+	var xev C.XEvent
+	ev := (*C.XClientMessageEvent)(unsafe.Pointer(&xev))
+	*ev = C.XClientMessageEvent{
+		_type:        C.ClientMessage,
+		display:      w.x,
+		window:       w.xw,
+		message_type: w.atoms.wmMoveResize,
+		format:       32,
+	}
+	data := (*[5]C.long)(unsafe.Pointer(&ev.data))
+	data[0] = C.long(rootX)
+	data[1] = C.long(rootY)
+	data[2] = dir
+	data[3] = C.long(button)
+	data[4] = 1 // application
+	C.XSendEvent(
+		w.x,
+		C.XDefaultRootWindow(w.x),
+		C.False,
+		C.SubstructureNotifyMask|C.SubstructureRedirectMask,
+		&xev,
+	)
+	C.XFlush(w.x)
+	// The window manager takes over the pointer; release the buttons.
+	w.pointerBtns = 0
+	w.ProcessEvent(pointer.Event{Kind: pointer.Cancel})
+}
+
 // action is one of _NET_WM_STATE_REMOVE, _NET_WM_STATE_ADD.
 func (w *x11Window) sendWMStateEvent(action C.long, atom1, atom2 C.ulong) {
 	var xev C.XEvent
@@ -406,6 +747,7 @@ func (w *x11Window) Run(f func()) {
 
 func (w *x11Window) Frame(frame *op.Ops) {
 	w.w.ProcessFrame(frame, nil)
+	w.a11y.update(w.config.Title, w.config.Position, w.config.Size, w.config.Focused)
 }
 
 func (w *x11Window) Invalidate() {
@@ -439,6 +781,7 @@ func (w *x11Window) dispatch() {
 		w.w.Invalidate()
 	default:
 	}
+	w.a11y.runActions()
 
 	xfd := C.XConnectionNumber(w.x)
 
@@ -460,12 +803,22 @@ func (w *x11Window) dispatch() {
 		return
 	}
 	if !syn {
-		anim = w.animating
+		// Animation frames wait for the display refresh.
+		anim = w.animating && w.vblank()
 		if !anim {
 			// Clear poll events.
 			*xEvents = 0
 			// Wait for X event or gio notification.
-			if _, err := syscall.Poll(pollfds, -1); err != nil && err != syscall.EINTR {
+			timeout := -1
+			switch {
+			case w.grabPending:
+				// Retry the grabs of a popup.
+				timeout = 20
+			case w.animating:
+				// Wait for the refresh notification.
+				timeout = w.vblankTimeout()
+			}
+			if _, err := syscall.Poll(pollfds, timeout); err != nil && err != syscall.EINTR {
 				panic(fmt.Errorf("x11 loop: poll failed: %w", err))
 			}
 			switch {
@@ -476,6 +829,7 @@ func (w *x11Window) dispatch() {
 				}
 			case *xEvents&(syscall.POLLERR|syscall.POLLHUP) != 0:
 			}
+			anim = w.animating && w.vblank()
 		}
 	}
 	// Clear notifications.
@@ -488,12 +842,22 @@ func (w *x11Window) dispatch() {
 			panic(fmt.Errorf("x11 loop: read from notify pipe failed: %w", err))
 		}
 	}
+	if w.grabPending {
+		w.grabPopup()
+	}
 	if (anim || syn) && w.config.Size.X != 0 && w.config.Size.Y != 0 {
+		now := time.Now()
+		present, refresh := w.present.predict(now)
+		if !present.IsZero() {
+			now = present
+		}
 		w.ProcessEvent(frameEvent{
 			FrameEvent: FrameEvent{
-				Now:    time.Now(),
-				Size:   w.config.Size,
-				Metric: w.metric,
+				Now:             now,
+				Size:            w.config.Size,
+				Metric:          w.metric,
+				PresentTime:     present,
+				RefreshInterval: refresh,
 			},
 			Sync: syn,
 		})
@@ -513,6 +877,10 @@ func (w *x11Window) destroy() {
 		w.xkb.Destroy()
 		w.xkb = nil
 	}
+	w.destroyIME()
+	w.destroyPresent()
+	w.a11y.destroy()
+	w.a11y = nil
 	C.XDestroyWindow(w.x, w.xw)
 	C.XCloseDisplay(w.x)
 	w.x = nil
@@ -562,15 +930,31 @@ func (h *x11EventHandler) handleEvents() bool {
 				h.w.xkb.UpdateMask(uint32(state.base_mods), uint32(state.latched_mods), uint32(state.locked_mods),
 					uint32(state.base_group), uint32(state.latched_group), uint32(state.locked_group))
 			}
+		case h.w.randrEventBase + C.RRScreenChangeNotify:
+			// Monitors were added, removed or reconfigured.
+			C.XRRUpdateConfiguration(xev)
+			w.screens = x11QueryScreens(w.x)
+			if moved, screenChanged := w.updatePosition(); moved || screenChanged {
+				w.ProcessEvent(ConfigEvent{Config: w.config})
+				redraw = redraw || screenChanged
+			}
 		case C.KeyPress, C.KeyRelease:
 			ks := key.Press
 			if _type == C.KeyRelease {
 				ks = key.Release
 			}
 			kevt := (*C.XKeyPressedEvent)(unsafe.Pointer(xev))
+			if kevt.keycode == 0 {
+				// Text committed by the input method.
+				if _type == C.KeyPress && w.ime.active {
+					if text := h.lookupText(kevt); text != "" {
+						w.commitIME(text)
+					}
+				}
+				break
+			}
 			for _, e := range h.w.xkb.DispatchKey(uint32(kevt.keycode), ks) {
 				if ee, ok := e.(key.EditEvent); ok {
-					// There's no support for IME yet.
 					w.w.EditorInsert(ee.Text)
 				} else {
 					w.ProcessEvent(e)
@@ -591,156 +975,109 @@ func (h *x11EventHandler) handleEvents() bool {
 			if bevt._type == C.ButtonRelease {
 				ev.Kind = pointer.Release
 			}
-			var btn pointer.Buttons
-			const scrollScale = 10
-			switch bevt.button {
-			case C.Button1:
-				btn = pointer.ButtonPrimary
-			case C.Button2:
-				btn = pointer.ButtonTertiary
-			case C.Button3:
-				btn = pointer.ButtonSecondary
-			case C.Button4:
-				ev.Kind = pointer.Scroll
-				// scroll up or left (if shift is pressed).
-				if ev.Modifiers == key.ModShift {
-					ev.Scroll.X = -scrollScale
-				} else {
-					ev.Scroll.Y = -scrollScale
-				}
-			case C.Button5:
-				// scroll down or right (if shift is pressed).
-				ev.Kind = pointer.Scroll
-				if ev.Modifiers == key.ModShift {
-					ev.Scroll.X = +scrollScale
-				} else {
-					ev.Scroll.Y = +scrollScale
-				}
-			case 6:
-				// http://xahlee.info/linux/linux_x11_mouse_button_number.html
-				// scroll left.
-				ev.Kind = pointer.Scroll
-				ev.Scroll.X = -scrollScale * 2
-			case 7:
-				// scroll right
-				ev.Kind = pointer.Scroll
-				ev.Scroll.X = +scrollScale * 2
-			default:
-				continue
-			}
-			switch _type {
-			case C.ButtonPress:
-				w.pointerBtns |= btn
-			case C.ButtonRelease:
-				w.pointerBtns &^= btn
-			}
-			ev.Buttons = w.pointerBtns
-			w.ProcessEvent(ev)
+			w.pointerButton(ev, bevt.button, bevt.x_root, bevt.y_root, bevt.time)
 		case C.MotionNotify:
 			mevt := (*C.XMotionEvent)(unsafe.Pointer(xev))
-			w.ProcessEvent(pointer.Event{
-				Kind:    pointer.Move,
-				Source:  pointer.Mouse,
-				Buttons: w.pointerBtns,
+			w.pointerMotion(pointer.Event{
+				Source: pointer.Mouse,
 				Position: f32.Point{
 					X: float32(mevt.x),
 					Y: float32(mevt.y),
 				},
 				Time:      time.Duration(mevt.time) * time.Millisecond,
 				Modifiers: w.xkb.Modifiers(),
-			})
+			}, mevt.x_root, mevt.y_root, mevt.time)
 		case C.Expose: // update
 			// redraw only on the last expose event
 			redraw = (*C.XExposeEvent)(unsafe.Pointer(xev)).count == 0
+		case C.MapNotify:
+			if w.config.Popup {
+				w.grabPopup()
+			}
+			w.setStage(StageRunning)
+		case C.UnmapNotify:
+			w.setStage(StageHidden)
+		case C.VisibilityNotify:
+			vevt := (*C.XVisibilityEvent)(unsafe.Pointer(xev))
+			if vevt.state == C.VisibilityFullyObscured {
+				w.setStage(StagePaused)
+			} else {
+				w.setStage(StageRunning)
+			}
 		case C.FocusIn:
 			w.config.Focused = true
+			w.updateIME()
 			w.ProcessEvent(ConfigEvent{Config: w.config})
 		case C.FocusOut:
 			w.config.Focused = false
+			w.updateIME()
 			w.ProcessEvent(ConfigEvent{Config: w.config})
 		case C.ConfigureNotify: // window configuration change
 			cevt := (*C.XConfigureEvent)(unsafe.Pointer(xev))
+			changed := false
 			if sz := image.Pt(int(cevt.width), int(cevt.height)); sz != w.config.Size {
 				w.config.Size = sz
+				changed = true
+			}
+			moved, screenChanged := w.updatePosition()
+			if moved {
+				changed = true
+			}
+			if screenChanged {
+				// Redraw with the metric of the new screen.
+				changed, redraw = true, true
+			}
+			if changed {
 				w.ProcessEvent(ConfigEvent{Config: w.config})
 			}
 			// redraw will be done by a later expose event
 		case C.SelectionNotify:
 			cevt := (*C.XSelectionEvent)(unsafe.Pointer(xev))
-			prop := w.atoms.clipboardContent
-			if cevt.property != prop {
+			if cevt.selection == w.atoms.xdndSelection {
+				w.receiveDrop(cevt)
 				break
 			}
-			if cevt.selection != w.atoms.clipboard {
-				break
+			w.receiveSelection(cevt)
+		case C.SelectionClear:
+			cevt := (*C.XSelectionClearEvent)(unsafe.Pointer(xev))
+			if cevt.selection == w.atoms.xdndSelection {
+				w.clearExport()
 			}
-			var text C.XTextProperty
-			if st := C.XGetTextProperty(w.x, w.xw, &text, prop); st == 0 {
-				// Failed; ignore.
-				break
-			}
-			if text.format != 8 || text.encoding != w.atoms.utf8string {
-				// Ignore non-utf-8 encoded strings.
-				break
-			}
-			str := C.GoStringN((*C.char)(unsafe.Pointer(text.value)), C.int(text.nitems))
-			w.ProcessEvent(transfer.DataEvent{
-				Type: "application/text",
-				Open: func() io.ReadCloser {
-					return io.NopCloser(strings.NewReader(str))
-				},
-			})
 		case C.SelectionRequest:
 			cevt := (*C.XSelectionRequestEvent)(unsafe.Pointer(xev))
+			if cevt.selection == w.atoms.xdndSelection {
+				w.requestExport(cevt)
+				break
+			}
 			if (cevt.selection != w.atoms.clipboard && cevt.selection != w.atoms.primary) || cevt.property == C.None {
 				// Unsupported clipboard or obsolete requestor.
 				break
 			}
-			notify := func() {
-				var xev C.XEvent
-				ev := (*C.XSelectionEvent)(unsafe.Pointer(&xev))
-				*ev = C.XSelectionEvent{
-					_type:     C.SelectionNotify,
-					display:   cevt.display,
-					requestor: cevt.requestor,
-					selection: cevt.selection,
-					target:    cevt.target,
-					property:  cevt.property,
-					time:      cevt.time,
+			w.requestSelection(cevt)
+		case C.PropertyNotify:
+			pevt := (*C.XPropertyEvent)(unsafe.Pointer(xev))
+			if pevt.window == w.xw && pevt.atom == w.atoms.wmState {
+				if w.updateWMState() {
+					w.ProcessEvent(ConfigEvent{Config: w.config})
 				}
-				C.XSendEvent(w.x, cevt.requestor, 0, 0, &xev)
+				break
 			}
-			switch cevt.target {
-			case w.atoms.targets:
-				// The requestor wants the supported clipboard
-				// formats. First write the targets...
-				formats := [...]C.long{
-					C.long(w.atoms.targets),
-					C.long(w.atoms.utf8string),
-					C.long(w.atoms.plaintext),
-					// GTK clients need this.
-					C.long(w.atoms.gtk_text_buffer_contents),
-				}
-				C.XChangeProperty(w.x, cevt.requestor, cevt.property, w.atoms.atom,
-					32 /* bitwidth of formats */, C.PropModeReplace,
-					(*C.uchar)(unsafe.Pointer(&formats)), C.int(len(formats)),
-				)
-				// ...then notify the requestor.
-				notify()
-			case w.atoms.plaintext, w.atoms.utf8string, w.atoms.gtk_text_buffer_contents:
-				content := w.clipboard.content
-				var ptr *C.uchar
-				if len(content) > 0 {
-					ptr = (*C.uchar)(unsafe.Pointer(&content[0]))
-				}
-				C.XChangeProperty(w.x, cevt.requestor, cevt.property, cevt.target,
-					8 /* bitwidth */, C.PropModeReplace,
-					ptr, C.int(len(content)),
-				)
-				notify()
+			w.selectionProperty(pevt)
+		case C.GenericEvent:
+			cookie := (*C.XGenericEventCookie)(unsafe.Pointer(xev))
+			if cookie.extension != w.xi.opcode || C.XGetEventData(w.x, cookie) == C.False {
+				break
 			}
+			w.handleXInput(cookie)
+			C.XFreeEventData(w.x, cookie)
 		case C.ClientMessage: // extensions
 			cevt := (*C.XClientMessageEvent)(unsafe.Pointer(xev))
+			switch cevt.message_type {
+			case w.atoms.xdndEnter, w.atoms.xdndPosition, w.atoms.xdndLeave, w.atoms.xdndDrop,
+				w.atoms.xdndStatus, w.atoms.xdndFinished:
+				w.handleXdnd(cevt)
+				continue
+			}
 			switch *(*C.long)(unsafe.Pointer(&cevt.data)) {
 			case C.long(w.atoms.evDelWindow):
 				w.shutdown(nil)
@@ -751,27 +1088,116 @@ func (h *x11EventHandler) handleEvents() bool {
 	return redraw
 }
 
-var x11Threads sync.Once
+// pointerButton completes and delivers a press or release of an X11
+// pointer button.
+func (w *x11Window) pointerButton(ev pointer.Event, button C.uint, rootX, rootY C.int, t C.Time) {
+	if w.config.Popup && ev.Kind == pointer.Press {
+		// The grab reports presses outside the popup, which dismiss it.
+		p := ev.Position
+		if p.X < 0 || p.Y < 0 || p.X >= float32(w.config.Size.X) || p.Y >= float32(w.config.Size.Y) {
+			w.close()
+			return
+		}
+	}
+	var btn pointer.Buttons
+	switch button {
+	case C.Button1:
+		btn = pointer.ButtonPrimary
+		if ev.Kind == pointer.Press && w.config.Mode == Windowed && !w.config.Popup {
+			if act, ok := w.w.ActionAt(ev.Position); ok && isMoveResize(act) {
+				w.moveResize(act, rootX, rootY, button, t)
+				return
+			}
+		}
+	case C.Button2:
+		btn = pointer.ButtonTertiary
+	case C.Button3:
+		btn = pointer.ButtonSecondary
+	case C.Button4:
+		ev.Kind = pointer.Scroll
+		// scroll up or left (if shift is pressed).
+		if ev.Modifiers == key.ModShift {
+			ev.Scroll.X = -scrollScale
+		} else {
+			ev.Scroll.Y = -scrollScale
+		}
+	case C.Button5:
+		// scroll down or right (if shift is pressed).
+		ev.Kind = pointer.Scroll
+		if ev.Modifiers == key.ModShift {
+			ev.Scroll.X = +scrollScale
+		} else {
+			ev.Scroll.Y = +scrollScale
+		}
+	case 6:
+		// http://xahlee.info/linux/linux_x11_mouse_button_number.html
+		// scroll left.
+		ev.Kind = pointer.Scroll
+		ev.Scroll.X = -scrollScale * 2
+	case 7:
+		// scroll right
+		ev.Kind = pointer.Scroll
+		ev.Scroll.X = +scrollScale * 2
+	default:
+		return
+	}
+	switch ev.Kind {
+	case pointer.Press:
+		w.pointerBtns |= btn
+	case pointer.Release:
+		w.pointerBtns &^= btn
+		if btn == pointer.ButtonPrimary {
+			w.dropExport(t)
+		}
+	}
+	ev.Buttons = w.pointerBtns
+	w.ProcessEvent(ev)
+}
+
+// pointerMotion delivers a pointer motion to ev.Position, or (rootX, rootY)
+// in root window coordinates.
+func (w *x11Window) pointerMotion(ev pointer.Event, rootX, rootY C.int, t C.Time) {
+	ev.Kind = pointer.Move
+	ev.Buttons = w.pointerBtns
+	w.ProcessEvent(ev)
+	if w.pointerBtns&pointer.ButtonPrimary != 0 {
+		w.dragExport(C.int(ev.Position.X), C.int(ev.Position.Y), rootX, rootY, t)
+	}
+}
+
+var (
+	x11Threads    sync.Once
+	x11ThreadsErr error
+)
 
 func init() {
 	x11Driver = newX11Window
+	x11Screens = x11ListScreens
 }
 
-func newX11Window(gioWin *callbacks, options []Option) error {
-	var err error
+// initX11Threads prepares Xlib for use by multiple threads.
+func initX11Threads() error {
+	x11Threads.Do(func() {
+		if C.XInitThreads() == 0 {
+			x11ThreadsErr = errors.New("x11: threads init failed")
+		}
+		C.XrmInitialize()
+		// Input methods are selected by the locale and XMODIFIERS.
+		if C.GoString(C.setlocale(C.LC_CTYPE, nil)) == "C" {
+			C.setlocale(C.LC_CTYPE, (*C.char)(unsafe.Pointer(&[]byte("\x00")[0])))
+		}
+		C.XSetLocaleModifiers((*C.char)(unsafe.Pointer(&[]byte("\x00")[0])))
+	})
+	return x11ThreadsErr
+}
 
+func newX11Window(gioWin *callbacks, options []Option) error {
 	pipe := make([]int, 2)
 	if err := syscall.Pipe2(pipe, syscall.O_NONBLOCK|syscall.O_CLOEXEC); err != nil {
 		return fmt.Errorf("NewX11Window: failed to create pipe: %w", err)
 	}
 
-	x11Threads.Do(func() {
-		if C.XInitThreads() == 0 {
-			err = errors.New("x11: threads init failed")
-		}
-		C.XrmInitialize()
-	})
-	if err != nil {
+	if err := initX11Threads(); err != nil {
 		return err
 	}
 	dpy := C.XOpenDisplay(nil)
@@ -795,33 +1221,83 @@ func newX11Window(gioWin *callbacks, options []Option) error {
 		return fmt.Errorf("x11: %v", err)
 	}
 
-	ppsp := x11DetectUIScale(dpy)
-	cfg := unit.Metric{PxPerDp: ppsp, PxPerSp: ppsp}
-	// Only use cnf for getting the window size.
+	// Use the metric of the target screen, or the primary screen
+	// until the window manager has placed the window.
+	screens := x11QueryScreens(dpy)
 	var cnf Config
+	// Only the target screen is needed from this pass.
+	cnf.apply(unit.Metric{}, options)
+	scr, found := findScreen(screens, cnf.Screen.Name)
+	// A popup is placed against its anchor, on the screen of the anchor.
+	anchor, popup := x11PopupAnchor(dpy, cnf)
+	if popup {
+		scr, found = screenAt(screens, anchor.Min)
+	}
+	if !found {
+		scr, _ = primaryScreen(screens)
+	}
+	cfg := screenMetric(scr)
+	// Use cnf for getting the window size and initial hints.
+	cnf = Config{}
 	cnf.apply(cfg, options)
+	var pos image.Point
+	switch {
+	case popup:
+		pos = popupBounds(anchor, cnf.Size, scr.Bounds).Min
+	case found:
+		pos = screenCenter(scr, cnf.Size)
+	}
+	if !popup {
+		// Apply the options over the default position to learn whether
+		// the Position option is present.
+		placed := Config{Position: pos}
+		placed.apply(cfg, options)
+		if placed.Position != pos {
+			pos, found = placed.Position, true
+		}
+	}
 
 	swa := C.XSetWindowAttributes{
 		event_mask: C.ExposureMask | C.FocusChangeMask | // update
 			C.KeyPressMask | C.KeyReleaseMask | // keyboard
 			C.ButtonPressMask | C.ButtonReleaseMask | // mouse clicks
 			C.PointerMotionMask | // mouse movement
-			C.StructureNotifyMask, // resize
+			C.StructureNotifyMask | // resize
+			C.VisibilityChangeMask | // stage
+			C.PropertyChangeMask, // incremental clipboard transfers
 		background_pixmap: C.None,
 		override_redirect: C.False,
 	}
+	if popup {
+		// Popups are placed by us, not the window manager.
+		swa.override_redirect = C.True
+	}
+	mask := C.ulong(C.CWEventMask | C.CWBackPixmap | C.CWOverrideRedirect)
+	depth, visual := C.int(C.CopyFromParent), (*C.Visual)(nil)
+	var visualID C.VisualID
+	if cnf.Transparent {
+		// A window with a different visual than its parent needs its
+		// own colormap and border pixel.
+		if v, id, ok := x11ARGBVisual(dpy); ok {
+			depth, visual, visualID = 32, v, id
+			swa.colormap = C.XCreateColormap(dpy, C.XDefaultRootWindow(dpy), v, C.AllocNone)
+			mask |= C.CWColormap | C.CWBorderPixel
+		}
+	}
 	win := C.XCreateWindow(dpy, C.XDefaultRootWindow(dpy),
-		0, 0, C.uint(cnf.Size.X), C.uint(cnf.Size.Y),
-		0, C.CopyFromParent, C.InputOutput, nil,
-		C.CWEventMask|C.CWBackPixmap|C.CWOverrideRedirect, &swa)
+		C.int(pos.X), C.int(pos.Y), C.uint(cnf.Size.X), C.uint(cnf.Size.Y),
+		0, depth, C.InputOutput, visual, mask, &swa)
 
 	w := &x11Window{
 		w: gioWin, x: dpy, xw: win,
 		metric:       cfg,
+		screens:      screens,
 		xkb:          xkb,
 		xkbEventBase: xkbEventBase,
 		wakeups:      make(chan struct{}, 1),
-		config:       Config{Size: cnf.Size},
+		stage:        StageRunning,
+		config:       Config{Size: cnf.Size, Position: pos, Screen: scr, Popup: popup, Transparent: visualID != 0},
+		visualID:     int(visualID),
 	}
 	w.handler = x11EventHandler{w: w, xev: new(C.XEvent), text: make([]byte, 4)}
 	w.notify.read = pipe[0]
@@ -838,6 +1314,19 @@ func newX11Window(gioWin *callbacks, options []Option) error {
 	hints.flags = C.InputHint
 	C.XSetWMHints(dpy, win, &hints)
 
+	// Position the window content rather than the window manager frame.
+	shints := C.XSizeHints{flags: C.PWinGravity, win_gravity: C.StaticGravity}
+	if found {
+		// Ask the window manager to keep the requested position.
+		shints.flags |= C.USPosition
+		shints.x, shints.y = C.int(pos.X), C.int(pos.Y)
+	}
+	C.XSetWMNormalHints(dpy, win, &shints)
+	if evBase, _ := x11RandR(dpy); evBase != 0 {
+		w.randrEventBase = evBase
+		C.XRRSelectInput(dpy, C.XDefaultRootWindow(dpy), C.RRScreenChangeNotifyMask)
+	}
+
 	name := C.CString(ID)
 	defer C.free(unsafe.Pointer(name))
 	wmhints := C.XClassHint{name, name}
@@ -850,17 +1339,53 @@ func newX11Window(gioWin *callbacks, options []Option) error {
 	w.atoms.clipboard = w.atom("CLIPBOARD", false)
 	w.atoms.primary = w.atom("PRIMARY", false)
 	w.atoms.clipboardContent = w.atom("CLIPBOARD_CONTENT", false)
+	w.atoms.primaryContent = w.atom("GIO_PRIMARY_CONTENT", false)
 	w.atoms.atom = w.atom("ATOM", false)
 	w.atoms.targets = w.atom("TARGETS", false)
+	w.atoms.incr = w.atom("INCR", false)
 	w.atoms.wmName = w.atom("_NET_WM_NAME", false)
+	w.atoms.wmIcon = w.atom("_NET_WM_ICON", false)
 	w.atoms.wmState = w.atom("_NET_WM_STATE", false)
 	w.atoms.wmStateFullscreen = w.atom("_NET_WM_STATE_FULLSCREEN", false)
 	w.atoms.wmActiveWindow = w.atom("_NET_ACTIVE_WINDOW", false)
 	w.atoms.wmStateMaximizedHorz = w.atom("_NET_WM_STATE_MAXIMIZED_HORZ", false)
 	w.atoms.wmStateMaximizedVert = w.atom("_NET_WM_STATE_MAXIMIZED_VERT", false)
+	w.atoms.wmMoveResize = w.atom("_NET_WM_MOVERESIZE", false)
+	w.atoms.wmStateAbove = w.atom("_NET_WM_STATE_ABOVE", false)
+	w.atoms.wmStateSkipTaskbar = w.atom("_NET_WM_STATE_SKIP_TASKBAR", false)
+	w.atoms.wmStateModal = w.atom("_NET_WM_STATE_MODAL", false)
+	w.atoms.wmWindowType = w.atom("_NET_WM_WINDOW_TYPE", false)
+	w.atoms.wmWindowTypeNormal = w.atom("_NET_WM_WINDOW_TYPE_NORMAL", false)
+	w.atoms.wmWindowTypeDialog = w.atom("_NET_WM_WINDOW_TYPE_DIALOG", false)
+	w.atoms.wmWindowTypeUtility = w.atom("_NET_WM_WINDOW_TYPE_UTILITY", false)
+	w.atoms.wmWindowTypeSplash = w.atom("_NET_WM_WINDOW_TYPE_SPLASH", false)
+	w.atoms.wmWindowTypeNotification = w.atom("_NET_WM_WINDOW_TYPE_NOTIFICATION", false)
+	w.atoms.xdndAware = w.atom("XdndAware", false)
+	w.atoms.xdndEnter = w.atom("XdndEnter", false)
+	w.atoms.xdndPosition = w.atom("XdndPosition", false)
+	w.atoms.xdndStatus = w.atom("XdndStatus", false)
+	w.atoms.xdndLeave = w.atom("XdndLeave", false)
+	w.atoms.xdndDrop = w.atom("XdndDrop", false)
+	w.atoms.xdndFinished = w.atom("XdndFinished", false)
+	w.atoms.xdndSelection = w.atom("XdndSelection", false)
+	w.atoms.xdndTypeList = w.atom("XdndTypeList", false)
+	w.atoms.xdndActionCopy = w.atom("XdndActionCopy", false)
+	w.atoms.xdndContent = w.atom("GIO_XDND_CONTENT", false)
 
 	// extensions
 	C.XSetWMProtocols(dpy, win, &w.atoms.evDelWindow, 1)
+	w.initXInput()
+	w.initIME()
+	w.initPresent()
+	w.a11y = newATSPIWindow(w.w, w.Invalidate)
+	// Accept drops from other clients.
+	xdndVersion := C.long(x11XdndVersion)
+	C.XChangeProperty(dpy, win, w.atoms.xdndAware, C.XA_ATOM, 32, C.PropModeReplace,
+		(*C.uchar)(unsafe.Pointer(&xdndVersion)), 1)
+
+	// The window manager reads the type and initial state of the window
+	// when it is mapped.
+	w.setHints(w.config, cnf, false)
 
 	// make the window visible on the screen
 	C.XMapWindow(dpy, win)
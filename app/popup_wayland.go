@@ -0,0 +1,215 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+//go:build linux && !nowayland
+// +build linux,!nowayland
+
+package app
+
+/*
+#include <stdlib.h>
+#include <wayland-client.h>
+#include "wayland_xdg_shell.h"
+
+extern const struct xdg_popup_listener gio_xdg_popup_listener;
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"unsafe"
+
+	syscall "golang.org/x/sys/unix"
+
+	"github.com/mleku/gio/app/internal/xkb"
+)
+
+// wlPopupParent returns the display of the parent of a popup. It
+// reports false if cnf is not a popup of a Wayland window.
+func wlPopupParent(cnf Config) (*wlDisplay, bool) {
+	if !cnf.Popup || cnf.Parent == nil {
+		return nil, false
+	}
+	v, ok := cnf.Parent.view().(WaylandViewEvent)
+	if !ok || !v.Valid() {
+		return nil, false
+	}
+	p, ok := callbackMap.Load(v.Surface)
+	if !ok {
+		return nil, false
+	}
+	w, ok := p.(*wlWindow)
+	if !ok || w.disp == nil {
+		return nil, false
+	}
+	return w.disp, true
+}
+
+// newPopupDisplay returns a display for a popup of the window of d. An
+// xdg_popup must be created on the connection of its parent, so the
+// display shares the connection of d, and dispatches its own event
+// queue. Its input devices are created from the seat of the connection,
+// and see the events of every surface of the connection.
+func (d *wlDisplay) newPopupDisplay() (*wlDisplay, error) {
+	r := d.root()
+	if d.sharedState().closed || r.sharedState().closed || !d.acquire() {
+		return nil, errors.New("wayland: popup parent is closed")
+	}
+	p := &wlDisplay{
+		disp:   r.disp,
+		parent: d,
+	}
+	pipe := make([]int, 2)
+	if err := syscall.Pipe2(pipe, syscall.O_NONBLOCK|syscall.O_CLOEXEC); err != nil {
+		p.release()
+		return nil, fmt.Errorf("wayland: failed to create pipe: %v", err)
+	}
+	p.notify.read = pipe[0]
+	p.notify.write = pipe[1]
+	xkb, err := xkb.New()
+	if err != nil {
+		p.destroy()
+		return nil, fmt.Errorf("wayland: %v", err)
+	}
+	p.xkb = xkb
+	p.queue = C.wl_display_create_queue(p.disp)
+	// New objects are created in the queue of the proxy that creates
+	// them.
+	p.compositor = (*C.struct_wl_compositor)(p.wrap(unsafe.Pointer(r.compositor)))
+	p.wm = (*C.struct_xdg_wm_base)(p.wrap(unsafe.Pointer(r.wm)))
+	p.shm = (*C.struct_wl_shm)(p.wrap(unsafe.Pointer(r.shm)))
+	// Cursor shape devices have no events.
+	p.cursorShape = r.cursorShape
+	if st := r.sharedState(); st.seat != nil {
+		p.seat = &wlSeat{
+			disp:      p,
+			seat:      (*C.struct_wl_seat)(p.wrap(unsafe.Pointer(st.seat))),
+			offers:    make(map[*C.struct_wl_data_offer][]string),
+			touchFoci: make(map[C.int32_t]*wlTouch),
+		}
+		callbackStore(unsafe.Pointer(p.seat.seat), p.seat)
+		p.seat.updateCaps(st.caps)
+	}
+	// Process the keymap of the keyboard.
+	C.wl_display_roundtrip_queue(p.disp, p.queue)
+	return p, nil
+}
+
+// wrap returns a wrapper of proxy that creates objects in the event
+// queue of d, or nil if proxy is nil.
+func (d *wlDisplay) wrap(proxy unsafe.Pointer) unsafe.Pointer {
+	if proxy == nil {
+		return nil
+	}
+	w := C.wl_proxy_create_wrapper(proxy)
+	C.wl_proxy_set_queue((*C.struct_wl_proxy)(w), d.queue)
+	return w
+}
+
+// destroyPopup destroys the wrappers and the event queue of the display
+// of a popup, and releases its connection.
+func (d *wlDisplay) destroyPopup() {
+	for _, w := range []unsafe.Pointer{unsafe.Pointer(d.compositor), unsafe.Pointer(d.wm), unsafe.Pointer(d.shm)} {
+		if w != nil {
+			C.wl_proxy_wrapper_destroy(w)
+		}
+	}
+	d.compositor, d.wm, d.shm, d.cursorShape = nil, nil, nil, nil
+	if d.queue != nil {
+		C.wl_event_queue_destroy(d.queue)
+		d.queue = nil
+	}
+	d.release()
+	d.disp = nil
+}
+
+// createPopup makes w an xdg_popup of the window of its parent display,
+// placed against cnf.Anchor. The popup grabs the seat, so that the
+// compositor dismisses it when the user clicks outside.
+func (w *wlWindow) createPopup(cnf Config) error {
+	parent := w.disp.parent.sharedState()
+	if parent.closed {
+		return errors.New("wayland: popup parent is closed")
+	}
+	w.size = cnf.Size.Div(w.scale)
+	w.config.Popup = true
+	w.config.Parent = cnf.Parent
+	w.config.Anchor = cnf.Anchor
+	// Popups have no decorations.
+	w.config.Decorated = true
+	pos := w.positioner(parent.scale)
+	defer C.xdg_positioner_destroy(pos)
+	w.popup = C.xdg_surface_get_popup(w.wmSurf, parent.wmSurf, pos)
+	if w.popup == nil {
+		return errors.New("wayland: xdg_surface_get_popup failed")
+	}
+	C.xdg_popup_add_listener(w.popup, &C.gio_xdg_popup_listener, unsafe.Pointer(w.surf))
+	if s := w.disp.seat; s != nil {
+		// The serial of the click that opened the popup.
+		C.xdg_popup_grab(w.popup, s.seat, parent.serial)
+	}
+	return nil
+}
+
+// positioner returns a positioner that places the popup below its
+// anchor, or above if there is no room below, like popupBounds. The
+// anchor is in pixels of the parent, whose scale is parentScale.
+func (w *wlWindow) positioner(parentScale int) *C.struct_xdg_positioner {
+	parentScale = max(parentScale, 1)
+	anchor := w.config.Anchor
+	if p := w.config.Parent; p != nil {
+		anchor = anchor.Add(p.contentOffset())
+	}
+	anchor = image.Rectangle{
+		Min: anchor.Min.Div(parentScale),
+		Max: anchor.Max.Div(parentScale),
+	}
+	pos := C.xdg_wm_base_create_positioner(w.disp.wm)
+	// The protocol rejects empty sizes.
+	C.xdg_positioner_set_size(pos, C.int32_t(max(w.size.X, 1)), C.int32_t(max(w.size.Y, 1)))
+	C.xdg_positioner_set_anchor_rect(pos, C.int32_t(anchor.Min.X), C.int32_t(anchor.Min.Y),
+		C.int32_t(max(anchor.Dx(), 1)), C.int32_t(max(anchor.Dy(), 1)))
+	C.xdg_positioner_set_anchor(pos, C.XDG_POSITIONER_ANCHOR_BOTTOM_LEFT)
+	C.xdg_positioner_set_gravity(pos, C.XDG_POSITIONER_GRAVITY_BOTTOM_RIGHT)
+	C.xdg_positioner_set_constraint_adjustment(pos,
+		C.XDG_POSITIONER_CONSTRAINT_ADJUSTMENT_FLIP_Y|C.XDG_POSITIONER_CONSTRAINT_ADJUSTMENT_SLIDE_X)
+	return pos
+}
+
+// configurePopup applies changes to the size and anchor of a popup.
+// Moving a popup needs version 3 of xdg_wm_base.
+func (w *wlWindow) configurePopup(prev, cnf Config) {
+	moved := cnf.Anchor != prev.Anchor || cnf.Size != prev.Size
+	if cnf.Size != prev.Size {
+		w.config.Size = cnf.Size
+		w.size = cnf.Size.Div(w.scale)
+	}
+	w.config.Anchor = cnf.Anchor
+	if !moved || !w.configured || C.xdg_popup_get_version(w.popup) < C.XDG_POPUP_REPOSITION_SINCE_VERSION {
+		return
+	}
+	pos := w.positioner(w.disp.parent.sharedState().scale)
+	defer C.xdg_positioner_destroy(pos)
+	C.xdg_popup_reposition(w.popup, pos, 0)
+}
+
+//export gio_onPopupConfigure
+func gio_onPopupConfigure(data unsafe.Pointer, popup *C.struct_xdg_popup, x, y, width, height C.int32_t) {
+	w := callbackLoad(data).(*wlWindow)
+	if width > 0 && height > 0 {
+		w.size = image.Pt(int(width), int(height))
+	}
+}
+
+//export gio_onPopupDone
+func gio_onPopupDone(data unsafe.Pointer, popup *C.struct_xdg_popup) {
+	w := callbackLoad(data).(*wlWindow)
+	// The compositor dismissed the popup, such as after a click
+	// outside it.
+	w.closing = true
+}
+
+//export gio_onPopupRepositioned
+func gio_onPopupRepositioned(data unsafe.Pointer, popup *C.struct_xdg_popup, token C.uint32_t) {
+}
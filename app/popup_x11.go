@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+//go:build linux && !nox11
+// +build linux,!nox11
+
+package app
+
+/*
+#include <X11/Xlib.h>
+*/
+import "C"
+
+import (
+	"image"
+)
+
+// x11PopupAnchor returns the anchor of a popup in root window
+// coordinates. It reports false if cnf is not a popup of an X11 window.
+func x11PopupAnchor(dpy *C.Display, cnf Config) (image.Rectangle, bool) {
+	if !cnf.Popup || cnf.Parent == nil {
+		return image.Rectangle{}, false
+	}
+	v, ok := cnf.Parent.view().(X11ViewEvent)
+	if !ok || !v.Valid() {
+		return image.Rectangle{}, false
+	}
+	r := cnf.Anchor.Add(cnf.Parent.contentOffset())
+	var x, y C.int
+	var child C.Window
+	if C.XTranslateCoordinates(dpy, C.Window(v.Window), C.XDefaultRootWindow(dpy),
+		C.int(r.Min.X), C.int(r.Min.Y), &x, &y, &child) == C.False {
+		return image.Rectangle{}, false
+	}
+	return r.Add(image.Pt(int(x), int(y)).Sub(r.Min)), true
+}
+
+// placePopup moves a popup against its anchor.
+func (w *x11Window) placePopup() {
+	anchor, ok := x11PopupAnchor(w.x, w.config)
+	if !ok {
+		return
+	}
+	scr, _ := screenAt(w.screens, anchor.Min)
+	pos := popupBounds(anchor, w.config.Size, scr.Bounds).Min
+	C.XMoveWindow(w.x, w.xw, C.int(pos.X), C.int(pos.Y))
+}
+
+// grabPopup grabs the pointer and keyboard for a popup, so that a click
+// outside it is reported. The grabs fail while another client holds
+// them, typically the parent window during the press that opened the
+// popup, and are retried until they succeed.
+func (w *x11Window) grabPopup() {
+	const mask = C.ButtonPressMask | C.ButtonReleaseMask | C.PointerMotionMask
+	ps := C.XGrabPointer(w.x, w.xw, C.True, mask, C.GrabModeAsync, C.GrabModeAsync,
+		C.None, C.None, C.CurrentTime)
+	ks := C.XGrabKeyboard(w.x, w.xw, C.True, C.GrabModeAsync, C.GrabModeAsync, C.CurrentTime)
+	w.grabPending = ps != C.GrabSuccess || ks != C.GrabSuccess
+}
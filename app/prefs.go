@@ -0,0 +1,207 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package app
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+)
+
+// Preferences is a persistent key/value store of application settings,
+// shared by the windows of the program. On desktop systems the
+// preferences are saved under [DataDir], and each change replaces the
+// file atomically. In the browser they are saved in localStorage, shared
+// by the tabs of the page origin.
+//
+// Values are stored as strings. The typed getters return the given
+// default for keys that are missing or don't parse as the type.
+//
+// The methods of Preferences are safe for concurrent use.
+type Preferences struct {
+	name string
+	// store is the platform storage.
+	store prefsStore
+
+	mu     sync.Mutex
+	values map[string]string
+	notify []chan<- PreferencesChange
+}
+
+// PreferencesChange lists the keys of preferences that changed or were
+// deleted, in sorted order.
+type PreferencesChange struct {
+	Keys []string
+}
+
+var openPrefs struct {
+	mu    sync.Mutex
+	prefs map[string]*Preferences
+}
+
+// OpenPreferences returns the preferences saved under name. Every call
+// with the same name returns the same Preferences.
+func OpenPreferences(name string) (*Preferences, error) {
+	if name == "" || filepath.Base(name) != name {
+		return nil, errors.New("app: invalid preferences name")
+	}
+	openPrefs.mu.Lock()
+	defer openPrefs.mu.Unlock()
+	if p, ok := openPrefs.prefs[name]; ok {
+		return p, nil
+	}
+	p := &Preferences{name: name}
+	if err := p.open(); err != nil {
+		return nil, err
+	}
+	if openPrefs.prefs == nil {
+		openPrefs.prefs = make(map[string]*Preferences)
+	}
+	openPrefs.prefs[name] = p
+	return p, nil
+}
+
+// Notify causes changes to the preferences to be sent to c. Changes are
+// reported whether they are made by this program, by another process or
+// by another browser tab. Notify does not block sending to c, so c
+// should be buffered.
+func (p *Preferences) Notify(c chan<- PreferencesChange) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.notify = append(p.notify, c)
+}
+
+// Stop undoes the effect of previous calls to Notify with c.
+func (p *Preferences) Stop(c chan<- PreferencesChange) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.notify = slices.DeleteFunc(p.notify, func(n chan<- PreferencesChange) bool {
+		return n == c
+	})
+}
+
+// Keys returns the keys of the preferences in sorted order.
+func (p *Preferences) Keys() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	keys := make([]string, 0, len(p.values))
+	for k := range p.values {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// String returns the value of key, or def if it is not set.
+func (p *Preferences) String(key, def string) string {
+	if v, ok := p.lookup(key); ok {
+		return v
+	}
+	return def
+}
+
+// Int returns the value of key as an integer, or def.
+func (p *Preferences) Int(key string, def int) int {
+	if v, ok := p.lookup(key); ok {
+		if i, err := strconv.Atoi(v); err == nil {
+			return i
+		}
+	}
+	return def
+}
+
+// Float returns the value of key as a floating-point number, or def.
+func (p *Preferences) Float(key string, def float64) float64 {
+	if v, ok := p.lookup(key); ok {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	}
+	return def
+}
+
+// Bool returns the value of key as a boolean, or def.
+func (p *Preferences) Bool(key string, def bool) bool {
+	if v, ok := p.lookup(key); ok {
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return def
+}
+
+// SetString sets the value of key and saves the preferences.
+func (p *Preferences) SetString(key, value string) error {
+	return p.set(key, &value)
+}
+
+// SetInt sets the value of key to an integer and saves the preferences.
+func (p *Preferences) SetInt(key string, value int) error {
+	return p.SetString(key, strconv.Itoa(value))
+}
+
+// SetFloat sets the value of key to a floating-point number and saves
+// the preferences.
+func (p *Preferences) SetFloat(key string, value float64) error {
+	return p.SetString(key, strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+// SetBool sets the value of key to a boolean and saves the preferences.
+func (p *Preferences) SetBool(key string, value bool) error {
+	return p.SetString(key, strconv.FormatBool(value))
+}
+
+// Delete removes key and saves the preferences.
+func (p *Preferences) Delete(key string) error {
+	return p.set(key, nil)
+}
+
+func (p *Preferences) lookup(key string) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	v, ok := p.values[key]
+	return v, ok
+}
+
+// set stores value under key, or deletes key if value is nil.
+func (p *Preferences) set(key string, value *string) error {
+	if key == "" {
+		return errors.New("app: empty preferences key")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	old, ok := p.values[key]
+	if value == nil && !ok || value != nil && ok && *value == old {
+		return nil
+	}
+	return p.save(key, value)
+}
+
+// update replaces the values with the stored values, and notifies the
+// keys that differ. It must be called with p.mu held.
+func (p *Preferences) update(values map[string]string) {
+	var keys []string
+	for k, v := range values {
+		if old, ok := p.values[k]; !ok || old != v {
+			keys = append(keys, k)
+		}
+	}
+	for k := range p.values {
+		if _, ok := values[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	p.values = values
+	if len(keys) == 0 {
+		return
+	}
+	slices.Sort(keys)
+	for _, c := range p.notify {
+		select {
+		case c <- PreferencesChange{Keys: slices.Clone(keys)}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package app
+
+import (
+	"errors"
+	"strings"
+	"syscall/js"
+)
+
+// prefsStore holds the localStorage items of the preferences, one item
+// per key.
+type prefsStore struct {
+	storage js.Value
+	// prefix is prepended to the keys of the items.
+	prefix string
+}
+
+func (p *Preferences) open() error {
+	storage := js.Global().Get("localStorage")
+	if !storage.Truthy() {
+		return errors.New("app: localStorage is not available")
+	}
+	app, err := appDir()
+	if err != nil {
+		return err
+	}
+	p.store = prefsStore{
+		storage: storage,
+		prefix:  "gio/" + app + "/" + p.name + "/",
+	}
+	p.values = p.store.load()
+	// The storage event reports changes by other tabs.
+	js.Global().Call("addEventListener", "storage", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		e := args[0]
+		if !e.Get("storageArea").Equal(storage) {
+			return nil
+		}
+		// A null key means the storage was cleared.
+		if k := e.Get("key"); !k.IsNull() && !strings.HasPrefix(k.String(), p.store.prefix) {
+			return nil
+		}
+		// Don't block the browser on the lock.
+		go p.reload()
+		return nil
+	}))
+	return nil
+}
+
+// save sets or removes the item of key.
+func (p *Preferences) save(key string, value *string) (err error) {
+	defer func() {
+		// Writes fail with an exception when the storage is full.
+		if r := recover(); r != nil {
+			jsErr, ok := r.(js.Error)
+			if !ok {
+				panic(r)
+			}
+			err = jsErr
+		}
+	}()
+	if value != nil {
+		p.store.storage.Call("setItem", p.store.prefix+key, *value)
+	} else {
+		p.store.storage.Call("removeItem", p.store.prefix+key)
+	}
+	p.update(p.store.load())
+	return nil
+}
+
+func (p *Preferences) reload() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.update(p.store.load())
+}
+
+// load returns the values of the items of the preferences.
+func (s prefsStore) load() map[string]string {
+	values := make(map[string]string)
+	for i, n := 0, s.storage.Length(); i < n; i++ {
+		k := s.storage.Call("key", i).String()
+		if key, ok := strings.CutPrefix(k, s.prefix); ok {
+			values[key] = s.storage.Call("getItem", k).String()
+		}
+	}
+	return values
+}
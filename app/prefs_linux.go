@@ -0,0 +1,154 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"unsafe"
+
+	syscall "golang.org/x/sys/unix"
+)
+
+// prefsStore is the file of the preferences.
+type prefsStore struct {
+	path string
+}
+
+func (p *Preferences) open() error {
+	path, err := appFile("prefs-" + p.name + ".json")
+	if err != nil {
+		return err
+	}
+	p.store.path = path
+	values, err := readPrefs(p.store.path)
+	if err != nil {
+		return err
+	}
+	p.values = values
+	if err := os.MkdirAll(filepath.Dir(p.store.path), 0o700); err != nil {
+		return err
+	}
+	go p.watch()
+	return nil
+}
+
+// save writes the preferences with key changed to value. The file is
+// read first under an exclusive lock, so that concurrent changes by
+// other processes are kept.
+func (p *Preferences) save(key string, value *string) error {
+	unlock, err := p.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	values, err := readPrefs(p.store.path)
+	if err != nil {
+		return err
+	}
+	if value != nil {
+		values[key] = *value
+	} else {
+		delete(values, key)
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(p.store.path, data); err != nil {
+		return err
+	}
+	p.update(values)
+	return nil
+}
+
+// lock takes an exclusive lock on the lock file next to the
+// preferences file. It blocks while another process holds the lock.
+func (p *Preferences) lock() (unlock func(), err error) {
+	f, err := os.OpenFile(p.store.path+".lock", os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+		if err != syscall.EINTR {
+			break
+		}
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	// Closing the file releases the lock.
+	return func() { f.Close() }, nil
+}
+
+// watch reloads the preferences when another process replaces or
+// removes the file. Without inotify, changes by other processes are
+// only seen by the next save.
+func (p *Preferences) watch() {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return
+	}
+	defer syscall.Close(fd)
+	dir, base := filepath.Split(p.store.path)
+	const mask = syscall.IN_MOVED_TO | syscall.IN_CLOSE_WRITE | syscall.IN_DELETE
+	if _, err := syscall.InotifyAddWatch(fd, dir, mask); err != nil {
+		return
+	}
+	var buf [syscall.SizeofInotifyEvent * 64]byte
+	for {
+		n, err := syscall.Read(fd, buf[:])
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil || n <= 0 {
+			return
+		}
+		changed := false
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			off += syscall.SizeofInotifyEvent
+			name := strings.TrimRight(string(buf[off:off+int(ev.Len)]), "\x00")
+			off += int(ev.Len)
+			changed = changed || name == base
+		}
+		if changed {
+			p.reload()
+		}
+	}
+}
+
+func (p *Preferences) reload() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if values, err := readPrefs(p.store.path); err == nil {
+		p.update(values)
+	}
+}
+
+// readPrefs reads the preferences file at path. A missing file holds no
+// preferences.
+func readPrefs(path string) (map[string]string, error) {
+	values := make(map[string]string)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return values, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+	if values == nil {
+		// The file holds null.
+		values = make(map[string]string)
+	}
+	return values, nil
+}
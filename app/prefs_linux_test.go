@@ -0,0 +1,74 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPreferences(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	// Preferences stay open, so use a new name for every run.
+	name := fmt.Sprintf("test-%d", time.Now().UnixNano())
+	p, err := OpenPreferences(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := make(chan PreferencesChange, 10)
+	p.Notify(changes)
+	defer p.Stop(changes)
+	expect := func(keys ...string) {
+		t.Helper()
+		select {
+		case c := <-changes:
+			if !slices.Equal(c.Keys, keys) {
+				t.Errorf("changed keys %q, want %q", c.Keys, keys)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no change of %q", keys)
+		}
+	}
+
+	if err := p.SetInt("width", 640); err != nil {
+		t.Fatal(err)
+	}
+	expect("width")
+	if err := p.SetBool("dark", true); err != nil {
+		t.Fatal(err)
+	}
+	expect("dark")
+	if got := p.Int("width", 0); got != 640 {
+		t.Errorf("Int(width) = %d, want 640", got)
+	}
+	if got := p.Bool("dark", false); !got {
+		t.Errorf("Bool(dark) = %v, want true", got)
+	}
+	if got := p.Float("dark", 1.5); got != 1.5 {
+		t.Errorf("Float(dark) = %v, want the default", got)
+	}
+
+	// Replace the file as another process would.
+	if err := writeFileAtomic(p.store.path, []byte(`{"width":"800"}`)); err != nil {
+		t.Fatal(err)
+	}
+	expect("dark", "width")
+	if got := p.Int("width", 0); got != 800 {
+		t.Errorf("Int(width) = %d after external write, want 800", got)
+	}
+	if err := p.Delete("width"); err != nil {
+		t.Fatal(err)
+	}
+	expect("width")
+	data, err := os.ReadFile(filepath.Join(os.Getenv("XDG_CONFIG_HOME"), ID, "prefs-"+name+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); got != "{}" {
+		t.Errorf("saved %s, want {}", got)
+	}
+}
@@ -0,0 +1,186 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+//go:build linux && !nox11 && !nox11present
+// +build linux,!nox11,!nox11present
+
+package app
+
+/*
+#cgo linux pkg-config: xcb xcb-present
+#cgo freebsd openbsd LDFLAGS: -lxcb -lxcb-present
+
+#include <stdlib.h>
+#include <stdint.h>
+#include <X11/Xlib.h>
+#include <X11/Xlib-xcb.h>
+#include <xcb/xcb.h>
+#include <xcb/present.h>
+
+// gio_x11MSCNotify extracts the counters of a CompleteNotify event
+// for a NotifyMSC request. The event struct is packed, out of reach
+// of cgo.
+static int gio_x11MSCNotify(xcb_generic_event_t *ev, uint64_t *ust, uint64_t *msc) {
+	xcb_present_generic_event_t *ge = (xcb_present_generic_event_t *)ev;
+	if (ge->evtype != XCB_PRESENT_COMPLETE_NOTIFY) {
+		return 0;
+	}
+	xcb_present_complete_notify_event_t *ce = (xcb_present_complete_notify_event_t *)ev;
+	if (ce->kind != XCB_PRESENT_COMPLETE_KIND_NOTIFY_MSC) {
+		return 0;
+	}
+	*ust = ce->ust;
+	*msc = ce->msc;
+	return 1;
+}
+*/
+import "C"
+
+import (
+	"time"
+	"unsafe"
+
+	syscall "golang.org/x/sys/unix"
+)
+
+// maxRefreshInterval is the longest plausible refresh interval.
+const maxRefreshInterval = 100 * time.Millisecond
+
+// x11Present paces the frames of an animating window to the display
+// refresh with the Present extension. Build with the nox11present tag
+// to drop the dependency on the xcb-present library.
+type x11Present struct {
+	conn *C.xcb_connection_t
+	// events receives the notifications of the window, or is nil if
+	// the extension is missing.
+	events *C.xcb_special_event_t
+	serial C.uint32_t
+	// pending is set while waiting for the notification of the next
+	// vertical blank, requested at time requested.
+	pending   bool
+	requested time.Time
+	// ready is set when the display refreshed since the last
+	// animation frame.
+	ready bool
+	// ust is the CLOCK_MONOTONIC time of the most recent vertical
+	// blank and msc its counter.
+	ust time.Duration
+	msc uint64
+	// refresh is the measured refresh interval, or zero.
+	refresh time.Duration
+}
+
+// initPresent selects vertical blank notifications for the window.
+func (w *x11Window) initPresent() {
+	conn := C.XGetXCBConnection(w.x)
+	ext := C.xcb_get_extension_data(conn, &C.xcb_present_id)
+	if ext == nil || ext.present == 0 {
+		return
+	}
+	reply := C.xcb_present_query_version_reply(conn, C.xcb_present_query_version(conn, C.XCB_PRESENT_MAJOR_VERSION, C.XCB_PRESENT_MINOR_VERSION), nil)
+	if reply == nil {
+		return
+	}
+	C.free(unsafe.Pointer(reply))
+	eid := C.xcb_generate_id(conn)
+	C.xcb_present_select_input(conn, eid, C.xcb_window_t(w.xw), C.XCB_PRESENT_EVENT_MASK_COMPLETE_NOTIFY)
+	w.present = x11Present{
+		conn:   conn,
+		events: C.xcb_register_for_special_xge(conn, &C.xcb_present_id, eid, nil),
+	}
+}
+
+func (w *x11Window) destroyPresent() {
+	if p := &w.present; p.events != nil {
+		C.xcb_unregister_for_special_event(p.conn, p.events)
+		p.events = nil
+	}
+}
+
+// vblank reports whether an animation frame is due. Without the
+// Present extension every frame is due. Otherwise, a frame is due when
+// the display refreshed since the previous frame, and the notification
+// of the next refresh is requested if not. Notifications never arrive
+// for windows on no output, so a frame is also due when the
+// notification is late by a refresh interval of the screen.
+func (w *x11Window) vblank() bool {
+	p := &w.present
+	if p.events == nil {
+		return true
+	}
+	p.readNotifications()
+	if p.ready {
+		p.ready = false
+		return true
+	}
+	if !p.pending {
+		// Wait for the refresh after the last one, or the current
+		// counter if the last one is unknown or past.
+		target := C.uint64_t(0)
+		if p.msc != 0 {
+			target = C.uint64_t(p.msc + 1)
+		}
+		p.serial++
+		C.xcb_present_notify_msc(p.conn, C.xcb_window_t(w.xw), p.serial, target, 0, 0)
+		C.xcb_flush(p.conn)
+		p.pending, p.requested = true, time.Now()
+		return false
+	}
+	if time.Since(p.requested) >= w.refreshInterval() {
+		p.pending = false
+		return true
+	}
+	return false
+}
+
+// vblankTimeout returns the time in milliseconds to wait for the
+// notification requested by vblank.
+func (w *x11Window) vblankTimeout() int {
+	p := &w.present
+	wait := time.Until(p.requested.Add(w.refreshInterval()))
+	// Round up to not wake before the notification is late.
+	return max(int((wait+time.Millisecond-1)/time.Millisecond), 0)
+}
+
+// readNotifications processes the queued vertical blank notifications.
+func (p *x11Present) readNotifications() {
+	for {
+		ev := C.xcb_poll_for_special_event(p.conn, p.events)
+		if ev == nil {
+			return
+		}
+		var ust, msc C.uint64_t
+		if C.gio_x11MSCNotify(ev, &ust, &msc) != 0 {
+			p.notify(time.Duration(ust)*time.Microsecond, uint64(msc))
+		}
+		C.free(unsafe.Pointer(ev))
+	}
+}
+
+func (p *x11Present) notify(ust time.Duration, msc uint64) {
+	if p.msc != 0 && msc > p.msc && ust > p.ust {
+		// Measure the interval, ignoring implausible rates such as
+		// those of windows on no output.
+		if r := (ust - p.ust) / time.Duration(msc-p.msc); r >= time.Millisecond && r < maxRefreshInterval {
+			p.refresh = r
+		}
+	}
+	p.ust, p.msc = ust, msc
+	p.pending = false
+	p.ready = true
+}
+
+// predict returns the predicted time of the first display refresh after
+// now, and the refresh interval. It returns the zero time if the
+// refresh is unknown.
+func (p *x11Present) predict(now time.Time) (time.Time, time.Duration) {
+	if p.refresh == 0 {
+		return time.Time{}, 0
+	}
+	var ts syscall.Timespec
+	if err := syscall.ClockGettime(syscall.CLOCK_MONOTONIC, &ts); err != nil {
+		return time.Time{}, 0
+	}
+	mono := time.Duration(ts.Nano())
+	n := (mono-p.ust)/p.refresh + 1
+	return now.Add(p.ust + n*p.refresh - mono), p.refresh
+}
@@ -0,0 +1,31 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+//go:build linux && !nox11 && nox11present
+// +build linux,!nox11,nox11present
+
+package app
+
+import "time"
+
+// x11Present is empty without the Present extension. Animation frames
+// are paced by the swap of the GPU context.
+type x11Present struct{}
+
+func (w *x11Window) initPresent() {}
+
+func (w *x11Window) destroyPresent() {}
+
+// vblank reports that every animation frame is due.
+func (w *x11Window) vblank() bool {
+	return true
+}
+
+// vblankTimeout is never called, because vblank never waits.
+func (w *x11Window) vblankTimeout() int {
+	return -1
+}
+
+// predict returns the zero time, because the refresh is unknown.
+func (p *x11Present) predict(now time.Time) (time.Time, time.Duration) {
+	return time.Time{}, 0
+}
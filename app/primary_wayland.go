@@ -0,0 +1,204 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+//go:build linux && !nowayland
+// +build linux,!nowayland
+
+package app
+
+/*
+#include <stdlib.h>
+#include <wayland-client.h>
+#include "wayland_primary_selection.h"
+
+extern const struct zwp_primary_selection_device_v1_listener gio_primary_selection_device_listener;
+extern const struct zwp_primary_selection_offer_v1_listener gio_primary_selection_offer_listener;
+extern const struct zwp_primary_selection_source_v1_listener gio_primary_selection_source_listener;
+*/
+import "C"
+
+import (
+	"io"
+	"os"
+	"slices"
+	"unsafe"
+
+	syscall "golang.org/x/sys/unix"
+
+	"github.com/mleku/gio/io/input"
+)
+
+// wlPrimary is the primary selection of a seat, through the
+// primary-selection-unstable-v1 protocol.
+type wlPrimary struct {
+	dev *C.struct_zwp_primary_selection_device_v1
+	// offers maps the active offers to their mime types.
+	offers map[*C.struct_zwp_primary_selection_offer_v1][]string
+	// selection is the offer of the primary selection.
+	selection *C.struct_zwp_primary_selection_offer_v1
+	// mimeType is the chosen mime type of selected text, if any.
+	mimeType string
+	// source is the content of the most recent write, if any.
+	source  *C.struct_zwp_primary_selection_source_v1
+	content []input.ClipboardData
+}
+
+// bindPrimaryDevice initializes the primary selection device of the
+// seat if and only if both the primary selection manager and the seat
+// are initialized.
+func (d *wlDisplay) bindPrimaryDevice() {
+	if d.primaryManager == nil || d.seat == nil {
+		return
+	}
+	s := d.seat
+	s.primary.dev = C.zwp_primary_selection_device_manager_v1_get_device(d.primaryManager, s.seat)
+	if s.primary.dev == nil {
+		return
+	}
+	s.primary.offers = make(map[*C.struct_zwp_primary_selection_offer_v1][]string)
+	callbackStore(unsafe.Pointer(s.primary.dev), s)
+	C.zwp_primary_selection_device_v1_add_listener(s.primary.dev, &C.gio_primary_selection_device_listener, unsafe.Pointer(s.primary.dev))
+}
+
+// destroyPrimaryManager destroys the primary selection manager of d.
+func (d *wlDisplay) destroyPrimaryManager() {
+	if d.primaryManager != nil {
+		C.zwp_primary_selection_device_manager_v1_destroy(d.primaryManager)
+		d.primaryManager = nil
+	}
+}
+
+// flush removes the offers that are not the selection.
+func (p *wlPrimary) flush() {
+	for o := range p.offers {
+		if o == p.selection {
+			continue
+		}
+		delete(p.offers, o)
+		callbackDelete(unsafe.Pointer(o))
+		C.zwp_primary_selection_offer_v1_destroy(o)
+	}
+}
+
+func (p *wlPrimary) destroy() {
+	if p.source != nil {
+		callbackDelete(unsafe.Pointer(p.source))
+		C.zwp_primary_selection_source_v1_destroy(p.source)
+		p.source = nil
+		p.content = nil
+	}
+	p.selection = nil
+	p.flush()
+	if p.dev != nil {
+		callbackDelete(unsafe.Pointer(p.dev))
+		C.zwp_primary_selection_device_v1_destroy(p.dev)
+		p.dev = nil
+	}
+}
+
+// readPrimary reads the primary selection content of type mime, or
+// returns nil if the selection holds no such content.
+func (d *wlDisplay) readPrimary(mime string) (io.ReadCloser, error) {
+	if d.seat == nil {
+		return nil, nil
+	}
+	p := &d.seat.primary
+	if p.selection == nil {
+		return nil, nil
+	}
+	switch {
+	case isTextMIME(mime):
+		if p.mimeType == "" {
+			return nil, nil
+		}
+		mime = p.mimeType
+	case !slices.Contains(p.offers[p.selection], mime):
+		return nil, nil
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	// The receive request duplicates the write end of the pipe.
+	defer w.Close()
+	cmime := C.CString(mime)
+	defer C.free(unsafe.Pointer(cmime))
+	C.zwp_primary_selection_offer_v1_receive(p.selection, cmime, C.int32_t(w.Fd()))
+	return r, nil
+}
+
+func (d *wlDisplay) writePrimary(content []input.ClipboardData) {
+	s := d.seat
+	if s == nil {
+		return
+	}
+	p := &s.primary
+	// Clear old offer.
+	if p.source != nil {
+		callbackDelete(unsafe.Pointer(p.source))
+		C.zwp_primary_selection_source_v1_destroy(p.source)
+		p.source = nil
+		p.content = nil
+	}
+	if d.primaryManager == nil || p.dev == nil {
+		return
+	}
+	p.content = content
+	p.source = C.zwp_primary_selection_device_manager_v1_create_source(d.primaryManager)
+	callbackStore(unsafe.Pointer(p.source), s)
+	C.zwp_primary_selection_source_v1_add_listener(p.source, &C.gio_primary_selection_source_listener, unsafe.Pointer(p.source))
+	for _, mime := range offerMIMETypes(content) {
+		cmime := C.CString(mime)
+		C.zwp_primary_selection_source_v1_offer(p.source, cmime)
+		C.free(unsafe.Pointer(cmime))
+	}
+	C.zwp_primary_selection_device_v1_set_selection(p.dev, p.source, s.serial)
+}
+
+//export gio_onPrimarySelectionDataOffer
+func gio_onPrimarySelectionDataOffer(data unsafe.Pointer, dev *C.struct_zwp_primary_selection_device_v1, offer *C.struct_zwp_primary_selection_offer_v1) {
+	s := callbackLoad(data).(*wlSeat)
+	callbackStore(unsafe.Pointer(offer), s)
+	C.zwp_primary_selection_offer_v1_add_listener(offer, &C.gio_primary_selection_offer_listener, unsafe.Pointer(offer))
+	s.primary.offers[offer] = nil
+}
+
+//export gio_onPrimarySelectionSelection
+func gio_onPrimarySelectionSelection(data unsafe.Pointer, dev *C.struct_zwp_primary_selection_device_v1, offer *C.struct_zwp_primary_selection_offer_v1) {
+	s := callbackLoad(data).(*wlSeat)
+	p := &s.primary
+	defer p.flush()
+	p.selection = nil
+	p.mimeType = ""
+	if offer == nil || len(p.offers[offer]) == 0 {
+		return
+	}
+	p.selection = offer
+	p.mimeType = textMIME(p.offers[offer])
+}
+
+//export gio_onPrimarySelectionOffer
+func gio_onPrimarySelectionOffer(data unsafe.Pointer, offer *C.struct_zwp_primary_selection_offer_v1, mime *C.char) {
+	s := callbackLoad(data).(*wlSeat)
+	s.primary.offers[offer] = append(s.primary.offers[offer], C.GoString(mime))
+}
+
+//export gio_onPrimarySelectionSend
+func gio_onPrimarySelectionSend(data unsafe.Pointer, source *C.struct_zwp_primary_selection_source_v1, mime *C.char, fd C.int32_t) {
+	s := callbackLoad(data).(*wlSeat)
+	content := sourceContent(s.primary.content, C.GoString(mime))
+	go func() {
+		defer syscall.Close(int(fd))
+		syscall.Write(int(fd), content)
+	}()
+}
+
+//export gio_onPrimarySelectionCancelled
+func gio_onPrimarySelectionCancelled(data unsafe.Pointer, source *C.struct_zwp_primary_selection_source_v1) {
+	s := callbackLoad(data).(*wlSeat)
+	if p := &s.primary; p.source == source {
+		p.content = nil
+		p.source = nil
+	}
+	callbackDelete(unsafe.Pointer(source))
+	C.zwp_primary_selection_source_v1_destroy(source)
+}
@@ -0,0 +1,105 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package app
+
+import (
+	"image"
+	"math"
+
+	"github.com/mleku/gio/unit"
+)
+
+// Screen describes a monitor attached to the system.
+type Screen struct {
+	// Name identifies the screen, for example "DP-1" or "HDMI-1".
+	Name string
+	// Bounds is the area covered by the screen in the virtual desktop,
+	// in pixels.
+	Bounds image.Rectangle
+	// RefreshRate is the refresh rate in Hz, or zero if unknown.
+	RefreshRate float32
+	// Scale is the number of pixels per device independent pixel
+	// used for windows on the screen.
+	Scale float32
+	// Primary reports whether the screen is the primary screen.
+	Primary bool
+}
+
+// Screens returns the screens attached to the system, or nil if the
+// platform doesn't support listing them.
+func Screens() []Screen {
+	return osScreens()
+}
+
+// findScreen returns the screen named name.
+func findScreen(screens []Screen, name string) (Screen, bool) {
+	for _, s := range screens {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return Screen{}, false
+}
+
+// primaryScreen returns the primary screen, or the first screen if
+// none is marked primary.
+func primaryScreen(screens []Screen) (Screen, bool) {
+	for _, s := range screens {
+		if s.Primary {
+			return s, true
+		}
+	}
+	if len(screens) > 0 {
+		return screens[0], true
+	}
+	return Screen{}, false
+}
+
+// screenAt returns the screen containing p.
+func screenAt(screens []Screen, p image.Point) (Screen, bool) {
+	for _, s := range screens {
+		if p.In(s.Bounds) {
+			return s, true
+		}
+	}
+	return Screen{}, false
+}
+
+// roundScale rounds a scale to a multiple of 1/4 to keep layouts
+// crisp. The result is never below lowest.
+func roundScale(scale, lowest float32) float32 {
+	s := float32(math.Round(float64(scale)*4) / 4)
+	return max(s, lowest)
+}
+
+// screenMetric returns the metric for windows on s.
+func screenMetric(s Screen) unit.Metric {
+	return unit.Metric{PxPerDp: s.Scale, PxPerSp: s.Scale}
+}
+
+// screenCenter returns the position of a window of size sz centered
+// on s.
+func screenCenter(s Screen, sz image.Point) image.Point {
+	return s.Bounds.Min.Add(s.Bounds.Size().Sub(sz).Div(2))
+}
+
+// popupBounds places a popup of size sz below anchor, or above it if
+// there is room only there, and shifts it horizontally to fit within
+// bounds. An empty bounds leaves the popup below the anchor.
+func popupBounds(anchor image.Rectangle, sz image.Point, bounds image.Rectangle) image.Rectangle {
+	r := image.Rectangle{Min: image.Pt(anchor.Min.X, anchor.Max.Y)}
+	r.Max = r.Min.Add(sz)
+	if bounds.Empty() {
+		return r
+	}
+	if r.Max.Y > bounds.Max.Y && anchor.Min.Y-sz.Y >= bounds.Min.Y {
+		r = r.Add(image.Pt(0, anchor.Min.Y-sz.Y-r.Min.Y))
+	}
+	if r.Max.X > bounds.Max.X {
+		r = r.Add(image.Pt(bounds.Max.X-r.Max.X, 0))
+	}
+	if r.Min.X < bounds.Min.X {
+		r = r.Add(image.Pt(bounds.Min.X-r.Min.X, 0))
+	}
+	return r
+}
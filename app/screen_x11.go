@@ -0,0 +1,203 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+//go:build linux && !nox11
+// +build linux,!nox11
+
+package app
+
+/*
+#include <X11/Xlib.h>
+#include <X11/extensions/Xrandr.h>
+*/
+import "C"
+
+import (
+	"image"
+	"time"
+	"unsafe"
+)
+
+// x11ListScreens lists the screens through a temporary connection to
+// the X server.
+func x11ListScreens() []Screen {
+	if err := initX11Threads(); err != nil {
+		return nil
+	}
+	dpy := C.XOpenDisplay(nil)
+	if dpy == nil {
+		return nil
+	}
+	defer C.XCloseDisplay(dpy)
+	return x11QueryScreens(dpy)
+}
+
+// x11RandR returns the event base of the RandR extension, and whether
+// the server supports monitor queries (RandR 1.5 or later).
+func x11RandR(dpy *C.Display) (C.int, bool) {
+	var evBase, errBase C.int
+	if C.XRRQueryExtension(dpy, &evBase, &errBase) == C.False {
+		return 0, false
+	}
+	var major, minor C.int
+	if C.XRRQueryVersion(dpy, &major, &minor) == 0 {
+		return 0, false
+	}
+	return evBase, major > 1 || major == 1 && minor >= 5
+}
+
+// x11QueryScreens lists the monitors of the display. The whole display
+// is reported as a single screen if RandR is not available.
+func x11QueryScreens(dpy *C.Display) []Screen {
+	base := x11DetectUIScale(dpy)
+	if _, ok := x11RandR(dpy); !ok {
+		return []Screen{x11DisplayScreen(dpy, base)}
+	}
+	root := C.XDefaultRootWindow(dpy)
+	var n C.int
+	mons := C.XRRGetMonitors(dpy, root, C.True, &n)
+	if mons == nil || n == 0 {
+		return []Screen{x11DisplayScreen(dpy, base)}
+	}
+	defer C.XRRFreeMonitors(mons)
+	monitors := unsafe.Slice(mons, n)
+	res := C.XRRGetScreenResourcesCurrent(dpy, root)
+	if res != nil {
+		defer C.XRRFreeScreenResources(res)
+	}
+	// Xft.dpi is tuned by the user for the primary monitor. Scale the
+	// other monitors by their density relative to it.
+	var refDPI float32
+	for _, m := range monitors {
+		if m.primary != C.False {
+			refDPI = x11MonitorDPI(m)
+		}
+	}
+	if refDPI == 0 {
+		refDPI = x11MonitorDPI(monitors[0])
+	}
+	screens := make([]Screen, 0, len(monitors))
+	for _, m := range monitors {
+		s := Screen{
+			Bounds:  image.Rect(int(m.x), int(m.y), int(m.x+m.width), int(m.y+m.height)),
+			Scale:   base,
+			Primary: m.primary != C.False,
+		}
+		if name := C.XGetAtomName(dpy, m.name); name != nil {
+			s.Name = C.GoString(name)
+			C.XFree(unsafe.Pointer(name))
+		}
+		if dpi := x11MonitorDPI(m); dpi > 0 && refDPI > 0 {
+			s.Scale = roundScale(base*dpi/refDPI, min(base, 1))
+		}
+		if res != nil && m.noutput > 0 {
+			s.RefreshRate = x11RefreshRate(dpy, res, *m.outputs)
+		}
+		screens = append(screens, s)
+	}
+	return screens
+}
+
+// x11DisplayScreen describes the whole display as a screen.
+func x11DisplayScreen(dpy *C.Display, scale float32) Screen {
+	scr := C.XDefaultScreen(dpy)
+	return Screen{
+		Name:    C.GoString(C.XDisplayString(dpy)),
+		Bounds:  image.Rect(0, 0, int(C.XDisplayWidth(dpy, scr)), int(C.XDisplayHeight(dpy, scr))),
+		Scale:   scale,
+		Primary: true,
+	}
+}
+
+// x11MonitorDPI returns the horizontal density of a monitor, or zero if
+// its physical size is unknown or implausible as reported by some
+// projectors and virtual outputs.
+func x11MonitorDPI(m C.XRRMonitorInfo) float32 {
+	if m.mwidth <= 0 {
+		return 0
+	}
+	const mmPerInch = 25.4
+	dpi := float32(m.width) * mmPerInch / float32(m.mwidth)
+	if dpi < 50 || dpi > 800 {
+		return 0
+	}
+	return dpi
+}
+
+// x11RefreshRate returns the refresh rate of the mode driving output,
+// or zero if the output is disabled.
+func x11RefreshRate(dpy *C.Display, res *C.XRRScreenResources, output C.RROutput) float32 {
+	oinfo := C.XRRGetOutputInfo(dpy, res, output)
+	if oinfo == nil {
+		return 0
+	}
+	defer C.XRRFreeOutputInfo(oinfo)
+	if oinfo.crtc == 0 {
+		return 0
+	}
+	cinfo := C.XRRGetCrtcInfo(dpy, res, oinfo.crtc)
+	if cinfo == nil {
+		return 0
+	}
+	defer C.XRRFreeCrtcInfo(cinfo)
+	for _, mode := range unsafe.Slice(res.modes, res.nmode) {
+		if mode.id != cinfo.mode {
+			continue
+		}
+		if mode.hTotal == 0 || mode.vTotal == 0 {
+			return 0
+		}
+		vTotal := float32(mode.vTotal)
+		if mode.modeFlags&C.RR_DoubleScan != 0 {
+			vTotal *= 2
+		}
+		if mode.modeFlags&C.RR_Interlace != 0 {
+			vTotal /= 2
+		}
+		return float32(mode.dotClock) / (float32(mode.hTotal) * vTotal)
+	}
+	return 0
+}
+
+// refreshInterval returns the refresh interval of the screen of the
+// window, or that of a 60 Hz display if its refresh rate is unknown.
+func (w *x11Window) refreshInterval() time.Duration {
+	rate := w.config.Screen.RefreshRate
+	if rate <= 0 {
+		rate = 60
+	}
+	return time.Duration(float64(time.Second) / float64(rate))
+}
+
+// updatePosition tracks the position of the window and the screen
+// containing its center, and reports whether they changed.
+func (w *x11Window) updatePosition() (moved, screenChanged bool) {
+	var x, y C.int
+	var child C.Window
+	root := C.XDefaultRootWindow(w.x)
+	if C.XTranslateCoordinates(w.x, w.xw, root, 0, 0, &x, &y, &child) == C.False {
+		return false, false
+	}
+	pos := image.Pt(int(x), int(y))
+	if pos != w.config.Position {
+		w.config.Position = pos
+		moved = true
+	}
+	center := pos.Add(w.config.Size.Div(2))
+	s, ok := screenAt(w.screens, center)
+	if !ok || s == w.config.Screen {
+		return moved, false
+	}
+	w.config.Screen = s
+	w.metric = screenMetric(s)
+	return moved, true
+}
+
+// moveToScreen centers the window on the screen named name.
+func (w *x11Window) moveToScreen(name string) {
+	s, ok := findScreen(w.screens, name)
+	if !ok {
+		return
+	}
+	pos := screenCenter(s, w.config.Size)
+	C.XMoveWindow(w.x, w.xw, C.int(pos.X), C.int(pos.Y))
+}
@@ -2,6 +2,11 @@
 
 package app
 
+import (
+	"image"
+	"net/url"
+)
+
 // DestroyEvent is the last event sent through
 // a window event channel.
 type DestroyEvent struct {
@@ -11,3 +16,77 @@ type DestroyEvent struct {
 }
 
 func (DestroyEvent) ImplementsEvent() {}
+
+// ScreenshotEvent carries the copy of a frame requested by
+// Window.Screenshot.
+type ScreenshotEvent struct {
+	// Image is the frame, in sRGB with premultiplied alpha. It is nil
+	// if the copy failed.
+	Image *image.RGBA
+	// Err is the cause of a failed copy.
+	Err error
+}
+
+func (ScreenshotEvent) ImplementsEvent() {}
+
+// URLEvent is sent when the address of the window changes. In the
+// browser, it carries the page URL on startup and after navigation
+// through the history or the URL fragment. On Linux, it carries the URL
+// passed on the command line to a program registered as an
+// x-scheme-handler.
+//
+// Use [io/system.URLCmd] to change the address.
+type URLEvent struct {
+	URL *url.URL
+}
+
+func (URLEvent) ImplementsEvent() {}
+
+// Stage is the visibility of a window.
+type Stage uint8
+
+const (
+	// StageHidden is for windows that are not shown, such as minimized
+	// windows and browser pages in background tabs.
+	StageHidden Stage = iota
+	// StagePaused is for windows that are shown but fully covered by
+	// other windows.
+	StagePaused
+	// StageRunning is for visible windows.
+	StageRunning
+)
+
+// StageEvent is sent when the stage of a window changes. Windows start
+// in StageRunning. Animation frames are not drawn while a window is
+// below StageRunning, but the program may still draw in response to
+// FrameEvents sent by the platform.
+//
+// The stages reported by each platform are:
+//
+//   - X11: StageHidden while the window is unmapped, such as when it is
+//     minimized, and StagePaused while it is fully covered.
+//   - Wayland: StageHidden when the compositor stops the frame callbacks
+//     of an animating window for a second, which compositors do for
+//     windows that are not shown. Windows that don't animate stay in
+//     StageRunning.
+//   - JS: StageHidden while the page is hidden, such as in a background
+//     tab.
+//   - Headless windows: the stages injected by [HeadlessWindow.Inject].
+type StageEvent struct {
+	Stage Stage
+}
+
+func (StageEvent) ImplementsEvent() {}
+
+func (s Stage) String() string {
+	switch s {
+	case StageHidden:
+		return "StageHidden"
+	case StagePaused:
+		return "StagePaused"
+	case StageRunning:
+		return "StageRunning"
+	default:
+		panic("unexpected Stage value")
+	}
+}
@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+//go:build linux && !nox11
+// +build linux,!nox11
+
+package app
+
+/*
+#cgo linux pkg-config: xext
+#cgo freebsd openbsd LDFLAGS: -lXext
+
+#include <X11/Xlib.h>
+#include <X11/Xutil.h>
+#include <X11/extensions/shape.h>
+*/
+import "C"
+
+import (
+	"image"
+	"math"
+)
+
+// x11ARGBVisual returns the 32-bit visual with an alpha channel of the
+// default screen, used by transparent windows.
+func x11ARGBVisual(dpy *C.Display) (*C.Visual, C.VisualID, bool) {
+	var info C.XVisualInfo
+	if C.XMatchVisualInfo(dpy, C.XDefaultScreen(dpy), 32, C.TrueColor, &info) == 0 {
+		return nil, 0, false
+	}
+	return info.visual, info.visualid, true
+}
+
+// setInputRegion restricts pointer input to the rectangles through the
+// input shape of the window, or restores input to the whole window if
+// there are none.
+func (w *x11Window) setInputRegion(rects []image.Rectangle) {
+	var evBase, errBase C.int
+	if C.XShapeQueryExtension(w.x, &evBase, &errBase) == C.False {
+		return
+	}
+	if len(rects) == 0 {
+		C.XShapeCombineMask(w.x, w.xw, C.ShapeInput, 0, 0, C.None, C.ShapeSet)
+		return
+	}
+	clampPos := func(v int) C.short {
+		return C.short(max(math.MinInt16, min(v, math.MaxInt16)))
+	}
+	clampSize := func(v int) C.ushort {
+		return C.ushort(max(0, min(v, math.MaxUint16)))
+	}
+	xrects := make([]C.XRectangle, len(rects))
+	for i, r := range rects {
+		r = r.Canon()
+		xrects[i] = C.XRectangle{
+			x:      clampPos(r.Min.X),
+			y:      clampPos(r.Min.Y),
+			width:  clampSize(r.Dx()),
+			height: clampSize(r.Dy()),
+		}
+	}
+	C.XShapeCombineRectangles(w.x, w.xw, C.ShapeInput, 0, 0, &xrects[0], C.int(len(xrects)), C.ShapeSet, C.Unsorted)
+}
@@ -0,0 +1,168 @@
+/* SPDX-License-Identifier: MIT */
+
+/* Client API for the cursor-shape-v1 protocol. */
+
+#ifndef CURSOR_SHAPE_V1_CLIENT_PROTOCOL_H
+#define CURSOR_SHAPE_V1_CLIENT_PROTOCOL_H
+
+#include <stdint.h>
+#include <stddef.h>
+#include "wayland-client.h"
+
+#ifdef  __cplusplus
+extern "C" {
+#endif
+
+struct wl_pointer;
+struct wp_cursor_shape_device_v1;
+struct wp_cursor_shape_manager_v1;
+struct zwp_tablet_tool_v2;
+
+extern const struct wl_interface wp_cursor_shape_manager_v1_interface;
+extern const struct wl_interface wp_cursor_shape_device_v1_interface;
+
+#define WP_CURSOR_SHAPE_MANAGER_V1_DESTROY 0
+#define WP_CURSOR_SHAPE_MANAGER_V1_GET_POINTER 1
+#define WP_CURSOR_SHAPE_MANAGER_V1_GET_TABLET_TOOL_V2 2
+
+#define WP_CURSOR_SHAPE_MANAGER_V1_DESTROY_SINCE_VERSION 1
+#define WP_CURSOR_SHAPE_MANAGER_V1_GET_POINTER_SINCE_VERSION 1
+#define WP_CURSOR_SHAPE_MANAGER_V1_GET_TABLET_TOOL_V2_SINCE_VERSION 1
+
+static inline void
+wp_cursor_shape_manager_v1_set_user_data(struct wp_cursor_shape_manager_v1 *wp_cursor_shape_manager_v1, void *user_data)
+{
+	wl_proxy_set_user_data((struct wl_proxy *) wp_cursor_shape_manager_v1, user_data);
+}
+
+static inline void *
+wp_cursor_shape_manager_v1_get_user_data(struct wp_cursor_shape_manager_v1 *wp_cursor_shape_manager_v1)
+{
+	return wl_proxy_get_user_data((struct wl_proxy *) wp_cursor_shape_manager_v1);
+}
+
+static inline uint32_t
+wp_cursor_shape_manager_v1_get_version(struct wp_cursor_shape_manager_v1 *wp_cursor_shape_manager_v1)
+{
+	return wl_proxy_get_version((struct wl_proxy *) wp_cursor_shape_manager_v1);
+}
+
+static inline void
+wp_cursor_shape_manager_v1_destroy(struct wp_cursor_shape_manager_v1 *wp_cursor_shape_manager_v1)
+{
+	wl_proxy_marshal_flags((struct wl_proxy *) wp_cursor_shape_manager_v1,
+			 WP_CURSOR_SHAPE_MANAGER_V1_DESTROY, NULL, wl_proxy_get_version((struct wl_proxy *) wp_cursor_shape_manager_v1), WL_MARSHAL_FLAG_DESTROY);
+}
+
+static inline struct wp_cursor_shape_device_v1 *
+wp_cursor_shape_manager_v1_get_pointer(struct wp_cursor_shape_manager_v1 *wp_cursor_shape_manager_v1, struct wl_pointer *pointer)
+{
+	struct wl_proxy *cursor_shape_device;
+
+	cursor_shape_device = wl_proxy_marshal_flags((struct wl_proxy *) wp_cursor_shape_manager_v1,
+			 WP_CURSOR_SHAPE_MANAGER_V1_GET_POINTER, &wp_cursor_shape_device_v1_interface, wl_proxy_get_version((struct wl_proxy *) wp_cursor_shape_manager_v1), 0, NULL, pointer);
+
+	return (struct wp_cursor_shape_device_v1 *) cursor_shape_device;
+}
+
+static inline struct wp_cursor_shape_device_v1 *
+wp_cursor_shape_manager_v1_get_tablet_tool_v2(struct wp_cursor_shape_manager_v1 *wp_cursor_shape_manager_v1, struct zwp_tablet_tool_v2 *tablet_tool)
+{
+	struct wl_proxy *cursor_shape_device;
+
+	cursor_shape_device = wl_proxy_marshal_flags((struct wl_proxy *) wp_cursor_shape_manager_v1,
+			 WP_CURSOR_SHAPE_MANAGER_V1_GET_TABLET_TOOL_V2, &wp_cursor_shape_device_v1_interface, wl_proxy_get_version((struct wl_proxy *) wp_cursor_shape_manager_v1), 0, NULL, tablet_tool);
+
+	return (struct wp_cursor_shape_device_v1 *) cursor_shape_device;
+}
+
+#ifndef WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_ENUM
+#define WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_ENUM
+enum wp_cursor_shape_device_v1_shape {
+	WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_DEFAULT = 1,
+	WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_CONTEXT_MENU = 2,
+	WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_HELP = 3,
+	WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_POINTER = 4,
+	WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_PROGRESS = 5,
+	WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_WAIT = 6,
+	WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_CELL = 7,
+	WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_CROSSHAIR = 8,
+	WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_TEXT = 9,
+	WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_VERTICAL_TEXT = 10,
+	WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_ALIAS = 11,
+	WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_COPY = 12,
+	WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_MOVE = 13,
+	WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_NO_DROP = 14,
+	WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_NOT_ALLOWED = 15,
+	WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_GRAB = 16,
+	WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_GRABBING = 17,
+	WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_E_RESIZE = 18,
+	WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_N_RESIZE = 19,
+	WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_NE_RESIZE = 20,
+	WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_NW_RESIZE = 21,
+	WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_S_RESIZE = 22,
+	WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_SE_RESIZE = 23,
+	WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_SW_RESIZE = 24,
+	WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_W_RESIZE = 25,
+	WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_EW_RESIZE = 26,
+	WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_NS_RESIZE = 27,
+	WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_NESW_RESIZE = 28,
+	WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_NWSE_RESIZE = 29,
+	WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_COL_RESIZE = 30,
+	WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_ROW_RESIZE = 31,
+	WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_ALL_SCROLL = 32,
+	WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_ZOOM_IN = 33,
+	WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_ZOOM_OUT = 34,
+};
+#endif /* WP_CURSOR_SHAPE_DEVICE_V1_SHAPE_ENUM */
+
+#ifndef WP_CURSOR_SHAPE_DEVICE_V1_ERROR_ENUM
+#define WP_CURSOR_SHAPE_DEVICE_V1_ERROR_ENUM
+enum wp_cursor_shape_device_v1_error {
+	WP_CURSOR_SHAPE_DEVICE_V1_ERROR_INVALID_SHAPE = 1,
+};
+#endif /* WP_CURSOR_SHAPE_DEVICE_V1_ERROR_ENUM */
+
+#define WP_CURSOR_SHAPE_DEVICE_V1_DESTROY 0
+#define WP_CURSOR_SHAPE_DEVICE_V1_SET_SHAPE 1
+
+#define WP_CURSOR_SHAPE_DEVICE_V1_DESTROY_SINCE_VERSION 1
+#define WP_CURSOR_SHAPE_DEVICE_V1_SET_SHAPE_SINCE_VERSION 1
+
+static inline void
+wp_cursor_shape_device_v1_set_user_data(struct wp_cursor_shape_device_v1 *wp_cursor_shape_device_v1, void *user_data)
+{
+	wl_proxy_set_user_data((struct wl_proxy *) wp_cursor_shape_device_v1, user_data);
+}
+
+static inline void *
+wp_cursor_shape_device_v1_get_user_data(struct wp_cursor_shape_device_v1 *wp_cursor_shape_device_v1)
+{
+	return wl_proxy_get_user_data((struct wl_proxy *) wp_cursor_shape_device_v1);
+}
+
+static inline uint32_t
+wp_cursor_shape_device_v1_get_version(struct wp_cursor_shape_device_v1 *wp_cursor_shape_device_v1)
+{
+	return wl_proxy_get_version((struct wl_proxy *) wp_cursor_shape_device_v1);
+}
+
+static inline void
+wp_cursor_shape_device_v1_destroy(struct wp_cursor_shape_device_v1 *wp_cursor_shape_device_v1)
+{
+	wl_proxy_marshal_flags((struct wl_proxy *) wp_cursor_shape_device_v1,
+			 WP_CURSOR_SHAPE_DEVICE_V1_DESTROY, NULL, wl_proxy_get_version((struct wl_proxy *) wp_cursor_shape_device_v1), WL_MARSHAL_FLAG_DESTROY);
+}
+
+static inline void
+wp_cursor_shape_device_v1_set_shape(struct wp_cursor_shape_device_v1 *wp_cursor_shape_device_v1, uint32_t serial, uint32_t shape)
+{
+	wl_proxy_marshal_flags((struct wl_proxy *) wp_cursor_shape_device_v1,
+			 WP_CURSOR_SHAPE_DEVICE_V1_SET_SHAPE, NULL, wl_proxy_get_version((struct wl_proxy *) wp_cursor_shape_device_v1), 0, serial, shape);
+}
+
+#ifdef  __cplusplus
+}
+#endif
+
+#endif
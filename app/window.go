@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"image"
 	"image/color"
+	"io"
 	"runtime"
+	"slices"
 	"sync"
 	"time"
 	"unicode/utf8"
@@ -17,6 +19,7 @@ import (
 	"github.com/mleku/gio/gpu"
 	"github.com/mleku/gio/internal/debug"
 	"github.com/mleku/gio/internal/ops"
+	"github.com/mleku/gio/io/clipboard"
 	"github.com/mleku/gio/io/event"
 	"github.com/mleku/gio/io/input"
 	"github.com/mleku/gio/io/key"
@@ -57,12 +60,20 @@ type Window struct {
 	animating    bool
 	hasNextFrame bool
 	nextFrame    time.Time
+	// stage is the last reported stage of the window.
+	stage Stage
+	// forceFrame requests the next frame even if the window is not
+	// running. It is set by invalidations from outside the frame
+	// loop and by screenshots, but not by the continuous animation
+	// of frames that invalidate themselves.
+	forceFrame bool
 	// viewport is the latest frame size with insets applied.
 	viewport image.Rectangle
 	// metric is the metric from the most recent frame.
 	metric      unit.Metric
 	queue       input.Router
 	cursor      pointer.Cursor
+	imageCursor *pointer.ImageCursor
 	decorations struct {
 		op.Ops
 		// enabled tracks the Decorated option as
@@ -89,6 +100,15 @@ type Window struct {
 	}
 	imeState editorState
 	driver   driver
+	// nativeView is the most recent ViewEvent, for relating windows to
+	// each other. It is protected by invMu.
+	nativeView ViewEvent
+	// viewOffset is the offset of the frame content in the native view,
+	// protected by invMu.
+	viewOffset image.Point
+	// screenshot is set when the next frame is to be copied for a
+	// ScreenshotEvent.
+	screenshot bool
 	// gpuErr tracks the GPU error that is to be reported when
 	// the window is closed.
 	gpuErr error
@@ -115,6 +135,9 @@ type eventSummary struct {
 	view         *ViewEvent
 	frame        *frameEvent
 	framePending bool
+	screenshot   *ScreenshotEvent
+	url          *URLEvent
+	stage        *StageEvent
 	destroy      *DestroyEvent
 }
 
@@ -207,18 +230,44 @@ func (w *Window) validateAndProcess(size image.Point, sync bool, frame *op.Ops,
 }
 
 func (w *Window) frame(frame *op.Ops, viewport image.Point) error {
-	if runtime.GOOS == "js" {
-		// Use transparent black when Gio is embedded, to allow mixing of Gio and
-		// foreign content below.
-		w.gpu.Clear(color.NRGBA{A: 0x00, R: 0x00, G: 0x00, B: 0x00})
-	} else {
-		w.gpu.Clear(color.NRGBA{A: 0xff, R: 0xff, G: 0xff, B: 0xff})
+	clearColor := color.NRGBA{A: 0xff, R: 0xff, G: 0xff, B: 0xff}
+	if runtime.GOOS == "js" || w.decorations.Config.Transparent {
+		// Use transparent black when Gio is embedded or the window is
+		// transparent, to allow mixing of Gio and foreign content below.
+		clearColor = color.NRGBA{A: 0x00, R: 0x00, G: 0x00, B: 0x00}
 	}
+	w.gpu.Clear(clearColor)
 	target, err := w.ctx.RenderTarget()
 	if err != nil {
 		return err
 	}
-	return w.gpu.Frame(frame, target, viewport)
+	if err := w.gpu.Frame(frame, target, viewport); err != nil {
+		return err
+	}
+	if w.screenshot {
+		w.screenshot = false
+		w.coalesced.screenshot = w.readFrame(viewport)
+	}
+	return nil
+}
+
+// frameReader is implemented by GPUs that can copy the frame they drew
+// last.
+type frameReader interface {
+	ReadFrame(img *image.RGBA) error
+}
+
+// readFrame copies the frame drawn last, before it is presented.
+func (w *Window) readFrame(viewport image.Point) *ScreenshotEvent {
+	r, ok := w.gpu.(frameReader)
+	if !ok {
+		return &ScreenshotEvent{Err: errors.New("app: Screenshot is not supported by the GPU")}
+	}
+	img := image.NewRGBA(image.Rectangle{Max: viewport})
+	if err := r.ReadFrame(img); err != nil {
+		return &ScreenshotEvent{Err: err}
+	}
+	return &ScreenshotEvent{Image: img}
 }
 
 func (w *Window) processFrame(frame *op.Ops, ack chan<- struct{}) {
@@ -253,11 +302,23 @@ func (w *Window) updateState() {
 	if hint, ok := q.TextInputHint(); ok {
 		w.driver.SetInputHint(hint)
 	}
-	if mime, txt, ok := q.WriteClipboard(); ok {
-		w.driver.WriteClipboard(mime, txt)
+	if content, ok := q.WriteClipboard(); ok {
+		w.driver.WriteClipboard(clipboard.Clipboard, content)
+	}
+	if content, ok := q.WritePrimary(); ok {
+		w.driver.WriteClipboard(clipboard.Primary, content)
 	}
 	if q.ClipboardRequested() {
-		w.driver.ReadClipboard()
+		w.driver.ReadClipboard(clipboard.Clipboard, q.ClipboardTypes(clipboard.Clipboard))
+	}
+	if q.PrimaryRequested() {
+		w.driver.ReadClipboard(clipboard.Primary, q.ClipboardTypes(clipboard.Primary))
+	}
+	if mime, data, ok := q.ExportData(); ok {
+		w.driver.ExportData(mime, data)
+	}
+	for _, cmd := range q.URLs() {
+		w.driver.SetURL(cmd.URL, cmd.Replace)
 	}
 	oldState := w.imeState
 	newState := oldState
@@ -289,6 +350,25 @@ func (w *Window) Invalidate() {
 	}
 }
 
+// Screenshot requests a copy of the next frame of the window, delivered
+// by Event as a ScreenshotEvent after the frame is drawn. The copy
+// includes the decorations drawn by Gio, but not those of the platform.
+//
+// Screenshot is not supported by windows with a CustomRenderer, nor by
+// the Vulkan backend.
+func (w *Window) Screenshot() {
+	w.Run(func() {
+		if w.nocontext {
+			w.coalesced.screenshot = &ScreenshotEvent{Err: errors.New("app: Screenshot is not supported with a CustomRenderer")}
+		} else {
+			w.screenshot = true
+			w.forceFrame = true
+		}
+		w.setNextFrame(time.Time{})
+		w.updateAnimation()
+	})
+}
+
 // Option applies the options to the window. The options are hints; the platform is
 // free to ignore or adjust them.
 func (w *Window) Option(opts ...Option) {
@@ -342,7 +422,9 @@ func (w *Window) updateAnimation() {
 	animate := false
 	if w.hasNextFrame {
 		if dt := time.Until(w.nextFrame); dt <= 0 {
-			animate = true
+			// Only visible windows animate; the frames of others
+			// wait until the window runs again.
+			animate = w.stage == StageRunning || w.forceFrame
 		} else {
 			// Schedule redraw.
 			w.scheduleInvalidate(w.nextFrame)
@@ -399,6 +481,21 @@ func (c *callbacks) SetDriver(d driver) {
 	c.w.driver = d
 }
 
+// view returns the most recent ViewEvent of the window, or nil.
+func (w *Window) view() ViewEvent {
+	w.invMu.Lock()
+	defer w.invMu.Unlock()
+	return w.nativeView
+}
+
+// contentOffset returns the offset of the frame content in the native
+// view, such as the height of fallback decorations.
+func (w *Window) contentOffset() image.Point {
+	w.invMu.Lock()
+	defer w.invMu.Unlock()
+	return w.viewOffset
+}
+
 func (c *callbacks) ProcessFrame(frame *op.Ops, ack chan<- struct{}) {
 	c.w.processFrame(frame, ack)
 }
@@ -499,10 +596,91 @@ func (c *callbacks) ClickFocus() {
 	c.w.updateAnimation()
 }
 
+// SemanticFocus returns the semantic node containing the focused
+// handler, if any.
+func (c *callbacks) SemanticFocus() (input.SemanticID, bool) {
+	return c.w.queue.SemanticFocus()
+}
+
+// FocusSemantic focuses the semantic node id on behalf of assistive
+// technology.
+func (c *callbacks) FocusSemantic(id input.SemanticID) {
+	if c.w.queue.FocusSemantic(id) {
+		c.w.setNextFrame(time.Time{})
+		c.w.updateAnimation()
+	}
+}
+
+// ClickSemantic clicks the semantic node id on behalf of assistive
+// technology.
+func (c *callbacks) ClickSemantic(id input.SemanticID) {
+	if c.w.queue.ClickSemantic(id) {
+		c.w.setNextFrame(time.Time{})
+		c.w.updateAnimation()
+	}
+}
+
 func (c *callbacks) ActionAt(p f32.Point) (system.Action, bool) {
 	return c.w.queue.ActionAt(p)
 }
 
+// DragEnter starts a drag and drop transfer from another application
+// offering the MIME types mimes.
+func (c *callbacks) DragEnter(mimes []string) {
+	c.w.queue.DragEnter(mimes)
+	c.w.wakeupQueue()
+}
+
+// DragOver moves the transfer started by DragEnter to p, and reports the
+// MIME type accepted by the target at p, if any.
+func (c *callbacks) DragOver(p f32.Point) (string, bool) {
+	return c.w.queue.DragOver(p)
+}
+
+// Drop completes the transfer started by DragEnter.
+func (c *callbacks) Drop(mime string, data io.ReadCloser) {
+	c.w.queue.Drop(mime, data)
+	c.w.wakeupQueue()
+}
+
+// DragLeave cancels the transfer started by DragEnter.
+func (c *callbacks) DragLeave() {
+	c.w.queue.DragLeave()
+	c.w.wakeupQueue()
+}
+
+// DragSource returns the MIME types of the drag in progress, if any.
+func (c *callbacks) DragSource() ([]string, bool) {
+	return c.w.queue.DragSource()
+}
+
+// ExportDrag hands the drag in progress over to another application.
+func (c *callbacks) ExportDrag() {
+	c.w.queue.ExportDrag()
+	c.w.wakeupQueue()
+}
+
+// RequestExport requests the data of the exported drag. The data is
+// delivered through driver.ExportData.
+func (c *callbacks) RequestExport(mime string) {
+	c.w.queue.RequestExport(mime)
+	c.w.wakeupQueue()
+}
+
+// CancelExport cancels the exported drag.
+func (c *callbacks) CancelExport() {
+	c.w.queue.CancelExport()
+	c.w.wakeupQueue()
+}
+
+// wakeupQueue schedules a frame if the router has pending events.
+func (w *Window) wakeupQueue() {
+	if t, handled := w.queue.WakeupTime(); handled {
+		w.setNextFrame(t)
+		w.updateAnimation()
+	}
+}
+
 func (w *Window) destroyGPU() {
 	if w.gpu != nil {
 		w.ctx.Lock()
@@ -553,6 +731,7 @@ func (w *Window) collectSemanticDiffs(diffs *[]input.SemanticID, n input.Semanti
 }
 
 func (c *callbacks) Invalidate() {
+	c.w.forceFrame = true
 	c.w.setNextFrame(time.Time{})
 	c.w.updateAnimation()
 	// Guarantee a wakeup, even when not animating.
@@ -587,6 +766,18 @@ func (w *Window) nextEvent() (event.Event, bool) {
 		e := *s.cfg
 		s.cfg = nil
 		return e, true
+	case s.stage != nil:
+		e := *s.stage
+		s.stage = nil
+		return e, true
+	case s.url != nil:
+		e := *s.url
+		s.url = nil
+		return e, true
+	case s.screenshot != nil:
+		e := *s.screenshot
+		s.screenshot = nil
+		return e, true
 	case s.frame != nil:
 		e := *s.frame
 		s.frame = nil
@@ -611,6 +802,7 @@ func (w *Window) processEvent(e event.Event) bool {
 		}
 		w.metric = e2.Metric
 		w.hasNextFrame = false
+		w.forceFrame = false
 		e2.Frame = w.driver.Frame
 		e2.Source = w.queue.Source()
 		// Prepare the decorations and update the frame insets.
@@ -637,6 +829,9 @@ func (w *Window) processEvent(e event.Event) bool {
 		w.lastFrame.size = e2.Size
 		w.lastFrame.sync = e2.Sync
 		w.lastFrame.off = offset
+		w.invMu.Lock()
+		w.viewOffset = offset
+		w.invMu.Unlock()
 		e2.Size = e2.Size.Sub(offset)
 		w.coalesced.frame = &e2
 	case DestroyEvent:
@@ -647,12 +842,19 @@ func (w *Window) processEvent(e event.Event) bool {
 		w.invMu.Lock()
 		w.mayInvalidate = false
 		w.driver = nil
+		w.nativeView = nil
 		w.invMu.Unlock()
 		if q := w.timer.quit; q != nil {
 			q <- struct{}{}
 			<-q
 		}
 		w.coalesced.destroy = &e2
+	case URLEvent:
+		w.coalesced.url = &e2
+	case StageEvent:
+		w.stage = e2.Stage
+		w.coalesced.stage = &e2
+		w.updateAnimation()
 	case ViewEvent:
 		if !e2.Valid() && w.gpu != nil {
 			w.ctx.Lock()
@@ -660,6 +862,9 @@ func (w *Window) processEvent(e event.Event) bool {
 			w.gpu = nil
 			w.ctx.Unlock()
 		}
+		w.invMu.Lock()
+		w.nativeView = e2
+		w.invMu.Unlock()
 		w.coalesced.view = &e2
 	case ConfigEvent:
 		w.decorations.Decorations.Maximized = e2.Config.Mode == Maximized
@@ -765,8 +970,13 @@ func (w *Window) init() {
 	w.decorations.enabled = cnf.Decorated
 	w.decorations.height = decoHeight
 	w.imeState.compose = key.Range{Start: -1, End: -1}
+	w.stage = StageRunning
 	w.semantic.ids = make(map[input.SemanticID]input.SemanticNode)
-	newWindow(&callbacks{w}, options)
+	if useHeadless(cnf) {
+		newHeadlessWindow(&callbacks{w}, options)
+	} else {
+		newWindow(&callbacks{w}, options)
+	}
 	for _, acts := range w.initialActions {
 		w.Perform(acts)
 	}
@@ -774,8 +984,14 @@ func (w *Window) init() {
 }
 
 func (w *Window) updateCursor() {
-	if c := w.queue.Cursor(); c != w.cursor {
-		w.cursor = c
+	c, img := w.queue.Cursor(), w.queue.ImageCursor()
+	if c == w.cursor && img == w.imageCursor {
+		return
+	}
+	w.cursor, w.imageCursor = c, img
+	if img != nil {
+		w.driver.SetImageCursor(img)
+	} else {
 		w.driver.SetCursor(c)
 	}
 }
@@ -792,7 +1008,7 @@ func (w *Window) decorate(e FrameEvent, o *op.Ops) image.Point {
 	}
 	deco := w.decorations.Decorations
 	allActions := system.ActionMinimize | system.ActionMaximize | system.ActionUnmaximize |
-		system.ActionClose | system.ActionMove
+		system.ActionClose | system.ActionMove | system.ActionResize
 	style := material.Decorations(w.decorations.Theme, deco, allActions, w.decorations.Config.Title)
 	// Update the decorations based on the current window mode.
 	var actions system.Action
@@ -885,6 +1101,26 @@ func Title(t string) Option {
 	}
 }
 
+// Icon sets the window icon. Pass the icon in several sizes, or a single
+// large image from which the smaller sizes are scaled. In browsers, Icon
+// sets the page favicon. Icon can be changed while the window is open,
+// for example to show a badge.
+func Icon(imgs ...image.Image) Option {
+	imgs = slices.Clone(imgs)
+	return func(_ unit.Metric, cnf *Config) {
+		cnf.Icon = imgs
+	}
+}
+
+// OnScreen places the window on a screen returned by Screens. The
+// window is centered on the screen, unless a window manager or the
+// platform decides otherwise.
+func OnScreen(s Screen) Option {
+	return func(_ unit.Metric, cnf *Config) {
+		cnf.Screen = s
+	}
+}
+
 // Size sets the size of the window. The mode will be changed to Windowed.
 func Size(w, h unit.Dp) Option {
 	if w <= 0 {
@@ -902,6 +1138,15 @@ func Size(w, h unit.Dp) Option {
 	}
 }
 
+// Position moves the window content to p, in the coordinates of the
+// screen bounds (see Screens). Platforms that don't support placing
+// windows, such as Wayland, ignore it.
+func Position(p image.Point) Option {
+	return func(_ unit.Metric, cnf *Config) {
+		cnf.Position = p
+	}
+}
+
 // MaxSize sets the maximum size of the window.
 func MaxSize(w, h unit.Dp) Option {
 	if w <= 0 {
@@ -934,7 +1179,7 @@ func MinSize(w, h unit.Dp) Option {
 	}
 }
 
-// StatusColor sets the color of the status bar (unused on Linux/X11).
+// StatusColor sets the color of the status bar (unused on Linux).
 func StatusColor(color color.NRGBA) Option {
 	return func(_ unit.Metric, cnf *Config) {
 		cnf.StatusColor = color
@@ -969,6 +1214,87 @@ func Decorated(enabled bool) Option {
 	}
 }
 
+// AlwaysOnTop keeps the window above other windows.
+func AlwaysOnTop(above bool) Option {
+	return func(_ unit.Metric, cnf *Config) {
+		cnf.AlwaysOnTop = above
+	}
+}
+
+// SkipTaskbar leaves the window out of taskbars and window switchers.
+func SkipTaskbar(skip bool) Option {
+	return func(_ unit.Metric, cnf *Config) {
+		cnf.SkipTaskbar = skip
+	}
+}
+
+// TransientFor makes the window transient for parent, such as a dialog
+// that stays above and is minimized with its parent. A nil parent
+// removes the relationship. The parent window should be created before
+// the option is applied.
+func TransientFor(parent *Window) Option {
+	return func(_ unit.Metric, cnf *Config) {
+		cnf.Parent = parent
+	}
+}
+
+// Popup makes the window a borderless popup of parent, such as a menu
+// or a tooltip, placed against anchor, a rectangle in the coordinates
+// of the parent frames. The popup is placed below the anchor, or above
+// it if there is no room below. It grabs the pointer and keyboard, and a
+// click outside the popup closes it.
+//
+// Popup must be given before the window is created, after parent is
+// created. Changing the anchor moves the popup.
+//
+// On Wayland, the popup is an xdg_popup of parent, and the compositor
+// places and closes it. Moving an open popup needs a compositor with
+// version 3 of xdg_wm_base, and Wayland popups have no clipboard or
+// input method. Popups of windows that are not Wayland windows are
+// opened by the X11 driver.
+func Popup(parent *Window, anchor image.Rectangle) Option {
+	return func(_ unit.Metric, cnf *Config) {
+		cnf.Parent = parent
+		cnf.Popup = true
+		cnf.Anchor = anchor
+		cnf.Decorated = false
+	}
+}
+
+// Modal controls whether the window blocks input to the window it is
+// transient for (see TransientFor).
+func Modal(modal bool) Option {
+	return func(_ unit.Metric, cnf *Config) {
+		cnf.Modal = modal
+	}
+}
+
+// Transparent makes the window background transparent, so that the
+// desktop shows through where the frame paints nothing or paints
+// translucent colors. Transparency needs a compositing window manager
+// and must be set when the window is created; later changes are
+// ignored.
+func Transparent(transparent bool) Option {
+	return func(_ unit.Metric, cnf *Config) {
+		cnf.Transparent = transparent
+	}
+}
+
+// InputRegion restricts pointer input to the rectangles, in pixels of
+// the window. Pointer events outside the region pass through to the
+// windows below, which is useful for the transparent parts of a
+// Transparent window. No rectangles restore input to the whole window.
+// InputRegion is only supported on X11.
+//
+// The region is a union of rectangles rather than a clip.Op, because
+// the Shape extension of X11 takes rectangles, and the region is not
+// rasterized to approximate curved or transformed clip paths.
+func InputRegion(rects ...image.Rectangle) Option {
+	return func(_ unit.Metric, cnf *Config) {
+		cnf.InputRegion = slices.Clone(rects)
+	}
+}
+
 // flushEvent is sent to detect when the user program
 // has completed processing of all prior events. Its an
 // [io/event.Event] but only for internal use.
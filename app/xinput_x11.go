@@ -0,0 +1,327 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+//go:build linux && !nox11
+// +build linux,!nox11
+
+package app
+
+/*
+#include <stdlib.h>
+#include <X11/Xlib.h>
+#include <X11/extensions/XInput2.h>
+*/
+import "C"
+
+import (
+	"slices"
+	"strings"
+	"time"
+	"unsafe"
+
+	"github.com/mleku/gio/f32"
+	"github.com/mleku/gio/io/key"
+	"github.com/mleku/gio/io/pointer"
+)
+
+// x11XInput tracks the XInput2 devices and touches of a window.
+type x11XInput struct {
+	// opcode is the major opcode of the extension, or zero if XInput 2.2
+	// is not available.
+	opcode C.int
+	// devices caches the axes of the slave devices by id.
+	devices map[C.int]*x11InputDevice
+	// touches holds the active touch sequences. The pointer.ID of a
+	// touch is its index plus one, leaving zero for the mouse.
+	touches []x11Touch
+	// device is the master device of the last button press.
+	device C.int
+	// Axis labels.
+	absPressure, absMTPressure, absTiltX, absTiltY C.Atom
+}
+
+type x11Touch struct {
+	detail C.int
+	active bool
+}
+
+// x11InputDevice describes the axes of an input device.
+type x11InputDevice struct {
+	pen, eraser            bool
+	pressure, tiltX, tiltY x11Valuator
+	scroll                 []x11ScrollValuator
+	// pressureVal and tilt are the last reported values, for events
+	// that omit unchanged axes.
+	pressureVal float32
+	tilt        f32.Point
+}
+
+// x11Valuator describes a device axis. Its number is -1 if the device
+// lacks the axis.
+type x11Valuator struct {
+	number   C.int
+	min, max float64
+}
+
+// x11ScrollValuator tracks an axis for smooth scrolling.
+type x11ScrollValuator struct {
+	number     C.int
+	horizontal bool
+	// increment is the axis distance of a scroll step.
+	increment float64
+	// last is the last value of the axis, if valid.
+	last  float64
+	valid bool
+}
+
+// initXInput selects XInput2 events for the window. The server then
+// stops sending core pointer events to it.
+func (w *x11Window) initXInput() {
+	var opcode, evBase, errBase C.int
+	name := C.CString("XInputExtension")
+	defer C.free(unsafe.Pointer(name))
+	if C.XQueryExtension(w.x, name, &opcode, &evBase, &errBase) == C.False {
+		return
+	}
+	// Touch events need XInput 2.2.
+	major, minor := C.int(2), C.int(2)
+	if C.XIQueryVersion(w.x, &major, &minor) != C.Success || major < 2 || major == 2 && minor < 2 {
+		return
+	}
+	const maskLen = C.XI_LASTEVENT>>3 + 1
+	bits := (*[2 * maskLen]C.uchar)(C.calloc(2*maskLen, 1))
+	defer C.free(unsafe.Pointer(bits))
+	set := func(mask []C.uchar, evs ...C.int) {
+		for _, ev := range evs {
+			mask[ev>>3] |= 1 << (ev & 7)
+		}
+	}
+	set(bits[:maskLen], C.XI_ButtonPress, C.XI_ButtonRelease, C.XI_Motion, C.XI_Enter,
+		C.XI_DeviceChanged, C.XI_TouchBegin, C.XI_TouchUpdate, C.XI_TouchEnd)
+	set(bits[maskLen:], C.XI_HierarchyChanged)
+	masks := [2]C.XIEventMask{
+		{deviceid: C.XIAllMasterDevices, mask_len: maskLen, mask: &bits[0]},
+		{deviceid: C.XIAllDevices, mask_len: maskLen, mask: &bits[maskLen]},
+	}
+	if C.XISelectEvents(w.x, w.xw, &masks[0], C.int(len(masks))) != C.Success {
+		return
+	}
+	w.xi.opcode = opcode
+	w.xi.absPressure = w.atom("Abs Pressure", false)
+	w.xi.absMTPressure = w.atom("Abs MT Pressure", false)
+	w.xi.absTiltX = w.atom("Abs Tilt X", false)
+	w.xi.absTiltY = w.atom("Abs Tilt Y", false)
+}
+
+func (w *x11Window) handleXInput(cookie *C.XGenericEventCookie) {
+	switch cookie.evtype {
+	case C.XI_HierarchyChanged, C.XI_DeviceChanged:
+		// Devices were added, removed or switched; query their axes
+		// again when used.
+		clear(w.xi.devices)
+		return
+	case C.XI_Enter:
+		// The scroll axes may have changed while the pointer was
+		// elsewhere.
+		for _, d := range w.xi.devices {
+			for i := range d.scroll {
+				d.scroll[i].valid = false
+			}
+		}
+		return
+	}
+	xev := (*C.XIDeviceEvent)(cookie.data)
+	dev := w.inputDevice(xev.sourceid)
+	ev := pointer.Event{
+		Source: pointer.Mouse,
+		Position: f32.Point{
+			X: float32(xev.event_x),
+			Y: float32(xev.event_y),
+		},
+		Time:      time.Duration(xev.time) * time.Millisecond,
+		Modifiers: w.xkb.Modifiers(),
+	}
+	ev.Pressure, ev.Tilt = dev.penState(&xev.valuators)
+	if dev.pen {
+		ev.Source = pointer.Pen
+		ev.Eraser = dev.eraser
+	}
+	switch xev.evtype {
+	case C.XI_ButtonPress, C.XI_ButtonRelease:
+		if xev.flags&C.XIPointerEmulated != 0 {
+			// Wheel buttons emulated from smooth scrolling.
+			return
+		}
+		ev.Kind = pointer.Press
+		if xev.evtype == C.XI_ButtonRelease {
+			ev.Kind = pointer.Release
+		}
+		w.xi.device = xev.deviceid
+		w.pointerButton(ev, C.uint(xev.detail), C.int(xev.root_x), C.int(xev.root_y), xev.time)
+	case C.XI_Motion:
+		if d := dev.scrollDelta(&xev.valuators); d != (f32.Point{}) {
+			// scroll left or right if shift is pressed.
+			if ev.Modifiers == key.ModShift && d.X == 0 {
+				d.X, d.Y = d.Y, 0
+			}
+			ev.Kind = pointer.Scroll
+			ev.Scroll = d.Mul(scrollScale)
+			ev.Buttons = w.pointerBtns
+			w.ProcessEvent(ev)
+			return
+		}
+		w.pointerMotion(ev, C.int(xev.root_x), C.int(xev.root_y), xev.time)
+	case C.XI_TouchBegin, C.XI_TouchUpdate, C.XI_TouchEnd:
+		ev.Source = pointer.Touch
+		ev.Eraser = false
+		ev.Tilt = f32.Point{}
+		switch xev.evtype {
+		case C.XI_TouchBegin:
+			ev.Kind = pointer.Press
+		case C.XI_TouchUpdate:
+			ev.Kind = pointer.Move
+		case C.XI_TouchEnd:
+			ev.Kind = pointer.Release
+		}
+		ev.PointerID = w.xi.touchID(xev.detail, ev.Kind == pointer.Release)
+		w.ProcessEvent(ev)
+	}
+}
+
+// ungrabXInput releases the implicit grab of the last button press, if
+// XInput2 is in use.
+func (w *x11Window) ungrabXInput(t C.Time) {
+	if w.xi.opcode != 0 {
+		C.XIUngrabDevice(w.x, w.xi.device, t)
+	}
+}
+
+// inputDevice returns the axes of the device with the id.
+func (w *x11Window) inputDevice(id C.int) *x11InputDevice {
+	if d, ok := w.xi.devices[id]; ok {
+		return d
+	}
+	d := &x11InputDevice{
+		pressure: x11Valuator{number: -1},
+		tiltX:    x11Valuator{number: -1},
+		tiltY:    x11Valuator{number: -1},
+	}
+	var n C.int
+	if info := C.XIQueryDevice(w.x, id, &n); info != nil {
+		touch := false
+		for _, class := range unsafe.Slice(info.classes, info.num_classes) {
+			switch class._type {
+			case C.XIValuatorClass:
+				vc := (*C.XIValuatorClassInfo)(unsafe.Pointer(class))
+				v := x11Valuator{number: vc.number, min: float64(vc.min), max: float64(vc.max)}
+				switch vc.label {
+				case w.xi.absPressure, w.xi.absMTPressure:
+					d.pressure = v
+				case w.xi.absTiltX:
+					d.tiltX = v
+				case w.xi.absTiltY:
+					d.tiltY = v
+				}
+			case C.XIScrollClass:
+				sc := (*C.XIScrollClassInfo)(unsafe.Pointer(class))
+				d.scroll = append(d.scroll, x11ScrollValuator{
+					number:     sc.number,
+					horizontal: sc.scroll_type == C.XIScrollTypeHorizontal,
+					increment:  float64(sc.increment),
+				})
+			case C.XITouchClass:
+				touch = true
+			}
+		}
+		// Tablet drivers name the eraser end of a pen as a separate
+		// device, for example "Wacom Intuos Pen eraser".
+		d.pen = !touch && d.pressure.number != -1
+		d.eraser = d.pen && strings.Contains(strings.ToLower(C.GoString(info.name)), "eraser")
+		C.XIFreeDeviceInfo(info)
+	}
+	if w.xi.devices == nil {
+		w.xi.devices = make(map[C.int]*x11InputDevice)
+	}
+	w.xi.devices[id] = d
+	return d
+}
+
+// penState returns the normalized pressure and the tilt in degrees.
+func (d *x11InputDevice) penState(s *C.XIValuatorState) (float32, f32.Point) {
+	if v, ok := valuatorValue(s, d.pressure.number); ok && d.pressure.max > d.pressure.min {
+		d.pressureVal = float32((v - d.pressure.min) / (d.pressure.max - d.pressure.min))
+	}
+	// The tablet drivers report tilt in degrees.
+	if v, ok := valuatorValue(s, d.tiltX.number); ok {
+		d.tilt.X = float32(max(-90, min(v, 90)))
+	}
+	if v, ok := valuatorValue(s, d.tiltY.number); ok {
+		d.tilt.Y = float32(max(-90, min(v, 90)))
+	}
+	return d.pressureVal, d.tilt
+}
+
+// scrollDelta returns the scroll since the previous event, in steps.
+func (d *x11InputDevice) scrollDelta(s *C.XIValuatorState) f32.Point {
+	var delta f32.Point
+	for i := range d.scroll {
+		sv := &d.scroll[i]
+		v, ok := valuatorValue(s, sv.number)
+		if !ok {
+			continue
+		}
+		if sv.valid && sv.increment != 0 {
+			steps := float32((v - sv.last) / sv.increment)
+			if sv.horizontal {
+				delta.X += steps
+			} else {
+				delta.Y += steps
+			}
+		}
+		sv.last, sv.valid = v, true
+	}
+	return delta
+}
+
+// valuatorValue returns the value of axis number, if present in s.
+func valuatorValue(s *C.XIValuatorState, number C.int) (float64, bool) {
+	if number < 0 || number >= s.mask_len*8 {
+		return 0, false
+	}
+	mask := unsafe.Slice(s.mask, s.mask_len)
+	isSet := func(n C.int) bool {
+		return mask[n>>3]&(1<<(n&7)) != 0
+	}
+	if !isSet(number) {
+		return 0, false
+	}
+	// Values are packed for the axes present.
+	idx := 0
+	for n := range number {
+		if isSet(n) {
+			idx++
+		}
+	}
+	return float64(unsafe.Slice(s.values, idx+1)[idx]), true
+}
+
+// touchID returns the pointer ID of a touch sequence. The ID is freed
+// for reuse when the touch ends.
+func (x *x11XInput) touchID(detail C.int, end bool) pointer.ID {
+	slot := slices.IndexFunc(x.touches, func(t x11Touch) bool {
+		return t.active && t.detail == detail
+	})
+	if slot == -1 {
+		slot = slices.IndexFunc(x.touches, func(t x11Touch) bool {
+			return !t.active
+		})
+		if slot == -1 {
+			slot = len(x.touches)
+			x.touches = append(x.touches, x11Touch{})
+		}
+		x.touches[slot] = x11Touch{detail: detail, active: true}
+	}
+	if end {
+		x.touches[slot].active = false
+	}
+	return pointer.ID(slot + 1)
+}
@@ -21,9 +21,14 @@
                 ++ (if stdenv.isLinux then [
                   vulkan-headers
                   libxkbcommon
+                  wayland
                   xorg.libX11
                   xorg.libXcursor
                   xorg.libXfixes
+                  xorg.libXrandr
+                  xorg.libXi
+                  xorg.libXext
+                  xorg.libxcb
                   libGL
                   pkg-config
                 ] else
@@ -23,6 +23,9 @@ type MetalRenderTarget = driver.MetalRenderTarget
 // VulkanRenderTarget is a render target suitable for the Vulkan backend.
 type VulkanRenderTarget = driver.VulkanRenderTarget
 
+// CPURenderTarget is a render target suitable for the CPU rasterizer.
+type CPURenderTarget = driver.CPURenderTarget
+
 // OpenGL denotes the OpenGL or OpenGL ES API.
 type OpenGL = driver.OpenGL
 
@@ -35,6 +38,9 @@ type Metal = driver.Metal
 // Vulkan denotes the Vulkan API.
 type Vulkan = driver.Vulkan
 
+// CPU denotes the pure Go rasterizer that runs without a GPU.
+type CPU = driver.CPU
+
 // ErrDeviceLost is returned from GPU operations when the underlying GPU device
 // is lost and should be recreated.
 var ErrDeviceLost = driver.ErrDeviceLost
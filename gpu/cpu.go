@@ -0,0 +1,850 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package gpu
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/mleku/gio/internal/f32"
+	"github.com/mleku/gio/internal/f32color"
+	"github.com/mleku/gio/internal/ops"
+	"github.com/mleku/gio/internal/scene"
+	"github.com/mleku/gio/internal/stroke"
+	"github.com/mleku/gio/op"
+)
+
+// cpuGPU implements GPU by rasterizing frames in memory. It is slow compared
+// to the GPU renderers, but works in environments without a GPU driver such as
+// containers and CI machines.
+//
+// Like the GPU renderers, colors are blended in linear space with
+// premultiplied alpha, and the result is stored in premultiplied sRGB.
+type cpuGPU struct {
+	clear      bool
+	clearColor f32color.RGBA
+	viewport   image.Point
+	reader     ops.Reader
+	states     []f32.Affine2D
+	transStack []f32.Affine2D
+	// layers is the stack of opacity layers. The bottom layer is the frame
+	// buffer and retains its contents between frames.
+	layers []*cpuLayer
+	// freeLayers contains released layers for re-use.
+	freeLayers []*cpuLayer
+	quads      []stroke.StrokeQuad
+	scratch    []stroke.QuadSegment
+	fill       []float32
+	textures   map[*image.RGBA]*cpuTexture
+	// target is the render target of the last frame.
+	target *image.RGBA
+}
+
+// cpuLayer is a buffer of linear, premultiplied pixels the size of the
+// viewport.
+type cpuLayer struct {
+	pix     []f32color.RGBA
+	opacity float32
+	// dirty is the area drawn since the layer was pushed.
+	dirty image.Rectangle
+}
+
+// cpuClip is an entry in the clip stack. It is the intersection of
+// its own area and the area of its parent.
+type cpuClip struct {
+	parent *cpuClip
+	// bounds in pixels.
+	bounds image.Rectangle
+	// mask contains the coverage of bounds, or nil if bounds is
+	// completely covered.
+	mask *cpuMask
+}
+
+// cpuMask contains the coverage of the pixels in rect.
+type cpuMask struct {
+	rect image.Rectangle
+	cov  []float32
+}
+
+// cpuTexture is an image converted to linear colors, along with its
+// mipmaps.
+type cpuTexture struct {
+	levels []cpuTexLevel
+	// used tracks whether the texture was used in the current frame.
+	used bool
+}
+
+type cpuTexLevel struct {
+	size image.Point
+	pix  []f32color.RGBA
+}
+
+type cpuState struct {
+	t    f32.Affine2D
+	clip *cpuClip
+
+	matType materialType
+	color   f32color.RGBA
+	image   imageOpData
+	stop1   f32.Point
+	stop2   f32.Point
+	color1  f32color.RGBA
+	color2  f32color.RGBA
+}
+
+// srgbToLinear maps 8-bit sRGB values to linear values.
+var srgbToLinear [256]float32
+
+func init() {
+	for i := range srgbToLinear {
+		srgbToLinear[i] = f32color.LinearFromSRGB(color.NRGBA{R: uint8(i), A: 0xff}).R
+	}
+}
+
+func newCPUGPU() *cpuGPU {
+	return new(cpuGPU)
+}
+
+func (g *cpuGPU) Release() {
+	*g = cpuGPU{}
+}
+
+func (g *cpuGPU) Clear(col color.NRGBA) {
+	g.clear = true
+	g.clearColor = f32color.LinearFromSRGB(col)
+}
+
+func (g *cpuGPU) Frame(frame *op.Ops, target RenderTarget, viewport image.Point) error {
+	t, ok := target.(CPURenderTarget)
+	if !ok || t.Image == nil {
+		return fmt.Errorf("gpu: render target %T not supported by the CPU rasterizer", target)
+	}
+	g.resize(viewport)
+	fb := g.layers[0]
+	if g.clear {
+		g.clear = false
+		for i := range fb.pix {
+			fb.pix[i] = g.clearColor
+		}
+	}
+	var o *ops.Ops
+	if frame != nil {
+		o = &frame.Internal
+	}
+	g.reader.Reset(o)
+	g.collect()
+	// Discard unbalanced layers.
+	for len(g.layers) > 1 {
+		g.popLayer()
+	}
+	g.releaseTextures()
+	g.output(t.Image)
+	g.target = t.Image
+	return nil
+}
+
+// ReadFrame copies the frame drawn by the last Frame into img, with
+// the origin at img.Rect.Min.
+func (g *cpuGPU) ReadFrame(img *image.RGBA) error {
+	if g.target == nil {
+		return errors.New("gpu: no frame to read")
+	}
+	draw.Draw(img, img.Bounds(), g.target, g.target.Rect.Min, draw.Src)
+	return nil
+}
+
+func (g *cpuGPU) resize(viewport image.Point) {
+	if viewport.X < 0 {
+		viewport.X = 0
+	}
+	if viewport.Y < 0 {
+		viewport.Y = 0
+	}
+	if len(g.layers) == 0 {
+		g.layers = append(g.layers, new(cpuLayer))
+	}
+	if g.viewport == viewport {
+		return
+	}
+	g.viewport = viewport
+	// Layer contents are not preserved across resizes.
+	g.clear = true
+	g.freeLayers = g.freeLayers[:0]
+	fb := g.layers[0]
+	fb.pix = make([]f32color.RGBA, viewport.X*viewport.Y)
+}
+
+func (g *cpuGPU) collect() {
+	state := cpuState{t: f32.AffineId()}
+	reset := func() {
+		state = cpuState{
+			t:     f32.AffineId(),
+			color: f32color.RGBA{A: 1},
+		}
+	}
+	reset()
+	g.transStack = g.transStack[:0]
+	var (
+		pathData    []byte
+		strokeWidth float32
+	)
+	r := &g.reader
+loop:
+	for encOp, ok := r.Decode(); ok; encOp, ok = r.Decode() {
+		switch ops.OpType(encOp.Data[0]) {
+		case ops.TypeTransform:
+			dop, push := ops.DecodeTransform(encOp.Data)
+			if push {
+				g.transStack = append(g.transStack, state.t)
+			}
+			state.t = state.t.Mul(dop)
+		case ops.TypePopTransform:
+			n := len(g.transStack)
+			state.t = g.transStack[n-1]
+			g.transStack = g.transStack[:n-1]
+
+		case ops.TypePushOpacity:
+			g.pushLayer(ops.DecodeOpacity(encOp.Data))
+		case ops.TypePopOpacity:
+			g.popLayer()
+
+		case ops.TypeStroke:
+			strokeWidth = decodeStrokeOp(encOp.Data)
+		case ops.TypePath:
+			encOp, ok = r.Decode()
+			if !ok {
+				break loop
+			}
+			pathData = encOp.Data[ops.TypeAuxLen:]
+		case ops.TypeClip:
+			var op ops.ClipOp
+			op.Decode(encOp.Data)
+			state.clip = g.pushClip(state.clip, state.t, op, pathData, strokeWidth)
+			pathData, strokeWidth = nil, 0
+		case ops.TypePopClip:
+			state.clip = state.clip.parent
+
+		case ops.TypeColor:
+			state.matType = materialColor
+			state.color = f32color.LinearFromSRGB(decodeColorOp(encOp.Data))
+		case ops.TypeLinearGradient:
+			state.matType = materialLinearGradient
+			op := decodeLinearGradientOp(encOp.Data)
+			state.stop1 = op.stop1
+			state.stop2 = op.stop2
+			state.color1 = f32color.LinearFromSRGB(op.color1)
+			state.color2 = f32color.LinearFromSRGB(op.color2)
+		case ops.TypeImage:
+			state.matType = materialTexture
+			state.image = decodeImageOp(encOp.Data, encOp.Refs)
+		case ops.TypePaint:
+			g.paint(&state)
+		case ops.TypeSave:
+			id := ops.DecodeSave(encOp.Data)
+			if extra := id - len(g.states) + 1; extra > 0 {
+				for range extra {
+					g.states = append(g.states, f32.AffineId())
+				}
+			}
+			g.states[id] = state.t
+		case ops.TypeLoad:
+			reset()
+			id := ops.DecodeLoad(encOp.Data)
+			state.t = g.states[id]
+		}
+	}
+}
+
+func (g *cpuGPU) pushLayer(opacity float32) {
+	var l *cpuLayer
+	if n := len(g.freeLayers); n > 0 {
+		l = g.freeLayers[n-1]
+		g.freeLayers = g.freeLayers[:n-1]
+	} else {
+		l = &cpuLayer{pix: make([]f32color.RGBA, g.viewport.X*g.viewport.Y)}
+	}
+	l.opacity = opacity
+	l.dirty = image.Rectangle{}
+	g.layers = append(g.layers, l)
+}
+
+// popLayer composites the top layer onto the layer below.
+func (g *cpuGPU) popLayer() {
+	n := len(g.layers)
+	l := g.layers[n-1]
+	g.layers = g.layers[:n-1]
+	dst := g.layers[n-2]
+	r := l.dirty
+	dst.dirty = dst.dirty.Union(r)
+	stride := g.viewport.X
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		row := y * stride
+		for x := r.Min.X; x < r.Max.X; x++ {
+			idx := row + x
+			src := l.pix[idx]
+			l.pix[idx] = f32color.RGBA{}
+			blendOver(&dst.pix[idx], src, l.opacity)
+		}
+	}
+	g.freeLayers = append(g.freeLayers, l)
+}
+
+// pushClip returns the intersection of parent and the clip area described by
+// op, pathData and strokeWidth.
+func (g *cpuGPU) pushClip(parent *cpuClip, t f32.Affine2D, op ops.ClipOp, pathData []byte, strokeWidth float32) *cpuClip {
+	limit := image.Rectangle{Max: g.viewport}
+	if parent != nil {
+		limit = parent.bounds
+	}
+	c := &cpuClip{parent: parent}
+	g.quads = g.quads[:0]
+	switch {
+	case len(pathData) > 0 && strokeWidth > 0:
+		quads := stroke.StrokePathCommands(stroke.StrokeStyle{Width: strokeWidth}, pathData)
+		for _, q := range quads {
+			q.Quad = q.Quad.Transform(t)
+			g.splitQuad(q)
+		}
+		c.bounds, c.mask = g.rasterize(limit)
+	case len(pathData) > 0:
+		g.decodePath(t, pathData)
+		c.bounds, c.mask = g.rasterize(limit)
+	default:
+		c.bounds, c.mask = g.rect(limit, t, op.Bounds)
+	}
+	if parent != nil && parent.mask != nil && !c.bounds.Empty() {
+		c.mask = intersectMasks(c.bounds, c.mask, parent.mask)
+	}
+	return c
+}
+
+// decodePath transforms and appends the segments of an outline path to
+// g.quads.
+func (g *cpuGPU) decodePath(t f32.Affine2D, pathData []byte) {
+	for len(pathData) >= scene.CommandSize+4 {
+		contour := binary.LittleEndian.Uint32(pathData)
+		cmd := ops.DecodeCommand(pathData[4:])
+		var q stroke.QuadSegment
+		switch cmd.Op() {
+		case scene.OpLine:
+			q.From, q.To = scene.DecodeLine(cmd)
+			q.Ctrl = q.From.Add(q.To).Mul(.5)
+			g.splitQuad(stroke.StrokeQuad{Contour: contour, Quad: q.Transform(t)})
+		case scene.OpGap:
+			q.From, q.To = scene.DecodeGap(cmd)
+			q.Ctrl = q.From.Add(q.To).Mul(.5)
+			g.splitQuad(stroke.StrokeQuad{Contour: contour, Quad: q.Transform(t)})
+		case scene.OpQuad:
+			q.From, q.Ctrl, q.To = scene.DecodeQuad(cmd)
+			g.splitQuad(stroke.StrokeQuad{Contour: contour, Quad: q.Transform(t)})
+		case scene.OpCubic:
+			from, ctrl0, ctrl1, to := scene.DecodeCubic(cmd)
+			g.scratch = stroke.SplitCubic(from, ctrl0, ctrl1, to, g.scratch[:0])
+			for _, q := range g.scratch {
+				g.splitQuad(stroke.StrokeQuad{Contour: contour, Quad: q.Transform(t)})
+			}
+		default:
+			panic("unsupported scene command")
+		}
+		pathData = pathData[scene.CommandSize+4:]
+	}
+}
+
+// splitQuad appends q to g.quads, split into x monotone curves as
+// expected by cover.
+func (g *cpuGPU) splitQuad(q stroke.StrokeQuad) {
+	from, ctrl, to := q.Quad.From, q.Quad.Ctrl, q.Quad.To
+	v0 := ctrl.Sub(from)
+	v1 := to.Sub(ctrl)
+	d := v0.X - v1.X
+	// t = v0 / d. Split if t is in ]0;1[.
+	if v0.X > 0 && d > v0.X || v0.X < 0 && d < v0.X {
+		t := v0.X / d
+		ctrl0 := from.Mul(1 - t).Add(ctrl.Mul(t))
+		ctrl1 := ctrl.Mul(1 - t).Add(to.Mul(t))
+		mid := ctrl0.Mul(1 - t).Add(ctrl1.Mul(t))
+		g.quads = append(g.quads,
+			stroke.StrokeQuad{Contour: q.Contour, Quad: stroke.QuadSegment{From: from, Ctrl: ctrl0, To: mid}},
+			stroke.StrokeQuad{Contour: q.Contour, Quad: stroke.QuadSegment{From: mid, Ctrl: ctrl1, To: to}},
+		)
+		return
+	}
+	g.quads = append(g.quads, q)
+}
+
+// rect returns the area of r transformed by t, restricted to limit.
+func (g *cpuGPU) rect(limit image.Rectangle, t f32.Affine2D, r image.Rectangle) (image.Rectangle, *cpuMask) {
+	sx, hx, ox, hy, sy, oy := t.Elems()
+	if sx == 1 && hx == 0 && hy == 0 && sy == 1 && isInt(ox) && isInt(oy) {
+		// Rectangles at integer offsets need no rasterization.
+		return r.Add(image.Pt(int(ox), int(oy))).Intersect(limit), nil
+	}
+	corners := [4]f32.Point{
+		t.Transform(f32.Pt(float32(r.Min.X), float32(r.Min.Y))),
+		t.Transform(f32.Pt(float32(r.Max.X), float32(r.Min.Y))),
+		t.Transform(f32.Pt(float32(r.Max.X), float32(r.Max.Y))),
+		t.Transform(f32.Pt(float32(r.Min.X), float32(r.Max.Y))),
+	}
+	g.quads = g.quads[:0]
+	for i, from := range corners {
+		to := corners[(i+1)%len(corners)]
+		g.quads = append(g.quads, stroke.StrokeQuad{
+			Quad: stroke.QuadSegment{From: from, Ctrl: from.Add(to).Mul(.5), To: to},
+		})
+	}
+	return g.rasterize(limit)
+}
+
+func isInt(v float32) bool {
+	return v == float32(math.Floor(float64(v)))
+}
+
+// rasterize computes the coverage of the closed contours in g.quads. The
+// coverage matches the stencil program of the GPU renderer: every x
+// monotone curve contributes the signed area between itself and the
+// bottom of its contour, approximated by the tangent line at the center
+// of each pixel column.
+func (g *cpuGPU) rasterize(limit image.Rectangle) (image.Rectangle, *cpuMask) {
+	if len(g.quads) == 0 {
+		return image.Rectangle{}, nil
+	}
+	inf := float32(math.Inf(+1))
+	fb := f32.Rectangle{
+		Min: f32.Point{X: inf, Y: inf},
+		Max: f32.Point{X: -inf, Y: -inf},
+	}
+	for _, q := range g.quads {
+		from, ctrl, to := q.Quad.From, q.Quad.Ctrl, q.Quad.To
+		fb.Min.X = min(fb.Min.X, from.X, to.X)
+		fb.Max.X = max(fb.Max.X, from.X, to.X)
+		fb.Min.Y = min(fb.Min.Y, from.Y, to.Y)
+		fb.Max.Y = max(fb.Max.Y, from.Y, to.Y)
+		// Include the y extremum, if any.
+		v0, v1 := ctrl.Sub(from), to.Sub(ctrl)
+		if d := v0.Y - v1.Y; v0.Y > 0 && d > v0.Y || v0.Y < 0 && d < v0.Y {
+			t := v0.Y / d
+			y := (1-t)*(1-t)*from.Y + 2*(1-t)*t*ctrl.Y + t*t*to.Y
+			fb.Min.Y = min(fb.Min.Y, y)
+			fb.Max.Y = max(fb.Max.Y, y)
+		}
+	}
+	b := fb.Round().Intersect(limit)
+	if b.Empty() {
+		return image.Rectangle{}, nil
+	}
+	w, h := b.Dx(), b.Dy()
+	m := &cpuMask{rect: b, cov: make([]float32, w*h)}
+	// fill accumulates the areas of pixels entirely below a curve, as
+	// differences between rows.
+	if n := w * (h + 1); cap(g.fill) < n {
+		g.fill = make([]float32, n)
+	} else {
+		g.fill = g.fill[:n]
+		clear(g.fill)
+	}
+	quads := g.quads
+	for len(quads) > 0 {
+		// Find the maximum y of the contour.
+		contour := quads[0].Contour
+		n := 0
+		maxy := float32(math.Inf(-1))
+		for ; n < len(quads) && quads[n].Contour == contour; n++ {
+			q := quads[n].Quad
+			maxy = max(maxy, q.From.Y, q.Ctrl.Y, q.To.Y)
+		}
+		for _, q := range quads[:n] {
+			g.cover(m, q.Quad, maxy)
+		}
+		quads = quads[n:]
+	}
+	for x := range w {
+		acc := float32(0)
+		for y := range h {
+			acc += g.fill[y*w+x]
+			idx := y*w + x
+			m.cov[idx] = min(float32(math.Abs(float64(m.cov[idx]+acc))), 1)
+		}
+	}
+	return b, m
+}
+
+// cover adds the signed area covered by the x monotone curve q to m.
+func (g *cpuGPU) cover(m *cpuMask, q stroke.QuadSegment, maxy float32) {
+	b := m.rect
+	w := b.Dx()
+	left, right := q.From, q.To
+	if right.X < left.X {
+		left, right = right, left
+	}
+	miny := min(q.From.Y, q.Ctrl.Y, q.To.Y)
+	// The rows whose pixel centers lie within the vertical extent of the
+	// curve, extended by a pixel.
+	y0 := max(int(math.Ceil(float64(miny-1-.5))), b.Min.Y)
+	y1 := min(int(math.Ceil(float64(maxy+1-.5))), b.Max.Y)
+	x0 := max(int(math.Floor(float64(left.X-.5))), b.Min.X)
+	x1 := min(int(math.Ceil(float64(right.X+.5))), b.Max.X)
+	p1 := q.Ctrl.Sub(left)
+	v := right.Sub(q.Ctrl)
+	for x := x0; x < x1; x++ {
+		cx := float32(x) + .5
+		// The signed horizontal extent of the pixel.
+		e0 := clamp1(q.From.X-cx, -.5, .5)
+		e1 := clamp1(q.To.X-cx, -.5, .5)
+		width := e1 - e0
+		if width == 0 {
+			continue
+		}
+		// Find the t where the curve crosses the middle of the extent.
+		midx := (e0 + e1) * .5
+		xm := midx + cx - left.X
+		t := xm / (p1.X + float32(math.Sqrt(float64(max(p1.X*p1.X+(v.X-p1.X)*xm, 0)))))
+		if t != t {
+			// Degenerate curve.
+			t = 0
+		}
+		t = clamp1(t, 0, 1)
+		// Find y(t) on the curve and the slope.
+		cy := mix(mix(left.Y, q.Ctrl.Y, t), mix(q.Ctrl.Y, right.Y, t), t)
+		dhalf := p1.Add(v.Sub(p1).Mul(t))
+		dy := float32(math.Abs(float64(dhalf.Y / dhalf.X * width)))
+		if dy != dy {
+			dy = 0
+		}
+		// Skip the rows entirely above the line.
+		y := max(y0, int(math.Floor(float64(cy-dy*.5-1.5))))
+		for ; y < y1; y++ {
+			yc := cy - (float32(y) + .5)
+			var area float32
+			if dy == 0 {
+				area = clamp1(.5-yc, 0, 1)
+			} else {
+				sx := clamp1(dy*+.5+yc+.5, 0, 1)
+				sy := clamp1(dy*-.5+yc+.5, 0, 1)
+				sz := clamp1((+.5-yc)/dy+.5, 0, 1)
+				sw := clamp1((-.5-yc)/dy+.5, 0, 1)
+				area = .5 * (sz - sz*sy + 1 - sx + sx*sw)
+			}
+			if area >= 1 {
+				// The remaining rows are entirely below the line.
+				g.fill[(y-b.Min.Y)*w+x-b.Min.X] += width
+				g.fill[(y1-b.Min.Y)*w+x-b.Min.X] -= width
+				break
+			}
+			m.cov[(y-b.Min.Y)*w+x-b.Min.X] += area * width
+		}
+	}
+}
+
+func clamp1(v, lo, hi float32) float32 {
+	return min(max(v, lo), hi)
+}
+
+func mix(a, b, t float32) float32 {
+	return a + (b-a)*t
+}
+
+// at returns the coverage of the pixel at (x, y).
+func (m *cpuMask) at(x, y int) float32 {
+	return m.cov[(y-m.rect.Min.Y)*m.rect.Dx()+x-m.rect.Min.X]
+}
+
+// intersectMasks returns the mask covering the intersection of b, m1 and m2.
+// A nil mask covers its entire bounds.
+func intersectMasks(b image.Rectangle, m1, m2 *cpuMask) *cpuMask {
+	res := &cpuMask{rect: b, cov: make([]float32, b.Dx()*b.Dy())}
+	i := 0
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := m2.at(x, y)
+			if m1 != nil {
+				c *= m1.at(x, y)
+			}
+			res.cov[i] = c
+			i++
+		}
+	}
+	return res
+}
+
+// paint fills the current clip area with the current material.
+func (g *cpuGPU) paint(state *cpuState) {
+	bounds := image.Rectangle{Max: g.viewport}
+	var clipMask *cpuMask
+	if c := state.clip; c != nil {
+		bounds = c.bounds
+		clipMask = c.mask
+	}
+	var (
+		imgMask *cpuMask
+		inv     f32.Affine2D
+		tex     *cpuTexture
+		lod     float32
+	)
+	switch state.matType {
+	case materialTexture:
+		src := state.image.src
+		if src == nil {
+			return
+		}
+		// Images are bounded by their transformed rectangle.
+		sz := src.Bounds().Size()
+		bounds, imgMask = g.rect(bounds, state.t, image.Rectangle{Max: sz})
+		inv = state.t.Invert()
+		if imgMask != nil {
+			// Like the GPU renderer, map the image to the rounded bounds
+			// of its transformed rectangle.
+			fb := transformedBounds(state.t, sz)
+			ib := fb.Round()
+			s := f32.Pt(fb.Dx()/float32(ib.Dx()), fb.Dy()/float32(ib.Dy()))
+			inv = inv.Mul(f32.AffineId().Offset(f32.FPt(ib.Min).Mul(-1)).Scale(f32.Point{}, s).Offset(fb.Min))
+		}
+		tex = g.texture(src)
+		if state.image.filter == filterLinear {
+			// Select the mipmap level like the GPU does, from the
+			// texel footprint of a pixel.
+			sx, hx, _, hy, sy, _ := inv.Elems()
+			rho := max(float32(math.Hypot(float64(sx), float64(hy))), float32(math.Hypot(float64(hx), float64(sy))))
+			lod = float32(math.Log2(float64(rho)))
+		}
+	case materialLinearGradient:
+		// Gradient stops are relative to the integer offset of the
+		// transformation.
+		_, off := transformOffset(state.t)
+		inv = f32.AffineId().Offset(f32.FPt(off))
+	}
+	if bounds.Empty() {
+		return
+	}
+	l := g.layers[len(g.layers)-1]
+	l.dirty = l.dirty.Union(bounds)
+	var (
+		grad   f32.Point
+		gradSq float32
+		stop1  f32.Point
+	)
+	if state.matType == materialLinearGradient {
+		stop1 = inv.Transform(state.stop1)
+		grad = inv.Transform(state.stop2).Sub(stop1)
+		gradSq = grad.X*grad.X + grad.Y*grad.Y
+	}
+	stride := g.viewport.X
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row := y * stride
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			cov := float32(1)
+			if clipMask != nil {
+				cov *= clipMask.at(x, y)
+			}
+			if imgMask != nil {
+				cov *= imgMask.at(x, y)
+			}
+			if cov == 0 {
+				continue
+			}
+			center := f32.Pt(float32(x)+.5, float32(y)+.5)
+			var col f32color.RGBA
+			switch state.matType {
+			case materialColor:
+				col = state.color
+			case materialLinearGradient:
+				var u float32
+				if gradSq > 0 {
+					d := center.Sub(stop1)
+					u = (d.X*grad.X + d.Y*grad.Y) / gradSq
+				}
+				col = mixRGBA(state.color1, state.color2, clamp1(u, 0, 1))
+			case materialTexture:
+				col = tex.sample(inv.Transform(center), state.image.filter, lod)
+			}
+			blendOver(&l.pix[row+x], col, cov)
+		}
+	}
+}
+
+// transformedBounds returns the bounds of the rectangle of size sz
+// transformed by t.
+func transformedBounds(t f32.Affine2D, sz image.Point) f32.Rectangle {
+	inf := float32(math.Inf(+1))
+	b := f32.Rectangle{
+		Min: f32.Point{X: inf, Y: inf},
+		Max: f32.Point{X: -inf, Y: -inf},
+	}
+	for _, c := range [...]f32.Point{{}, {X: float32(sz.X)}, {X: float32(sz.X), Y: float32(sz.Y)}, {Y: float32(sz.Y)}} {
+		c = t.Transform(c)
+		b.Min.X, b.Min.Y = min(b.Min.X, c.X), min(b.Min.Y, c.Y)
+		b.Max.X, b.Max.Y = max(b.Max.X, c.X), max(b.Max.Y, c.Y)
+	}
+	return b
+}
+
+// texture returns the cached texture for img.
+func (g *cpuGPU) texture(img *image.RGBA) *cpuTexture {
+	if t, ok := g.textures[img]; ok {
+		t.used = true
+		return t
+	}
+	if g.textures == nil {
+		g.textures = make(map[*image.RGBA]*cpuTexture)
+	}
+	sz := img.Bounds().Size()
+	lvl := cpuTexLevel{size: sz, pix: make([]f32color.RGBA, sz.X*sz.Y)}
+	for y := range sz.Y {
+		for x := range sz.X {
+			i := img.PixOffset(img.Rect.Min.X+x, img.Rect.Min.Y+y)
+			p := img.Pix[i : i+4 : i+4]
+			lvl.pix[y*sz.X+x] = f32color.RGBA{
+				R: srgbToLinear[p[0]],
+				G: srgbToLinear[p[1]],
+				B: srgbToLinear[p[2]],
+				A: float32(p[3]) / 0xff,
+			}
+		}
+	}
+	t := &cpuTexture{levels: []cpuTexLevel{lvl}, used: true}
+	g.textures[img] = t
+	return t
+}
+
+// releaseTextures frees the textures not used since the previous call.
+func (g *cpuGPU) releaseTextures() {
+	for img, t := range g.textures {
+		if !t.used {
+			delete(g.textures, img)
+			continue
+		}
+		t.used = false
+	}
+}
+
+// sample the texture at p, measured in texels of the base level.
+func (t *cpuTexture) sample(p f32.Point, filter byte, lod float32) f32color.RGBA {
+	base := t.levels[0]
+	if filter == filterNearest {
+		x := min(max(int(math.Floor(float64(p.X))), 0), base.size.X-1)
+		y := min(max(int(math.Floor(float64(p.Y))), 0), base.size.Y-1)
+		return base.pix[y*base.size.X+x]
+	}
+	if lod <= 0 {
+		return base.bilinear(p)
+	}
+	t.generateMipmaps()
+	n := len(t.levels) - 1
+	lvl := int(lod)
+	if lvl >= n {
+		return t.levels[n].bilinear(t.levelPos(p, n))
+	}
+	c0 := t.levels[lvl].bilinear(t.levelPos(p, lvl))
+	c1 := t.levels[lvl+1].bilinear(t.levelPos(p, lvl+1))
+	return mixRGBA(c0, c1, lod-float32(lvl))
+}
+
+// levelPos maps p from the base level to level lvl.
+func (t *cpuTexture) levelPos(p f32.Point, lvl int) f32.Point {
+	base, l := t.levels[0].size, t.levels[lvl].size
+	return f32.Pt(p.X*float32(l.X)/float32(base.X), p.Y*float32(l.Y)/float32(base.Y))
+}
+
+// generateMipmaps fills in the levels below the base level by box
+// filtering.
+func (t *cpuTexture) generateMipmaps() {
+	if len(t.levels) > 1 {
+		return
+	}
+	for {
+		src := t.levels[len(t.levels)-1]
+		if src.size.X == 1 && src.size.Y == 1 {
+			break
+		}
+		sz := image.Pt(max(src.size.X/2, 1), max(src.size.Y/2, 1))
+		dst := cpuTexLevel{size: sz, pix: make([]f32color.RGBA, sz.X*sz.Y)}
+		for y := range sz.Y {
+			y0, y1 := min(y*2, src.size.Y-1), min(y*2+1, src.size.Y-1)
+			for x := range sz.X {
+				x0, x1 := min(x*2, src.size.X-1), min(x*2+1, src.size.X-1)
+				c := mixRGBA(
+					mixRGBA(src.at(x0, y0), src.at(x1, y0), .5),
+					mixRGBA(src.at(x0, y1), src.at(x1, y1), .5),
+					.5,
+				)
+				dst.pix[y*sz.X+x] = c
+			}
+		}
+		t.levels = append(t.levels, dst)
+	}
+}
+
+func (l cpuTexLevel) at(x, y int) f32color.RGBA {
+	return l.pix[y*l.size.X+x]
+}
+
+// bilinear filters between the four texels nearest to p.
+func (l cpuTexLevel) bilinear(p f32.Point) f32color.RGBA {
+	fx, fy := p.X-.5, p.Y-.5
+	x0, y0 := int(math.Floor(float64(fx))), int(math.Floor(float64(fy)))
+	wx, wy := fx-float32(x0), fy-float32(y0)
+	clampX := func(x int) int { return min(max(x, 0), l.size.X-1) }
+	clampY := func(y int) int { return min(max(y, 0), l.size.Y-1) }
+	c00 := l.at(clampX(x0), clampY(y0))
+	c10 := l.at(clampX(x0+1), clampY(y0))
+	c01 := l.at(clampX(x0), clampY(y0+1))
+	c11 := l.at(clampX(x0+1), clampY(y0+1))
+	return mixRGBA(mixRGBA(c00, c10, wx), mixRGBA(c01, c11, wx), wy)
+}
+
+func mixRGBA(c1, c2 f32color.RGBA, t float32) f32color.RGBA {
+	return f32color.RGBA{
+		R: c1.R + (c2.R-c1.R)*t,
+		G: c1.G + (c2.G-c1.G)*t,
+		B: c1.B + (c2.B-c1.B)*t,
+		A: c1.A + (c2.A-c1.A)*t,
+	}
+}
+
+// blendOver blends src scaled by alpha over dst.
+func blendOver(dst *f32color.RGBA, src f32color.RGBA, alpha float32) {
+	sa := src.A * alpha
+	inv := 1 - sa
+	dst.R = src.R*alpha + dst.R*inv
+	dst.G = src.G*alpha + dst.G*inv
+	dst.B = src.B*alpha + dst.B*inv
+	dst.A = sa + dst.A*inv
+}
+
+// output converts the frame buffer to premultiplied sRGB and stores it in img.
+func (g *cpuGPU) output(img *image.RGBA) {
+	fb := g.layers[0]
+	r := img.Bounds().Intersect(image.Rectangle{Max: g.viewport}.Add(img.Rect.Min))
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		row := (y - img.Rect.Min.Y) * g.viewport.X
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c := fb.pix[row+x-img.Rect.Min.X]
+			i := img.PixOffset(x, y)
+			p := img.Pix[i : i+4 : i+4]
+			p[0] = linearToSRGB8(c.R)
+			p[1] = linearToSRGB8(c.G)
+			p[2] = linearToSRGB8(c.B)
+			p[3] = uint8(min(max(c.A, 0), 1)*0xff + .5)
+		}
+	}
+}
+
+// linearToSRGB8 converts a linear color component to 8-bit sRGB.
+func linearToSRGB8(c float32) uint8 {
+	var s float64
+	switch {
+	case c <= 0:
+		return 0
+	case c < 0.0031308:
+		s = 12.92 * float64(c)
+	case c < 1:
+		s = 1.055*math.Pow(float64(c), 1/2.4) - 0.055
+	default:
+		return 0xff
+	}
+	return uint8(s*0xff + .5)
+}
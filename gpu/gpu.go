@@ -57,6 +57,10 @@ type gpu struct {
 	drawOps                                drawOps
 	ctx                                    driver.Device
 	renderer                               *renderer
+	// target and output are the render target of the last frame and
+	// its framebuffer.
+	target RenderTarget
+	output driver.Texture
 }
 
 type renderer struct {
@@ -331,6 +335,9 @@ const (
 
 // New creates a GPU for the given API.
 func New(api API) (GPU, error) {
+	if _, ok := api.(CPU); ok {
+		return newCPUGPU(), nil
+	}
 	d, err := driver.NewDevice(api)
 	if err != nil {
 		return nil, err
@@ -389,6 +396,23 @@ func (g *gpu) Frame(frameOps *op.Ops, target RenderTarget, viewport image.Point)
 	return g.frame(target)
 }
 
+// ReadFrame copies the frame drawn by the last Frame into img, with
+// the origin at img.Rect.Min. It must be called before the frame is
+// presented.
+func (g *gpu) ReadFrame(img *image.RGBA) error {
+	if g.output == nil {
+		return errors.New("gpu: no frame to read")
+	}
+	if _, ok := g.target.(VulkanRenderTarget); ok {
+		// Swapchain images can't be copied from.
+		return errors.New("gpu: reading Vulkan frames is not supported")
+	}
+	sz := img.Bounds().Size()
+	// Download to the origin of the framebuffer.
+	dst := &image.RGBA{Pix: img.Pix, Stride: img.Stride, Rect: image.Rectangle{Max: sz}}
+	return driver.DownloadImage(g.ctx, g.output, dst)
+}
+
 func (g *gpu) collect(viewport image.Point, frameOps *op.Ops) {
 	g.renderer.blitter.viewport = viewport
 	g.renderer.pather.viewport = viewport
@@ -407,6 +431,7 @@ func (g *gpu) frame(target RenderTarget) error {
 	viewport := g.renderer.blitter.viewport
 	defFBO := g.ctx.BeginFrame(target, g.drawOps.clear, viewport)
 	defer g.ctx.EndFrame()
+	g.target, g.output = target, defFBO
 	g.drawOps.buildPaths(g.ctx)
 	for _, img := range g.drawOps.imageOps {
 		expandPathOp(img.path, img.clip)
@@ -167,6 +167,10 @@ func newDriver(t *testing.T) driver.Device {
 	if err != nil {
 		t.Skipf("no context available: %v", err)
 	}
+	if _, ok := ctx.API().(driver.CPU); ok {
+		ctx.Release()
+		t.Skip("no GPU context available")
+	}
 	if err := ctx.MakeCurrent(); err != nil {
 		t.Fatal(err)
 	}
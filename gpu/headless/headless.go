@@ -1,13 +1,15 @@
 // SPDX-License-Identifier: Unlicense OR MIT
 
 // Package headless implements headless windows for rendering
-// an operation list to an image.
+// an operation list to an image. Windows fall back to a CPU rasterizer
+// when no GPU backend is available.
 package headless
 
 import (
 	"errors"
 	"image"
 	"image/color"
+	"image/draw"
 
 	"github.com/mleku/gio/gpu"
 	"github.com/mleku/gio/gpu/internal/driver"
@@ -21,6 +23,8 @@ type Window struct {
 	dev    driver.Device
 	gpu    gpu.GPU
 	fboTex driver.Texture
+	// img is the render target of the CPU rasterizer.
+	img *image.RGBA
 }
 
 type context interface {
@@ -36,7 +40,7 @@ var (
 )
 
 func newContext() (context, error) {
-	funcs := []func() (context, error){newContextPrimary, newContextFallback}
+	funcs := []func() (context, error){newContextPrimary, newContextFallback, newContextCPU}
 	var firstErr error
 	for _, f := range funcs {
 		if f == nil {
@@ -67,6 +71,15 @@ func NewWindow(width, height int) (*Window, error) {
 		size: image.Point{X: width, Y: height},
 		ctx:  ctx,
 	}
+	if _, ok := ctx.API().(gpu.CPU); ok {
+		gp, err := gpu.New(ctx.API())
+		if err != nil {
+			return nil, err
+		}
+		w.gpu = gp
+		w.img = image.NewRGBA(image.Rectangle{Max: w.size})
+		return w, nil
+	}
 	err = contextDo(ctx, func() error {
 		dev, err := driver.NewDevice(ctx.API())
 		if err != nil {
@@ -131,12 +144,19 @@ func (w *Window) Size() image.Point {
 func (w *Window) Frame(frame *op.Ops) error {
 	return contextDo(w.ctx, func() error {
 		w.gpu.Clear(color.NRGBA{})
+		if w.img != nil {
+			return w.gpu.Frame(frame, gpu.CPURenderTarget{Image: w.img}, w.size)
+		}
 		return w.gpu.Frame(frame, w.fboTex, w.size)
 	})
 }
 
 // Screenshot transfers the Window content at origin img.Rect.Min to img.
 func (w *Window) Screenshot(img *image.RGBA) error {
+	if w.img != nil {
+		draw.Draw(img, img.Bounds(), w.img, img.Rect.Min, draw.Src)
+		return nil
+	}
 	return contextDo(w.ctx, func() error {
 		return driver.DownloadImage(w.dev, w.fboTex, img)
 	})
@@ -0,0 +1,29 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package headless
+
+import (
+	"github.com/mleku/gio/gpu"
+)
+
+// cpuContext is the context of the CPU rasterizer, used when no GPU backend
+// is available.
+type cpuContext struct{}
+
+func newContextCPU() (context, error) {
+	return cpuContext{}, nil
+}
+
+func (cpuContext) API() gpu.API {
+	return gpu.CPU{}
+}
+
+func (cpuContext) Release() {
+}
+
+func (cpuContext) ReleaseCurrent() {
+}
+
+func (cpuContext) MakeCurrent() error {
+	return nil
+}
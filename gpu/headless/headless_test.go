@@ -3,10 +3,12 @@
 package headless
 
 import (
+	"errors"
 	"image"
 	"image/color"
 	"testing"
 
+	"github.com/mleku/gio/gpu"
 	"github.com/mleku/gio/internal/f32color"
 	"github.com/mleku/gio/op"
 	"github.com/mleku/gio/op/clip"
@@ -40,6 +42,100 @@ func TestHeadless(t *testing.T) {
 	}
 }
 
+func TestTransparentClear(t *testing.T) {
+	w, release := newTestWindow(t)
+	defer release()
+
+	// A translucent color covering the window must keep its alpha
+	// over the transparent clear color.
+	col := color.NRGBA{A: 0x80, R: 0xca, G: 0xfe}
+	var ops op.Ops
+	paint.FillShape(&ops, col, clip.Rect(image.Rectangle{Max: w.size}).Op())
+	if err := w.Frame(&ops); err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewRGBA(image.Rectangle{Max: w.Size()})
+	if err := w.Screenshot(img); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []image.Point{{}, {X: 400, Y: 300}, w.size.Sub(image.Pt(1, 1))} {
+		if got := img.RGBAAt(p.X, p.Y); got.A != 0x80 {
+			t.Errorf("got alpha %#x at %v, expected 0x80", got.A, p)
+		}
+	}
+}
+
+func TestGPUReadFrame(t *testing.T) {
+	w, release := newTestWindow(t)
+	defer release()
+
+	col := color.NRGBA{A: 0xff, R: 0xca, G: 0xfe}
+	var ops op.Ops
+	paint.FillShape(&ops, col, clip.Rect(image.Rect(10, 20, 30, 40)).Op())
+	if err := w.Frame(&ops); err != nil {
+		t.Fatal(err)
+	}
+	r, ok := w.gpu.(interface{ ReadFrame(img *image.RGBA) error })
+	if !ok {
+		t.Fatalf("%T can't read frames", w.gpu)
+	}
+	// Copy into a sub-image to verify the origin.
+	img := image.NewRGBA(image.Rect(0, 0, 110, 100)).SubImage(image.Rect(10, 0, 110, 100)).(*image.RGBA)
+	if err := contextDo(w.ctx, func() error { return r.ReadFrame(img) }); err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := img.RGBAAt(10+15, 25), f32color.NRGBAToRGBA(col); got != exp {
+		t.Errorf("got color %v inside shape, expected %v", got, exp)
+	}
+	if got := img.RGBAAt(10+5, 25); got != (color.RGBA{}) {
+		t.Errorf("got color %v outside shape, expected transparent", got)
+	}
+	// The shape is upright.
+	if got := img.RGBAAt(10+15, 100-25); got != (color.RGBA{}) {
+		t.Errorf("got color %v below shape, expected transparent", got)
+	}
+}
+
+func TestCPUFallback(t *testing.T) {
+	primary, fallback := newContextPrimary, newContextFallback
+	defer func() {
+		newContextPrimary, newContextFallback = primary, fallback
+	}()
+	failing := func() (context, error) {
+		return nil, errors.New("no GPU")
+	}
+	newContextPrimary, newContextFallback = failing, failing
+	w, release := newTestWindow(t)
+	defer release()
+	if _, ok := w.ctx.API().(gpu.CPU); !ok {
+		t.Fatalf("got API %T, expected the CPU rasterizer", w.ctx.API())
+	}
+
+	col := color.NRGBA{A: 0xff, R: 0xca, G: 0xfe}
+	var ops op.Ops
+	paint.FillShape(&ops, col, clip.Rect(image.Rect(10, 10, 20, 20)).Op())
+	// Translucent layer.
+	opacity := paint.PushOpacity(&ops, .5)
+	paint.FillShape(&ops, col, clip.Rect(image.Rect(30, 10, 40, 20)).Op())
+	opacity.Pop()
+	if err := w.Frame(&ops); err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewRGBA(image.Rectangle{Max: w.Size()})
+	if err := w.Screenshot(img); err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := img.RGBAAt(15, 15), f32color.NRGBAToRGBA(col); got != exp {
+		t.Errorf("got color %v, expected %v", got, exp)
+	}
+	if got := img.RGBAAt(5, 5); got != (color.RGBA{}) {
+		t.Errorf("got color %v outside shape, expected transparent", got)
+	}
+	if got := img.RGBAAt(35, 15); got.A != 0x80 {
+		t.Errorf("got alpha %#x, expected 0x80 inside translucent layer", got.A)
+	}
+}
+
 func TestClipping(t *testing.T) {
 	w, release := newTestWindow(t)
 	defer release()
@@ -4,6 +4,7 @@ package driver
 
 import (
 	"fmt"
+	"image"
 	"unsafe"
 
 	"github.com/mleku/gio/internal/gl"
@@ -44,6 +45,11 @@ type VulkanRenderTarget struct {
 	Framebuffer uint64
 }
 
+type CPURenderTarget struct {
+	// Image receives the rendered frame in premultiplied sRGB.
+	Image *image.RGBA
+}
+
 type OpenGL struct {
 	// ES forces the use of ANGLE OpenGL ES libraries on macOS. It is
 	// ignored on all other platforms.
@@ -84,6 +90,8 @@ type Vulkan struct {
 	Format int
 }
 
+type CPU struct{}
+
 // API specific device constructors.
 var (
 	NewOpenGLDevice     func(api OpenGL) (Device, error)
@@ -123,7 +131,9 @@ func (OpenGL) implementsAPI()                          {}
 func (Direct3D11) implementsAPI()                      {}
 func (Metal) implementsAPI()                           {}
 func (Vulkan) implementsAPI()                          {}
+func (CPU) implementsAPI()                             {}
 func (OpenGLRenderTarget) ImplementsRenderTarget()     {}
 func (Direct3D11RenderTarget) ImplementsRenderTarget() {}
 func (MetalRenderTarget) ImplementsRenderTarget()      {}
 func (VulkanRenderTarget) ImplementsRenderTarget()     {}
+func (CPURenderTarget) ImplementsRenderTarget()        {}
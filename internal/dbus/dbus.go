@@ -0,0 +1,478 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+// Package dbus implements a minimal D-Bus client, sufficient for
+// exporting objects and calling methods on a message bus.
+package dbus
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MessageType is the type of a message.
+type MessageType byte
+
+const (
+	TypeMethodCall MessageType = 1 + iota
+	TypeMethodReturn
+	TypeError
+	TypeSignal
+)
+
+// FlagNoReplyExpected marks method calls that expect no reply.
+const FlagNoReplyExpected = 0x1
+
+// Well-known names of the message bus.
+const (
+	BusName      = "org.freedesktop.DBus"
+	BusPath      = ObjectPath("/org/freedesktop/DBus")
+	BusInterface = "org.freedesktop.DBus"
+)
+
+// Names of common errors.
+const (
+	ErrUnknownMethod    = "org.freedesktop.DBus.Error.UnknownMethod"
+	ErrUnknownObject    = "org.freedesktop.DBus.Error.UnknownObject"
+	ErrUnknownInterface = "org.freedesktop.DBus.Error.UnknownInterface"
+	ErrUnknownProperty  = "org.freedesktop.DBus.Error.UnknownProperty"
+	ErrInvalidArgs      = "org.freedesktop.DBus.Error.InvalidArgs"
+)
+
+// header field codes.
+const (
+	fieldPath = 1 + iota
+	fieldInterface
+	fieldMember
+	fieldErrorName
+	fieldReplySerial
+	fieldDestination
+	fieldSender
+	fieldSignature
+)
+
+// maxMessageSize is the maximum size of a message allowed by the
+// specification.
+const maxMessageSize = 128 << 20
+
+// Message is a D-Bus message. The values of Body have the Go types
+// listed for [SignatureOf], except that arrays and structs decode to
+// []any, and dicts to map[any]any.
+type Message struct {
+	Type        MessageType
+	Flags       byte
+	Serial      uint32
+	Path        ObjectPath
+	Interface   string
+	Member      string
+	ErrorName   string
+	ReplySerial uint32
+	Destination string
+	Sender      string
+	Body        []any
+}
+
+// Error is an error reply to a method call.
+type Error struct {
+	Name    string
+	Message string
+}
+
+// Handler handles the method calls and signals received by a
+// connection. It runs on the goroutine that reads messages, and must not
+// wait for the replies of its own calls.
+type Handler func(c *Conn, m *Message)
+
+// Conn is a connection to a message bus.
+type Conn struct {
+	conn    net.Conn
+	r       *bufio.Reader
+	handler Handler
+	name    string
+
+	wmu    sync.Mutex
+	serial uint32
+
+	mu    sync.Mutex
+	calls map[uint32]chan *Message
+	err   error
+	// done is closed when the connection is lost.
+	done chan struct{}
+}
+
+// SessionBusAddress returns the address of the session bus, or the
+// empty string if there is none.
+func SessionBusAddress() string {
+	return os.Getenv("DBUS_SESSION_BUS_ADDRESS")
+}
+
+// Dial connects to the message bus at address and registers with it.
+// The address lists alternatives separated by semicolons; only unix
+// transports are supported. Incoming method calls and signals are
+// passed to handler if it is not nil.
+func Dial(address string, handler Handler) (*Conn, error) {
+	conn, err := dialAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	c := &Conn{
+		conn:    conn,
+		r:       bufio.NewReader(conn),
+		handler: handler,
+		calls:   make(map[uint32]chan *Message),
+		done:    make(chan struct{}),
+	}
+	if err := c.auth(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	go c.readLoop()
+	reply, err := c.Call(BusName, BusPath, BusInterface, "Hello")
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	if len(reply) > 0 {
+		c.name, _ = reply[0].(string)
+	}
+	return c, nil
+}
+
+func dialAddress(address string) (net.Conn, error) {
+	if address == "" {
+		return nil, errors.New("dbus: no bus address")
+	}
+	var errs []error
+	for _, addr := range strings.Split(address, ";") {
+		transport, params, _ := strings.Cut(addr, ":")
+		if transport != "unix" {
+			errs = append(errs, fmt.Errorf("dbus: unsupported transport %q", transport))
+			continue
+		}
+		var path string
+		for _, kv := range strings.Split(params, ",") {
+			k, v, _ := strings.Cut(kv, "=")
+			v, err := url.PathUnescape(v)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			switch k {
+			case "path":
+				path = v
+			case "abstract":
+				path = "@" + v
+			}
+		}
+		if path == "" {
+			errs = append(errs, fmt.Errorf("dbus: unsupported address %q", addr))
+			continue
+		}
+		conn, err := net.Dial("unix", path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		return conn, nil
+	}
+	return nil, errors.Join(errs...)
+}
+
+// auth authenticates with the EXTERNAL mechanism, which identifies the
+// client by the credentials of its socket.
+func (c *Conn) auth() error {
+	uid := hex.EncodeToString([]byte(strconv.Itoa(os.Getuid())))
+	if _, err := io.WriteString(c.conn, "\x00AUTH EXTERNAL "+uid+"\r\n"); err != nil {
+		return err
+	}
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "OK ") {
+		return fmt.Errorf("dbus: authentication failed: %q", strings.TrimSpace(line))
+	}
+	_, err = io.WriteString(c.conn, "BEGIN\r\n")
+	return err
+}
+
+// UniqueName returns the unique name of the connection on the bus.
+func (c *Conn) UniqueName() string {
+	return c.name
+}
+
+// Done returns a channel that is closed when the connection is closed
+// or lost.
+func (c *Conn) Done() <-chan struct{} {
+	return c.done
+}
+
+// Close closes the connection. Pending calls fail.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// Call calls a method and waits for the reply, returning its values.
+// Error replies are returned as *Error.
+func (c *Conn) Call(dest string, path ObjectPath, iface, method string, args ...any) ([]any, error) {
+	ch := make(chan *Message, 1)
+	m := &Message{
+		Type:        TypeMethodCall,
+		Path:        path,
+		Interface:   iface,
+		Member:      method,
+		Destination: dest,
+	}
+	err := c.send(m, args, func(serial uint32) error {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.err != nil {
+			return c.err
+		}
+		c.calls[serial] = ch
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	reply, ok := <-ch
+	if !ok {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return nil, c.err
+	}
+	if reply.Type == TypeError {
+		e := &Error{Name: reply.ErrorName}
+		if len(reply.Body) > 0 {
+			e.Message, _ = reply.Body[0].(string)
+		}
+		return nil, e
+	}
+	return reply.Body, nil
+}
+
+// Emit broadcasts a signal.
+func (c *Conn) Emit(path ObjectPath, iface, member string, args ...any) error {
+	m := &Message{
+		Type:      TypeSignal,
+		Path:      path,
+		Interface: iface,
+		Member:    member,
+	}
+	return c.send(m, args, nil)
+}
+
+// Reply replies to the method call m with the values args.
+func (c *Conn) Reply(m *Message, args ...any) error {
+	if m.Flags&FlagNoReplyExpected != 0 {
+		return nil
+	}
+	r := &Message{
+		Type:        TypeMethodReturn,
+		ReplySerial: m.Serial,
+		Destination: m.Sender,
+	}
+	return c.send(r, args, nil)
+}
+
+// ReplyError replies to the method call m with an error.
+func (c *Conn) ReplyError(m *Message, name, text string) error {
+	if m.Flags&FlagNoReplyExpected != 0 {
+		return nil
+	}
+	r := &Message{
+		Type:        TypeError,
+		ErrorName:   name,
+		ReplySerial: m.Serial,
+		Destination: m.Sender,
+	}
+	return c.send(r, []any{text}, nil)
+}
+
+// send encodes and writes m with the body args. The serial of the
+// message is passed to register before writing it.
+func (c *Conn) send(m *Message, args []any, register func(serial uint32) error) error {
+	sig, err := SignatureOf(args...)
+	if err != nil {
+		return err
+	}
+	body := new(encoder)
+	for _, a := range args {
+		if err := body.value(reflect.ValueOf(a)); err != nil {
+			return err
+		}
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.serial++
+	m.Serial = c.serial
+	if register != nil {
+		if err := register(m.Serial); err != nil {
+			return err
+		}
+	}
+	type field struct {
+		Code  byte
+		Value Variant
+	}
+	var fields []field
+	if m.Path != "" {
+		fields = append(fields, field{fieldPath, Variant{m.Path}})
+	}
+	if m.Interface != "" {
+		fields = append(fields, field{fieldInterface, Variant{m.Interface}})
+	}
+	if m.Member != "" {
+		fields = append(fields, field{fieldMember, Variant{m.Member}})
+	}
+	if m.ErrorName != "" {
+		fields = append(fields, field{fieldErrorName, Variant{m.ErrorName}})
+	}
+	if m.ReplySerial != 0 {
+		fields = append(fields, field{fieldReplySerial, Variant{m.ReplySerial}})
+	}
+	if m.Destination != "" {
+		fields = append(fields, field{fieldDestination, Variant{m.Destination}})
+	}
+	if sig != "" {
+		fields = append(fields, field{fieldSignature, Variant{sig}})
+	}
+	e := &encoder{buf: []byte{'l', byte(m.Type), m.Flags, 1}}
+	e.uint32(uint32(len(body.buf)))
+	e.uint32(m.Serial)
+	if err := e.value(reflect.ValueOf(fields)); err != nil {
+		return err
+	}
+	e.align(8)
+	e.buf = append(e.buf, body.buf...)
+	_, err = c.conn.Write(e.buf)
+	return err
+}
+
+func (c *Conn) readLoop() {
+	var err error
+	for {
+		var m *Message
+		m, err = c.readMessage()
+		if err != nil {
+			break
+		}
+		switch m.Type {
+		case TypeMethodReturn, TypeError:
+			c.mu.Lock()
+			ch, ok := c.calls[m.ReplySerial]
+			delete(c.calls, m.ReplySerial)
+			c.mu.Unlock()
+			if ok {
+				ch <- m
+			}
+		default:
+			if c.handler != nil {
+				c.handler(c, m)
+			} else if m.Type == TypeMethodCall {
+				c.ReplyError(m, ErrUnknownObject, "no objects exported")
+			}
+		}
+	}
+	c.conn.Close()
+	defer close(c.done)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if errors.Is(err, net.ErrClosed) || errors.Is(err, io.EOF) {
+		err = errors.New("dbus: connection closed")
+	}
+	c.err = err
+	for serial, ch := range c.calls {
+		close(ch)
+		delete(c.calls, serial)
+	}
+}
+
+func (c *Conn) readMessage() (*Message, error) {
+	var fixed [16]byte
+	if _, err := io.ReadFull(c.r, fixed[:]); err != nil {
+		return nil, err
+	}
+	var order binary.ByteOrder
+	switch fixed[0] {
+	case 'l':
+		order = binary.LittleEndian
+	case 'B':
+		order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("dbus: invalid byte order %q", fixed[0])
+	}
+	bodyLen := order.Uint32(fixed[4:])
+	fieldsLen := order.Uint32(fixed[12:])
+	headerLen := (16 + int(fieldsLen) + 7) &^ 7
+	size := headerLen + int(bodyLen)
+	if bodyLen > maxMessageSize || fieldsLen > maxMessageSize || size > maxMessageSize {
+		return nil, errors.New("dbus: message too large")
+	}
+	buf := make([]byte, size)
+	copy(buf, fixed[:])
+	if _, err := io.ReadFull(c.r, buf[16:]); err != nil {
+		return nil, err
+	}
+	m := &Message{
+		Type:   MessageType(fixed[1]),
+		Flags:  fixed[2],
+		Serial: order.Uint32(fixed[8:]),
+	}
+	d := &decoder{buf: buf[:16+fieldsLen], pos: 12, order: order}
+	fields, err := d.value("a(yv)")
+	if err != nil {
+		return nil, err
+	}
+	var sig Signature
+	for _, f := range fields.([]any) {
+		f := f.([]any)
+		v := f[1].(Variant).Value
+		var ok bool
+		switch f[0].(byte) {
+		case fieldPath:
+			m.Path, ok = v.(ObjectPath)
+		case fieldInterface:
+			m.Interface, ok = v.(string)
+		case fieldMember:
+			m.Member, ok = v.(string)
+		case fieldErrorName:
+			m.ErrorName, ok = v.(string)
+		case fieldReplySerial:
+			m.ReplySerial, ok = v.(uint32)
+		case fieldDestination:
+			m.Destination, ok = v.(string)
+		case fieldSender:
+			m.Sender, ok = v.(string)
+		case fieldSignature:
+			sig, ok = v.(Signature)
+		default:
+			ok = true
+		}
+		if !ok {
+			return nil, fmt.Errorf("dbus: invalid header field %d", f[0])
+		}
+	}
+	d = &decoder{buf: buf[headerLen:], order: order}
+	if m.Body, err = d.values(string(sig)); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return e.Name
+	}
+	return e.Name + ": " + e.Message
+}
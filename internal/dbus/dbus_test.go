@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package dbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mleku/gio/internal/dbus/dbustest"
+)
+
+func TestRoundTrip(t *testing.T) {
+	type entry struct {
+		Name  string
+		Value Variant
+	}
+	values := []any{
+		byte(7), true, int16(-3), uint16(4), int32(-5), uint32(6),
+		int64(-7), uint64(8), 1.5, "hello", ObjectPath("/a/b"), Signature("a{sv}"),
+		Variant{int32(42)},
+		[]string{"x", "y"},
+		map[string]Variant{"k": {"v"}},
+		[]entry{{"e", Variant{uint32(1)}}},
+	}
+	sig, err := SignatureOf(values...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := Signature("ybnqiuxtdsogvasa{sv}a(sv)"); sig != want {
+		t.Errorf("signature %q, want %q", sig, want)
+	}
+	e := new(encoder)
+	for _, v := range values {
+		if err := e.value(reflect.ValueOf(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d := &decoder{buf: e.buf, order: binary.LittleEndian}
+	got, err := d.values(string(sig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []any{
+		byte(7), true, int16(-3), uint16(4), int32(-5), uint32(6),
+		int64(-7), uint64(8), 1.5, "hello", ObjectPath("/a/b"), Signature("a{sv}"),
+		Variant{int32(42)},
+		[]any{"x", "y"},
+		map[any]any{"k": Variant{"v"}},
+		[]any{[]any{"e", Variant{uint32(1)}}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decoded\n%#v\nwant\n%#v", got, want)
+	}
+}
+
+func TestBus(t *testing.T) {
+	addr := dbustest.StartBus(t)
+	const path = ObjectPath("/org/example/Object")
+	server, err := Dial(addr, func(c *Conn, m *Message) {
+		if m.Type != TypeMethodCall {
+			return
+		}
+		switch {
+		case m.Path != path:
+			c.ReplyError(m, ErrUnknownObject, string(m.Path))
+		case m.Member == "Add":
+			a, _ := m.Body[0].(int32)
+			b, _ := m.Body[1].(int32)
+			c.Reply(m, a+b)
+			c.Emit(path, "org.example.Object", "Added", a+b)
+		default:
+			c.ReplyError(m, ErrUnknownMethod, m.Member)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	if !strings.HasPrefix(server.UniqueName(), ":") {
+		t.Errorf("unique name %q", server.UniqueName())
+	}
+	signals := make(chan *Message, 1)
+	client, err := Dial(addr, func(c *Conn, m *Message) {
+		if m.Type == TypeSignal && m.Member == "Added" {
+			signals <- m
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if _, err := client.Call(BusName, BusPath, BusInterface, "AddMatch", "type='signal',interface='org.example.Object'"); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := client.Call(server.UniqueName(), path, "org.example.Object", "Add", int32(2), int32(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(reply, []any{int32(5)}) {
+		t.Errorf("reply %v, want [5]", reply)
+	}
+	select {
+	case s := <-signals:
+		if s.Path != path || !reflect.DeepEqual(s.Body, []any{int32(5)}) {
+			t.Errorf("signal %+v", s)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("no signal")
+	}
+	_, err = client.Call(server.UniqueName(), path, "org.example.Object", "Missing")
+	var derr *Error
+	if !errors.As(err, &derr) || derr.Name != ErrUnknownMethod {
+		t.Errorf("got error %v, want %s", err, ErrUnknownMethod)
+	}
+}
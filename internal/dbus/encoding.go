@@ -0,0 +1,531 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package dbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ObjectPath is a D-Bus object path, such as "/org/freedesktop/DBus".
+type ObjectPath string
+
+// Signature is a D-Bus type signature, such as "a{sv}".
+type Signature string
+
+// Variant is a value along with its type, the D-Bus "v" type. Decoded
+// variants hold values of the types documented for [Message.Body].
+type Variant struct {
+	Value any
+}
+
+var (
+	objectPathType = reflect.TypeOf(ObjectPath(""))
+	signatureType  = reflect.TypeOf(Signature(""))
+	variantType    = reflect.TypeOf(Variant{})
+)
+
+// SignatureOf returns the D-Bus signature of the values. The Go types
+// map to D-Bus types as follows: byte is "y", bool "b", int16 "n",
+// uint16 "q", int32 "i", uint32 "u", int64 "x", uint64 "t", float64
+// "d", string "s", ObjectPath "o", Signature "g", Variant "v", slices
+// and arrays "a" followed by the element type, maps "a{kv}" and
+// structs of exported fields "(...)".
+func SignatureOf(values ...any) (Signature, error) {
+	var sig strings.Builder
+	for _, v := range values {
+		s, err := typeSignature(reflect.TypeOf(v))
+		if err != nil {
+			return "", err
+		}
+		sig.WriteString(s)
+	}
+	return Signature(sig.String()), nil
+}
+
+func typeSignature(t reflect.Type) (string, error) {
+	if t == nil {
+		return "", errors.New("dbus: nil value")
+	}
+	switch t {
+	case objectPathType:
+		return "o", nil
+	case signatureType:
+		return "g", nil
+	case variantType:
+		return "v", nil
+	}
+	switch t.Kind() {
+	case reflect.Uint8:
+		return "y", nil
+	case reflect.Bool:
+		return "b", nil
+	case reflect.Int16:
+		return "n", nil
+	case reflect.Uint16:
+		return "q", nil
+	case reflect.Int32:
+		return "i", nil
+	case reflect.Uint32:
+		return "u", nil
+	case reflect.Int64:
+		return "x", nil
+	case reflect.Uint64:
+		return "t", nil
+	case reflect.Float64:
+		return "d", nil
+	case reflect.String:
+		return "s", nil
+	case reflect.Slice, reflect.Array:
+		elem, err := typeSignature(t.Elem())
+		if err != nil {
+			return "", err
+		}
+		return "a" + elem, nil
+	case reflect.Map:
+		k, err := typeSignature(t.Key())
+		if err != nil {
+			return "", err
+		}
+		if len(k) != 1 || strings.ContainsAny(k, "av") {
+			return "", fmt.Errorf("dbus: invalid dict key type %v", t.Key())
+		}
+		v, err := typeSignature(t.Elem())
+		if err != nil {
+			return "", err
+		}
+		return "a{" + k + v + "}", nil
+	case reflect.Struct:
+		var sig strings.Builder
+		sig.WriteByte('(')
+		for i := range t.NumField() {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			s, err := typeSignature(f.Type)
+			if err != nil {
+				return "", err
+			}
+			sig.WriteString(s)
+		}
+		sig.WriteByte(')')
+		if sig.Len() == 2 {
+			return "", fmt.Errorf("dbus: empty struct %v", t)
+		}
+		return sig.String(), nil
+	case reflect.Interface:
+		return "", fmt.Errorf("dbus: interface type %v must be wrapped in a Variant", t)
+	}
+	return "", fmt.Errorf("dbus: unsupported type %v", t)
+}
+
+// alignment returns the alignment of the type with the signature code.
+func alignment(code byte) int {
+	switch code {
+	case 'y', 'g', 'v':
+		return 1
+	case 'n', 'q':
+		return 2
+	case 'b', 'i', 'u', 's', 'o', 'a':
+		return 4
+	default:
+		// x, t, d, structs and dict entries.
+		return 8
+	}
+}
+
+// encoder marshals values in little endian byte order. Alignment is
+// relative to the start of buf.
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) align(n int) {
+	for len(e.buf)%n != 0 {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+func (e *encoder) uint32(v uint32) {
+	e.align(4)
+	e.buf = binary.LittleEndian.AppendUint32(e.buf, v)
+}
+
+func (e *encoder) string(s string) {
+	e.uint32(uint32(len(s)))
+	e.buf = append(e.buf, s...)
+	e.buf = append(e.buf, 0)
+}
+
+func (e *encoder) signature(s string) {
+	e.buf = append(e.buf, byte(len(s)))
+	e.buf = append(e.buf, s...)
+	e.buf = append(e.buf, 0)
+}
+
+func (e *encoder) value(v reflect.Value) error {
+	switch v.Type() {
+	case objectPathType:
+		p := v.String()
+		if !validPath(p) {
+			return fmt.Errorf("dbus: invalid object path %q", p)
+		}
+		e.string(p)
+		return nil
+	case signatureType:
+		e.signature(v.String())
+		return nil
+	case variantType:
+		inner := v.Field(0)
+		if inner.IsNil() {
+			return errors.New("dbus: empty variant")
+		}
+		inner = inner.Elem()
+		sig, err := typeSignature(inner.Type())
+		if err != nil {
+			return err
+		}
+		e.signature(sig)
+		return e.value(inner)
+	}
+	switch v.Kind() {
+	case reflect.Uint8:
+		e.buf = append(e.buf, byte(v.Uint()))
+	case reflect.Bool:
+		b := uint32(0)
+		if v.Bool() {
+			b = 1
+		}
+		e.uint32(b)
+	case reflect.Int16:
+		e.align(2)
+		e.buf = binary.LittleEndian.AppendUint16(e.buf, uint16(v.Int()))
+	case reflect.Uint16:
+		e.align(2)
+		e.buf = binary.LittleEndian.AppendUint16(e.buf, uint16(v.Uint()))
+	case reflect.Int32:
+		e.uint32(uint32(v.Int()))
+	case reflect.Uint32:
+		e.uint32(uint32(v.Uint()))
+	case reflect.Int64:
+		e.align(8)
+		e.buf = binary.LittleEndian.AppendUint64(e.buf, uint64(v.Int()))
+	case reflect.Uint64:
+		e.align(8)
+		e.buf = binary.LittleEndian.AppendUint64(e.buf, v.Uint())
+	case reflect.Float64:
+		e.align(8)
+		e.buf = binary.LittleEndian.AppendUint64(e.buf, math.Float64bits(v.Float()))
+	case reflect.String:
+		e.string(v.String())
+	case reflect.Slice, reflect.Array:
+		sig, err := typeSignature(v.Type().Elem())
+		if err != nil {
+			return err
+		}
+		return e.array(alignment(sig[0]), v.Len(), func(i int) error {
+			return e.value(v.Index(i))
+		})
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+		})
+		return e.array(8, len(keys), func(i int) error {
+			e.align(8)
+			if err := e.value(keys[i]); err != nil {
+				return err
+			}
+			return e.value(v.MapIndex(keys[i]))
+		})
+	case reflect.Struct:
+		e.align(8)
+		for i := range v.NumField() {
+			if !v.Type().Field(i).IsExported() {
+				continue
+			}
+			if err := e.value(v.Field(i)); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("dbus: unsupported type %v", v.Type())
+	}
+	return nil
+}
+
+// array encodes the length of an array followed by its n elements. The
+// padding before the first element is not part of the length.
+func (e *encoder) array(align, n int, elem func(i int) error) error {
+	e.uint32(0)
+	lenPos := len(e.buf) - 4
+	e.align(align)
+	start := len(e.buf)
+	for i := range n {
+		if err := elem(i); err != nil {
+			return err
+		}
+	}
+	binary.LittleEndian.PutUint32(e.buf[lenPos:], uint32(len(e.buf)-start))
+	return nil
+}
+
+// decoder unmarshals values. Alignment is relative to the start of buf.
+type decoder struct {
+	buf   []byte
+	pos   int
+	order binary.ByteOrder
+}
+
+var errShort = errors.New("dbus: message too short")
+
+func (d *decoder) align(n int) error {
+	pos := (d.pos + n - 1) / n * n
+	if pos > len(d.buf) {
+		return errShort
+	}
+	d.pos = pos
+	return nil
+}
+
+func (d *decoder) read(n int) ([]byte, error) {
+	if len(d.buf)-d.pos < n {
+		return nil, errShort
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *decoder) uint32() (uint32, error) {
+	if err := d.align(4); err != nil {
+		return 0, err
+	}
+	b, err := d.read(4)
+	if err != nil {
+		return 0, err
+	}
+	return d.order.Uint32(b), nil
+}
+
+func (d *decoder) string() (string, error) {
+	n, err := d.uint32()
+	if err != nil {
+		return "", err
+	}
+	b, err := d.read(int(n) + 1)
+	if err != nil {
+		return "", err
+	}
+	return string(b[:n]), nil
+}
+
+func (d *decoder) signature() (string, error) {
+	n, err := d.read(1)
+	if err != nil {
+		return "", err
+	}
+	b, err := d.read(int(n[0]) + 1)
+	if err != nil {
+		return "", err
+	}
+	return string(b[:n[0]]), nil
+}
+
+// values decodes the values of a signature.
+func (d *decoder) values(sig string) ([]any, error) {
+	var vals []any
+	for sig != "" {
+		t, rest, err := nextType(sig)
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.value(t)
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, v)
+		sig = rest
+	}
+	return vals, nil
+}
+
+// value decodes a value of the single complete type t.
+func (d *decoder) value(t string) (any, error) {
+	switch t[0] {
+	case 'y':
+		b, err := d.read(1)
+		if err != nil {
+			return nil, err
+		}
+		return b[0], nil
+	case 'b':
+		v, err := d.uint32()
+		return v != 0, err
+	case 'n', 'q':
+		if err := d.align(2); err != nil {
+			return nil, err
+		}
+		b, err := d.read(2)
+		if err != nil {
+			return nil, err
+		}
+		v := d.order.Uint16(b)
+		if t[0] == 'n' {
+			return int16(v), nil
+		}
+		return v, nil
+	case 'i':
+		v, err := d.uint32()
+		return int32(v), err
+	case 'u', 'h':
+		return d.uint32()
+	case 'x', 't', 'd':
+		if err := d.align(8); err != nil {
+			return nil, err
+		}
+		b, err := d.read(8)
+		if err != nil {
+			return nil, err
+		}
+		v := d.order.Uint64(b)
+		switch t[0] {
+		case 'x':
+			return int64(v), nil
+		case 'd':
+			return math.Float64frombits(v), nil
+		}
+		return v, nil
+	case 's':
+		return d.string()
+	case 'o':
+		s, err := d.string()
+		return ObjectPath(s), err
+	case 'g':
+		s, err := d.signature()
+		return Signature(s), err
+	case 'v':
+		sig, err := d.signature()
+		if err != nil {
+			return nil, err
+		}
+		inner, rest, err := nextType(sig)
+		if err != nil {
+			return nil, err
+		}
+		if rest != "" {
+			return nil, fmt.Errorf("dbus: invalid variant signature %q", sig)
+		}
+		v, err := d.value(inner)
+		return Variant{Value: v}, err
+	case '(':
+		if err := d.align(8); err != nil {
+			return nil, err
+		}
+		return d.values(t[1 : len(t)-1])
+	case 'a':
+		n, err := d.uint32()
+		if err != nil {
+			return nil, err
+		}
+		elem := t[1:]
+		if err := d.align(alignment(elem[0])); err != nil {
+			return nil, err
+		}
+		end := d.pos + int(n)
+		if end > len(d.buf) {
+			return nil, errShort
+		}
+		if elem[0] == '{' {
+			kt, vt, _ := nextType(elem[1 : len(elem)-1])
+			m := make(map[any]any)
+			for d.pos < end {
+				if err := d.align(8); err != nil {
+					return nil, err
+				}
+				k, err := d.value(kt)
+				if err != nil {
+					return nil, err
+				}
+				v, err := d.value(vt)
+				if err != nil {
+					return nil, err
+				}
+				m[k] = v
+			}
+			return m, nil
+		}
+		var arr []any
+		for d.pos < end {
+			v, err := d.value(elem)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+		return arr, nil
+	}
+	return nil, fmt.Errorf("dbus: unsupported type %q", t)
+}
+
+// nextType splits the first complete type from sig.
+func nextType(sig string) (string, string, error) {
+	if sig == "" {
+		return "", "", errors.New("dbus: empty signature")
+	}
+	switch sig[0] {
+	case 'a':
+		t, rest, err := nextType(sig[1:])
+		if err != nil {
+			return "", "", err
+		}
+		return "a" + t, rest, nil
+	case '(', '{':
+		closing := byte(')')
+		if sig[0] == '{' {
+			closing = '}'
+		}
+		rest := sig[1:]
+		for rest != "" && rest[0] != closing {
+			var err error
+			if _, rest, err = nextType(rest); err != nil {
+				return "", "", err
+			}
+		}
+		if rest == "" {
+			return "", "", fmt.Errorf("dbus: unterminated signature %q", sig)
+		}
+		n := len(sig) - len(rest) + 1
+		return sig[:n], sig[n:], nil
+	case 'y', 'b', 'n', 'q', 'i', 'u', 'x', 't', 'd', 's', 'o', 'g', 'v', 'h':
+		return sig[:1], sig[1:], nil
+	}
+	return "", "", fmt.Errorf("dbus: invalid signature %q", sig)
+}
+
+// validPath reports whether p is a valid object path.
+func validPath(p string) bool {
+	if p == "/" {
+		return true
+	}
+	if !strings.HasPrefix(p, "/") || strings.HasSuffix(p, "/") {
+		return false
+	}
+	for _, elem := range strings.Split(p[1:], "/") {
+		if elem == "" {
+			return false
+		}
+		for _, r := range elem {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
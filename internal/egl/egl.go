@@ -11,6 +11,7 @@ import (
 	"runtime"
 	"slices"
 	"strings"
+	"sync"
 
 	"github.com/mleku/gio/gpu"
 )
@@ -37,6 +38,15 @@ var (
 	EGL_DEFAULT_DISPLAY NativeDisplayType
 )
 
+// displayRefs counts the contexts of every EGL display. Terminating a
+// display releases the resources of all its contexts, so it waits for
+// the last context, such as when a Wayland window and its popups share
+// a connection.
+var displayRefs struct {
+	mu   sync.Mutex
+	refs map[_EGLDisplay]int
+}
+
 const (
 	_EGL_ALPHA_SIZE             = 0x3021
 	_EGL_BLUE_SIZE              = 0x3022
@@ -62,7 +72,16 @@ func (c *Context) Release() {
 		eglDestroyContext(c.disp, c.eglCtx.ctx)
 		c.eglCtx = nil
 	}
-	eglTerminate(c.disp)
+	if c.disp == nilEGLDisplay {
+		return
+	}
+	displayRefs.mu.Lock()
+	defer displayRefs.mu.Unlock()
+	displayRefs.refs[c.disp]--
+	if displayRefs.refs[c.disp] == 0 {
+		delete(displayRefs.refs, c.disp)
+		eglTerminate(c.disp)
+	}
 	c.disp = nilEGLDisplay
 }
 
@@ -74,6 +93,18 @@ func (c *Context) Present() error {
 }
 
 func NewContext(disp NativeDisplayType) (*Context, error) {
+	return newContext(disp, false, 0)
+}
+
+// NewTransparentContext is like NewContext, but its surfaces have an
+// alpha channel for blending with the content below them. The
+// configuration is restricted to the native visual with the visualID,
+// if not zero, for windows that were created with a visual.
+func NewTransparentContext(disp NativeDisplayType, visualID int) (*Context, error) {
+	return newContext(disp, true, visualID)
+}
+
+func newContext(disp NativeDisplayType, alpha bool, visualID int) (*Context, error) {
 	if err := loadEGL(); err != nil {
 		return nil, err
 	}
@@ -87,10 +118,16 @@ func NewContext(disp NativeDisplayType) (*Context, error) {
 	if eglDisp == nilEGLDisplay {
 		return nil, fmt.Errorf("eglGetDisplay failed: 0x%x", eglGetError())
 	}
-	eglCtx, err := createContext(eglDisp)
+	displayRefs.mu.Lock()
+	defer displayRefs.mu.Unlock()
+	eglCtx, err := createContext(eglDisp, alpha, visualID)
 	if err != nil {
 		return nil, err
 	}
+	if displayRefs.refs == nil {
+		displayRefs.refs = make(map[_EGLDisplay]int)
+	}
+	displayRefs.refs[eglDisp]++
 	c := &Context{
 		disp:   eglDisp,
 		eglCtx: eglCtx,
@@ -158,7 +195,7 @@ func hasExtension(exts []string, ext string) bool {
 	return slices.Contains(exts, ext)
 }
 
-func createContext(disp _EGLDisplay) (*eglContext, error) {
+func createContext(disp _EGLDisplay, alpha bool, visualID int) (*eglContext, error) {
 	major, minor, ret := eglInitialize(disp)
 	if !ret {
 		return nil, fmt.Errorf("eglInitialize failed: 0x%x", eglGetError())
@@ -174,7 +211,9 @@ func createContext(disp _EGLDisplay) (*eglContext, error) {
 		_EGL_RED_SIZE, 8,
 		_EGL_CONFIG_CAVEAT, _EGL_NONE,
 	}
-	if srgb {
+	if alpha {
+		attribs = append(attribs, _EGL_ALPHA_SIZE, 8)
+	} else if srgb {
 		if runtime.GOOS == "linux" || runtime.GOOS == "android" {
 			// Some Mesa drivers crash if an sRGB framebuffer is requested without alpha.
 			// https://bugs.freedesktop.org/show_bug.cgi?id=107782.
@@ -184,9 +223,19 @@ func createContext(disp _EGLDisplay) (*eglContext, error) {
 		}
 	}
 	attribs = append(attribs, _EGL_NONE)
-	eglCfg, ret := eglChooseConfig(disp, attribs)
-	if !ret {
-		return nil, fmt.Errorf("eglChooseConfig failed: 0x%x", eglGetError())
+	var eglCfg _EGLConfig
+	if visualID != 0 {
+		cfg, err := chooseVisualConfig(disp, attribs, visualID)
+		if err != nil {
+			return nil, err
+		}
+		eglCfg = cfg
+	} else {
+		cfg, ret := eglChooseConfig(disp, attribs)
+		if !ret {
+			return nil, fmt.Errorf("eglChooseConfig failed: 0x%x", eglGetError())
+		}
+		eglCfg = cfg
 	}
 	if eglCfg == nilEGLConfig {
 		supportsNoCfg := hasExtension(exts, "EGL_KHR_no_config_context")
@@ -227,6 +276,21 @@ func createContext(disp _EGLDisplay) (*eglContext, error) {
 	}, nil
 }
 
+// chooseVisualConfig returns the best configuration matching attribs
+// whose native visual is visualID.
+func chooseVisualConfig(disp _EGLDisplay, attribs []_EGLint, visualID int) (_EGLConfig, error) {
+	cfgs, ok := eglChooseConfigs(disp, attribs)
+	if !ok {
+		return nilEGLConfig, fmt.Errorf("eglChooseConfig failed: 0x%x", eglGetError())
+	}
+	for _, cfg := range cfgs {
+		if id, ok := eglGetConfigAttrib(disp, cfg, _EGL_NATIVE_VISUAL_ID); ok && int(id) == visualID {
+			return cfg, nil
+		}
+	}
+	return nilEGLConfig, fmt.Errorf("eglChooseConfig returned no configs for visual 0x%x", visualID)
+}
+
 func createSurface(disp _EGLDisplay, eglCtx *eglContext, win NativeWindowType) (_EGLSurface, error) {
 	var surfAttribs []_EGLint
 	if eglCtx.srgb {
@@ -42,6 +42,23 @@ func eglChooseConfig(disp _EGLDisplay, attribs []_EGLint) (_EGLConfig, bool) {
 	return _EGLConfig(cfg), true
 }
 
+// eglChooseConfigs is like eglChooseConfig but returns every matching
+// configuration, best first.
+func eglChooseConfigs(disp _EGLDisplay, attribs []_EGLint) ([]_EGLConfig, bool) {
+	var ncfg C.EGLint
+	if C.eglChooseConfig(disp, &attribs[0], nil, 0, &ncfg) != C.EGL_TRUE {
+		return nil, false
+	}
+	if ncfg == 0 {
+		return nil, true
+	}
+	cfgs := make([]_EGLConfig, ncfg)
+	if C.eglChooseConfig(disp, &attribs[0], &cfgs[0], ncfg, &ncfg) != C.EGL_TRUE {
+		return nil, false
+	}
+	return cfgs[:ncfg], true
+}
+
 func eglCreateContext(disp _EGLDisplay, cfg _EGLConfig, shareCtx _EGLContext, attribs []_EGLint) _EGLContext {
 	ctx := C.eglCreateContext(disp, cfg, shareCtx, &attribs[0])
 	return _EGLContext(ctx)
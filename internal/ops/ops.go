@@ -77,6 +77,7 @@ const (
 	TypeSemanticSelected
 	TypeSemanticEnabled
 	TypeActionInput
+	TypeImageCursor
 )
 
 type StackID struct {
@@ -152,7 +153,8 @@ const (
 	TypeSemanticClassLen    = 2
 	TypeSemanticSelectedLen = 2
 	TypeSemanticEnabledLen  = 2
-	TypeActionInputLen      = 1 + 1
+	TypeActionInputLen      = 1 + 4
+	TypeImageCursorLen      = 1
 )
 
 func (op *ClipOp) Decode(data []byte) {
@@ -426,6 +428,7 @@ var opProps = [0x100]opProp{
 	TypeSemanticSelected: {Size: TypeSemanticSelectedLen, NumRefs: 0},
 	TypeSemanticEnabled:  {Size: TypeSemanticEnabledLen, NumRefs: 0},
 	TypeActionInput:      {Size: TypeActionInputLen, NumRefs: 0},
+	TypeImageCursor:      {Size: TypeImageCursorLen, NumRefs: 1},
 }
 
 func (t OpType) props() (size, numRefs uint32) {
@@ -8,17 +8,60 @@ import (
 	"github.com/mleku/gio/io/event"
 )
 
-// WriteCmd copies Text to the clipboard.
+// Kind selects the system clipboard addressed by a command.
+type Kind uint8
+
+const (
+	// Clipboard is the clipboard used by explicit copy and paste
+	// operations.
+	Clipboard Kind = iota
+	// Primary is the selection set by selecting text and pasted by
+	// the middle mouse button. Primary is supported on X11, and on
+	// Wayland compositors with the primary selection protocol; other
+	// platforms ignore writes and deliver no data for reads.
+	Primary
+)
+
+// WriteCmd copies Data to the clipboard.
 type WriteCmd struct {
 	Type string
 	Data io.ReadCloser
+	// Kind is the clipboard to write.
+	Kind Kind
+	// Alternatives are other representations of Data, in order of
+	// preference. For example, a chart may be copied as image/png with
+	// text/html and text/plain alternatives.
+	Alternatives []Alternative
+}
+
+// Alternative is a representation of the data in a [WriteCmd].
+type Alternative struct {
+	Type string
+	Data io.ReadCloser
 }
 
-// ReadCmd requests the text of the clipboard, delivered to
+// ReadCmd requests the content of the clipboard, delivered to
 // the handler through an [io/transfer.DataEvent].
 type ReadCmd struct {
 	Tag event.Tag
+	// Kind is the clipboard to read.
+	Kind Kind
+	// Type is the MIME type to read. The empty type reads text,
+	// delivered as "application/text". If the clipboard holds no
+	// data of the type, the DataEvent contains no data.
+	Type string
 }
 
 func (WriteCmd) ImplementsCommand() {}
 func (ReadCmd) ImplementsCommand()  {}
+
+func (k Kind) String() string {
+	switch k {
+	case Clipboard:
+		return "Clipboard"
+	case Primary:
+		return "Primary"
+	default:
+		panic("invalid Kind")
+	}
+}
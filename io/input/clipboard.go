@@ -8,29 +8,69 @@ import (
 
 	"github.com/mleku/gio/io/clipboard"
 	"github.com/mleku/gio/io/event"
+	"github.com/mleku/gio/io/transfer"
 )
 
+// ClipboardData is a representation of content copied to a clipboard.
+type ClipboardData struct {
+	// Type is the MIME type of Data.
+	Type string
+	Data []byte
+}
+
+// ClipboardEvent is the content of a clipboard kind, read by the
+// platform in response to ClipboardRequested or PrimaryRequested. A
+// transfer.DataEvent queued by itself is content of the Clipboard.
+type ClipboardEvent struct {
+	Kind clipboard.Kind
+	transfer.DataEvent
+}
+
 // clipboardState contains the state for clipboard event routing.
 type clipboardState struct {
-	receivers []event.Tag
+	receivers []clipboardReceiver
+	// primaryReceivers are the handlers waiting for the primary
+	// selection.
+	primaryReceivers []clipboardReceiver
+}
+
+// clipboardReceiver is a handler waiting for clipboard data of a
+// MIME type.
+type clipboardReceiver struct {
+	tag  event.Tag
+	mime string
 }
 
 type clipboardQueue struct {
 	// request avoid read clipboard every frame while waiting.
 	requested bool
-	mime      string
-	text      []byte
+	content   []ClipboardData
+	// primary is the clipboard state of the primary selection.
+	primary struct {
+		requested bool
+		content   []ClipboardData
+	}
+}
+
+// WriteClipboard returns the representations of the most recent
+// content to be copied to the clipboard, if any.
+func (q *clipboardQueue) WriteClipboard() (content []ClipboardData, ok bool) {
+	if q.content == nil {
+		return nil, false
+	}
+	content = q.content
+	q.content = nil
+	return content, true
 }
 
-// WriteClipboard returns the most recent data to be copied
-// to the clipboard, if any.
-func (q *clipboardQueue) WriteClipboard() (mime string, content []byte, ok bool) {
-	if q.text == nil {
-		return "", nil, false
+// WritePrimary is like WriteClipboard for the primary selection.
+func (q *clipboardQueue) WritePrimary() (content []ClipboardData, ok bool) {
+	if q.primary.content == nil {
+		return nil, false
 	}
-	content = q.text
-	q.text = nil
-	return q.mime, content, true
+	content = q.primary.content
+	q.primary.content = nil
+	return content, true
 }
 
 // ClipboardRequested reports if any new handler is waiting
@@ -41,31 +81,89 @@ func (q *clipboardQueue) ClipboardRequested(state clipboardState) bool {
 	return req
 }
 
-func (q *clipboardQueue) Push(state clipboardState, e event.Event) (clipboardState, []taggedEvent) {
+// PrimaryRequested is like ClipboardRequested for the primary
+// selection.
+func (q *clipboardQueue) PrimaryRequested(state clipboardState) bool {
+	req := len(state.primaryReceivers) > 0 && q.primary.requested
+	q.primary.requested = false
+	return req
+}
+
+// ClipboardTypes returns the MIME types requested by the handlers
+// waiting for the clipboard kind.
+func (q *clipboardQueue) ClipboardTypes(state clipboardState, kind clipboard.Kind) []string {
+	receivers := state.receivers
+	if kind == clipboard.Primary {
+		receivers = state.primaryReceivers
+	}
+	var mimes []string
+	for _, r := range receivers {
+		if !slices.Contains(mimes, r.mime) {
+			mimes = append(mimes, r.mime)
+		}
+	}
+	return mimes
+}
+
+// Push delivers the content of the clipboard kind to the handlers
+// waiting for its type.
+func (q *clipboardQueue) Push(state clipboardState, kind clipboard.Kind, e transfer.DataEvent) (clipboardState, []taggedEvent) {
+	receivers := &state.receivers
+	if kind == clipboard.Primary {
+		receivers = &state.primaryReceivers
+	}
 	var evts []taggedEvent
-	for _, r := range state.receivers {
-		evts = append(evts, taggedEvent{tag: r, event: e})
+	var waiting []clipboardReceiver
+	for _, r := range *receivers {
+		if r.mime != e.Type {
+			waiting = append(waiting, r)
+			continue
+		}
+		evts = append(evts, taggedEvent{tag: r.tag, event: e})
 	}
-	state.receivers = nil
+	*receivers = waiting
 	return state, evts
 }
 
 func (q *clipboardQueue) ProcessWriteClipboard(req clipboard.WriteCmd) {
 	defer req.Data.Close()
-	content, err := io.ReadAll(req.Data)
+	for _, alt := range req.Alternatives {
+		defer alt.Data.Close()
+	}
+	data, err := io.ReadAll(req.Data)
 	if err != nil {
 		return
 	}
-	q.mime = req.Type
-	q.text = content
+	content := []ClipboardData{{Type: req.Type, Data: data}}
+	for _, alt := range req.Alternatives {
+		data, err := io.ReadAll(alt.Data)
+		if err != nil {
+			continue
+		}
+		content = append(content, ClipboardData{Type: alt.Type, Data: data})
+	}
+	switch req.Kind {
+	case clipboard.Primary:
+		q.primary.content = content
+	default:
+		q.content = content
+	}
 }
 
-func (q *clipboardQueue) ProcessReadClipboard(state clipboardState, tag event.Tag) clipboardState {
-	if slices.Contains(state.receivers, tag) {
+func (q *clipboardQueue) ProcessReadClipboard(state clipboardState, req clipboard.ReadCmd) clipboardState {
+	receivers, requested := &state.receivers, &q.requested
+	if req.Kind == clipboard.Primary {
+		receivers, requested = &state.primaryReceivers, &q.primary.requested
+	}
+	r := clipboardReceiver{tag: req.Tag, mime: req.Type}
+	if r.mime == "" {
+		r.mime = "application/text"
+	}
+	if slices.Contains(*receivers, r) {
 		return state
 	}
-	n := len(state.receivers)
-	state.receivers = append(state.receivers[:n:n], tag)
-	q.requested = true
+	n := len(*receivers)
+	*receivers = append((*receivers)[:n:n], r)
+	*requested = true
 	return state
 }
@@ -4,6 +4,7 @@ package input
 
 import (
 	"io"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -87,6 +88,108 @@ func TestQueueProcessWriteClipboard(t *testing.T) {
 	assertClipboardWriteCmd(t, r, mime, "Write 2")
 }
 
+func TestClipboardFormats(t *testing.T) {
+	r, handlers := new(Router), make([]int, 2)
+
+	r.Source().Execute(clipboard.WriteCmd{
+		Type: "image/png",
+		Data: io.NopCloser(strings.NewReader("PNG")),
+		Alternatives: []clipboard.Alternative{
+			{Type: "text/html", Data: io.NopCloser(strings.NewReader("<img>"))},
+			{Type: "text/plain", Data: io.NopCloser(strings.NewReader("image"))},
+		},
+	})
+	content, ok := r.WriteClipboard()
+	if !ok {
+		t.Fatal("missing clipboard write")
+	}
+	want := []ClipboardData{
+		{Type: "image/png", Data: []byte("PNG")},
+		{Type: "text/html", Data: []byte("<img>")},
+		{Type: "text/plain", Data: []byte("image")},
+	}
+	if !reflect.DeepEqual(content, want) {
+		t.Errorf("got clipboard content %v, want %v", content, want)
+	}
+
+	r.Source().Execute(clipboard.ReadCmd{Tag: &handlers[0], Type: "image/png"})
+	r.Source().Execute(clipboard.ReadCmd{Tag: &handlers[1]})
+	if !r.ClipboardRequested() {
+		t.Error("missing request")
+	}
+	if got, want := r.ClipboardTypes(clipboard.Clipboard), []string{"image/png", "application/text"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got requested types %v, want %v", got, want)
+	}
+	r.Queue(transfer.DataEvent{
+		Type: "image/png",
+		Open: func() io.ReadCloser {
+			return io.NopCloser(strings.NewReader("PNG"))
+		},
+	})
+	assertEventTypeSequence(t, events(r, -1, transfer.TargetFilter{Target: &handlers[0], Type: "image/png"}), transfer.DataEvent{})
+	// The text reader is still waiting.
+	assertClipboardReadDuplicated(t, r, 1)
+	r.Queue(transfer.DataEvent{
+		Type: "application/text",
+		Open: func() io.ReadCloser {
+			return io.NopCloser(strings.NewReader("text"))
+		},
+	})
+	assertEventTypeSequence(t, events(r, -1, transfer.TargetFilter{Target: &handlers[1], Type: "application/text"}), transfer.DataEvent{})
+	assertClipboardReadCmd(t, r, 0)
+}
+
+func TestPrimarySelection(t *testing.T) {
+	r, handlers := new(Router), make([]int, 2)
+
+	const mime = "application/text"
+	r.Source().Execute(clipboard.WriteCmd{Type: mime, Data: io.NopCloser(strings.NewReader("Primary")), Kind: clipboard.Primary})
+	if _, ok := r.WriteClipboard(); ok {
+		t.Error("primary selection written to clipboard")
+	}
+	if content, ok := r.WritePrimary(); !ok || len(content) != 1 || content[0].Type != mime || string(content[0].Data) != "Primary" {
+		t.Errorf("got primary selection %v, %v", content, ok)
+	}
+
+	r.Source().Execute(clipboard.ReadCmd{Tag: &handlers[0]})
+	r.Source().Execute(clipboard.ReadCmd{Tag: &handlers[1], Kind: clipboard.Primary})
+	if !r.ClipboardRequested() {
+		t.Error("missing clipboard request")
+	}
+	if !r.PrimaryRequested() {
+		t.Error("missing primary request")
+	}
+	// Reads complete in any order, and are routed by their kind.
+	r.Queue(ClipboardEvent{
+		Kind: clipboard.Primary,
+		DataEvent: transfer.DataEvent{
+			Type: mime,
+			Open: func() io.ReadCloser {
+				return io.NopCloser(strings.NewReader("Primary"))
+			},
+		},
+	})
+	assertEventTypeSequence(t, events(r, -1, transfer.TargetFilter{Target: &handlers[1], Type: mime}), transfer.DataEvent{})
+	assertEventTypeSequence(t, events(r, -1, transfer.TargetFilter{Target: &handlers[0], Type: mime}))
+	if got := len(r.state().receivers); got != 1 {
+		t.Errorf("got %d clipboard receivers, want 1", got)
+	}
+	r.Queue(ClipboardEvent{
+		Kind: clipboard.Clipboard,
+		DataEvent: transfer.DataEvent{
+			Type: mime,
+			Open: func() io.ReadCloser {
+				return io.NopCloser(strings.NewReader("Clipboard"))
+			},
+		},
+	})
+	assertEventTypeSequence(t, events(r, -1, transfer.TargetFilter{Target: &handlers[0], Type: mime}), transfer.DataEvent{})
+	assertClipboardReadCmd(t, r, 0)
+	if got := len(r.state().primaryReceivers); got != 0 {
+		t.Errorf("got %d primary receivers, want 0", got)
+	}
+}
+
 func assertClipboardReadCmd(t *testing.T, router *Router, expected int) {
 	t.Helper()
 	if got := len(router.state().receivers); got != expected {
@@ -109,13 +212,17 @@ func assertClipboardReadDuplicated(t *testing.T, router *Router, expected int) {
 
 func assertClipboardWriteCmd(t *testing.T, router *Router, mimeExp, expected string) {
 	t.Helper()
-	if (router.cqueue.text != nil) != (expected != "") {
+	if (router.cqueue.content != nil) != (expected != "") {
 		t.Error("text not defined")
 	}
-	mime, text, ok := router.cqueue.WriteClipboard()
+	content, ok := router.cqueue.WriteClipboard()
 	if ok != (expected != "") {
 		t.Error("duplicated requests")
 	}
+	var mime, text string
+	if len(content) > 0 {
+		mime, text = content[0].Type, string(content[0].Data)
+	}
 	if string(mime) != mimeExp {
 		t.Errorf("got MIME type %s, expected %s", mime, mimeExp)
 	}
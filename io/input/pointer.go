@@ -42,10 +42,17 @@ type hitNode struct {
 
 // pointerState is the input state related to pointer events.
 type pointerState struct {
-	cursor   pointer.Cursor
+	cursor   cursor
 	pointers []pointerInfo
 }
 
+// cursor is a predefined or an image cursor shape. The zero value is
+// the default cursor.
+type cursor struct {
+	name  pointer.Cursor
+	image *pointer.ImageCursor
+}
+
 type pointerInfo struct {
 	id       pointer.ID
 	pressed  bool
@@ -88,7 +95,7 @@ type areaNode struct {
 	trans f32.Affine2D
 	area  areaOp
 
-	cursor pointer.Cursor
+	cursor cursor
 
 	// Tree indices, with -1 being the sentinel.
 	parent     int
@@ -393,10 +400,16 @@ func (c *pointerCollector) semanticEnabled(enabled bool) {
 	area.semantic.content.disabled = !enabled
 }
 
-func (c *pointerCollector) cursor(cursor pointer.Cursor) {
+func (c *pointerCollector) cursor(name pointer.Cursor) {
 	areaID := c.currentArea()
 	area := &c.q.areas[areaID]
-	area.cursor = cursor
+	area.cursor = cursor{name: name}
+}
+
+func (c *pointerCollector) imageCursor(img *pointer.ImageCursor) {
+	areaID := c.currentArea()
+	area := &c.q.areas[areaID]
+	area.cursor = cursor{image: img}
 }
 
 func (q *pointerQueue) offerData(handlers map[event.Tag]*handler, state pointerState, req transfer.OfferCmd) (pointerState, []taggedEvent) {
@@ -527,6 +540,30 @@ func (q *pointerQueue) ActionAt(pos f32.Point) (action system.Action, hasAction
 	return action, hasAction
 }
 
+// actionCursor returns the cursor matching a resize action, or
+// CursorDefault.
+func actionCursor(a system.Action) pointer.Cursor {
+	switch a {
+	case system.ActionResizeNorth:
+		return pointer.CursorNorthResize
+	case system.ActionResizeSouth:
+		return pointer.CursorSouthResize
+	case system.ActionResizeWest:
+		return pointer.CursorWestResize
+	case system.ActionResizeEast:
+		return pointer.CursorEastResize
+	case system.ActionResizeNorthWest:
+		return pointer.CursorNorthWestResize
+	case system.ActionResizeNorthEast:
+		return pointer.CursorNorthEastResize
+	case system.ActionResizeSouthWest:
+		return pointer.CursorSouthWestResize
+	case system.ActionResizeSouthEast:
+		return pointer.CursorSouthEastResize
+	}
+	return pointer.CursorDefault
+}
+
 func (q *pointerQueue) SemanticAt(pos f32.Point) (semID SemanticID, hasSemID bool) {
 	q.assignSemIDs()
 	q.hitTest(pos, func(n *hitNode) bool {
@@ -547,11 +584,11 @@ func (q *pointerQueue) SemanticAt(pos f32.Point) (semID SemanticID, hasSemID boo
 // the hit tree, or true to continue. Providing this algorithm in this generic way
 // allows normal event routing and system action event routing to share the same traversal
 // logic even though they are interested in different aspects of hit nodes.
-func (q *pointerQueue) hitTest(pos f32.Point, onNode func(*hitNode) bool) pointer.Cursor {
+func (q *pointerQueue) hitTest(pos f32.Point, onNode func(*hitNode) bool) cursor {
 	// Track whether we're passing through hits.
 	pass := true
 	idx := len(q.hitTree) - 1
-	cursor := pointer.CursorDefault
+	var cur cursor
 	for idx >= 0 {
 		n := &q.hitTree[idx]
 		hit, c := q.hit(n.area, pos)
@@ -559,8 +596,8 @@ func (q *pointerQueue) hitTest(pos f32.Point, onNode func(*hitNode) bool) pointe
 			idx--
 			continue
 		}
-		if cursor == pointer.CursorDefault {
-			cursor = c
+		if cur == (cursor{}) {
+			cur = c
 		}
 		pass = pass && n.pass
 		if pass {
@@ -572,7 +609,7 @@ func (q *pointerQueue) hitTest(pos f32.Point, onNode func(*hitNode) bool) pointe
 			break
 		}
 	}
-	return cursor
+	return cur
 }
 
 func (q *pointerQueue) invTransform(areaIdx int, p f32.Point) f32.Point {
@@ -582,13 +619,16 @@ func (q *pointerQueue) invTransform(areaIdx int, p f32.Point) f32.Point {
 	return q.areas[areaIdx].trans.Invert().Transform(p)
 }
 
-func (q *pointerQueue) hit(areaIdx int, p f32.Point) (bool, pointer.Cursor) {
-	c := pointer.CursorDefault
+func (q *pointerQueue) hit(areaIdx int, p f32.Point) (bool, cursor) {
+	var c cursor
 	for areaIdx != -1 {
 		a := &q.areas[areaIdx]
-		if c == pointer.CursorDefault {
+		if c == (cursor{}) {
 			c = a.cursor
 		}
+		if c == (cursor{}) {
+			c.name = actionCursor(a.action)
+		}
 		p := a.trans.Invert().Transform(p)
 		if !a.area.Hit(p) {
 			return false, c
@@ -714,6 +754,47 @@ func (q *pointerQueue) Deliver(handlers map[event.Tag]*handler, areaIdx int, e p
 	return evts
 }
 
+// semanticAreaFor returns the area of the semantic node id, or -1 if
+// there is none.
+func (q *pointerQueue) semanticAreaFor(id SemanticID) int {
+	if id == 0 {
+		return -1
+	}
+	q.assignSemIDs()
+	for i, a := range q.areas {
+		if a.semantic.id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// enclosingSemanticID returns the ID of the innermost semantic node
+// containing area.
+func (q *pointerQueue) enclosingSemanticID(areaIdx int) (SemanticID, bool) {
+	q.assignSemIDs()
+	for areaIdx != -1 {
+		a := &q.areas[areaIdx]
+		if a.semantic.id != 0 {
+			return a.semantic.id, true
+		}
+		areaIdx = a.parent
+	}
+	return 0, false
+}
+
+// contains reports whether areaIdx is ancestor or one of its
+// descendants.
+func (q *pointerQueue) contains(ancestor, areaIdx int) bool {
+	for areaIdx != -1 {
+		if areaIdx == ancestor {
+			return true
+		}
+		areaIdx = q.areas[areaIdx].parent
+	}
+	return false
+}
+
 // SemanticArea returns the sematic content for area, and its parent area.
 func (q *pointerQueue) SemanticArea(areaIdx int) (semanticContent, int) {
 	for areaIdx != -1 {
@@ -807,17 +888,18 @@ func (q *pointerQueue) deliverEvent(handlers map[event.Tag]*handler, p pointerIn
 	return evts
 }
 
-func (q *pointerQueue) deliverEnterLeaveEvents(handlers map[event.Tag]*handler, cursor pointer.Cursor, p pointerInfo, evts []taggedEvent, e pointer.Event) (pointerInfo, []taggedEvent, pointer.Cursor, bool) {
+func (q *pointerQueue) deliverEnterLeaveEvents(handlers map[event.Tag]*handler, cur cursor, p pointerInfo, evts []taggedEvent, e pointer.Event) (pointerInfo, []taggedEvent, cursor, bool) {
 	changed := false
 	var hits []event.Tag
-	if e.Source != pointer.Mouse && !p.pressed && e.Kind != pointer.Press {
-		// Consider non-mouse pointers leaving when they're released.
+	if e.Source == pointer.Touch && !p.pressed && e.Kind != pointer.Press {
+		// Consider touches leaving when they're released. Pens hover
+		// like mice.
 	} else {
 		var transSrc *pointerFilter
 		if p.dataSource != nil {
 			transSrc = &handlers[p.dataSource].filter.pointer
 		}
-		cursor = q.hitTest(e.Position, func(n *hitNode) bool {
+		cur = q.hitTest(e.Position, func(n *hitNode) bool {
 			h, ok := handlers[n.tag]
 			if !ok {
 				return true
@@ -883,7 +965,7 @@ func (q *pointerQueue) deliverEnterLeaveEvents(handlers map[event.Tag]*handler,
 		}
 	}
 	p.entered = hits
-	return p, evts, cursor, changed
+	return p, evts, cur, changed
 }
 
 func (q *pointerQueue) deliverDragEvent(handlers map[event.Tag]*handler, p pointerInfo, evts []taggedEvent) (pointerInfo, []taggedEvent) {
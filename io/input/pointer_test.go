@@ -321,6 +321,59 @@ func TestPointerSystemAction(t *testing.T) {
 		r.Frame(&ops)
 		assertActionAt(t, r, f32.Pt(50, 50), system.ActionClose)
 	})
+	t.Run("resize edges", func(t *testing.T) {
+		var ops op.Ops
+		r1 := clip.Rect(image.Rect(0, 0, 100, 100)).Push(&ops)
+		system.ActionInputOp(system.ActionMove).Add(&ops)
+		r2 := clip.Rect(image.Rect(0, 0, 100, 5)).Push(&ops)
+		system.ActionInputOp(system.ActionResizeNorth).Add(&ops)
+		r2.Pop()
+		r3 := clip.Rect(image.Rect(95, 95, 100, 100)).Push(&ops)
+		system.ActionInputOp(system.ActionResizeSouthEast).Add(&ops)
+		r3.Pop()
+		r1.Pop()
+
+		var r Router
+		r.Frame(&ops)
+		assertActionAt(t, r, f32.Pt(50, 2), system.ActionResizeNorth)
+		assertActionAt(t, r, f32.Pt(98, 98), system.ActionResizeSouthEast)
+		assertActionAt(t, r, f32.Pt(50, 50), system.ActionMove)
+	})
+}
+
+func TestPointerResizeCursor(t *testing.T) {
+	var ops op.Ops
+	r1 := clip.Rect(image.Rect(0, 0, 100, 5)).Push(&ops)
+	system.ActionInputOp(system.ActionResizeNorth).Add(&ops)
+	r1.Pop()
+	r2 := clip.Rect(image.Rect(0, 5, 100, 100)).Push(&ops)
+	system.ActionInputOp(system.ActionMove).Add(&ops)
+	r2.Pop()
+	r3 := clip.Rect(image.Rect(0, 95, 100, 100)).Push(&ops)
+	pointer.CursorPointer.Add(&ops)
+	system.ActionInputOp(system.ActionResizeSouth).Add(&ops)
+	r3.Pop()
+
+	var r Router
+	r.Frame(&ops)
+	for _, tc := range []struct {
+		pos  f32.Point
+		want pointer.Cursor
+	}{
+		{f32.Pt(50, 2), pointer.CursorNorthResize},
+		{f32.Pt(50, 50), pointer.CursorDefault},
+		// An explicit cursor takes precedence.
+		{f32.Pt(50, 98), pointer.CursorPointer},
+	} {
+		r.Queue(pointer.Event{
+			Kind:     pointer.Move,
+			Source:   pointer.Mouse,
+			Position: tc.pos,
+		})
+		if got := r.Cursor(); got != tc.want {
+			t.Errorf("cursor at %v: got %v; want %v", tc.pos, got, tc.want)
+		}
+	}
 }
 
 func TestPointerPriority(t *testing.T) {
@@ -694,6 +747,49 @@ func TestMultitouch(t *testing.T) {
 	assertEventPointerTypeSequence(t, events(&r, -1, f2), pointer.Enter, pointer.Press, pointer.Release)
 }
 
+func TestPenHover(t *testing.T) {
+	var ops op.Ops
+	var r Router
+
+	h := new(int)
+	f := addPointerHandler(&r, &ops, h, image.Rect(0, 0, 100, 100))
+	r.Frame(&ops)
+
+	// A hovering pen enters like a mouse.
+	r.Queue(pointer.Event{
+		Kind:     pointer.Move,
+		Source:   pointer.Pen,
+		Position: f32.Pt(50, 50),
+	})
+	assertEventPointerTypeSequence(t, events(&r, -1, f), pointer.Enter, pointer.Move)
+	r.Queue(
+		pointer.Event{
+			Kind:     pointer.Press,
+			Source:   pointer.Pen,
+			Position: f32.Pt(50, 50),
+			Pressure: 0.5,
+			Tilt:     f32.Pt(10, -20),
+		},
+		pointer.Event{
+			Kind:     pointer.Release,
+			Source:   pointer.Pen,
+			Position: f32.Pt(50, 50),
+		},
+	)
+	evts := events(&r, -1, f)
+	assertEventPointerTypeSequence(t, evts, pointer.Press, pointer.Release)
+	if e := evts[0].(pointer.Event); e.Pressure != 0.5 || e.Tilt != f32.Pt(10, -20) {
+		t.Errorf("got pressure %v and tilt %v, want 0.5 and (10,-20)", e.Pressure, e.Tilt)
+	}
+	// The pen stays inside after release, unlike a touch.
+	r.Queue(pointer.Event{
+		Kind:     pointer.Move,
+		Source:   pointer.Pen,
+		Position: f32.Pt(200, 200),
+	})
+	assertEventPointerTypeSequence(t, events(&r, -1, f), pointer.Leave)
+}
+
 func TestCursor(t *testing.T) {
 	_at := func(x, y float32) []event.Event {
 		return []event.Event{pointer.Event{
@@ -794,6 +890,44 @@ func TestCursor(t *testing.T) {
 	}
 }
 
+func TestImageCursor(t *testing.T) {
+	img := &pointer.ImageCursor{
+		Image:   image.NewRGBA(image.Rect(0, 0, 16, 16)),
+		Hotspot: image.Pt(8, 8),
+	}
+	var ops op.Ops
+	outer := clip.Rect(image.Rect(0, 0, 100, 100)).Push(&ops)
+	img.Add(&ops)
+	inner := clip.Rect(image.Rect(0, 0, 50, 50)).Push(&ops)
+	pointer.CursorPointer.Add(&ops)
+	inner.Pop()
+	outer.Pop()
+	var r Router
+	r.Frame(&ops)
+	for _, tc := range []struct {
+		pos    f32.Point
+		cursor pointer.Cursor
+		image  *pointer.ImageCursor
+	}{
+		{f32.Pt(75, 75), pointer.CursorDefault, img},
+		// The innermost cursor takes precedence.
+		{f32.Pt(25, 25), pointer.CursorPointer, nil},
+		{f32.Pt(200, 200), pointer.CursorDefault, nil},
+	} {
+		r.Queue(pointer.Event{
+			Kind:     pointer.Move,
+			Source:   pointer.Mouse,
+			Position: tc.pos,
+		})
+		if got := r.Cursor(); got != tc.cursor {
+			t.Errorf("at %v: got cursor %v, want %v", tc.pos, got, tc.cursor)
+		}
+		if got := r.ImageCursor(); got != tc.image {
+			t.Errorf("at %v: got image cursor %p, want %p", tc.pos, got, tc.image)
+		}
+	}
+}
+
 func TestPassOp(t *testing.T) {
 	var ops op.Ops
 
@@ -3,6 +3,7 @@
 package input
 
 import (
+	"encoding/binary"
 	"image"
 	"io"
 	"slices"
@@ -41,6 +42,7 @@ type Router struct {
 		scratchFilter keyFilter
 	}
 	cqueue clipboardQueue
+	tqueue transferQueue
 	// states is the list of pending state changes resulting from
 	// incoming events. The first element, if present, contains the state
 	// and events for the current frame.
@@ -53,6 +55,8 @@ type Router struct {
 	commands []Command
 	// transfers is the pending transfer.DataEvent.Open functions.
 	transfers []io.ReadCloser
+	// urls is the pending system.URLCmd commands.
+	urls []system.URLCmd
 	// deferring is set if command execution and event delivery is deferred
 	// to the next frame.
 	deferring bool
@@ -158,6 +162,7 @@ type inputState struct {
 	clipboardState
 	keyState
 	pointerState
+	transfer transferState
 }
 
 // taggedEvent represents an event and its target handler.
@@ -470,7 +475,11 @@ func (q *Router) processEvent(e event.Event, system bool) {
 		}
 		q.changeState(e, state, evts)
 	case transfer.DataEvent:
-		cstate, evts := q.cqueue.Push(state.clipboardState, e)
+		cstate, evts := q.cqueue.Push(state.clipboardState, clipboard.Clipboard, e)
+		state.clipboardState = cstate
+		q.changeState(e, state, evts)
+	case ClipboardEvent:
+		cstate, evts := q.cqueue.Push(state.clipboardState, e.Kind, e.DataEvent)
 		state.clipboardState = cstate
 		q.changeState(e, state, evts)
 	default:
@@ -545,11 +554,19 @@ func (q *Router) executeCommand(c Command) stateChange {
 	case key.SnippetCmd:
 		state.keyState = q.key.queue.setSnippet(state.keyState, req)
 	case transfer.OfferCmd:
+		if src := state.transfer.source; src != nil && src == req.Tag {
+			// The source stays available for further requests
+			// until CancelExport.
+			q.tqueue.offer(req)
+			break
+		}
 		state.pointerState, evts = q.pointer.queue.offerData(q.handlers, state.pointerState, req)
 	case clipboard.WriteCmd:
 		q.cqueue.ProcessWriteClipboard(req)
 	case clipboard.ReadCmd:
-		state.clipboardState = q.cqueue.ProcessReadClipboard(state.clipboardState, req.Tag)
+		state.clipboardState = q.cqueue.ProcessReadClipboard(state.clipboardState, req)
+	case system.URLCmd:
+		q.urls = append(q.urls, req)
 	case pointer.GrabCmd:
 		state.pointerState, evts = q.pointer.queue.grab(state.pointerState, req)
 	case op.InvalidateCmd:
@@ -705,6 +722,70 @@ func (q *Router) ClickFocus() {
 	q.changeState(nil, state, q.pointer.queue.Deliver(q.handlers, area, e))
 }
 
+// SemanticFocus returns the innermost semantic node containing the
+// focused handler, if any.
+func (q *Router) SemanticFocus() (SemanticID, bool) {
+	focus := q.state().focus
+	if focus == nil {
+		return 0, false
+	}
+	h, ok := q.handlers[focus]
+	if !ok || !h.key.visible {
+		return 0, false
+	}
+	return q.pointer.queue.enclosingSemanticID(q.key.queue.AreaFor(&h.key))
+}
+
+// FocusSemantic moves the focus to the first focusable handler inside
+// the semantic node id, in focus order. It reports whether a handler
+// was focused.
+func (q *Router) FocusSemantic(id SemanticID) bool {
+	area := q.pointer.queue.semanticAreaFor(id)
+	if area == -1 {
+		return false
+	}
+	for _, tag := range q.key.queue.order {
+		h, ok := q.handlers[tag]
+		if !ok || !h.filter.focusable || !h.key.visible {
+			continue
+		}
+		if !q.pointer.queue.contains(area, q.key.queue.AreaFor(&h.key)) {
+			continue
+		}
+		state := q.lastState()
+		kstate, evts := q.key.queue.Focus(q.handlers, state.keyState, tag)
+		state.keyState = kstate
+		q.changeState(nil, state, evts)
+		return true
+	}
+	return false
+}
+
+// ClickSemantic clicks the center of the semantic node id, as if
+// touched. It reports whether the click was delivered to a handler.
+func (q *Router) ClickSemantic(id SemanticID) bool {
+	area := q.pointer.queue.semanticAreaFor(id)
+	if area == -1 {
+		return false
+	}
+	bounds := q.pointer.queue.areas[area].bounds()
+	center := bounds.Max.Add(bounds.Min).Div(2)
+	e := pointer.Event{
+		Position: f32.Pt(float32(center.X), float32(center.Y)),
+		Source:   pointer.Touch,
+	}
+	e.Kind = pointer.Press
+	state := q.lastState()
+	evts := q.pointer.queue.Deliver(q.handlers, area, e)
+	if len(evts) == 0 {
+		return false
+	}
+	q.changeState(nil, state, evts)
+	e.Kind = pointer.Release
+	q.changeState(nil, state, q.pointer.queue.Deliver(q.handlers, area, e))
+	return true
+}
+
 // TextInputState returns the input state from the most recent
 // call to Frame.
 func (q *Router) TextInputState() TextInputState {
@@ -720,9 +801,9 @@ func (q *Router) TextInputHint() (key.InputHint, bool) {
 	return q.key.queue.InputHint(q.handlers, q.state().keyState)
 }
 
-// WriteClipboard returns the most recent content to be copied
-// to the clipboard, if any.
-func (q *Router) WriteClipboard() (mime string, content []byte, ok bool) {
+// WriteClipboard returns the representations of the most recent
+// content to be copied to the clipboard, if any.
+func (q *Router) WriteClipboard() (content []ClipboardData, ok bool) {
 	return q.cqueue.WriteClipboard()
 }
 
@@ -732,9 +813,119 @@ func (q *Router) ClipboardRequested() bool {
 	return q.cqueue.ClipboardRequested(q.lastState().clipboardState)
 }
 
-// Cursor returns the last cursor set.
+// WritePrimary returns the representations of the most recent
+// content to be copied to the primary selection, if any.
+func (q *Router) WritePrimary() (content []ClipboardData, ok bool) {
+	return q.cqueue.WritePrimary()
+}
+
+// PrimaryRequested reports if any new handler is waiting
+// to read the primary selection.
+func (q *Router) PrimaryRequested() bool {
+	return q.cqueue.PrimaryRequested(q.lastState().clipboardState)
+}
+
+// ClipboardTypes returns the MIME types requested by the handlers
+// waiting for the clipboard kind. Drivers must deliver a
+// [transfer.DataEvent] for each type, with no data if the type is
+// not available.
+func (q *Router) ClipboardTypes(kind clipboard.Kind) []string {
+	return q.cqueue.ClipboardTypes(q.lastState().clipboardState, kind)
+}
+
+// DragEnter notifies the router of a drag and drop transfer from another
+// application, offering data in the MIME types mimes. Potential targets
+// receive a [transfer.InitiateEvent].
+func (q *Router) DragEnter(mimes []string) {
+	state := q.lastState()
+	var evts []taggedEvent
+	state.transfer, evts = q.pointer.queue.dragEnter(q.handlers, state.transfer, mimes)
+	q.changeState(nil, state, evts)
+}
+
+// DragOver updates the position of the transfer started by DragEnter and
+// reports the MIME type accepted by the target under it, if any.
+func (q *Router) DragOver(pos f32.Point) (mime string, ok bool) {
+	state := q.lastState()
+	state.transfer = q.pointer.queue.dragOver(q.handlers, state.transfer, pos)
+	q.changeState(nil, state, nil)
+	return state.transfer.mime, state.transfer.target != nil
+}
+
+// Drop completes the transfer started by DragEnter by delivering data
+// to the target under the most recent DragOver position. The data is closed
+// if there is no target accepting mime.
+func (q *Router) Drop(mime string, data io.ReadCloser) {
+	state := q.lastState()
+	var evts []taggedEvent
+	state.transfer, evts = q.pointer.queue.drop(q.handlers, state.transfer, mime, data)
+	q.changeState(nil, state, evts)
+}
+
+// DragLeave cancels the transfer started by DragEnter.
+func (q *Router) DragLeave() {
+	state := q.lastState()
+	var evts []taggedEvent
+	state.transfer, evts = q.pointer.queue.dragLeave(q.handlers, state.transfer)
+	q.changeState(nil, state, evts)
+}
+
+// DragSource returns the MIME types offered by the source of the drag and
+// drop transfer in progress, if any.
+func (q *Router) DragSource() ([]string, bool) {
+	return q.pointer.queue.dragSource(q.handlers, q.lastState().pointerState)
+}
+
+// ExportDrag hands the drag and drop transfer in progress over to another
+// application. Potential targets in the window are cancelled, and the source
+// waits for RequestExport.
+func (q *Router) ExportDrag() {
+	state := q.lastState()
+	var evts []taggedEvent
+	state.pointerState, state.transfer, evts = q.pointer.queue.exportDrag(q.handlers, state.pointerState, state.transfer)
+	q.changeState(nil, state, evts)
+}
+
+// RequestExport requests the data of an exported transfer in the given
+// MIME type. The source responds with a [transfer.OfferCmd] whose data is
+// available through ExportData.
+func (q *Router) RequestExport(mime string) {
+	state := q.lastState()
+	q.changeState(nil, state, q.pointer.queue.requestExport(state.transfer, mime))
+}
+
+// CancelExport ends the transfer exported by ExportDrag, when the other
+// application finished the drop or the transfer is abandoned.
+func (q *Router) CancelExport() {
+	state := q.lastState()
+	var evts []taggedEvent
+	state.transfer, evts = q.pointer.queue.cancelExport(state.transfer)
+	q.changeState(nil, state, evts)
+}
+
+// ExportData returns the most recent data offered to another application,
+// if any.
+func (q *Router) ExportData() (mime string, data io.ReadCloser, ok bool) {
+	return q.tqueue.ExportData()
+}
+
+// URLs returns the URL changes requested since the last call, in order.
+func (q *Router) URLs() []system.URLCmd {
+	urls := q.urls
+	q.urls = nil
+	return urls
+}
+
+// Cursor returns the last cursor set. It is CursorDefault while an image
+// cursor is set.
 func (q *Router) Cursor() pointer.Cursor {
-	return q.state().cursor
+	return q.state().cursor.name
+}
+
+// ImageCursor returns the last image cursor set, or nil if the cursor is
+// a predefined shape.
+func (q *Router) ImageCursor() *pointer.ImageCursor {
+	return q.state().cursor.image
 }
 
 // SemanticAt returns the first semantic description under pos, if any.
@@ -833,8 +1024,10 @@ func (q *Router) collect() {
 		case ops.TypeCursor:
 			name := pointer.Cursor(encOp.Data[1])
 			pc.cursor(name)
+		case ops.TypeImageCursor:
+			pc.imageCursor(encOp.Refs[0].(*pointer.ImageCursor))
 		case ops.TypeActionInput:
-			act := system.Action(encOp.Data[1])
+			act := system.Action(binary.LittleEndian.Uint32(encOp.Data[1:]))
 			pc.actionInputOp(act)
 		case ops.TypeKeyInputHint:
 			op := key.InputHintOp{
@@ -3,9 +3,12 @@
 package input
 
 import (
+	"net/url"
+	"slices"
 	"testing"
 
 	"github.com/mleku/gio/io/pointer"
+	"github.com/mleku/gio/io/system"
 	"github.com/mleku/gio/op"
 )
 
@@ -32,3 +35,20 @@ func TestRouterWakeup(t *testing.T) {
 		t.Errorf("InvalidateCmd did not trigger a redraw")
 	}
 }
+
+func TestRouterURLs(t *testing.T) {
+	r := new(Router)
+	cmds := []system.URLCmd{
+		{URL: &url.URL{Path: "/a"}},
+		{URL: &url.URL{Fragment: "b"}, Replace: true},
+	}
+	for _, c := range cmds {
+		r.Source().Execute(c)
+	}
+	if got := r.URLs(); !slices.Equal(got, cmds) {
+		t.Errorf("got URL commands %v, want %v", got, cmds)
+	}
+	if got := r.URLs(); len(got) != 0 {
+		t.Errorf("URL commands %v were not cleared", got)
+	}
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/mleku/gio/f32"
 	"github.com/mleku/gio/io/event"
+	"github.com/mleku/gio/io/key"
 	"github.com/mleku/gio/io/pointer"
 	"github.com/mleku/gio/io/semantic"
 	"github.com/mleku/gio/op"
@@ -105,6 +106,50 @@ func TestSemanticDescription(t *testing.T) {
 	}
 }
 
+func TestSemanticActions(t *testing.T) {
+	var ops op.Ops
+	r := new(Router)
+	h1, h2 := new(int), new(int)
+	filters := func(h event.Tag) []event.Filter {
+		return []event.Filter{
+			key.FocusFilter{Target: h},
+			pointer.Filter{Target: h, Kinds: pointer.Press | pointer.Release},
+		}
+	}
+	events(r, -1, filters(h1)...)
+	events(r, -1, filters(h2)...)
+	for i, h := range []event.Tag{h1, h2} {
+		cl := clip.Rect(image.Rect(0, i*10, 10, i*10+10)).Push(&ops)
+		semantic.Button.Add(&ops)
+		event.Op(&ops, h)
+		cl.Pop()
+	}
+	r.Frame(&ops)
+	tree := r.AppendSemantics(nil)
+	if len(tree[0].Children) != 2 {
+		t.Fatalf("got %d semantic children, want 2", len(tree[0].Children))
+	}
+	second := tree[0].Children[1].ID
+	if _, ok := r.SemanticFocus(); ok {
+		t.Error("unexpected semantic focus")
+	}
+	if !r.FocusSemantic(second) {
+		t.Fatal("FocusSemantic failed")
+	}
+	assertFocus(t, r, h2)
+	if id, ok := r.SemanticFocus(); !ok || id != second {
+		t.Errorf("semantic focus %d, want %d", id, second)
+	}
+	if !r.ClickSemantic(second) {
+		t.Fatal("ClickSemantic failed")
+	}
+	assertEventPointerTypeSequence(t, events(r, -1, filters(h1)...))
+	assertEventPointerTypeSequence(t, events(r, -1, filters(h2)...), pointer.Press, pointer.Release)
+	if r.ClickSemantic(tree[0].ID) {
+		t.Error("the semantic root without handlers was clicked")
+	}
+}
+
 func lookupNode(tree []SemanticNode, id SemanticID) (SemanticNode, bool) {
 	for _, n := range tree {
 		if id == n.ID {
@@ -0,0 +1,196 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package input
+
+import (
+	"io"
+	"slices"
+
+	"github.com/mleku/gio/f32"
+	"github.com/mleku/gio/io/event"
+	"github.com/mleku/gio/io/transfer"
+)
+
+// transferState contains the state for drag and drop transfers
+// between the window and other applications.
+type transferState struct {
+	// mimes is the list of MIME types offered by the incoming transfer,
+	// or nil if no transfer is in progress.
+	mimes []string
+	// target is the handler under the incoming transfer, if any, and
+	// mime the type it accepts.
+	target event.Tag
+	mime   string
+	// source is the data source of an exported drag, waiting for
+	// another application to request its data.
+	source event.Tag
+}
+
+type transferQueue struct {
+	// mime and data is the most recent data offered to another
+	// application.
+	mime string
+	data io.ReadCloser
+}
+
+// ExportData returns the most recent data offered to another
+// application, if any. The caller is responsible for closing data.
+func (q *transferQueue) ExportData() (mime string, data io.ReadCloser, ok bool) {
+	if q.data == nil {
+		return "", nil, false
+	}
+	data = q.data
+	q.data = nil
+	return q.mime, data, true
+}
+
+func (q *transferQueue) offer(req transfer.OfferCmd) {
+	if q.data != nil {
+		q.data.Close()
+	}
+	q.mime = req.Type
+	q.data = req.Data
+}
+
+// potentialTargets appends a taggedEvent with e for every handler accepting
+// at least one of mimes.
+func potentialTargets(handlers map[event.Tag]*handler, mimes []string, evts []taggedEvent, e event.Event) []taggedEvent {
+	for k, h := range handlers {
+		if _, ok := mimeMatch(mimes, &h.filter.pointer); ok {
+			evts = append(evts, taggedEvent{tag: k, event: e})
+		}
+	}
+	return evts
+}
+
+// mimeMatch returns the first of the target's MIME types contained in mimes.
+func mimeMatch(mimes []string, tgt *pointerFilter) (string, bool) {
+	for _, m := range tgt.targetMimes {
+		if slices.Contains(mimes, m) {
+			return m, true
+		}
+	}
+	return "", false
+}
+
+func (q *pointerQueue) dragEnter(handlers map[event.Tag]*handler, state transferState, mimes []string) (transferState, []taggedEvent) {
+	var evts []taggedEvent
+	if state.mimes != nil {
+		state, evts = q.dragLeave(handlers, state)
+	}
+	state.mimes = slices.Clone(mimes)
+	if state.mimes == nil {
+		state.mimes = []string{}
+	}
+	evts = potentialTargets(handlers, state.mimes, evts, transfer.InitiateEvent{})
+	return state, evts
+}
+
+func (q *pointerQueue) dragOver(handlers map[event.Tag]*handler, state transferState, pos f32.Point) transferState {
+	state.target = nil
+	state.mime = ""
+	if state.mimes == nil {
+		return state
+	}
+	q.hitTest(pos, func(n *hitNode) bool {
+		h, ok := handlers[n.tag]
+		if !ok {
+			return true
+		}
+		m, ok := mimeMatch(state.mimes, &h.filter.pointer)
+		if !ok {
+			return true
+		}
+		state.target = n.tag
+		state.mime = m
+		return false
+	})
+	return state
+}
+
+func (q *pointerQueue) drop(handlers map[event.Tag]*handler, state transferState, mime string, data io.ReadCloser) (transferState, []taggedEvent) {
+	if state.target == nil || mime != state.mime {
+		data.Close()
+		return q.dragLeave(handlers, state)
+	}
+	evts := []taggedEvent{{tag: state.target, event: transfer.DataEvent{
+		Type: mime,
+		Open: func() io.ReadCloser {
+			return data
+		},
+	}}}
+	state, evts2 := q.dragLeave(handlers, state)
+	return state, append(evts, evts2...)
+}
+
+func (q *pointerQueue) dragLeave(handlers map[event.Tag]*handler, state transferState) (transferState, []taggedEvent) {
+	if state.mimes == nil {
+		return state, nil
+	}
+	evts := potentialTargets(handlers, state.mimes, nil, transfer.CancelEvent{})
+	state.mimes = nil
+	state.target = nil
+	state.mime = ""
+	return state, evts
+}
+
+// dragSource returns the MIME types of the source of the drag in
+// progress, if any.
+func (q *pointerQueue) dragSource(handlers map[event.Tag]*handler, state pointerState) ([]string, bool) {
+	for _, p := range state.pointers {
+		if p.dataSource == nil {
+			continue
+		}
+		h, ok := handlers[p.dataSource]
+		if !ok {
+			continue
+		}
+		return h.filter.pointer.sourceMimes, true
+	}
+	return nil, false
+}
+
+// exportDrag hands the drag in progress over to another application. Potential
+// targets in the window are cancelled, while the source is kept waiting for
+// requests.
+func (q *pointerQueue) exportDrag(handlers map[event.Tag]*handler, pstate pointerState, tstate transferState) (pointerState, transferState, []taggedEvent) {
+	var evts []taggedEvent
+	for i, p := range pstate.pointers {
+		if p.dataSource == nil {
+			continue
+		}
+		tstate, evts = q.cancelExport(tstate)
+		src := &handlers[p.dataSource].filter.pointer
+		for k, h := range handlers {
+			if k == p.dataSource {
+				continue
+			}
+			if _, ok := firstMimeMatch(src, &h.filter.pointer); ok {
+				evts = append(evts, taggedEvent{tag: k, event: transfer.CancelEvent{}})
+			}
+		}
+		tstate.source = p.dataSource
+		pstate.pointers = slices.Clone(pstate.pointers)
+		p.dataSource = nil
+		p.dataTarget = nil
+		pstate.pointers[i] = p
+		break
+	}
+	return pstate, tstate, evts
+}
+
+func (q *pointerQueue) requestExport(state transferState, mime string) []taggedEvent {
+	if state.source == nil {
+		return nil
+	}
+	return []taggedEvent{{tag: state.source, event: transfer.RequestEvent{Type: mime}}}
+}
+
+func (q *pointerQueue) cancelExport(state transferState) (transferState, []taggedEvent) {
+	if state.source == nil {
+		return state, nil
+	}
+	evts := []taggedEvent{{tag: state.source, event: transfer.CancelEvent{}}}
+	state.source = nil
+	return state, evts
+}
@@ -0,0 +1,164 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package input
+
+import (
+	"image"
+	"testing"
+
+	"github.com/mleku/gio/f32"
+	"github.com/mleku/gio/io/event"
+	"github.com/mleku/gio/io/pointer"
+	"github.com/mleku/gio/io/transfer"
+	"github.com/mleku/gio/op"
+	"github.com/mleku/gio/op/clip"
+)
+
+func TestExternalDrop(t *testing.T) {
+	ops := new(op.Ops)
+	var r Router
+	tgt1, tgt2 := new(int), new(int)
+	events(&r, -1, transfer.TargetFilter{Target: tgt1, Type: "text/uri-list"})
+	events(&r, -1, transfer.TargetFilter{Target: tgt2, Type: "image/png"})
+	stack := clip.Rect(image.Rect(0, 0, 20, 20)).Push(ops)
+	event.Op(ops, tgt1)
+	stack.Pop()
+	stack = clip.Rect(image.Rect(40, 0, 60, 20)).Push(ops)
+	event.Op(ops, tgt2)
+	stack.Pop()
+	r.Frame(ops)
+
+	r.DragEnter([]string{"text/plain", "text/uri-list"})
+	assertEventSequence(t, events(&r, -1, transfer.TargetFilter{Target: tgt1, Type: "text/uri-list"}), transfer.InitiateEvent{})
+	assertEventSequence(t, events(&r, -1, transfer.TargetFilter{Target: tgt2, Type: "image/png"}))
+
+	if _, ok := r.DragOver(f32.Pt(50, 10)); ok {
+		t.Error("target accepted a transfer with no matching type")
+	}
+	mime, ok := r.DragOver(f32.Pt(10, 10))
+	if !ok || mime != "text/uri-list" {
+		t.Fatalf("DragOver got %q, %v; want %q, true", mime, ok, "text/uri-list")
+	}
+	ofr := &offer{data: "file:///tmp/a.txt\r\n"}
+	r.Drop(mime, ofr)
+	evs := events(&r, -1, transfer.TargetFilter{Target: tgt1, Type: "text/uri-list"})
+	if len(evs) != 2 {
+		t.Fatalf("unexpected number of events: %d, want 2", len(evs))
+	}
+	assertEventSequence(t, evs[1:], transfer.CancelEvent{})
+	de, ok := evs[0].(transfer.DataEvent)
+	if !ok {
+		t.Fatalf("unexpected event type: %T, want %T", evs[0], transfer.DataEvent{})
+	}
+	if got := de.Open(); got != ofr {
+		t.Fatalf("got %v; want %v", got, ofr)
+	}
+	if ofr.closed {
+		t.Error("offer closed prematurely")
+	}
+}
+
+func TestExternalDragLeave(t *testing.T) {
+	ops := new(op.Ops)
+	var r Router
+	tgt := new(int)
+	events(&r, -1, transfer.TargetFilter{Target: tgt, Type: "text/plain"})
+	stack := clip.Rect(image.Rect(0, 0, 20, 20)).Push(ops)
+	event.Op(ops, tgt)
+	stack.Pop()
+	r.Frame(ops)
+
+	r.DragEnter([]string{"text/plain"})
+	r.DragOver(f32.Pt(10, 10))
+	r.DragLeave()
+	assertEventSequence(t, events(&r, -1, transfer.TargetFilter{Target: tgt, Type: "text/plain"}),
+		transfer.InitiateEvent{}, transfer.CancelEvent{})
+
+	// A drop without a target closes the data.
+	r.DragEnter([]string{"text/plain"})
+	ofr := new(offer)
+	r.Drop("text/plain", ofr)
+	if !ofr.closed {
+		t.Error("unused offer was not closed")
+	}
+}
+
+func TestExportDrag(t *testing.T) {
+	ops := new(op.Ops)
+	var r Router
+	src, tgt := new(int), new(int)
+	events(&r, -1, transfer.SourceFilter{Target: src, Type: "text/plain"})
+	events(&r, -1, transfer.TargetFilter{Target: tgt, Type: "text/plain"})
+	stack := clip.Rect(image.Rect(0, 0, 20, 20)).Push(ops)
+	event.Op(ops, src)
+	stack.Pop()
+	stack = clip.Rect(image.Rect(40, 0, 60, 20)).Push(ops)
+	event.Op(ops, tgt)
+	stack.Pop()
+	r.Frame(ops)
+
+	if _, ok := r.DragSource(); ok {
+		t.Fatal("unexpected drag source")
+	}
+	r.Queue(
+		pointer.Event{
+			Position: f32.Pt(10, 10),
+			Kind:     pointer.Press,
+		},
+		pointer.Event{
+			Position: f32.Pt(10, 10),
+			Kind:     pointer.Move,
+		},
+		// Leave the window.
+		pointer.Event{
+			Position: f32.Pt(-10, 10),
+			Kind:     pointer.Move,
+		},
+	)
+	mimes, ok := r.DragSource()
+	if !ok || len(mimes) != 1 || mimes[0] != "text/plain" {
+		t.Fatalf("DragSource got %v, %v; want [text/plain], true", mimes, ok)
+	}
+	assertEventSequence(t, events(&r, -1, transfer.TargetFilter{Target: tgt, Type: "text/plain"}), transfer.InitiateEvent{})
+
+	// Drop outside the window.
+	r.ExportDrag()
+	r.Queue(
+		pointer.Event{
+			Position: f32.Pt(-10, 10),
+			Kind:     pointer.Release,
+		},
+	)
+	assertEventSequence(t, events(&r, -1, transfer.TargetFilter{Target: tgt, Type: "text/plain"}), transfer.CancelEvent{})
+	assertEventSequence(t, events(&r, -1, transfer.SourceFilter{Target: src, Type: "text/plain"}))
+	r.Frame(ops)
+
+	r.RequestExport("text/plain")
+	assertEventSequence(t, events(&r, 1, transfer.SourceFilter{Target: src, Type: "text/plain"}), transfer.RequestEvent{Type: "text/plain"})
+	ofr := &offer{data: "hello"}
+	r.Source().Execute(transfer.OfferCmd{Tag: src, Type: "text/plain", Data: ofr})
+	assertEventSequence(t, events(&r, -1, transfer.SourceFilter{Target: src, Type: "text/plain"}))
+	mime, data, ok := r.ExportData()
+	if !ok || mime != "text/plain" || data != ofr {
+		t.Fatalf("ExportData got %q, %v, %v; want %q, %v, true", mime, data, ok, "text/plain", ofr)
+	}
+	if _, _, ok := r.ExportData(); ok {
+		t.Error("ExportData returned data twice")
+	}
+
+	// The target may request the data again before it finishes.
+	r.Frame(ops)
+	r.RequestExport("text/plain")
+	assertEventSequence(t, events(&r, 1, transfer.SourceFilter{Target: src, Type: "text/plain"}), transfer.RequestEvent{Type: "text/plain"})
+	ofr2 := &offer{data: "hello"}
+	r.Source().Execute(transfer.OfferCmd{Tag: src, Type: "text/plain", Data: ofr2})
+	if mime, data, ok := r.ExportData(); !ok || mime != "text/plain" || data != ofr2 {
+		t.Fatalf("second ExportData got %q, %v, %v; want %q, %v, true", mime, data, ok, "text/plain", ofr2)
+	}
+
+	r.Frame(ops)
+	r.CancelExport()
+	assertEventSequence(t, events(&r, -1, transfer.SourceFilter{Target: src, Type: "text/plain"}), transfer.CancelEvent{})
+	r.RequestExport("text/plain")
+	assertEventSequence(t, events(&r, -1, transfer.SourceFilter{Target: src, Type: "text/plain"}))
+}
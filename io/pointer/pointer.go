@@ -3,6 +3,7 @@
 package pointer
 
 import (
+	"image"
 	"strings"
 	"time"
 
@@ -39,6 +40,16 @@ type Event struct {
 	// Modifiers is the set of active modifiers when
 	// the mouse button was pressed.
 	Modifiers key.Modifiers
+	// Pressure is the normalized pressure of a pen or touch in
+	// the range [0, 1], or zero if the device doesn't report it.
+	Pressure float32
+	// Tilt is the angle in degrees between a pen and the normal of
+	// the surface, in the range [-90, 90] along each axis. Tilt.X is
+	// positive towards the right, Tilt.Y towards the user.
+	Tilt f32.Point
+	// Eraser reports whether the event is from the eraser end of
+	// a pen.
+	Eraser bool
 }
 
 // PassOp sets the pass-through mode. InputOps added while the pass-through
@@ -98,6 +109,21 @@ type Buttons uint8
 // operation that sets the cursor shape for the current clip area.
 type Cursor byte
 
+// ImageCursor is a cursor shape drawn from an image. Its Add method adds
+// an operation that sets the cursor shape for the current clip area.
+//
+// The cursor is identified by its address, so keep and re-add the same
+// ImageCursor rather than creating one per frame. The Image must not be
+// changed after the cursor is added. Image cursors are supported on X11
+// and in the browser; other platforms show CursorDefault.
+type ImageCursor struct {
+	// Image is the cursor shape, one image pixel per screen pixel.
+	Image image.Image
+	// Hotspot is the point of Image, in its coordinates, that tracks
+	// the pointer position.
+	Hotspot image.Point
+}
+
 // The cursors correspond to CSS pointer naming.
 const (
 	// CursorDefault is the default cursor.
@@ -200,6 +226,8 @@ const (
 	Mouse Source = iota
 	// Touch generated event.
 	Touch
+	// Pen generated event, from a stylus or tablet.
+	Pen
 )
 
 const (
@@ -254,6 +282,14 @@ func (op Cursor) Add(o *op.Ops) {
 	data[1] = byte(op)
 }
 
+// Add adds an operation that sets the cursor shape for the current clip
+// area to the image cursor c. It takes part in hit testing like the
+// predefined cursors.
+func (c *ImageCursor) Add(o *op.Ops) {
+	data := ops.Write1(&o.Internal, ops.TypeImageCursorLen, c)
+	data[0] = byte(ops.TypeImageCursor)
+}
+
 func (t Kind) String() string {
 	if t == Cancel {
 		return "Cancel"
@@ -310,6 +346,8 @@ func (s Source) String() string {
 		return "Mouse"
 	case Touch:
 		return "Touch"
+	case Pen:
+		return "Pen"
 	default:
 		panic("unknown source")
 	}
@@ -1,6 +1,7 @@
 package system
 
 import (
+	"encoding/binary"
 	"strings"
 
 	"github.com/mleku/gio/internal/ops"
@@ -10,7 +11,7 @@ import (
 // ActionAreaOp makes the current clip area available for
 // system gestures.
 //
-// Note: only ActionMove is supported.
+// Note: only ActionMove and the resize actions are supported.
 type ActionInputOp Action
 
 // Action is a set of window decoration actions.
@@ -38,12 +39,33 @@ const (
 	ActionClose
 	// ActionMove moves a window directed by the user.
 	ActionMove
+	// ActionResizeNorth resizes the top edge of a window directed
+	// by the user.
+	ActionResizeNorth
+	// ActionResizeSouth resizes the bottom edge of a window.
+	ActionResizeSouth
+	// ActionResizeWest resizes the left edge of a window.
+	ActionResizeWest
+	// ActionResizeEast resizes the right edge of a window.
+	ActionResizeEast
+	// ActionResizeNorthWest resizes the top-left corner of a window.
+	ActionResizeNorthWest
+	// ActionResizeNorthEast resizes the top-right corner of a window.
+	ActionResizeNorthEast
+	// ActionResizeSouthWest resizes the bottom-left corner of a window.
+	ActionResizeSouthWest
+	// ActionResizeSouthEast resizes the bottom-right corner of a window.
+	ActionResizeSouthEast
 )
 
+// ActionResize is the set of resize actions.
+const ActionResize = ActionResizeNorth | ActionResizeSouth | ActionResizeWest | ActionResizeEast |
+	ActionResizeNorthWest | ActionResizeNorthEast | ActionResizeSouthWest | ActionResizeSouthEast
+
 func (op ActionInputOp) Add(o *op.Ops) {
 	data := ops.Write(&o.Internal, ops.TypeActionInputLen)
 	data[0] = byte(ops.TypeActionInput)
-	data[1] = byte(op)
+	binary.LittleEndian.PutUint32(data[1:], uint32(op))
 }
 
 func (a Action) String() string {
@@ -72,6 +94,22 @@ func (a Action) string() string {
 		return "ActionClose"
 	case ActionMove:
 		return "ActionMove"
+	case ActionResizeNorth:
+		return "ActionResizeNorth"
+	case ActionResizeSouth:
+		return "ActionResizeSouth"
+	case ActionResizeWest:
+		return "ActionResizeWest"
+	case ActionResizeEast:
+		return "ActionResizeEast"
+	case ActionResizeNorthWest:
+		return "ActionResizeNorthWest"
+	case ActionResizeNorthEast:
+		return "ActionResizeNorthEast"
+	case ActionResizeSouthWest:
+		return "ActionResizeSouthWest"
+	case ActionResizeSouthEast:
+		return "ActionResizeSouthEast"
 	}
 	return ""
 }
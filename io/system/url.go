@@ -0,0 +1,16 @@
+package system
+
+import "net/url"
+
+// URLCmd changes the address of the window, which in the browser is the
+// page URL. The new URL is added to the browser history, or replaces its
+// current entry if Replace is set. Relative URLs are resolved against the
+// current URL.
+//
+// Note: only supported in the browser.
+type URLCmd struct {
+	URL     *url.URL
+	Replace bool
+}
+
+func (URLCmd) ImplementsCommand() {}
@@ -15,6 +15,13 @@
 // to the source and all potential targets.
 //
 // Note that the RequestEvent is sent to the source upon drop.
+//
+// On platforms that support it, transfers also cross the window
+// boundary: drops from other applications are delivered to targets
+// as DataEvents, and a drag leaving the window continues as a drag to
+// other applications. In the latter case, the source receives its
+// RequestEvent when the other application asks for data, and the
+// CancelEvent when the transfer is complete.
 package transfer
 
 import (
@@ -2,11 +2,14 @@ package widget
 
 import (
 	"fmt"
+	"image"
 	"math/bits"
 
 	"github.com/mleku/gio/io/system"
 	"github.com/mleku/gio/layout"
+	"github.com/mleku/gio/op"
 	"github.com/mleku/gio/op/clip"
+	"github.com/mleku/gio/unit"
 )
 
 // Decorations handles the states of window decorations.
@@ -26,6 +29,64 @@ func (d *Decorations) LayoutMove(gtx layout.Context, w layout.Widget) layout.Dim
 	return dims
 }
 
+const (
+	// resizeBorder is the width of the resize borders.
+	resizeBorder = unit.Dp(5)
+	// resizeCorner is the length of the resize corners along each edge.
+	resizeCorner = unit.Dp(12)
+)
+
+// LayoutResize lays out invisible borders along the edges of
+// gtx.Constraints.Max that resize a window. Only the resize actions
+// present in actions are laid out, and none while the window is
+// maximized. The borders are deferred so that they stay on top of the
+// content of the window, regardless of the order of layout.
+func (d *Decorations) LayoutResize(gtx layout.Context, actions system.Action) {
+	if d.Maximized {
+		return
+	}
+	sz := gtx.Constraints.Max
+	b := min(gtx.Dp(resizeBorder), sz.X/2, sz.Y/2)
+	c := max(min(gtx.Dp(resizeCorner), sz.X/2, sz.Y/2), b)
+	areas := []struct {
+		action system.Action
+		rects  []image.Rectangle
+	}{
+		{system.ActionResizeNorth, []image.Rectangle{image.Rect(c, 0, sz.X-c, b)}},
+		{system.ActionResizeSouth, []image.Rectangle{image.Rect(c, sz.Y-b, sz.X-c, sz.Y)}},
+		{system.ActionResizeWest, []image.Rectangle{image.Rect(0, c, b, sz.Y-c)}},
+		{system.ActionResizeEast, []image.Rectangle{image.Rect(sz.X-b, c, sz.X, sz.Y-c)}},
+		// The corners are L-shaped.
+		{system.ActionResizeNorthWest, []image.Rectangle{
+			image.Rect(0, 0, c, b), image.Rect(0, b, b, c),
+		}},
+		{system.ActionResizeNorthEast, []image.Rectangle{
+			image.Rect(sz.X-c, 0, sz.X, b), image.Rect(sz.X-b, b, sz.X, c),
+		}},
+		{system.ActionResizeSouthWest, []image.Rectangle{
+			image.Rect(0, sz.Y-b, c, sz.Y), image.Rect(0, sz.Y-c, b, sz.Y-b),
+		}},
+		{system.ActionResizeSouthEast, []image.Rectangle{
+			image.Rect(sz.X-c, sz.Y-b, sz.X, sz.Y), image.Rect(sz.X-b, sz.Y-c, sz.X, sz.Y-b),
+		}},
+	}
+	m := op.Record(gtx.Ops)
+	for _, a := range areas {
+		if actions&a.action == 0 {
+			continue
+		}
+		for _, r := range a.rects {
+			if r.Empty() {
+				continue
+			}
+			area := clip.Rect(r).Push(gtx.Ops)
+			system.ActionInputOp(a.action).Add(gtx.Ops)
+			area.Pop()
+		}
+	}
+	op.Defer(gtx.Ops, m.Stop())
+}
+
 // Clickable returns the clickable for the given single action.
 func (d *Decorations) Clickable(action system.Action) *Clickable {
 	if bits.OnesCount(uint(action)) != 1 {
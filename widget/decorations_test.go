@@ -0,0 +1,84 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget_test
+
+import (
+	"image"
+	"testing"
+
+	"github.com/mleku/gio/f32"
+	"github.com/mleku/gio/io/event"
+	"github.com/mleku/gio/io/input"
+	"github.com/mleku/gio/io/pointer"
+	"github.com/mleku/gio/io/system"
+	"github.com/mleku/gio/layout"
+	"github.com/mleku/gio/op"
+	"github.com/mleku/gio/op/clip"
+	"github.com/mleku/gio/widget"
+)
+
+func TestDecorationsResize(t *testing.T) {
+	var (
+		r    input.Router
+		deco widget.Decorations
+	)
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+	}
+	deco.LayoutResize(gtx, system.ActionResize)
+	r.Frame(gtx.Ops)
+	for _, tc := range []struct {
+		pos  f32.Point
+		want system.Action
+	}{
+		{f32.Pt(50, 2), system.ActionResizeNorth},
+		{f32.Pt(50, 98), system.ActionResizeSouth},
+		{f32.Pt(2, 50), system.ActionResizeWest},
+		{f32.Pt(98, 50), system.ActionResizeEast},
+		{f32.Pt(2, 2), system.ActionResizeNorthWest},
+		{f32.Pt(2, 10), system.ActionResizeNorthWest},
+		{f32.Pt(98, 2), system.ActionResizeNorthEast},
+		{f32.Pt(10, 98), system.ActionResizeSouthWest},
+		{f32.Pt(98, 98), system.ActionResizeSouthEast},
+		{f32.Pt(50, 50), 0},
+	} {
+		got, _ := r.ActionAt(tc.pos)
+		if got != tc.want {
+			t.Errorf("action at %v: got %v; want %v", tc.pos, got, tc.want)
+		}
+	}
+
+	deco.Maximized = true
+	gtx.Ops.Reset()
+	deco.LayoutResize(gtx, system.ActionResize)
+	r.Frame(gtx.Ops)
+	if got, ok := r.ActionAt(f32.Pt(50, 2)); ok {
+		t.Errorf("maximized window: got action %v", got)
+	}
+}
+
+func TestDecorationsResizeAboveContent(t *testing.T) {
+	var (
+		r       input.Router
+		deco    widget.Decorations
+		content int
+	)
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+	}
+	// Content covering the window, laid out after the decorations.
+	deco.LayoutResize(gtx, system.ActionResize)
+	area := clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops)
+	event.Op(gtx.Ops, &content)
+	area.Pop()
+	r.Event(pointer.Filter{Target: &content, Kinds: pointer.Press})
+	r.Frame(gtx.Ops)
+	if got, _ := r.ActionAt(f32.Pt(50, 2)); got != system.ActionResizeNorth {
+		t.Errorf("action at edge: got %v; want %v", got, system.ActionResizeNorth)
+	}
+	if got, ok := r.ActionAt(f32.Pt(50, 50)); ok {
+		t.Errorf("action at center: got %v", got)
+	}
+}
@@ -81,6 +81,7 @@ type Editor struct {
 
 	dragging    bool
 	dragger     gesture.Drag
+	primary     primarySelection
 	scroller    gesture.Scroll
 	scrollCaret bool
 	showCaret   bool
@@ -196,7 +197,16 @@ func (e *Editor) processEvents(gtx layout.Context) (ev EditorEvent, ok bool) {
 	selStart, selEnd := e.Selection()
 	defer func() {
 		afterSelStart, afterSelEnd := e.Selection()
-		if selStart != afterSelStart || selEnd != afterSelEnd {
+		changed := selStart != afterSelStart || selEnd != afterSelEnd
+		// Masked editors never expose their contents through the
+		// primary selection.
+		if e.Mask == 0 {
+			e.primary.update(gtx, changed, e.dragging, func() []byte {
+				e.scratch = e.text.SelectedText(e.scratch)
+				return e.scratch
+			})
+		}
+		if changed {
 			if ok {
 				e.pending = append(e.pending, SelectEvent{})
 			} else {
@@ -249,6 +259,16 @@ func (e *Editor) processPointer(gtx layout.Context) (EditorEvent, bool) {
 		e.text.ScrollRel(0, sdist)
 		soff = e.text.ScrollOff().Y
 	}
+	for {
+		evt, ok := gtx.Event(pointer.Filter{Target: e, Kinds: pointer.Press})
+		if !ok {
+			break
+		}
+		ev, ok := e.processPointerEvent(gtx, evt)
+		if ok {
+			return ev, ok
+		}
+	}
 	for {
 		evt, ok := e.clicker.Update(gtx.Source)
 		if !ok {
@@ -323,6 +343,20 @@ func (e *Editor) processPointerEvent(gtx layout.Context, ev event.Event) (Editor
 	case pointer.Event:
 		release := false
 		switch {
+		case evt.Kind == pointer.Press && evt.Source == pointer.Mouse && evt.Buttons == pointer.ButtonTertiary:
+			// Paste the primary selection at the pointer; the other half is
+			// in Editor.processKey() under transfer.DataEvent.
+			if e.ReadOnly {
+				break
+			}
+			e.blinkStart = gtx.Now
+			e.text.MoveCoord(image.Point{
+				X: int(math.Round(float64(evt.Position.X))),
+				Y: int(math.Round(float64(evt.Position.Y))),
+			})
+			e.text.ClearSelection()
+			gtx.Execute(key.FocusCmd{Tag: e})
+			gtx.Execute(clipboard.ReadCmd{Tag: e, Kind: clipboard.Primary})
 		case evt.Kind == pointer.Release && evt.Source == pointer.Mouse:
 			release = true
 			fallthrough
@@ -9,6 +9,7 @@ import (
 	"io"
 	"math/rand"
 	"reflect"
+	"strings"
 	"testing"
 	"testing/quick"
 	"time"
@@ -20,10 +21,12 @@ import (
 	"github.com/mleku/gio/font"
 	"github.com/mleku/gio/font/gofont"
 	"github.com/mleku/gio/font/opentype"
+	"github.com/mleku/gio/io/clipboard"
 	"github.com/mleku/gio/io/input"
 	"github.com/mleku/gio/io/key"
 	"github.com/mleku/gio/io/pointer"
 	"github.com/mleku/gio/io/system"
+	"github.com/mleku/gio/io/transfer"
 	"github.com/mleku/gio/layout"
 	"github.com/mleku/gio/op"
 	"github.com/mleku/gio/text"
@@ -1165,6 +1168,107 @@ func TestEditor_MaxLen(t *testing.T) {
 	}
 }
 
+func TestEditorPrimarySelection(t *testing.T) {
+	e := new(Editor)
+	e.SetText("hello world")
+	r := new(input.Router)
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+		Locale:      english,
+		Source:      r.Source(),
+	}
+	cache := text.NewShaper(text.NoSystemFonts(), text.WithCollection(gofont.Collection()))
+	fontSize := unit.Sp(10)
+	font := font.Font{}
+	gtx.Execute(key.FocusCmd{Tag: e})
+	e.Layout(gtx, cache, font, fontSize, op.CallOp{}, op.CallOp{})
+	r.Frame(gtx.Ops)
+
+	// Selecting text publishes it.
+	r.Queue(key.Event{Name: "A", Modifiers: key.ModShortcut, State: key.Press})
+	gtx.Ops.Reset()
+	e.Layout(gtx, cache, font, fontSize, op.CallOp{}, op.CallOp{})
+	r.Frame(gtx.Ops)
+	if content, ok := r.WritePrimary(); !ok || len(content) != 1 || string(content[0].Data) != "hello world" {
+		t.Errorf("got primary selection %v, %v; want %q", content, ok, "hello world")
+	}
+	if _, ok := r.WriteClipboard(); ok {
+		t.Error("selection written to clipboard")
+	}
+
+	// Dragging publishes the selection only when released.
+	r.Queue(
+		pointer.Event{
+			Kind:     pointer.Press,
+			Source:   pointer.Mouse,
+			Buttons:  pointer.ButtonPrimary,
+			Position: f32.Pt(0, 5),
+		},
+		pointer.Event{
+			Kind:     pointer.Move,
+			Source:   pointer.Mouse,
+			Buttons:  pointer.ButtonPrimary,
+			Position: f32.Pt(30, 5),
+		},
+	)
+	gtx.Ops.Reset()
+	e.Layout(gtx, cache, font, fontSize, op.CallOp{}, op.CallOp{})
+	r.Frame(gtx.Ops)
+	if content, ok := r.WritePrimary(); ok {
+		t.Errorf("primary selection %v written during drag", content)
+	}
+	r.Queue(pointer.Event{
+		Kind:     pointer.Release,
+		Source:   pointer.Mouse,
+		Position: f32.Pt(30, 5),
+	})
+	gtx.Ops.Reset()
+	e.Layout(gtx, cache, font, fontSize, op.CallOp{}, op.CallOp{})
+	r.Frame(gtx.Ops)
+	content, ok := r.WritePrimary()
+	if !ok || len(content) != 1 || string(content[0].Data) != e.SelectedText() || e.SelectedText() == "" {
+		t.Errorf("got primary selection %v, %v after drag; want %q", content, ok, e.SelectedText())
+	}
+	// An unchanged selection is not published again.
+	r.Queue(key.Event{Name: key.NameShift, State: key.Press})
+	gtx.Ops.Reset()
+	e.Layout(gtx, cache, font, fontSize, op.CallOp{}, op.CallOp{})
+	r.Frame(gtx.Ops)
+	if content, ok := r.WritePrimary(); ok {
+		t.Errorf("unchanged primary selection %v written again", content)
+	}
+	e.SetCaret(0, 0)
+
+	// Middle-clicking pastes it.
+	r.Queue(pointer.Event{
+		Kind:     pointer.Press,
+		Source:   pointer.Mouse,
+		Buttons:  pointer.ButtonTertiary,
+		Position: f32.Pt(0, 5),
+	})
+	gtx.Ops.Reset()
+	e.Layout(gtx, cache, font, fontSize, op.CallOp{}, op.CallOp{})
+	r.Frame(gtx.Ops)
+	if !r.PrimaryRequested() {
+		t.Fatal("middle-click didn't request the primary selection")
+	}
+	r.Queue(input.ClipboardEvent{
+		Kind: clipboard.Primary,
+		DataEvent: transfer.DataEvent{
+			Type: "application/text",
+			Open: func() io.ReadCloser {
+				return io.NopCloser(strings.NewReader("hi "))
+			},
+		},
+	})
+	gtx.Ops.Reset()
+	e.Layout(gtx, cache, font, fontSize, op.CallOp{}, op.CallOp{})
+	if got, want := e.Text(), "hi hello world"; got != want {
+		t.Errorf("got text %q after paste, want %q", got, want)
+	}
+}
+
 func TestEditor_Filter(t *testing.T) {
 	e := new(Editor)
 
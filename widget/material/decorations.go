@@ -37,7 +37,9 @@ func Decorations(th *Theme, deco *widget.Decorations, actions system.Action, tit
 	}
 }
 
-// Layout a window with its title and action buttons.
+// Layout a window with its title and action buttons. The resize actions
+// in Actions lay out invisible borders along the edges of
+// gtx.Constraints.Max.
 func (d DecorationsStyle) Layout(gtx layout.Context) layout.Dimensions {
 	rec := op.Record(gtx.Ops)
 	dims := d.layoutDecorations(gtx)
@@ -45,6 +47,7 @@ func (d DecorationsStyle) Layout(gtx layout.Context) layout.Dimensions {
 	r := clip.Rect{Max: dims.Size}
 	paint.FillShape(gtx.Ops, d.Background, r.Op())
 	decos.Add(gtx.Ops)
+	d.Decorations.LayoutResize(gtx, d.Actions)
 	return dims
 }
 
@@ -77,6 +77,7 @@ type Selectable struct {
 	focused   bool
 	dragging  bool
 	dragger   gesture.Drag
+	primary   primarySelection
 
 	clicker gesture.Click
 }
@@ -209,6 +210,10 @@ func (l *Selectable) handleEvents(gtx layout.Context) (selectionChanged bool) {
 		if newStart, newLen := min(l.text.Selection()), l.text.SelectionLen(); oldStart != newStart || oldLen != newLen {
 			selectionChanged = true
 		}
+		l.primary.update(gtx, selectionChanged, l.dragging, func() []byte {
+			l.scratch = l.text.SelectedText(l.scratch)
+			return l.scratch
+		})
 	}()
 	l.processPointer(gtx)
 	l.processKey(gtx)
@@ -326,6 +331,38 @@ func (e *Selectable) processKey(gtx layout.Context) {
 	}
 }
 
+// primarySelection copies a selection to the primary selection once the
+// selection settles, that is when a drag selecting text ends, or after
+// a selecting key press.
+type primarySelection struct {
+	// pending is set while a changed selection waits to settle.
+	pending bool
+	// text is the most recently written text.
+	text string
+}
+
+// update records whether the selection changed, and writes the selected
+// text when the selection is not being dragged and the text differs from
+// the last written.
+func (p *primarySelection) update(gtx layout.Context, changed, dragging bool, selected func() []byte) {
+	p.pending = p.pending || changed
+	if !p.pending || dragging {
+		return
+	}
+	p.pending = false
+	text := selected()
+	if len(text) == 0 {
+		// Selecting the same text again publishes it again.
+		p.text = ""
+		return
+	}
+	if string(text) == p.text {
+		return
+	}
+	p.text = string(text)
+	gtx.Execute(clipboard.WriteCmd{Type: "application/text", Data: io.NopCloser(strings.NewReader(p.text)), Kind: clipboard.Primary})
+}
+
 func (e *Selectable) command(gtx layout.Context, k key.Event) {
 	direction := 1
 	if gtx.Locale.Direction.Progression() == system.TowardOrigin {